@@ -0,0 +1,38 @@
+package zapdriver
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// TraceFromRequest returns the trace context fields of the request, based on
+// its `X-Cloud-Trace-Context` (or `traceparent`) header. It returns no fields
+// when the request has no trace header.
+//
+// App Engine nests application logs under the request log of the request with
+// the same trace, as long as the application logs are written to a different
+// log (e.g. stdout or stderr) than the request log.
+//
+// see: https://cloud.google.com/appengine/docs/standard/writing-application-logs
+func TraceFromRequest(r *http.Request, projectID string) []zap.Field {
+	trace, spanID, sampled := parseTraceHeaders(r.Header)
+	if trace == "" {
+		return nil
+	}
+
+	return TraceContext(trace, spanID, sampled, projectID)
+}
+
+// RequestLogger returns a child logger of `logger`, with the trace context of
+// the request attached, so that all entries logged using the child logger are
+// grouped under the App Engine request log. The project ID is detected using
+// `ProjectID()`.
+func RequestLogger(logger *zap.Logger, r *http.Request) *zap.Logger {
+	fields := TraceFromRequest(r, ProjectID())
+	if len(fields) == 0 {
+		return logger
+	}
+
+	return logger.With(fields...)
+}
@@ -0,0 +1,41 @@
+package zapdriver
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTraceFromRequest(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	assert.Empty(t, TraceFromRequest(req, "my-project"))
+
+	req.Header.Set(cloudTraceHeader, "105445aa7843bc8bf206b120001000/1;o=1")
+	assert.Equal(t, TraceContext("105445aa7843bc8bf206b120001000", "0000000000000001", true, "my-project"), TraceFromRequest(req, "my-project"))
+}
+
+func TestRequestLogger(t *testing.T) {
+	defer func(d *projectDetector) { detectedProject = d }(detectedProject)
+	detectedProject = &projectDetector{}
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "my-project")
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	assert.Same(t, logger, RequestLogger(logger, req))
+
+	req.Header.Set(cloudTraceHeader, "105445aa7843bc8bf206b120001000/1;o=0")
+	RequestLogger(logger, req).Info("hello")
+
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "projects/my-project/traces/105445aa7843bc8bf206b120001000", fields[traceKey])
+	assert.Equal(t, "0000000000000001", fields[spanKey])
+	assert.Equal(t, false, fields[traceSampledKey])
+}
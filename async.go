@@ -0,0 +1,299 @@
+package zapdriver
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// OverflowPolicy determines what happens when an entry is written while the
+// asynchronous queue is full.
+type OverflowPolicy int
+
+const (
+	// DropOnOverflow drops the entry, and increments the `DroppedEntries()`
+	// counter.
+	DropOnOverflow OverflowPolicy = iota
+
+	// BlockOnOverflow blocks the writer, until the queue has room for the entry.
+	BlockOnOverflow
+)
+
+// zapdriver core option to write entries asynchronously. Entries are queued in
+// a buffer of `size` entries, and written to the wrapped core by a background
+// goroutine. When the buffer is full, `policy` determines whether new entries
+// are dropped or block the writer. Calling `Sync()` flushes all queued entries,
+// and `CloseAsync()` flushes them and stops the background goroutine.
+//
+// Fields holding values that may still be modified by the caller (objects,
+// arrays, stringers and reflected values) are encoded before the entry is
+// queued, the rest of the entry is encoded by the background goroutine.
+//
+// Entries of PanicLevel and above are always written synchronously, as the
+// process is expected to exit right after.
+func Async(size int, policy OverflowPolicy) func(*core) {
+	return func(c *core) {
+		c.queue = newAsyncQueue(size, policy)
+	}
+}
+
+// DroppedEntries returns the number of entries dropped by the asynchronous
+// queue of the logger core, because the queue was full. It returns 0 if the
+// logger does not use a zapdriver core configured with `Async()`.
+func DroppedEntries(logger *zap.Logger) uint64 {
+	c, ok := coreOf(logger)
+	if !ok || c.queue == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&c.queue.dropped)
+}
+
+// CloseAsync writes all the entries queued by the asynchronous queue of the
+// logger core, and stops its background goroutine. Entries written afterwards
+// are written synchronously. The queue is shared by all the loggers derived
+// from the same core. It does nothing if the logger does not use a zapdriver
+// core configured with `Async()`.
+func CloseAsync(logger *zap.Logger) {
+	c, ok := coreOf(logger)
+	if !ok || c.queue == nil {
+		return
+	}
+
+	c.queue.close()
+}
+
+// asyncEntry is a single queued entry, or a flush request when done is set.
+type asyncEntry struct {
+	core   *core
+	ent    zapcore.Entry
+	fields []zapcore.Field
+	done   chan struct{}
+}
+
+// asyncQueue is a bounded queue of entries, consumed by a single background
+// goroutine. It is shared between the core and all the cores derived from it
+// using `With()`.
+type asyncQueue struct {
+	entries chan asyncEntry
+	policy  OverflowPolicy
+	dropped uint64
+
+	// pending is the number of queued entries not written yet.
+	pending int64
+
+	// mutex guards closed, and is held for reading while sending to entries.
+	mutex  *sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+func newAsyncQueue(size int, policy OverflowPolicy) *asyncQueue {
+	q := &asyncQueue{
+		entries: make(chan asyncEntry, size),
+		policy:  policy,
+		mutex:   &sync.RWMutex{},
+		done:    make(chan struct{}),
+	}
+
+	go q.run()
+
+	return q
+}
+
+func (q *asyncQueue) run() {
+	defer close(q.done)
+
+	for e := range q.entries {
+		if e.done != nil {
+			close(e.done)
+			continue
+		}
+
+		_ = e.core.writeNow(e.ent, e.fields)
+		atomic.AddInt64(&q.pending, -1)
+	}
+}
+
+// enqueue adds the entry to the queue, or drops it if the queue is full and the
+// policy allows it. It returns false if the queue is closed, and the entry must
+// be written synchronously.
+func (q *asyncQueue) enqueue(c *core, ent zapcore.Entry, fields []zapcore.Field) bool {
+	e := asyncEntry{core: c, ent: ent, fields: freezeFields(fields)}
+
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	if q.closed {
+		return false
+	}
+
+	atomic.AddInt64(&q.pending, 1)
+
+	if q.policy == BlockOnOverflow {
+		q.entries <- e
+		return true
+	}
+
+	select {
+	case q.entries <- e:
+	default:
+		atomic.AddInt64(&q.pending, -1)
+		atomic.AddUint64(&q.dropped, 1)
+	}
+
+	return true
+}
+
+// flush blocks until all the entries queued before the call are written.
+func (q *asyncQueue) flush() {
+	done := make(chan struct{})
+
+	q.mutex.RLock()
+	if q.closed {
+		q.mutex.RUnlock()
+		return
+	}
+	q.entries <- asyncEntry{done: done}
+	q.mutex.RUnlock()
+
+	<-done
+}
+
+// close writes the queued entries, and stops the background goroutine.
+func (q *asyncQueue) close() {
+	q.mutex.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.entries)
+	}
+	q.mutex.Unlock()
+
+	<-q.done
+}
+
+// flushContext blocks until all the entries queued before the call are
+// written, or until the context is done.
+func (q *asyncQueue) flushContext(ctx context.Context) error {
+	done := make(chan struct{})
+
+	q.mutex.RLock()
+	if q.closed {
+		q.mutex.RUnlock()
+		return nil
+	}
+
+	select {
+	case q.entries <- asyncEntry{done: done}:
+		q.mutex.RUnlock()
+	case <-ctx.Done():
+		q.mutex.RUnlock()
+		return &SyncError{Dropped: int(atomic.LoadInt64(&q.pending)), Err: ctx.Err()}
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return &SyncError{Dropped: int(atomic.LoadInt64(&q.pending)), Err: ctx.Err()}
+	}
+}
+
+// packagePath is the import path of this package, used to recognize the values
+// built by the core itself, which are never modified once the entry is written.
+var packagePath = reflect.TypeOf(core{}).PkgPath()
+
+// freezeFields returns the fields, with the values that may be modified by the
+// caller after the entry is written encoded. The fields are copied when any of
+// them is encoded.
+func freezeFields(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i := range fields {
+		frozen, ok := freezeField(fields[i])
+		if !ok {
+			continue
+		}
+
+		if out == nil {
+			out = make([]zapcore.Field, len(fields))
+			copy(out, fields)
+		}
+		out[i] = frozen
+	}
+
+	if out == nil {
+		return fields
+	}
+
+	return out
+}
+
+// freezeField encodes the value of the field, if it may be modified by the
+// caller. It returns false if the field is left as is.
+func freezeField(field zapcore.Field) (zapcore.Field, bool) {
+	switch field.Type {
+	case zapcore.ObjectMarshalerType, zapcore.InlineMarshalerType, zapcore.ArrayMarshalerType,
+		zapcore.StringerType, zapcore.ReflectType:
+	default:
+		return field, false
+	}
+
+	if isPackageValue(field.Interface) {
+		return field, false
+	}
+
+	if field.Type == zapcore.ReflectType {
+		b, err := json.Marshal(field.Interface)
+		if err != nil {
+			return zapcore.Field{Key: field.Key + "Error", Type: zapcore.StringType, String: err.Error()}, true
+		}
+
+		return zapcore.Field{Key: field.Key, Type: zapcore.ReflectType, Interface: json.RawMessage(b)}, true
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	field.AddTo(enc)
+
+	return zapcore.Field{Key: field.Key, Type: zapcore.InlineMarshalerType, Interface: frozenObject(enc.Fields)}, true
+}
+
+// isPackageValue returns true if the value is a type of this package.
+func isPackageValue(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t != nil && t.PkgPath() == packagePath
+}
+
+// frozenObject holds the fields encoded by a map encoder, to be added inline to
+// the entry in the order of their keys.
+type frozenObject map[string]interface{}
+
+func (o frozenObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	keys := make([]string, 0, len(o))
+	for key := range o {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		switch v := o[key].(type) {
+		case string:
+			enc.AddString(key, v)
+		default:
+			if err := enc.AddReflected(key, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package zapdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// blockingCore is a core that blocks all writes until it is released.
+type blockingCore struct {
+	zapcore.Core
+	release chan struct{}
+}
+
+func (c *blockingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	<-c.release
+	return c.Core.Write(ent, fields)
+}
+
+func TestWriteAsync(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(Async(16, BlockOnOverflow)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.With(Label("one", "world")).Info("hello", Label("two", "worlds"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.NoError(t, logger.Sync())
+	require.Equal(t, 800, logs.Len())
+	assert.Zero(t, DroppedEntries(logger))
+
+	labels := logs.All()[0].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, "world", labels["one"])
+	assert.Equal(t, "worlds", labels["two"])
+}
+
+func TestWriteAsync_DropOnOverflow(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	blocking := &blockingCore{Core: debugcore, release: make(chan struct{})}
+	logger := zap.New(blocking, WrapCore(Async(2, DropOnOverflow)))
+
+	for i := 0; i < 10; i++ {
+		logger.Info("hello")
+	}
+
+	close(blocking.release)
+	require.NoError(t, logger.Sync())
+
+	// One entry might be held by the background goroutine, outside of the queue.
+	assert.True(t, DroppedEntries(logger) >= 7)
+	assert.Equal(t, 10, logs.Len()+int(DroppedEntries(logger)))
+}
+
+func TestDroppedEntries_NotAsync(t *testing.T) {
+	assert.Zero(t, DroppedEntries(zap.NewNop()))
+	assert.Zero(t, DroppedEntries(zap.New(zapcore.NewNopCore(), WrapCore())))
+}
+
+func TestWriteAsync_MutableFields(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	blocking := &blockingCore{Core: debugcore, release: make(chan struct{})}
+	logger := zap.New(blocking, WrapCore(Async(16, BlockOnOverflow)))
+
+	stringer := bytes.NewBufferString("before")
+	object := map[string]string{"value": "before"}
+	logger.Info("hello",
+		zap.Stringer("stringer", stringer),
+		zap.Reflect("reflected", object),
+		zap.Object("object", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddString("value", stringer.String())
+			return nil
+		})),
+		zap.String("string", "value"),
+	)
+
+	stringer.Reset()
+	stringer.WriteString("after")
+	object["value"] = "after"
+
+	close(blocking.release)
+	require.NoError(t, logger.Sync())
+	require.Equal(t, 1, logs.Len())
+
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "before", fields["stringer"])
+	assert.JSONEq(t, `{"value":"before"}`, string(fields["reflected"].(json.RawMessage)))
+	assert.Equal(t, map[string]interface{}{"value": "before"}, fields["object"])
+	assert.Equal(t, "value", fields["string"])
+}
+
+func TestCloseAsync(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(Async(16, BlockOnOverflow)))
+
+	logger.Info("queued")
+	CloseAsync(logger)
+	assert.Equal(t, 1, logs.Len())
+
+	// Entries are written synchronously once the queue is closed.
+	logger.With(zap.String("child", "value")).Info("synchronous")
+	assert.Equal(t, 2, logs.Len())
+
+	CloseAsync(logger)
+	require.NoError(t, logger.Sync())
+	assert.Zero(t, DroppedEntries(logger))
+}
@@ -0,0 +1,159 @@
+package zapdriver
+
+import (
+	"encoding/json"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	protoPayloadKey = "protoPayload"
+	auditLogType    = "type.googleapis.com/google.cloud.audit.AuditLog"
+)
+
+// ProtoPayload adds the JSON representation of a protocol buffer message of
+// type `typeURL` (e.g. "type.googleapis.com/google.cloud.audit.AuditLog") as
+// the `protoPayload` of the entry.
+//
+// Note that the logging agent stores the payload of entries written to
+// standard output in `jsonPayload.protoPayload`. Only entries written through
+// the Cloud Logging API are stored as a true `protoPayload`.
+//
+// see: https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry
+func ProtoPayload(typeURL string, payload zapcore.ObjectMarshaler) zap.Field {
+	return zap.Object(protoPayloadKey, &protoPayload{Type: typeURL, Payload: payload})
+}
+
+// AuditLog adds an application-level audit event, formatted like the
+// `google.cloud.audit.AuditLog` entries of the platform audit logs.
+//
+// A nil payload adds no field.
+//
+// see: https://cloud.google.com/logging/docs/reference/audit/auditlog/rest/Shared.Types/AuditLog
+func AuditLog(payload *AuditLogPayload) zap.Field {
+	if payload == nil {
+		return zap.Skip()
+	}
+
+	return ProtoPayload(auditLogType, payload)
+}
+
+// protoPayload wraps an object with its protocol buffer type.
+type protoPayload struct {
+	Type    string
+	Payload zapcore.ObjectMarshaler
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (p protoPayload) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("@type", p.Type)
+	if p.Payload == nil {
+		return nil
+	}
+
+	return p.Payload.MarshalLogObject(enc)
+}
+
+// AuditLogPayload is the payload of an audit event.
+type AuditLogPayload struct {
+	// The name of the API service performing the operation. For example,
+	// "compute.googleapis.com".
+	ServiceName string `json:"serviceName"`
+
+	// The name of the service method or operation. For example,
+	// "v1.compute.instances.insert".
+	MethodName string `json:"methodName"`
+
+	// The resource or collection that is the target of the operation. For
+	// example, "projects/my-project/instances/my-instance".
+	ResourceName string `json:"resourceName,omitempty"`
+
+	// Authentication information of the caller.
+	AuthenticationInfo AuthenticationInfo `json:"authenticationInfo"`
+
+	// Metadata about the request.
+	RequestMetadata RequestMetadata `json:"requestMetadata"`
+
+	// The status of the overall operation. A code of 0 means success. Both are
+	// encoded in a nested `status` object.
+	StatusCode    int    `json:"-"`
+	StatusMessage string `json:"-"`
+}
+
+// auditStatus is the JSON shape of the status of an audit event.
+type auditStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, encoding the payload in the same shape
+// as MarshalLogObject.
+func (a AuditLogPayload) MarshalJSON() ([]byte, error) {
+	type payload AuditLogPayload
+
+	return json.Marshal(struct {
+		payload
+		Status auditStatus `json:"status"`
+	}{payload(a), auditStatus{Code: a.StatusCode, Message: a.StatusMessage}})
+}
+
+// AuthenticationInfo is the authentication information of an audit event.
+type AuthenticationInfo struct {
+	// The email address of the authenticated user making the request.
+	PrincipalEmail string `json:"principalEmail,omitempty"`
+
+	// String representation of the identity of the requesting party.
+	PrincipalSubject string `json:"principalSubject,omitempty"`
+}
+
+// RequestMetadata is the metadata of the request of an audit event.
+type RequestMetadata struct {
+	// The IP address of the caller.
+	CallerIP string `json:"callerIp,omitempty"`
+
+	// The user agent of the caller.
+	CallerSuppliedUserAgent string `json:"callerSuppliedUserAgent,omitempty"`
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (a AuditLogPayload) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("serviceName", a.ServiceName)
+	enc.AddString("methodName", a.MethodName)
+	if a.ResourceName != "" {
+		enc.AddString("resourceName", a.ResourceName)
+	}
+
+	_ = enc.AddObject("authenticationInfo", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		if a.AuthenticationInfo.PrincipalEmail != "" {
+			enc.AddString("principalEmail", a.AuthenticationInfo.PrincipalEmail)
+		}
+		if a.AuthenticationInfo.PrincipalSubject != "" {
+			enc.AddString("principalSubject", a.AuthenticationInfo.PrincipalSubject)
+		}
+
+		return nil
+	}))
+
+	_ = enc.AddObject("requestMetadata", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		if a.RequestMetadata.CallerIP != "" {
+			enc.AddString("callerIp", a.RequestMetadata.CallerIP)
+		}
+		if a.RequestMetadata.CallerSuppliedUserAgent != "" {
+			enc.AddString("callerSuppliedUserAgent", a.RequestMetadata.CallerSuppliedUserAgent)
+		}
+
+		return nil
+	}))
+
+	_ = enc.AddObject("status", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		enc.AddInt("code", a.StatusCode)
+		if a.StatusMessage != "" {
+			enc.AddString("message", a.StatusMessage)
+		}
+
+		return nil
+	}))
+
+	return nil
+}
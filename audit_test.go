@@ -0,0 +1,90 @@
+package zapdriver
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestAuditLog(t *testing.T) {
+	t.Parallel()
+
+	field := AuditLog(&AuditLogPayload{
+		ServiceName:        "billing.example.com",
+		MethodName:         "InvoiceService.Delete",
+		ResourceName:       "invoices/123",
+		AuthenticationInfo: AuthenticationInfo{PrincipalEmail: "jane@example.com"},
+		RequestMetadata:    RequestMetadata{CallerIP: "127.0.0.1"},
+		StatusCode:         7,
+		StatusMessage:      "permission denied",
+	})
+
+	enc := zapcore.NewMapObjectEncoder()
+	field.AddTo(enc)
+
+	want := map[string]interface{}{
+		"@type":              "type.googleapis.com/google.cloud.audit.AuditLog",
+		"serviceName":        "billing.example.com",
+		"methodName":         "InvoiceService.Delete",
+		"resourceName":       "invoices/123",
+		"authenticationInfo": map[string]interface{}{"principalEmail": "jane@example.com"},
+		"requestMetadata":    map[string]interface{}{"callerIp": "127.0.0.1"},
+		"status":             map[string]interface{}{"code": 7, "message": "permission denied"},
+	}
+
+	assert.Equal(t, want, enc.Fields[protoPayloadKey])
+}
+
+func TestAuditLogPayload_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	payloads := map[string]AuditLogPayload{
+		"full": {
+			ServiceName:        "billing.example.com",
+			MethodName:         "InvoiceService.Delete",
+			ResourceName:       "invoices/123",
+			AuthenticationInfo: AuthenticationInfo{PrincipalEmail: "jane@example.com"},
+			RequestMetadata:    RequestMetadata{CallerIP: "127.0.0.1"},
+			StatusCode:         7,
+			StatusMessage:      "permission denied",
+		},
+		"minimal": {ServiceName: "billing.example.com", MethodName: "InvoiceService.List"},
+	}
+
+	for name, payload := range payloads {
+		payload := payload
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := json.Marshal(payload)
+			require.NoError(t, err)
+
+			enc := zapcore.NewJSONEncoder(zapcore.EncoderConfig{})
+			want, err := enc.EncodeEntry(zapcore.Entry{}, []zapcore.Field{zap.Inline(payload)})
+			require.NoError(t, err)
+
+			assert.JSONEq(t, want.String(), string(got))
+		})
+	}
+}
+
+func TestAuditLog_Nil(t *testing.T) {
+	t.Parallel()
+
+	enc := zapcore.NewMapObjectEncoder()
+	assert.NotPanics(t, func() { AuditLog(nil).AddTo(enc) })
+	assert.Empty(t, enc.Fields)
+}
+
+func TestProtoPayload_Nil(t *testing.T) {
+	t.Parallel()
+
+	enc := zapcore.NewMapObjectEncoder()
+	ProtoPayload("type.googleapis.com/google.protobuf.Empty", nil).AddTo(enc)
+
+	assert.Equal(t, map[string]interface{}{"@type": "type.googleapis.com/google.protobuf.Empty"}, enc.Fields[protoPayloadKey])
+}
@@ -0,0 +1,335 @@
+package zapdriver
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// auditChainKey is the key of the field chaining each audit entry to the
+// previous one.
+const auditChainKey = "auditChain"
+
+// auditFile holds the configuration of an AuditFileCore.
+type auditFile struct {
+	rotateSize int64
+	now        func() time.Time
+}
+
+// zapdriver audit file option to rotate the file once it reaches `bytes` bytes.
+// The rotated file is renamed with a timestamp and sequence number suffix, and
+// the chain continues in the new file
+func AuditRotateSize(bytes int64) func(*auditFile) {
+	return func(f *auditFile) {
+		f.rotateSize = bytes
+	}
+}
+
+// AuditFileCore is a zapcore.Core writing Stackdriver-formatted entries to a
+// local append-only file, for tamper-evident audit trails. Each entry holds an
+// `auditChain` field with its sequence number and the SHA-256 hash of the
+// previous entry, as written in the file, so that modifying or removing an
+// entry breaks the chain (see `VerifyAuditChain()`).
+//
+// It is meant to be used as a secondary core, along with the main core of the
+// logger:
+//
+//	audit, err := zapdriver.NewAuditFileCore("audit.log", zap.InfoLevel)
+//	logger := zap.New(zapcore.NewTee(core, audit))
+type AuditFileCore struct {
+	zapcore.LevelEnabler
+
+	enc    zapcore.Encoder
+	writer *auditWriter
+}
+
+// auditWriter appends chained entries to the audit file. It is shared between
+// the core and all the cores derived from it using `With()`.
+type auditWriter struct {
+	path   string
+	config auditFile
+
+	mutex    *sync.Mutex
+	file     *os.File
+	size     int64
+	sequence uint64
+	previous string
+}
+
+// NewAuditFileCore opens (or creates) the audit file at `path`, and returns a
+// core writing the entries enabled by `enab` to it. When the file already
+// holds entries, the chain continues from its last entry.
+func NewAuditFileCore(path string, enab zapcore.LevelEnabler, options ...func(*auditFile)) (*AuditFileCore, error) {
+	w := &auditWriter{
+		path:   path,
+		config: auditFile{now: time.Now},
+		mutex:  &sync.Mutex{},
+	}
+	for _, option := range options {
+		option(&w.config)
+	}
+
+	if err := w.resume(); err != nil {
+		return nil, err
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	return &AuditFileCore{
+		LevelEnabler: enab,
+		enc:          NewEncoder(NewProductionEncoderConfig()),
+		writer:       w,
+	}, nil
+}
+
+// With implements zapcore.Core interface.
+func (c *AuditFileCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+
+	return &AuditFileCore{LevelEnabler: c.LevelEnabler, enc: enc, writer: c.writer}
+}
+
+// Check implements zapcore.Core interface.
+func (c *AuditFileCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+// Write implements zapcore.Core interface. Entries below the level of the core
+// are dropped, as `zapcore.NewTee()` wrapped by the zapdriver core writes every
+// entry to all of its cores.
+func (c *AuditFileCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !c.Enabled(ent.Level) {
+		return nil
+	}
+
+	return c.writer.append(c.enc, ent, fields)
+}
+
+// Sync implements zapcore.Core interface.
+func (c *AuditFileCore) Sync() error {
+	c.writer.mutex.Lock()
+	defer c.writer.mutex.Unlock()
+
+	return c.writer.file.Sync()
+}
+
+// Close syncs and closes the audit file.
+func (c *AuditFileCore) Close() error {
+	c.writer.mutex.Lock()
+	defer c.writer.mutex.Unlock()
+
+	if err := c.writer.file.Sync(); err != nil {
+		return err
+	}
+
+	return c.writer.file.Close()
+}
+
+// append encodes the entry, chained to the previous entry, and appends it to
+// the file.
+func (w *auditWriter) append(enc zapcore.Encoder, ent zapcore.Entry, fields []zapcore.Field) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	fields = append(fields[:len(fields):len(fields)], zap.Object(auditChainKey, auditChain{
+		Sequence:     w.sequence + 1,
+		PreviousHash: w.previous,
+	}))
+
+	buf, err := enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	line := bytes.TrimRight(buf.Bytes(), "\n")
+
+	n, err := w.file.Write(append(line, '\n'))
+	if err != nil {
+		// Remove the torn line, so the next entry is not chained onto it.
+		if n > 0 {
+			_ = w.file.Truncate(w.size)
+		}
+
+		return err
+	}
+	w.size += int64(n)
+
+	w.sequence++
+	w.previous = hashLine(line)
+
+	if w.config.rotateSize > 0 && w.size >= w.config.rotateSize {
+		return w.rotate()
+	}
+
+	return nil
+}
+
+// open opens the file for appending.
+func (w *auditWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	w.file = file
+	w.size = info.Size()
+
+	return nil
+}
+
+// rotate renames the file with a timestamp suffix, and opens a new file.
+func (w *auditWriter) rotate() error {
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	// The sequence number of the last entry keeps the names unique.
+	name := fmt.Sprintf("%s.%s.%d", w.path, w.config.now().UTC().Format("20060102T150405.000000000"), w.sequence)
+	if err := os.Rename(w.path, name); err != nil {
+		return err
+	}
+
+	return w.open()
+}
+
+// resume reads the sequence number and the hash of the last entry of an
+// existing file, to continue its chain.
+func (w *auditWriter) resume() error {
+	file, err := os.Open(w.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	last, err := lastLine(file)
+	if err != nil || last == nil {
+		return err
+	}
+
+	chain, err := parseAuditChain(last)
+	if err != nil {
+		return fmt.Errorf("zapdriver: cannot resume audit chain of %s: %w", w.path, err)
+	}
+
+	w.sequence = chain.Sequence
+	w.previous = hashLine(last)
+
+	return nil
+}
+
+// auditChain links an audit entry to the previous one.
+type auditChain struct {
+	Sequence     uint64 `json:"sequence"`
+	PreviousHash string `json:"previousHash"`
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (c auditChain) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddUint64("sequence", c.Sequence)
+	enc.AddString("previousHash", c.PreviousHash)
+
+	return nil
+}
+
+// VerifyAuditChain verifies the chain of the entries read from `r`, as written
+// by an AuditFileCore, starting from `previousHash` (empty for the first file
+// of the chain). It returns the hash of the last entry, to verify the next
+// (rotated) file of the chain, or an error locating the first broken link.
+func VerifyAuditChain(r io.Reader, previousHash string) (string, error) {
+	scanner := newLineScanner(r)
+
+	for n := 1; scanner.Scan(); n++ {
+		chain, err := parseAuditChain(scanner.Bytes())
+		if err != nil {
+			return previousHash, fmt.Errorf("zapdriver: audit entry %d: %w", n, err)
+		}
+
+		if chain.PreviousHash != previousHash {
+			return previousHash, fmt.Errorf("zapdriver: audit entry %d (sequence %d): chain broken", n, chain.Sequence)
+		}
+
+		previousHash = hashLine(scanner.Bytes())
+	}
+
+	return previousHash, scanner.Err()
+}
+
+// parseAuditChain returns the chain field of an encoded audit entry.
+func parseAuditChain(line []byte) (auditChain, error) {
+	var entry struct {
+		Chain *auditChain `json:"auditChain"`
+	}
+
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return auditChain{}, err
+	}
+
+	if entry.Chain == nil {
+		return auditChain{}, fmt.Errorf("missing %s field", auditChainKey)
+	}
+
+	return *entry.Chain, nil
+}
+
+// hashLine returns the hex-encoded SHA-256 hash of an encoded entry.
+func hashLine(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+// lastLine returns the last non-empty line read from `r`, or nil if there is
+// none.
+func lastLine(r io.Reader) ([]byte, error) {
+	var last []byte
+
+	scanner := newLineScanner(r)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			last = append(last[:0], scanner.Bytes()...)
+		}
+	}
+
+	return last, scanner.Err()
+}
+
+// newLineScanner returns a scanner of lines as large as the maximum entry size
+// of Cloud Logging.
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*DefaultMaxEntrySize)
+
+	return scanner
+}
@@ -0,0 +1,103 @@
+package zapdriver
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestAuditFileCore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	audit, err := NewAuditFileCore(path, zapcore.InfoLevel)
+	require.NoError(t, err)
+
+	logger := zap.New(audit).With(Label("tenant", "acme"))
+	logger.Info("one")
+	logger.Debug("skipped")
+	logger.Warn("two", zap.String("user", "jane"))
+	require.NoError(t, audit.Close())
+
+	// Reopening the file continues the chain.
+	audit, err = NewAuditFileCore(path, zapcore.InfoLevel)
+	require.NoError(t, err)
+	zap.New(audit).Info("three")
+	require.NoError(t, audit.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"logging.googleapis.com/labels":{"tenant":"acme"}`)
+	assert.Contains(t, lines[0], `"auditChain":{"sequence":1,"previousHash":""}`)
+	assert.Contains(t, lines[2], `"auditChain":{"sequence":3,"previousHash":"`+hashLine([]byte(lines[1]))+`"}`)
+
+	last, err := VerifyAuditChain(bytes.NewReader(data), "")
+	require.NoError(t, err)
+	assert.Equal(t, hashLine([]byte(lines[2])), last)
+
+	tampered := strings.Replace(string(data), `"user":"jane"`, `"user":"john"`, 1)
+	_, err = VerifyAuditChain(strings.NewReader(tampered), "")
+	assert.EqualError(t, err, "zapdriver: audit entry 3 (sequence 3): chain broken")
+}
+
+func TestAuditFileCore_WrappedTee(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	audit, err := NewAuditFileCore(path, zapcore.WarnLevel)
+	require.NoError(t, err)
+
+	logger := zap.New(zapcore.NewTee(zapcore.NewCore(NewEncoder(NewProductionEncoderConfig()), zapcore.AddSync(io.Discard), zapcore.DebugLevel), audit), WrapCore())
+	logger.Info("skipped")
+	logger.Warn("audited")
+	require.NoError(t, audit.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], "audited")
+}
+
+func TestAuditFileCore_Rotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+
+	audit, err := NewAuditFileCore(path, zapcore.InfoLevel, AuditRotateSize(1))
+	require.NoError(t, err)
+
+	logger := zap.New(audit)
+	for i := 0; i < 3; i++ {
+		logger.Info("entry")
+	}
+	require.NoError(t, audit.Close())
+
+	rotated, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	require.Len(t, rotated, 3)
+	sort.Strings(rotated)
+
+	previous := ""
+	for _, name := range rotated {
+		data, err := os.ReadFile(name)
+		require.NoError(t, err)
+
+		previous, err = VerifyAuditChain(bytes.NewReader(data), previous)
+		require.NoError(t, err, name)
+	}
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+}
@@ -0,0 +1,67 @@
+package zapdriver
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	processTraceOnce sync.Once
+	processTrace     string
+)
+
+// zapdriver core option to add a trace ID, generated once per process, to the
+// entries without a trace context. Batch jobs and CLIs, which have no inbound
+// request to take the trace from, can still group the entries of a run by
+// trace in the Logs Explorer. Use `ScopedTrace()` to group the entries of a
+// single workflow of the process instead
+func AutoTrace() func(*core) {
+	return func(c *core) {
+		c.config.AutoTrace = processTraceID()
+	}
+}
+
+// ScopedTrace returns a scoped logger (see `Scoped()`), adding a newly
+// generated trace ID to every entry it writes, so the entries of a multi-entry
+// workflow can be grouped by trace without Cloud Trace involvement. The trace
+// takes precedence over the trace of `AutoTrace()`.
+func ScopedTrace(logger *zap.Logger, labels ...zap.Field) *zap.Logger {
+	return Scoped(logger, labels...).With(zap.String(traceKey, traceName(ProjectID(), randomID())))
+}
+
+// processTraceID returns the trace ID of the process.
+func processTraceID() string {
+	processTraceOnce.Do(func() {
+		processTrace = randomID()
+	})
+
+	return processTrace
+}
+
+// traceOf returns the trace of the fields, if they hold a trace context.
+func traceOf(fields []zapcore.Field) (string, bool) {
+	for i := range fields {
+		if fields[i].Key == traceKey {
+			return fields[i].String, true
+		}
+	}
+
+	return "", false
+}
+
+// withAutoTrace adds the trace of the process to the entry, unless the entry or
+// the logger already holds a trace context.
+func (c *core) withAutoTrace(fields []zapcore.Field) []zapcore.Field {
+	if _, ok := traceOf(fields); ok || c.traced {
+		return fields
+	}
+
+	project := c.config.TraceProject
+	if project == "" {
+		project = ProjectID()
+	}
+
+	return append(fields[:len(fields):len(fields)], zap.String(traceKey, traceName(project, c.config.AutoTrace)))
+}
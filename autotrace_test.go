@@ -0,0 +1,81 @@
+package zapdriver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAutoTrace(t *testing.T) {
+	defer func(d *projectDetector) { detectedProject = d }(detectedProject)
+	detectedProject = &projectDetector{}
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "my-project")
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(AutoTrace()))
+
+	logger.Info("first")
+	logger.With(zap.String("job", "sync")).Info("second")
+	logger.Info("explicit", TraceContext("105445aa7843bc8bf206b120001000", "0", true)...)
+	logger.With(TraceContext("105445aa7843bc8bf206b120001000", "0", true)...).Info("with")
+
+	require.Equal(t, 4, logs.Len())
+
+	trace := "projects/my-project/traces/" + processTraceID()
+	assert.Equal(t, trace, logs.All()[0].ContextMap()[traceKey])
+	assert.Equal(t, trace, logs.All()[1].ContextMap()[traceKey])
+	assert.Len(t, processTraceID(), 32)
+
+	for _, log := range logs.All()[2:] {
+		assert.Equal(t, "projects/my-project/traces/105445aa7843bc8bf206b120001000", log.ContextMap()[traceKey])
+
+		n := 0
+		for _, field := range log.Context {
+			if field.Key == traceKey {
+				n++
+			}
+		}
+		assert.Equal(t, 1, n)
+	}
+}
+
+func TestAutoTrace_TraceProject(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(AutoTrace(), TraceProject("other-project")))
+
+	logger.Info("hello")
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "projects/other-project/traces/"+processTraceID(), logs.All()[0].ContextMap()[traceKey])
+}
+
+func TestScopedTrace(t *testing.T) {
+	defer func(d *projectDetector) { detectedProject = d }(detectedProject)
+	detectedProject = &projectDetector{}
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "my-project")
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(AutoTrace()))
+
+	first := ScopedTrace(logger, Label("workflow", "first"))
+	second := ScopedTrace(logger)
+
+	first.Info("one")
+	first.Info("two")
+	second.Info("three")
+
+	require.Equal(t, 3, logs.Len())
+
+	trace := logs.All()[0].ContextMap()[traceKey]
+	assert.Regexp(t, "^projects/my-project/traces/[0-9a-f]{32}$", trace)
+	assert.NotEqual(t, "projects/my-project/traces/"+processTraceID(), trace)
+	assert.Equal(t, trace, logs.All()[1].ContextMap()[traceKey])
+	assert.NotEqual(t, trace, logs.All()[2].ContextMap()[traceKey])
+
+	labels := logs.All()[0].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"workflow": "first"}, labels)
+}
@@ -0,0 +1,274 @@
+package zapdriver
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// zapdriver core option to write column-safe key names (letters, digits and
+// underscores, not starting with a digit) when set to true, for logs exported
+// to BigQuery, which mangles keys containing dots or slashes. The keys of the
+// fields (including the keys of nested objects) and of the labels are
+// sanitized, while the special Stackdriver keys interpreted by Cloud Logging
+// are kept as-is
+func BigQueryCompatible(enabled bool) func(*core) {
+	return func(c *core) {
+		c.config.BigQueryCompatible = enabled
+	}
+}
+
+// bigQueryKey returns the key with all characters not allowed in BigQuery
+// column names replaced by underscores.
+func bigQueryKey(key string) string {
+	safe := key != ""
+	for i := 0; i < len(key) && safe; i++ {
+		safe = isColumnChar(key[i], i == 0)
+	}
+	if safe {
+		return key
+	}
+
+	b := make([]byte, 0, len(key)+1)
+	if key == "" || (key[0] >= '0' && key[0] <= '9') {
+		b = append(b, '_')
+	}
+	for i := 0; i < len(key); i++ {
+		if isColumnChar(key[i], false) {
+			b = append(b, key[i])
+		} else {
+			b = append(b, '_')
+		}
+	}
+
+	return string(b)
+}
+
+// isColumnChar returns true if the character is allowed in a BigQuery column
+// name, at the start of the name when `first` is set.
+func isColumnChar(c byte, first bool) bool {
+	switch {
+	case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return true
+	case c >= '0' && c <= '9':
+		return !first
+	}
+
+	return false
+}
+
+// bigQueryFields returns the fields with column-safe keys. Special fields are
+// kept as-is, except for the keys of the labels.
+func bigQueryFields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		out[i] = bigQueryField(field)
+	}
+
+	return out
+}
+
+func bigQueryField(field zapcore.Field) zapcore.Field {
+	if lbls, ok := field.Interface.(*LabelSet); ok && field.Key == labelsKey {
+		safe := NewLabelSet()
+		safe.sorted = lbls.sorted
+		for k, v := range lbls.Map() {
+			safe.Add(bigQueryKey(k), v)
+		}
+
+		return labelsField(safe)
+	}
+
+	if isSpecialField(field) || field.Key == "httpRequest" {
+		return field
+	}
+
+	field.Key = bigQueryKey(field.Key)
+
+	switch field.Type {
+	case zapcore.ObjectMarshalerType, zapcore.InlineMarshalerType:
+		field.Interface = bigQueryObject{field.Interface.(zapcore.ObjectMarshaler)}
+	case zapcore.ArrayMarshalerType:
+		field.Interface = bigQueryArray{field.Interface.(zapcore.ArrayMarshaler)}
+	case zapcore.ReflectType:
+		field.Interface = bigQueryReflected(field.Interface)
+	}
+
+	return field
+}
+
+// bigQueryReflected returns the JSON representation of the value, with
+// column-safe object keys.
+func bigQueryReflected(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return v
+	}
+
+	return sanitizeJSON(decoded)
+}
+
+func sanitizeJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[bigQueryKey(k)] = sanitizeJSON(e)
+		}
+
+		return out
+	case []interface{}:
+		for i := range v {
+			v[i] = sanitizeJSON(v[i])
+		}
+	}
+
+	return v
+}
+
+// bigQueryObject marshals an object with column-safe keys.
+type bigQueryObject struct {
+	zapcore.ObjectMarshaler
+}
+
+func (o bigQueryObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return o.ObjectMarshaler.MarshalLogObject(bigQueryObjectEncoder{enc})
+}
+
+// bigQueryArray marshals an array with column-safe keys in its objects.
+type bigQueryArray struct {
+	zapcore.ArrayMarshaler
+}
+
+func (a bigQueryArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	return a.ArrayMarshaler.MarshalLogArray(bigQueryArrayEncoder{enc})
+}
+
+// bigQueryObjectEncoder is an object encoder writing column-safe keys.
+type bigQueryObjectEncoder struct {
+	enc zapcore.ObjectEncoder
+}
+
+func (e bigQueryObjectEncoder) AddArray(key string, v zapcore.ArrayMarshaler) error {
+	return e.enc.AddArray(bigQueryKey(key), bigQueryArray{v})
+}
+
+func (e bigQueryObjectEncoder) AddObject(key string, v zapcore.ObjectMarshaler) error {
+	return e.enc.AddObject(bigQueryKey(key), bigQueryObject{v})
+}
+
+func (e bigQueryObjectEncoder) AddBinary(key string, v []byte) {
+	e.enc.AddBinary(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddByteString(key string, v []byte) {
+	e.enc.AddByteString(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddBool(key string, v bool) {
+	e.enc.AddBool(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddComplex128(key string, v complex128) {
+	e.enc.AddComplex128(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddComplex64(key string, v complex64) {
+	e.enc.AddComplex64(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddDuration(key string, v time.Duration) {
+	e.enc.AddDuration(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddFloat64(key string, v float64) {
+	e.enc.AddFloat64(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddFloat32(key string, v float32) {
+	e.enc.AddFloat32(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddInt(key string, v int) {
+	e.enc.AddInt(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddInt64(key string, v int64) {
+	e.enc.AddInt64(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddInt32(key string, v int32) {
+	e.enc.AddInt32(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddInt16(key string, v int16) {
+	e.enc.AddInt16(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddInt8(key string, v int8) {
+	e.enc.AddInt8(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddString(key string, v string) {
+	e.enc.AddString(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddTime(key string, v time.Time) {
+	e.enc.AddTime(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddUint(key string, v uint) {
+	e.enc.AddUint(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddUint64(key string, v uint64) {
+	e.enc.AddUint64(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddUint32(key string, v uint32) {
+	e.enc.AddUint32(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddUint16(key string, v uint16) {
+	e.enc.AddUint16(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddUint8(key string, v uint8) {
+	e.enc.AddUint8(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddUintptr(key string, v uintptr) {
+	e.enc.AddUintptr(bigQueryKey(key), v)
+}
+
+func (e bigQueryObjectEncoder) AddReflected(key string, v interface{}) error {
+	return e.enc.AddReflected(bigQueryKey(key), bigQueryReflected(v))
+}
+
+func (e bigQueryObjectEncoder) OpenNamespace(key string) {
+	e.enc.OpenNamespace(bigQueryKey(key))
+}
+
+// bigQueryArrayEncoder is an array encoder writing column-safe keys in the
+// objects of the array.
+type bigQueryArrayEncoder struct {
+	zapcore.ArrayEncoder
+}
+
+func (e bigQueryArrayEncoder) AppendArray(v zapcore.ArrayMarshaler) error {
+	return e.ArrayEncoder.AppendArray(bigQueryArray{v})
+}
+
+func (e bigQueryArrayEncoder) AppendObject(v zapcore.ObjectMarshaler) error {
+	return e.ArrayEncoder.AppendObject(bigQueryObject{v})
+}
+
+func (e bigQueryArrayEncoder) AppendReflected(v interface{}) error {
+	return e.ArrayEncoder.AppendReflected(bigQueryReflected(v))
+}
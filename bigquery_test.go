@@ -0,0 +1,79 @@
+package zapdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestBigQueryKey(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]string{
+		"user_id":                  "user_id",
+		"db.query":                 "db_query",
+		"k8s.io/app-name":          "k8s_io_app_name",
+		"2xx":                      "_2xx",
+		"":                         "_",
+		"héllo":                    "h__llo",
+		"logging.googleapis.com/x": "logging_googleapis_com_x",
+	}
+
+	for key, want := range tests {
+		assert.Equal(t, want, bigQueryKey(key), key)
+	}
+}
+
+func TestBigQueryCompatible(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(NewProductionEncoderConfig()), zapcore.AddSync(buf), zapcore.DebugLevel)
+
+	logger := zap.New(core, WrapCore(
+		BigQueryCompatible(true),
+		Clock(fixedClock(time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC))),
+	)).With(zap.String("request.id", "1"), Label("app.kubernetes.io/name", "api"))
+
+	logger.Info("hello",
+		zap.String("db.query", "SELECT 1"),
+		zap.Object("db-stats", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddInt("rows.read", 3)
+			return enc.AddObject("cache.hits", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+				enc.AddInt("l1.hits", 2)
+				return nil
+			}))
+		})),
+		zap.Any("payload", map[string]interface{}{"a.b": []interface{}{map[string]string{"c-d": "e"}}}),
+		Label("team/name", "core"),
+		HTTP(&HTTPPayload{RequestMethod: "GET", Status: 200}),
+		TraceContext("105445aa7843bc8bf206b120001000", "0", true, "my-project")[0],
+	)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+
+	assert.Equal(t, "GET", entry["httpRequest"].(map[string]interface{})["requestMethod"])
+	delete(entry, "httpRequest")
+
+	out, err := json.Marshal(entry)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"severity": "INFO",
+		"timestamp": "2020-01-02T03:04:05.000000006Z",
+		"message": "hello",
+		"request_id": "1",
+		"db_query": "SELECT 1",
+		"db_stats": {"rows_read": 3, "cache_hits": {"l1_hits": 2}},
+		"payload": {"a_b": [{"c_d": "e"}]},
+		"logging.googleapis.com/trace": "projects/my-project/traces/105445aa7843bc8bf206b120001000",
+		"logging.googleapis.com/labels": {"app_kubernetes_io_name": "api", "team_name": "core"}
+	}`, string(out))
+}
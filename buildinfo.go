@@ -0,0 +1,47 @@
+package zapdriver
+
+import (
+	"runtime/debug"
+)
+
+// readBuildInfo returns the build information of the binary. It is replaced in
+// tests.
+var readBuildInfo = debug.ReadBuildInfo
+
+// zapdriver core option to add the build information embedded in the binary
+// by the Go toolchain as permanent labels: the `vcs.revision` and `vcs.time`
+// of the commit the binary was built from, the `go.version` used to build it,
+// and the `module.version` of the main module (when available). The VCS
+// revision is also used as the service version, when `ServiceVersion()` is not
+// set, so versions no longer need to be injected using `-ldflags`
+func BuildInfoLabels() func(*core) {
+	return func(c *core) {
+		info, ok := readBuildInfo()
+		if !ok {
+			return
+		}
+
+		c.config.BuildLabels = map[string]string{}
+		add := func(key, value string) {
+			if value != "" {
+				c.permLabels.Add(key, value)
+				c.config.BuildLabels[key] = value
+			}
+		}
+
+		add("go.version", info.GoVersion)
+		if info.Main.Version != "(devel)" {
+			add("module.version", info.Main.Version)
+		}
+
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				add(setting.Key, setting.Value)
+				c.config.BuildRevision = setting.Value
+			case "vcs.time":
+				add(setting.Key, setting.Value)
+			}
+		}
+	}
+}
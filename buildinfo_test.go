@@ -0,0 +1,58 @@
+package zapdriver
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestBuildInfoLabels(t *testing.T) {
+	defer func(fn func() (*debug.BuildInfo, bool)) { readBuildInfo = fn }(readBuildInfo)
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{
+			GoVersion: "go1.21.0",
+			Main:      debug.Module{Path: "example.com/app", Version: "v1.2.3"},
+			Settings: []debug.BuildSetting{
+				{Key: "vcs", Value: "git"},
+				{Key: "vcs.revision", Value: "3f2c1a0"},
+				{Key: "vcs.time", Value: "2023-01-02T03:04:05Z"},
+			},
+		}, true
+	}
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	zap.New(debugcore, WrapCore(BuildInfoLabels(), ServiceName("app"))).Info("hello")
+	zap.New(debugcore, WrapCore(ServiceVersion("v2"), BuildInfoLabels(), ServiceName("app"))).Info("hello")
+
+	require.Equal(t, 2, logs.Len())
+
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, map[string]interface{}{
+		"go.version":     "go1.21.0",
+		"module.version": "v1.2.3",
+		"vcs.revision":   "3f2c1a0",
+		"vcs.time":       "2023-01-02T03:04:05Z",
+	}, fields[labelsKey])
+	assert.Equal(t, map[string]interface{}{"service": "app", "version": "3f2c1a0"}, fields[serviceContextKey])
+
+	fields = logs.All()[1].ContextMap()
+	assert.Equal(t, map[string]interface{}{"service": "app", "version": "v2"}, fields[serviceContextKey])
+}
+
+func TestBuildInfoLabels_Devel(t *testing.T) {
+	defer func(fn func() (*debug.BuildInfo, bool)) { readBuildInfo = fn }(readBuildInfo)
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{GoVersion: "go1.21.0", Main: debug.Module{Version: "(devel)"}}, true
+	}
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	zap.New(debugcore, WrapCore(BuildInfoLabels())).Info("hello")
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, map[string]interface{}{"go.version": "go1.21.0"}, logs.All()[0].ContextMap()[labelsKey])
+}
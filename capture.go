@@ -0,0 +1,277 @@
+package zapdriver
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// captureMessage is the message of the entries holding captured bodies.
+const captureMessage = "HTTP bodies captured."
+
+// bodyCapture holds the configuration of the body capture middleware.
+type bodyCapture struct {
+	header       string
+	labelKey     string
+	labelValue   string
+	maxBytes     int
+	contentTypes []string
+}
+
+// zapdriver body capture option to capture the bodies of the requests with the
+// header `name` set to a true value (e.g. "1" or "true"). The header can be set
+// by any client, so only use it when the edge proxy strips it from untrusted
+// requests
+func CaptureHeader(name string) func(*bodyCapture) {
+	return func(b *bodyCapture) {
+		b.header = name
+	}
+}
+
+// zapdriver body capture option to capture the bodies of the requests whose
+// logger (see `FromContext()`) holds the label `key` set to `value`
+func CaptureLabel(key, value string) func(*bodyCapture) {
+	return func(b *bodyCapture) {
+		b.labelKey = key
+		b.labelValue = value
+	}
+}
+
+// zapdriver body capture option to capture at most `n` bytes of each body,
+// instead of 4 KiB
+func CaptureMaxBytes(n int) func(*bodyCapture) {
+	return func(b *bodyCapture) {
+		b.maxBytes = n
+	}
+}
+
+// zapdriver body capture option to only capture the bodies with a media type
+// starting with any of `types` (e.g. "application/json" or "text/"), instead
+// of JSON, XML, form and text bodies
+func CaptureContentTypes(types ...string) func(*bodyCapture) {
+	return func(b *bodyCapture) {
+		b.contentTypes = types
+	}
+}
+
+// BodyCapture returns a HTTP middleware which captures the request and
+// response bodies of the requests marked for debugging, by a header (see
+// `CaptureHeader()`) or by a label of the request logger (see
+// `CaptureLabel()`). No request is marked unless one of these triggers is
+// configured. The bodies are truncated to the maximum size,
+// and only captured for textual content types. They are written after the
+// request is handled, in a single entry, using the logger of the request
+// context (see `FromContext()`), so the middleware should be installed after
+// the `LoggerMiddleware`:
+//
+//	handler := zapdriver.LoggerMiddleware(logger)(zapdriver.BodyCapture()(mux))
+//
+// Requests which are not marked are not affected, so request forensics can be
+// enabled for targeted requests without enabling it globally.
+func BodyCapture(options ...func(*bodyCapture)) func(http.Handler) http.Handler {
+	b := &bodyCapture{
+		maxBytes:     4096,
+		contentTypes: []string{"application/json", "application/xml", "application/x-www-form-urlencoded", "text/"},
+	}
+	for _, option := range options {
+		option(b)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger := FromContext(r.Context())
+			if !b.enabled(r, logger) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			req := capturedBody{contentType: r.Header.Get("Content-Type")}
+			if r.Body != nil && r.Body != http.NoBody && b.captures(req.contentType) {
+				prefix, err := io.ReadAll(io.LimitReader(r.Body, int64(b.maxBytes)+1))
+				req.captured = true
+				req.setBody(prefix, b.maxBytes)
+
+				r.Body = &prefixedBody{Reader: io.MultiReader(bytes.NewReader(prefix), &errReader{err: err, r: r.Body}), closer: r.Body}
+			}
+
+			cw := &captureWriter{ResponseWriter: w, capture: b}
+			next.ServeHTTP(cw, r)
+
+			res := cw.body
+			if !cw.typed {
+				res.contentType = w.Header().Get("Content-Type")
+			}
+
+			logger.Info(captureMessage, zap.Object("requestBody", req), zap.Object("responseBody", res))
+		})
+	}
+}
+
+// enabled returns true if the bodies of the request should be captured.
+func (b *bodyCapture) enabled(r *http.Request, logger *zap.Logger) bool {
+	if b.header != "" {
+		switch strings.ToLower(r.Header.Get(b.header)) {
+		case "1", "true", "yes", "on":
+			return true
+		}
+	}
+
+	if b.labelKey == "" {
+		return false
+	}
+
+	value, ok := scopedLabelsOf(logger).Get(b.labelKey)
+	if !ok {
+		c, driver := coreOf(logger)
+		if !driver {
+			return false
+		}
+
+		value, ok = c.permLabels.Get(b.labelKey)
+	}
+
+	return ok && value == b.labelValue
+}
+
+// captures returns true if bodies of the content type are captured.
+func (b *bodyCapture) captures(contentType string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	for _, t := range b.contentTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// capturedBody is a (possibly truncated) captured body.
+type capturedBody struct {
+	contentType string
+	captured    bool
+	body        []byte
+	truncated   bool
+}
+
+// setBody sets the captured body, truncated to `max` bytes.
+func (b *capturedBody) setBody(p []byte, max int) {
+	if len(p) > max {
+		// Avoid splitting a multi-byte character.
+		for max > 0 && !utf8.RuneStart(p[max]) {
+			max--
+		}
+
+		p, b.truncated = p[:max], true
+	}
+
+	b.body = p
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (b capturedBody) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if b.contentType != "" {
+		enc.AddString("contentType", b.contentType)
+	}
+
+	if !b.captured {
+		enc.AddBool("captured", false)
+		return nil
+	}
+
+	enc.AddByteString("body", b.body)
+	if b.truncated {
+		enc.AddBool("truncated", true)
+	}
+
+	return nil
+}
+
+// prefixedBody is a request body of which a prefix has been read already.
+type prefixedBody struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (b *prefixedBody) Close() error {
+	return b.closer.Close()
+}
+
+// errReader returns the error which interrupted the capture of the prefix,
+// or reads the rest of the body.
+type errReader struct {
+	err error
+	r   io.Reader
+}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+
+	return e.r.Read(p)
+}
+
+// captureWriter captures the beginning of the response body.
+type captureWriter struct {
+	http.ResponseWriter
+
+	capture *bodyCapture
+	body    capturedBody
+	typed   bool
+	full    bool
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	if !w.typed {
+		w.typed = true
+		w.body.contentType = w.Header().Get("Content-Type")
+		if w.body.contentType == "" {
+			w.body.contentType = http.DetectContentType(p)
+		}
+		w.body.captured = w.capture.captures(w.body.contentType)
+	}
+
+	if w.body.captured && !w.full {
+		n := w.capture.maxBytes + 1 - len(w.body.body)
+		if n > len(p) {
+			n = len(p)
+		}
+
+		buf := append(w.body.body, p[:n]...)
+		w.full = len(buf) > w.capture.maxBytes
+		w.body.setBody(buf, w.capture.maxBytes)
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements the http.Flusher interface, when the wrapped
+// ResponseWriter does.
+func (w *captureWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements the http.Hijacker interface, when the wrapped
+// ResponseWriter does.
+func (w *captureWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the wrapped ResponseWriter, for `http.ResponseController`.
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,161 @@
+package zapdriver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestBodyCapture(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore)
+
+	var received string
+	handler := BodyCapture(CaptureHeader("X-Debug-Capture"), CaptureMaxBytes(5))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = string(b)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":`))
+		_, _ = w.Write([]byte(`true}`))
+	}))
+
+	req := httptest.NewRequest("POST", "/path", strings.NewReader(`{"name":"value"}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-Debug-Capture", "1")
+	req = req.WithContext(WithLogger(req.Context(), logger))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, `{"name":"value"}`, received)
+	assert.Equal(t, `{"ok":true}`, rec.Body.String())
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, captureMessage, logs.All()[0].Message)
+
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, map[string]interface{}{
+		"contentType": "application/json; charset=utf-8",
+		"body":        `{"nam`,
+		"truncated":   true,
+	}, fields["requestBody"])
+	assert.Equal(t, map[string]interface{}{
+		"contentType": "application/json",
+		"body":        `{"ok"`,
+		"truncated":   true,
+	}, fields["responseBody"])
+}
+
+func TestBodyCapture_NotMarked(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+
+	handler := BodyCapture()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+
+	req := httptest.NewRequest("POST", "/path", strings.NewReader("hello"))
+	req.Header.Set("X-Debug-Capture", "1")
+	req = req.WithContext(WithLogger(req.Context(), zap.New(debugcore)))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, "hello", rec.Body.String())
+	assert.Equal(t, 0, logs.Len())
+}
+
+func TestBodyCapture_Label(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore()).With(Label("tenant", "acme"))
+
+	handler := BodyCapture(CaptureLabel("tenant", "acme"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+
+	req := httptest.NewRequest("GET", "/path", nil)
+	req = req.WithContext(WithLogger(req.Context(), logger))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, map[string]interface{}{
+		"contentType": "text/plain; charset=utf-8",
+		"body":        "hello",
+	}, logs.All()[0].ContextMap()["responseBody"])
+
+	other := logger.With(Label("tenant", "other"))
+	req = req.WithContext(WithLogger(req.Context(), other))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, 1, logs.Len())
+
+	scoped := Scoped(other, Label("tenant", "acme"))
+	req = req.WithContext(WithLogger(req.Context(), scoped))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, 2, logs.Len(), "the labels of scoped loggers trigger the capture")
+}
+
+func TestBodyCapture_ContentTypes(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+
+	handler := BodyCapture(CaptureHeader("X-Forensics"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte{0x89, 'P', 'N', 'G'})
+	}))
+
+	req := httptest.NewRequest("POST", "/path", strings.NewReader("binary"))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-Forensics", "true")
+	req = req.WithContext(WithLogger(req.Context(), zap.New(debugcore)))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.Equal(t, 1, logs.Len())
+
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, map[string]interface{}{
+		"contentType": "application/octet-stream",
+		"captured":    false,
+	}, fields["requestBody"])
+	assert.Equal(t, map[string]interface{}{
+		"contentType": "image/png",
+		"captured":    false,
+	}, fields["responseBody"])
+}
+
+func TestBodyCapture_ResponseWriter(t *testing.T) {
+	t.Parallel()
+
+	handler := BodyCapture(CaptureHeader("X-Debug-Capture"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+
+		_, _, err := w.(http.Hijacker).Hijack()
+		assert.ErrorIs(t, err, http.ErrNotSupported)
+	}))
+
+	req := httptest.NewRequest("GET", "/path", nil)
+	req.Header.Set("X-Debug-Capture", "1")
+	req = req.WithContext(WithLogger(req.Context(), zap.NewNop()))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.True(t, rec.Flushed)
+}
@@ -0,0 +1,115 @@
+package zapdriver
+
+import (
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// otherLabelValue replaces the label values exceeding the cardinality limit.
+const otherLabelValue = "_other"
+
+// OverflowStrategy determines how the label values exceeding the cardinality
+// limit are replaced.
+type OverflowStrategy int
+
+const (
+	// OverflowOther replaces the values exceeding the limit by `_other`.
+	OverflowOther OverflowStrategy = iota
+
+	// OverflowHash replaces the values exceeding the limit by one of 16 hashed
+	// buckets (`_other_0` to `_other_f`), so the same value always lands in the
+	// same bucket, while the cardinality stays bounded.
+	OverflowHash
+)
+
+// cardinalityGuard limits the number of distinct values per label key. It is
+// shared between the core and all the cores derived from it using `With()`.
+type cardinalityGuard struct {
+	// keys are the keys of the guarded labels, or nil for all labels.
+	keys map[string]bool
+
+	// limit is the number of distinct values per key allowed per period.
+	limit    int
+	per      time.Duration
+	strategy OverflowStrategy
+
+	mutex   *sync.Mutex
+	seen    map[string]map[string]struct{}
+	resetAt time.Time
+	now     func() time.Time
+}
+
+// zapdriver core option to allow at most `n` distinct values `per` period for
+// each of the label `keys` (or for all labels, when no keys are given), so
+// unbounded label values (user IDs, request IDs) don't blow up the cardinality
+// of log-based metrics. The values exceeding the limit are replaced using
+// `strategy`, until the period ends
+func LabelCardinality(n int, per time.Duration, strategy OverflowStrategy, keys ...string) func(*core) {
+	return func(c *core) {
+		g := &cardinalityGuard{
+			limit:    n,
+			per:      per,
+			strategy: strategy,
+			mutex:    &sync.Mutex{},
+			seen:     map[string]map[string]struct{}{},
+			now:      c.now,
+		}
+
+		if len(keys) > 0 {
+			g.keys = map[string]bool{}
+			for _, key := range keys {
+				g.keys[key] = true
+			}
+		}
+
+		c.cardinality = g
+	}
+}
+
+// guard replaces the values of the labels exceeding the limit.
+func (g *cardinalityGuard) guard(lbls *LabelSet) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if now := g.now(); !now.Before(g.resetAt) {
+		g.seen = map[string]map[string]struct{}{}
+		g.resetAt = now.Add(g.per)
+	}
+
+	for key, value := range lbls.Map() {
+		if g.keys != nil && !g.keys[key] {
+			continue
+		}
+
+		values, ok := g.seen[key]
+		if !ok {
+			values = map[string]struct{}{}
+			g.seen[key] = values
+		}
+
+		if _, ok := values[value]; ok {
+			continue
+		}
+
+		if len(values) < g.limit {
+			values[value] = struct{}{}
+			continue
+		}
+
+		lbls.Add(key, g.overflow(value))
+	}
+}
+
+// overflow returns the replacement of a value exceeding the limit.
+func (g *cardinalityGuard) overflow(value string) string {
+	if g.strategy != OverflowHash {
+		return otherLabelValue
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(value))
+
+	return otherLabelValue + "_" + strconv.FormatUint(uint64(h.Sum32()%16), 16)
+}
@@ -0,0 +1,67 @@
+package zapdriver
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLabelCardinality(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	c := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+	LabelCardinality(2, time.Minute, OverflowOther, "user")(c)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.cardinality.now = func() time.Time { return now }
+
+	write := func(fields ...zapcore.Field) map[string]interface{} {
+		require.NoError(t, c.With(nil).Write(zapcore.Entry{Level: zapcore.InfoLevel}, fields))
+		return logs.TakeAll()[0].ContextMap()[labelsKey].(map[string]interface{})
+	}
+
+	assert.Equal(t, "a", write(Label("user", "a"))["user"])
+	assert.Equal(t, "b", write(Label("user", "b"))["user"])
+	assert.Equal(t, otherLabelValue, write(Label("user", "c"))["user"])
+	assert.Equal(t, "a", write(Label("user", "a"))["user"], "known values are kept")
+
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, strconv.Itoa(i), write(Label("request", strconv.Itoa(i)))["request"], "other labels are not guarded")
+	}
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, "c", write(Label("user", "c"))["user"])
+}
+
+func TestLabelCardinality_Hash(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	c := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+	LabelCardinality(1, time.Minute, OverflowHash)(c)
+
+	buckets := map[interface{}]bool{}
+	for i := 0; i < 200; i++ {
+		require.NoError(t, c.Write(zapcore.Entry{Level: zapcore.InfoLevel}, []zapcore.Field{Label("user", strconv.Itoa(i))}))
+
+		value := logs.TakeAll()[0].ContextMap()[labelsKey].(map[string]interface{})["user"]
+		if i == 0 {
+			assert.Equal(t, "0", value)
+			continue
+		}
+
+		assert.Regexp(t, `^_other_[0-9a-f]$`, value)
+		buckets[value] = true
+	}
+	assert.Len(t, buckets, 16)
+
+	assert.Equal(t, c.cardinality.overflow("same"), c.cardinality.overflow("same"))
+}
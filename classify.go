@@ -0,0 +1,122 @@
+package zapdriver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	errorKindLabel      = "error.kind"
+	errorHTTPClassLabel = "error.http_class"
+)
+
+// zapdriver core option to classify the errors of the logs when set to true.
+// The cause of the errors logged using `zap.Error()` is added as the
+// `error.kind` label (e.g. `context_deadline`, `context_canceled` or `timeout`,
+// see `ErrorKinds()` for other kinds), and the class of the HTTP status code of the entry
+// or of the error as the `error.http_class` label (e.g. `4xx`), so dashboards
+// can slice error logs by category without matching on messages
+func ClassifyErrors(enabled bool) func(*core) {
+	return func(c *core) {
+		c.config.ClassifyErrors = enabled
+	}
+}
+
+// zapdriver core option to classify the errors which are not classified by
+// zapdriver using `kind`, which returns the kind of an error (e.g.
+// `grpczap.ErrorKind()`), or an empty string if it cannot classify it
+func ErrorKinds(kind func(error) string) func(*core) {
+	return func(c *core) {
+		c.config.ErrorKind = kind
+	}
+}
+
+// statusCoder is implemented by errors carrying an HTTP status code.
+type statusCoder interface {
+	StatusCode() int
+}
+
+// classifyErrors adds the classification labels of the errors of the entry.
+func (c *core) classifyErrors(fields []zapcore.Field) []zapcore.Field {
+	var kind, class string
+	for i := range fields {
+		switch v := fields[i].Interface.(type) {
+		case error:
+			if fields[i].Type != zapcore.ErrorType {
+				continue
+			}
+
+			if kind == "" {
+				kind = c.errorKind(v)
+			}
+
+			var coder statusCoder
+			if class == "" && errors.As(v, &coder) {
+				class = httpClass(coder.StatusCode())
+			}
+		case *HTTPPayload:
+			if class == "" && v != nil {
+				class = httpClass(v.Status)
+			}
+		}
+	}
+
+	if kind == "" && class == "" {
+		return fields
+	}
+
+	fields = fields[:len(fields):len(fields)]
+	if kind != "" {
+		fields = append(fields, Label(errorKindLabel, kind))
+	}
+	if class != "" {
+		fields = append(fields, Label(errorHTTPClassLabel, class))
+	}
+
+	return fields
+}
+
+// errorKind returns the kind of the cause of the error, or an empty string if
+// it cannot be classified.
+func (c *core) errorKind(err error) string {
+	return errorKind(err, c.config.ErrorKind)
+}
+
+// errorKind returns the kind of the cause of the error, using `custom` (when
+// set) for the errors which are not context errors, or an empty string if it
+// cannot be classified.
+func errorKind(err error, custom func(error) string) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "context_deadline"
+	case errors.Is(err, context.Canceled):
+		return "context_canceled"
+	}
+
+	if custom != nil {
+		if kind := custom(err); kind != "" {
+			return kind
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+
+	return ""
+}
+
+// httpClass returns the class of an HTTP error status code (e.g. `5xx`), or an
+// empty string if the status code is not an error.
+func httpClass(code int) string {
+	if code < 400 || code > 599 {
+		return ""
+	}
+
+	return strconv.Itoa(code/100) + "xx"
+}
@@ -0,0 +1,80 @@
+package zapdriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type statusError int
+
+func (e statusError) Error() string   { return fmt.Sprintf("status %d", int(e)) }
+func (e statusError) StatusCode() int { return int(e) }
+
+func TestErrorKind(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		err  error
+		want string
+	}{
+		{context.DeadlineExceeded, "context_deadline"},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), "context_deadline"},
+		{fmt.Errorf("query: %w", context.Canceled), "context_canceled"},
+		{&net.DNSError{IsTimeout: true}, "timeout"},
+		{&net.DNSError{}, ""},
+		{errors.New("boom"), ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, errorKind(tt.err, nil), tt.err.Error())
+	}
+
+	custom := func(err error) string {
+		if err.Error() == "boom" {
+			return "boom"
+		}
+		return ""
+	}
+	assert.Equal(t, "boom", errorKind(errors.New("boom"), custom))
+	assert.Equal(t, "context_canceled", errorKind(context.Canceled, custom))
+	assert.Equal(t, "timeout", errorKind(&net.DNSError{IsTimeout: true}, custom))
+}
+
+func TestHTTPClass(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "", httpClass(200))
+	assert.Equal(t, "", httpClass(302))
+	assert.Equal(t, "4xx", httpClass(404))
+	assert.Equal(t, "5xx", httpClass(503))
+	assert.Equal(t, "", httpClass(0))
+}
+
+func TestClassifyErrors(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(ClassifyErrors(true)))
+
+	logger.Error("timeout", zap.Error(fmt.Errorf("fetch: %w", context.DeadlineExceeded)))
+	logger.Warn("not found", zap.Error(fmt.Errorf("fetch: %w", statusError(404))))
+	logger.Error("request", HTTP(&HTTPPayload{Status: 502}))
+	logger.Error("plain", zap.Error(errors.New("boom")))
+	logger.Info("none", zap.String("error", "not an error"))
+
+	require.Equal(t, 5, logs.Len())
+
+	labels := func(i int) interface{} { return logs.All()[i].ContextMap()[labelsKey] }
+	assert.Equal(t, map[string]interface{}{errorKindLabel: "context_deadline"}, labels(0))
+	assert.Equal(t, map[string]interface{}{errorHTTPClassLabel: "4xx"}, labels(1))
+	assert.Equal(t, map[string]interface{}{errorHTTPClassLabel: "5xx"}, labels(2))
+	assert.Empty(t, labels(3))
+	assert.Empty(t, labels(4))
+}
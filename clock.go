@@ -0,0 +1,83 @@
+package zapdriver
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// CallerFunc returns the caller of an entry, used in the `sourceLocation` and
+// error context of the entry.
+type CallerFunc func(ent zapcore.Entry) zapcore.EntryCaller
+
+// zapdriver core option to use `clock` for the timestamps of the entries, and
+// for the time windows of the core (sampling, repeats and rate limiting), so
+// downstream projects can write deterministic golden-file tests of full
+// Stackdriver entries
+func Clock(clock zapcore.Clock) func(*core) {
+	return func(c *core) {
+		c.config.Clock = clock
+	}
+}
+
+// zapdriver core option to resolve the caller of the entries using `fn`,
+// instead of the caller resolved by zap. The function name of the caller, when
+// set, replaces the function name resolved from its program counter
+func Caller(fn CallerFunc) func(*core) {
+	return func(c *core) {
+		c.config.Caller = fn
+	}
+}
+
+// now returns the current time of the clock of the core.
+func (c *core) now() time.Time {
+	if c.config.Clock != nil {
+		return c.config.Clock.Now()
+	}
+
+	return time.Now()
+}
+
+// afterFunc calls `fn` in its own goroutine once `d` has elapsed on the clock of
+// the core, and returns a function stopping the timer. The ticker of the clock
+// is used, so that test clocks control the time windows of the core as well.
+func (c *core) afterFunc(d time.Duration, fn func()) func() {
+	if c.config.Clock == nil {
+		timer := time.AfterFunc(d, fn)
+		return func() { timer.Stop() }
+	}
+
+	if d <= 0 {
+		d = time.Nanosecond
+	}
+
+	ticker := c.config.Clock.NewTicker(d)
+	stop := make(chan struct{})
+	go func() {
+		defer ticker.Stop()
+
+		select {
+		case <-ticker.C:
+			fn()
+		case <-stop:
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(stop) }) }
+}
+
+// withClockAndCaller sets the time and caller of the entry, when the core is
+// configured with a clock or a caller function.
+func (c *core) withClockAndCaller(ent zapcore.Entry) zapcore.Entry {
+	if c.config.Clock != nil {
+		ent.Time = c.config.Clock.Now()
+	}
+
+	if c.config.Caller != nil {
+		ent.Caller = c.config.Caller(ent)
+	}
+
+	return ent
+}
@@ -0,0 +1,110 @@
+package zapdriver
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// fixedClock is a clock which always returns the same time.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time                         { return time.Time(c) }
+func (c fixedClock) NewTicker(d time.Duration) *time.Ticker { return time.NewTicker(d) }
+
+// manualClock is a clock which only advances with Add, firing the tickers it
+// created once their period has elapsed.
+type manualClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	tickers []*manualTicker
+}
+
+type manualTicker struct {
+	c    chan time.Time
+	next time.Time
+	d    time.Duration
+}
+
+func newManualClock() *manualClock {
+	return &manualClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+func (c *manualClock) NewTicker(d time.Duration) *time.Ticker {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	t := &manualTicker{c: make(chan time.Time, 1), next: c.now.Add(d), d: d}
+	c.tickers = append(c.tickers, t)
+
+	return &time.Ticker{C: t.c}
+}
+
+// Add advances the clock by `d`.
+func (c *manualClock) Add(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		for !t.next.After(c.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.d)
+		}
+	}
+}
+
+func TestClockAndCaller(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(NewProductionEncoderConfig()), zapcore.AddSync(buf), zapcore.DebugLevel)
+
+	logger := zap.New(core, zap.AddCaller(), WrapCore(
+		ReportAllErrors(true),
+		ServiceName("golden"),
+		Clock(fixedClock(time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC))),
+		Caller(func(zapcore.Entry) zapcore.EntryCaller {
+			return zapcore.EntryCaller{Defined: true, File: "app/main.go", Line: 42, Function: "main.main"}
+		}),
+	))
+
+	logger.Error("failed")
+
+	assert.JSONEq(t, `{
+		"severity": "ERROR",
+		"timestamp": "2020-01-02T03:04:05.000000006Z",
+		"caller": "app/main.go:42",
+		"message": "failed",
+		"logging.googleapis.com/labels": {},
+		"logging.googleapis.com/sourceLocation": {"file": "app/main.go", "line": "42", "function": "main.main"},
+		"serviceContext": {"service": "golden", "version": ""},
+		"context": {"reportLocation": {"filePath": "app/main.go", "lineNumber": 42, "functionName": "main.main"}}
+	}`, buf.String())
+}
+
+func TestClock_Windows(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &core{permLabels: NewLabelSet()}
+	SuppressRepeats(time.Minute)(c)
+	Clock(fixedClock(now))(c)
+
+	assert.Equal(t, now, c.repeats.now())
+}
@@ -0,0 +1,90 @@
+package zapdriver
+
+import (
+	"os"
+	"path"
+	"sync"
+)
+
+// cloudRunResourceType is the monitored resource type of Cloud Run services.
+const cloudRunResourceType = "cloud_run_revision"
+
+// zapdriver core option to detect whether the service runs on Cloud Run, and if
+// so, add the service, revision, configuration, region and instance ID as
+// labels, and use the `cloud_run_revision` monitored resource (which is only
+// used by API writers, see `ResourceOf()`). The region and instance ID are read
+// from the metadata server when the first entry is written (or the resource is
+// first requested), so building the core does not wait for it. The labels have
+// a lower precedence than the other permanent labels
+func DetectCloudRun() func(*core) {
+	return func(c *core) {
+		if os.Getenv("K_SERVICE") == "" {
+			return
+		}
+
+		platform := &cloudRun{}
+		c.config.PlatformLabels = platform
+		c.config.Resource = detectedResource(func() *MonitoredResource {
+			return platform.get().resource
+		})
+	}
+}
+
+// cloudRun holds the Cloud Run resource and labels, detected on first use.
+type cloudRun struct {
+	once     sync.Once
+	resource *MonitoredResource
+	labels   map[string]string
+}
+
+// get detects the resource and the labels on the first call.
+func (d *cloudRun) get() *cloudRun {
+	d.once.Do(func() {
+		d.resource, d.labels = detectCloudRun()
+	})
+
+	return d
+}
+
+// detectCloudRun returns the Cloud Run monitored resource and the labels
+// matching the ones added by the Cloud Run platform, or nil when not running on
+// Cloud Run.
+//
+// see: https://cloud.google.com/run/docs/container-contract#env-vars
+func detectCloudRun() (*MonitoredResource, map[string]string) {
+	service := os.Getenv("K_SERVICE")
+	if service == "" {
+		return nil, nil
+	}
+
+	resource := &MonitoredResource{
+		Type: cloudRunResourceType,
+		Labels: map[string]string{
+			"service_name":       service,
+			"revision_name":      os.Getenv("K_REVISION"),
+			"configuration_name": os.Getenv("K_CONFIGURATION"),
+		},
+	}
+
+	if project, err := metadataValue("project/project-id"); err == nil {
+		resource.Labels["project_id"] = project
+	}
+
+	// The region is formatted as `projects/<number>/regions/<region>`.
+	if region, err := metadataValue("instance/region"); err == nil {
+		resource.Labels["location"] = path.Base(region)
+	}
+
+	labels := map[string]string{}
+	for k, v := range resource.Labels {
+		if k != "project_id" && v != "" {
+			labels[k] = v
+		}
+	}
+
+	if id, err := metadataValue("instance/id"); err == nil {
+		labels["instanceId"] = id
+	}
+
+	return resource, labels
+}
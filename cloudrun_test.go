@@ -0,0 +1,100 @@
+package zapdriver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newMetadataServer(t *testing.T, values map[string]string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v, ok := values[strings.TrimPrefix(r.URL.Path, "/computeMetadata/v1/")]
+		if !ok || r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(v))
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+}
+
+func TestDetectCloudRun(t *testing.T) {
+	newMetadataServer(t, map[string]string{
+		"project/project-id": "my-project",
+		"instance/region":    "projects/123/regions/europe-west1",
+		"instance/id":        "00bf4bf02d",
+	})
+	t.Setenv("K_SERVICE", "my-service")
+	t.Setenv("K_REVISION", "my-service-00001-abc")
+	t.Setenv("K_CONFIGURATION", "my-service")
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	c := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+	DetectCloudRun()(c)
+	assert.Empty(t, c.permLabels.store, "the labels are detected on first use")
+
+	require.NotNil(t, c.config.Resource.get())
+	assert.Equal(t, "cloud_run_revision", c.config.Resource.get().Type)
+	assert.Equal(t, map[string]string{
+		"service_name":       "my-service",
+		"revision_name":      "my-service-00001-abc",
+		"configuration_name": "my-service",
+		"project_id":         "my-project",
+		"location":           "europe-west1",
+	}, c.config.Resource.get().Labels)
+
+	require.NoError(t, c.Write(zapcore.Entry{}, nil))
+
+	labels := logs.All()[0].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, "my-service", labels["service_name"])
+	assert.Equal(t, "europe-west1", labels["location"])
+	assert.Equal(t, "00bf4bf02d", labels["instanceId"])
+	assert.NotContains(t, labels, "project_id")
+}
+
+func TestDetectCloudRun_NotCloudRun(t *testing.T) {
+	t.Setenv("K_SERVICE", "")
+
+	c := &core{permLabels: NewLabelSet()}
+	DetectCloudRun()(c)
+
+	assert.Nil(t, c.config.Resource)
+	assert.Empty(t, c.permLabels.store)
+}
+
+func TestDetectCloudRun_Lazy(t *testing.T) {
+	t.Setenv("K_SERVICE", "my-service")
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte("projects/123/regions/europe-west1"))
+	}))
+	defer server.Close()
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(DetectCloudRun()))
+	assert.Zero(t, atomic.LoadInt32(&requests), "the metadata server is queried on first use")
+
+	logger.Info("hello")
+	assert.NotZero(t, atomic.LoadInt32(&requests))
+
+	labels := logs.All()[0].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, "my-service", labels["service_name"])
+	assert.Equal(t, "europe-west1", labels["location"])
+}
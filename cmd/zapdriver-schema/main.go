@@ -0,0 +1,49 @@
+// Command zapdriver-schema prints the JSON Schema of the entries written by a
+// zapdriver logger (see `zapdriver.Schema()`), for the configuration given by
+// its flags, e.g. to generate BigQuery table schemas of log sinks:
+//
+//	go run github.com/gridwise/zapdriver/cmd/zapdriver-schema -service my-service -bigquery > schema.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/gridwise/zapdriver"
+)
+
+func main() {
+	var (
+		service      = flag.String("service", "", "service name added to all entries")
+		reportErrors = flag.Bool("report-errors", false, "report all errors to Error Reporting")
+		bigquery     = flag.Bool("bigquery", false, "write BigQuery-compatible keys")
+		explorer     = flag.Bool("explorer-links", false, "add Logs Explorer links to error entries")
+		messageKey   = flag.String("message-key", "", "key of the message, instead of \"message\"")
+		severityKey  = flag.String("severity-key", "", "key of the severity, instead of \"severity\"")
+	)
+	flag.Parse()
+
+	keys := []func(*zapcore.EncoderConfig){}
+	if *messageKey != "" {
+		keys = append(keys, zapdriver.MessageKey(*messageKey))
+	}
+	if *severityKey != "" {
+		keys = append(keys, zapdriver.SeverityKey(*severityKey))
+	}
+
+	schema, err := zapdriver.Schema(zapdriver.EncoderKeys(keys...),
+		zapdriver.ServiceName(*service),
+		zapdriver.ReportAllErrors(*reportErrors),
+		zapdriver.BigQueryCompatible(*bigquery),
+		zapdriver.LogsExplorerLinks(*explorer),
+	)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Println(string(schema))
+}
@@ -1,10 +1,22 @@
 package zapdriver
 
 import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+// standardOutput and standardError are the streams the loggers built from a
+// Config write to.
+var (
+	standardOutput zapcore.WriteSyncer = zapcore.Lock(os.Stdout)
+	standardError  zapcore.WriteSyncer = zapcore.Lock(os.Stderr)
+)
+
 // NewProductionEncoderConfig returns an opinionated EncoderConfig for
 // production environments.
 func NewProductionEncoderConfig() zapcore.EncoderConfig {
@@ -17,6 +29,35 @@ func NewDevelopmentEncoderConfig() zapcore.EncoderConfig {
 	return encoderConfig
 }
 
+// EncoderKeys returns the production encoder configuration, with the key names
+// changed by `options` (see `MessageKey()` and `SeverityKey()`), for ingestion
+// pipelines expecting other key names than Stackdriver, such as `msg` and
+// `level`. The other keys and encoders are left untouched.
+func EncoderKeys(options ...func(*zapcore.EncoderConfig)) zapcore.EncoderConfig {
+	cfg := NewProductionEncoderConfig()
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	return cfg
+}
+
+// zapdriver encoder keys option to write the message of the entries in `key`,
+// instead of `message`
+func MessageKey(key string) func(*zapcore.EncoderConfig) {
+	return func(cfg *zapcore.EncoderConfig) {
+		cfg.MessageKey = key
+	}
+}
+
+// zapdriver encoder keys option to write the severity of the entries in `key`,
+// instead of `severity`
+func SeverityKey(key string) func(*zapcore.EncoderConfig) {
+	return func(cfg *zapcore.EncoderConfig) {
+		cfg.LevelKey = key
+	}
+}
+
 // NewProductionConfig is a reasonable production logging configuration.
 // Logging is enabled at InfoLevel and above.
 //
@@ -53,3 +94,235 @@ func NewDevelopmentConfig() zap.Config {
 		ErrorOutputPaths: []string{"stderr"},
 	}
 }
+
+// Config is a declarative way to construct a zapdriver logger, including the
+// configuration of the zapdriver core. It mirrors the ergonomics of
+// `zap.Config`, and can be loaded from the environment using `ConfigFromEnv()`.
+type Config struct {
+	// Level is the minimum enabled logging level.
+	Level zapcore.Level
+
+	// Development puts the logger in development mode, which makes DPanicLevel
+	// logs panic, uses the development encoder (`NewDevelopmentEncoder()`) and
+	// includes stacktraces on logs of WarnLevel and above.
+	Development bool
+
+	// Sampling enables sampling of repeated entries, with the same settings as
+	// `NewProductionConfig()`, using the sampler of the core (see
+	// `SampleByLabel()`), which never samples reported errors.
+	Sampling bool
+
+	// SplitStreams writes entries below ErrorLevel to standard output, and all
+	// other entries to standard error. When false, all entries are written to
+	// standard error.
+	SplitStreams bool
+
+	// ReportAllErrors reports all logs with level error or above to
+	// Stackdriver's Error Reporting.
+	ReportAllErrors bool
+
+	// ServiceName is added as `ServiceContext()` to all logs when set.
+	ServiceName string
+
+	// ServiceVersion is added as `ServiceContext()` to all logs when set.
+	ServiceVersion string
+
+	// ServiceLocation determines whether ServiceName and ServiceVersion are
+	// added as `ServiceContext()`, as labels, or both (see
+	// `ServiceContextLocation()`).
+	ServiceLocation ServiceLocation
+
+	// Labels are added as permanent labels to all logs.
+	Labels map[string]string
+
+	// MessageKey and SeverityKey are the keys of the message and severity of
+	// the entries written by the production encoder, when set (see
+	// `EncoderKeys()`).
+	MessageKey  string
+	SeverityKey string
+
+	// options are the additional core options of the environment presets.
+	options []func(*core)
+}
+
+// NewConfig returns the default configuration, matching the behavior of
+// `NewProduction()`.
+func NewConfig() Config {
+	return Config{
+		Level:    zapcore.InfoLevel,
+		Sampling: true,
+	}
+}
+
+// ConfigFromEnv returns the default configuration, overridden by the following
+// environment variables (when set):
+//
+//	ZAPDRIVER_DEVELOPMENT       - development mode, with DebugLevel and no sampling
+//	ZAPDRIVER_LEVEL             - Zap level (e.g. "debug") or severity (e.g. "WARNING")
+//	ZAPDRIVER_SAMPLING          - sampling of repeated entries
+//	ZAPDRIVER_SPLIT_STREAMS     - write entries below ErrorLevel to standard output
+//	ZAPDRIVER_REPORT_ALL_ERRORS - report all errors to Error Reporting
+//	ZAPDRIVER_SERVICE_NAME      - service name (defaults to K_SERVICE)
+//	ZAPDRIVER_SERVICE_VERSION   - service version (defaults to K_REVISION)
+//	ZAPDRIVER_LABELS            - permanent labels, formatted as "key=value,key=value"
+//	ZAPDRIVER_MESSAGE_KEY       - key of the message of the entries
+//	ZAPDRIVER_SEVERITY_KEY      - key of the severity of the entries
+//
+// Boolean values are parsed using `strconv.ParseBool`.
+func ConfigFromEnv() (Config, error) {
+	config := NewConfig()
+
+	var err error
+	lookupBool := func(key string, value *bool) {
+		if v := os.Getenv(key); v != "" && err == nil {
+			*value, err = strconv.ParseBool(v)
+			if err != nil {
+				err = fmt.Errorf("zapdriver: invalid %s: %w", key, err)
+			}
+		}
+	}
+
+	lookupBool("ZAPDRIVER_DEVELOPMENT", &config.Development)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if config.Development {
+		config.Level = zapcore.DebugLevel
+		config.Sampling = false
+	}
+
+	if v := os.Getenv("ZAPDRIVER_LEVEL"); v != "" {
+		config.Level, err = parseLevel(v)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
+	lookupBool("ZAPDRIVER_SAMPLING", &config.Sampling)
+	lookupBool("ZAPDRIVER_SPLIT_STREAMS", &config.SplitStreams)
+	lookupBool("ZAPDRIVER_REPORT_ALL_ERRORS", &config.ReportAllErrors)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config.ServiceName = firstEnv("ZAPDRIVER_SERVICE_NAME", "K_SERVICE")
+	config.ServiceVersion = firstEnv("ZAPDRIVER_SERVICE_VERSION", "K_REVISION")
+	config.MessageKey = os.Getenv("ZAPDRIVER_MESSAGE_KEY")
+	config.SeverityKey = os.Getenv("ZAPDRIVER_SEVERITY_KEY")
+
+	if v := os.Getenv("ZAPDRIVER_LABELS"); v != "" {
+		config.Labels = map[string]string{}
+		for _, pair := range strings.Split(v, ",") {
+			key, value := split(pair, "=")
+			if key = strings.TrimSpace(key); key == "" {
+				return Config{}, fmt.Errorf("zapdriver: invalid ZAPDRIVER_LABELS: %q", v)
+			}
+
+			config.Labels[key] = strings.TrimSpace(value)
+		}
+	}
+
+	return config, nil
+}
+
+// Build constructs a logger from the configuration, with the zapdriver core.
+func (c Config) Build(options ...zap.Option) (*zap.Logger, error) {
+	level := zap.NewAtomicLevelAt(c.Level)
+
+	var enc zapcore.Encoder
+	if c.Development {
+		enc = NewDevelopmentEncoder()
+	} else {
+		enc = zapcore.NewJSONEncoder(c.encoderConfig())
+	}
+
+	stderr := standardError
+
+	var zcore zapcore.Core
+	if c.SplitStreams {
+		// The zapdriver core only checks the entries against the combined
+		// level, so the streams are routed by the level-aware tee core.
+		zcore = &teeCore{
+			primary: zapcore.NewCore(enc, standardOutput, zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+				return level.Enabled(l) && l < zapcore.ErrorLevel
+			})),
+			secondary: zapcore.NewCore(enc.Clone(), stderr, zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+				return level.Enabled(l) && l >= zapcore.ErrorLevel
+			})),
+		}
+	} else {
+		zcore = zapcore.NewCore(enc, stderr, level)
+	}
+
+	coreOptions := c.coreOptions()
+	if c.Sampling {
+		// The zapdriver core checks the entries itself, so a zap sampler
+		// wrapped by it would never be asked to check them.
+		coreOptions = append([]func(*core){SampleByLabel("", 100, 100)}, coreOptions...)
+	}
+
+	opts := []zap.Option{zap.ErrorOutput(stderr), zap.AddCaller(), WrapCore(coreOptions...)}
+	if c.Development {
+		opts = append(opts, zap.Development(), zap.AddStacktrace(zapcore.WarnLevel))
+	} else {
+		opts = append(opts, zap.AddStacktrace(zapcore.ErrorLevel))
+	}
+
+	return zap.New(zcore, append(opts, options...)...), nil
+}
+
+// encoderConfig returns the production encoder configuration, with the key
+// names of the configuration.
+func (c Config) encoderConfig() zapcore.EncoderConfig {
+	var options []func(*zapcore.EncoderConfig)
+	if c.MessageKey != "" {
+		options = append(options, MessageKey(c.MessageKey))
+	}
+	if c.SeverityKey != "" {
+		options = append(options, SeverityKey(c.SeverityKey))
+	}
+
+	return EncoderKeys(options...)
+}
+
+// coreOptions returns the zapdriver core options matching the configuration.
+func (c Config) coreOptions() []func(*core) {
+	options := []func(*core){
+		ReportAllErrors(c.ReportAllErrors),
+		ServiceName(c.ServiceName),
+		ServiceVersion(c.ServiceVersion),
+		ServiceContextLocation(c.ServiceLocation),
+		DefaultLabels(c.Labels),
+	}
+	if c.MessageKey != "" || c.SeverityKey != "" {
+		options = append(options, ReservedEncoderKeys(c.encoderConfig()))
+	}
+
+	return append(options, c.options...)
+}
+
+// parseLevel parses either a Zap level name, or a Stackdriver severity name.
+func parseLevel(name string) (zapcore.Level, error) {
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(name)); err == nil {
+		return level, nil
+	}
+
+	severity, err := ParseSeverity(name)
+	if err != nil {
+		return level, fmt.Errorf("zapdriver: invalid ZAPDRIVER_LEVEL: %q", name)
+	}
+
+	return severity.Level(), nil
+}
+
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
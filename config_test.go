@@ -0,0 +1,147 @@
+package zapdriver
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestConfigFromEnv_Defaults(t *testing.T) {
+	for _, key := range []string{"ZAPDRIVER_DEVELOPMENT", "ZAPDRIVER_LEVEL", "ZAPDRIVER_SAMPLING",
+		"ZAPDRIVER_SPLIT_STREAMS", "ZAPDRIVER_REPORT_ALL_ERRORS", "ZAPDRIVER_SERVICE_NAME",
+		"ZAPDRIVER_SERVICE_VERSION", "ZAPDRIVER_LABELS", "ZAPDRIVER_MESSAGE_KEY", "ZAPDRIVER_SEVERITY_KEY",
+		"K_SERVICE", "K_REVISION"} {
+		t.Setenv(key, "")
+	}
+
+	config, err := ConfigFromEnv()
+	require.NoError(t, err)
+
+	assert.Equal(t, NewConfig(), config)
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("ZAPDRIVER_DEVELOPMENT", "true")
+	t.Setenv("ZAPDRIVER_LEVEL", "WARNING")
+	t.Setenv("ZAPDRIVER_SAMPLING", "")
+	t.Setenv("ZAPDRIVER_SPLIT_STREAMS", "1")
+	t.Setenv("ZAPDRIVER_REPORT_ALL_ERRORS", "true")
+	t.Setenv("ZAPDRIVER_SERVICE_NAME", "")
+	t.Setenv("K_SERVICE", "my-service")
+	t.Setenv("ZAPDRIVER_SERVICE_VERSION", "v1")
+	t.Setenv("ZAPDRIVER_LABELS", "env=prod, team = core")
+	t.Setenv("ZAPDRIVER_MESSAGE_KEY", "msg")
+	t.Setenv("ZAPDRIVER_SEVERITY_KEY", "")
+
+	config, err := ConfigFromEnv()
+	require.NoError(t, err)
+
+	assert.Equal(t, Config{
+		Level:           zapcore.WarnLevel,
+		Development:     true,
+		SplitStreams:    true,
+		ReportAllErrors: true,
+		ServiceName:     "my-service",
+		ServiceVersion:  "v1",
+		Labels:          map[string]string{"env": "prod", "team": "core"},
+		MessageKey:      "msg",
+	}, config)
+}
+
+func TestEncoderKeys(t *testing.T) {
+	cfg := EncoderKeys(MessageKey("msg"), SeverityKey("level"))
+
+	assert.Equal(t, "msg", cfg.MessageKey)
+	assert.Equal(t, "level", cfg.LevelKey)
+	assert.Equal(t, encoderConfig.TimeKey, cfg.TimeKey)
+
+	buf, err := zapcore.NewJSONEncoder(cfg).EncodeEntry(zapcore.Entry{Level: zapcore.WarnLevel, Message: "hello"}, nil)
+	require.NoError(t, err)
+
+	assert.Contains(t, buf.String(), `"level":"WARNING"`)
+	assert.Contains(t, buf.String(), `"msg":"hello"`)
+
+	assert.Equal(t, NewProductionEncoderConfig().MessageKey, EncoderKeys().MessageKey)
+}
+
+func TestConfigFromEnv_Invalid(t *testing.T) {
+	var tests = map[string]string{
+		"ZAPDRIVER_DEVELOPMENT": "maybe",
+		"ZAPDRIVER_LEVEL":       "loud",
+		"ZAPDRIVER_LABELS":      "=value",
+	}
+
+	for key, value := range tests {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+
+			_, err := ConfigFromEnv()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestConfig_Build(t *testing.T) {
+	config := NewConfig()
+	config.SplitStreams = true
+	config.ServiceName = "my-service"
+	config.Labels = map[string]string{"env": "prod"}
+
+	logger, err := config.Build(zap.Fields(zap.String("hello", "world")))
+	require.NoError(t, err)
+
+	c, ok := logger.Core().(*core)
+	require.True(t, ok)
+	assert.Equal(t, "my-service", c.config.ServiceName)
+	assert.Equal(t, "prod", c.permLabels.store["env"])
+	assert.False(t, logger.Core().Enabled(zapcore.DebugLevel))
+}
+
+// captureStreams redirects the standard streams of the loggers built from a
+// Config to buffers, for the duration of the test.
+func captureStreams(t *testing.T) (*bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := standardOutput, standardError
+	t.Cleanup(func() { standardOutput, standardError = stdout, stderr })
+
+	out, err := &bytes.Buffer{}, &bytes.Buffer{}
+	standardOutput, standardError = zapcore.AddSync(out), zapcore.AddSync(err)
+
+	return out, err
+}
+
+func TestConfig_Build_SplitStreams(t *testing.T) {
+	stdout, stderr := captureStreams(t)
+
+	config := NewConfig()
+	config.SplitStreams = true
+
+	logger, err := config.Build()
+	require.NoError(t, err)
+
+	logger.Info("routine")
+	logger.Error("failed")
+
+	assert.Contains(t, stdout.String(), "routine")
+	assert.NotContains(t, stdout.String(), "failed")
+	assert.Contains(t, stderr.String(), "failed")
+	assert.NotContains(t, stderr.String(), "routine")
+}
+
+func TestConfig_Build_Sampling(t *testing.T) {
+	logger, err := NewConfig().Build()
+	require.NoError(t, err)
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger.Core().(*core).Core = debugcore
+
+	for i := 0; i < 250; i++ {
+		logger.Info("hello")
+	}
+
+	assert.Equal(t, 101, logs.Len(), "the first 100 entries, then every 100th")
+}
@@ -0,0 +1,163 @@
+package zapdriver
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// loggerContextKey is the context key under which the logger is stored.
+type loggerContextKey struct{}
+
+// WithLogger returns a copy of the context holding the logger.
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// FromContext returns the logger stored in the context by `WithLogger()` (or
+// the logger middleware). It falls back to the global zap logger when the
+// context holds no logger, so it never returns nil.
+func FromContext(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*zap.Logger); ok {
+		return logger
+	}
+
+	return zap.L()
+}
+
+// RequestContext returns a copy of the request context, holding a child logger
+// of `logger` with the trace context and `httpRequest` fields of the request
+// attached. The reported errors of the child logger also hold the request in
+// their error context (see `ErrorHTTPContext()`).
+//
+// It can be used to inject the logger in routers that don't support net/http
+// middlewares, e.g. in gin:
+//
+//	router.Use(func(c *gin.Context) {
+//		c.Request = c.Request.WithContext(zapdriver.RequestContext(logger, c.Request))
+//	})
+func RequestContext(logger *zap.Logger, r *http.Request) context.Context {
+	return requestContext(logger, r, nil)
+}
+
+// requestContext returns the request context of `RequestContext()`, with the
+// error context reading the response status from `status` when set.
+func requestContext(logger *zap.Logger, r *http.Request, status *int32) context.Context {
+	errorHTTP := newErrorHTTPContext(r)
+	errorHTTP.status = status
+
+	logger = RequestLogger(logger, r).With(HTTP(requestPayload(r)), zap.Object(errorHTTPKey, errorHTTP))
+
+	return WithLogger(r.Context(), logger)
+}
+
+// LoggerMiddleware returns a HTTP middleware which stores a request-scoped
+// logger in the context of every request (see `RequestContext()`), which can
+// be retrieved using `FromContext()`. The error context of the reported errors
+// holds the response status written so far.
+//
+// The middleware can be used directly with routers supporting net/http
+// middlewares (such as chi), or wrapped using `echo.WrapMiddleware()` for echo.
+func LoggerMiddleware(logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r.WithContext(requestContext(logger, r, &sw.status)))
+		})
+	}
+}
+
+// statusWriter records the status code of the response.
+type statusWriter struct {
+	http.ResponseWriter
+
+	status int32
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	atomic.CompareAndSwapInt32(&w.status, 0, int32(status))
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	atomic.CompareAndSwapInt32(&w.status, 0, http.StatusOK)
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements the http.Flusher interface, when the wrapped
+// ResponseWriter does.
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		atomic.CompareAndSwapInt32(&w.status, 0, http.StatusOK)
+		flusher.Flush()
+	}
+}
+
+// Hijack implements the http.Hijacker interface, when the wrapped
+// ResponseWriter does.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the wrapped ResponseWriter, for `http.ResponseController`.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// requestPayload returns the HTTP payload of the request. Unlike `NewHTTP()`,
+// it does not read the request body, so the body is still available to the
+// handler.
+func requestPayload(r *http.Request) *HTTPPayload {
+	return &HTTPPayload{
+		RequestMethod: r.Method,
+		RequestURL:    requestURL(r),
+		UserAgent:     r.UserAgent(),
+		RemoteIP:      remoteIP(r),
+		Referer:       r.Referer(),
+		Protocol:      r.Proto,
+	}
+}
+
+// remoteIP returns the IP address of the client of the request, without the
+// port of `r.RemoteAddr`.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// requestURL returns the absolute URL of the request. On servers, `r.URL`
+// only holds the path and query, so the scheme and host are taken from the
+// connection (or the `X-Forwarded-Proto` header) and the `Host` header.
+func requestURL(r *http.Request) string {
+	if r.URL == nil {
+		return ""
+	}
+	if r.URL.IsAbs() || r.Host == "" {
+		return r.URL.String()
+	}
+
+	u := *r.URL
+	u.Host = r.Host
+	u.Scheme = "http"
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		u.Scheme = proto
+	}
+
+	return u.String()
+}
@@ -0,0 +1,162 @@
+package zapdriver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFromContext(t *testing.T) {
+	t.Parallel()
+
+	assert.Same(t, zap.L(), FromContext(context.Background()))
+
+	logger := zap.NewNop()
+	assert.Same(t, logger, FromContext(WithLogger(context.Background(), logger)))
+}
+
+func TestLoggerMiddleware(t *testing.T) {
+	defer func(d *projectDetector) { detectedProject = d }(detectedProject)
+	detectedProject = &projectDetector{}
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "my-project")
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	handler := LoggerMiddleware(zap.New(debugcore))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Info("hello")
+	}))
+
+	req := httptest.NewRequest("POST", "/path", nil)
+	req.Header.Set(cloudTraceHeader, "105445aa7843bc8bf206b120001000/1;o=1")
+	req.Header.Set("User-Agent", "test")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "projects/my-project/traces/105445aa7843bc8bf206b120001000", fields[traceKey])
+	request := fields["httpRequest"].(map[string]interface{})
+	assert.Equal(t, "POST", request["requestMethod"])
+	assert.Equal(t, "http://example.com/path", request["requestUrl"])
+	assert.Equal(t, "test", request["userAgent"])
+}
+
+func TestErrorHTTPContext(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest("GET", "/path?q=1", nil)
+	req.Header.Set("User-Agent", "test")
+	req.Header.Set("Referer", "https://example.com")
+
+	enc := zapcore.NewMapObjectEncoder()
+	ErrorHTTPContext(req, 503).AddTo(enc)
+
+	assert.Equal(t, map[string]interface{}{
+		"method":             "GET",
+		"url":                "http://example.com/path?q=1",
+		"userAgent":          "test",
+		"referrer":           "https://example.com",
+		"responseStatusCode": 503,
+		"remoteIp":           "192.0.2.1",
+	}, enc.Fields[errorHTTPKey])
+
+	pc, file, line, ok := runtime.Caller(0)
+	got := mergeErrorContext([]zapcore.Field{ErrorReport(pc, file, line, ok), ErrorHTTPContext(req, 0)})
+	require.Len(t, got, 1)
+	assert.Equal(t, 0, got[0].Interface.(*reportContext).HTTPRequest.Status)
+}
+
+func TestLoggerMiddleware_ErrorHTTPContext(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	handler := LoggerMiddleware(zap.New(debugcore, zap.AddCaller(), WrapCore(ReportAllErrors(true))))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Info("hello")
+
+		w.WriteHeader(http.StatusBadGateway)
+		FromContext(r.Context()).Error("upstream failed")
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/path", nil))
+
+	require.Equal(t, 2, logs.Len())
+	assert.NotContains(t, logs.All()[0].ContextMap(), errorHTTPKey)
+
+	fields := logs.All()[1].ContextMap()
+	assert.NotContains(t, fields, errorHTTPKey)
+
+	request := fields[contextKey].(map[string]interface{})["httpRequest"].(map[string]interface{})
+	assert.Equal(t, "POST", request["method"])
+	assert.Equal(t, "http://example.com/path", request["url"])
+	assert.Equal(t, 502, request["responseStatusCode"])
+}
+
+func TestRequestURL(t *testing.T) {
+	t.Parallel()
+
+	tls := httptest.NewRequest("GET", "https://example.com/path?q=1", nil)
+
+	forwarded := httptest.NewRequest("GET", "/path", nil)
+	forwarded.Header.Set("X-Forwarded-Proto", "https")
+
+	tests := map[string]struct {
+		req  *http.Request
+		want string
+	}{
+		"path":      {httptest.NewRequest("GET", "/path?q=1", nil), "http://example.com/path?q=1"},
+		"tls":       {tls, "https://example.com/path?q=1"},
+		"forwarded": {forwarded, "https://example.com/path"},
+		"absolute":  {httptest.NewRequest("GET", "http://other.com/path", nil), "http://other.com/path"},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, requestURL(tt.req))
+		})
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest("GET", "/path", nil)
+	assert.Equal(t, "192.0.2.1", remoteIP(req))
+
+	req.RemoteAddr = "[2001:db8::1]:1234"
+	assert.Equal(t, "2001:db8::1", remoteIP(req))
+
+	req.RemoteAddr = "192.0.2.1"
+	assert.Equal(t, "192.0.2.1", remoteIP(req))
+}
+
+func TestLoggerMiddleware_ResponseWriter(t *testing.T) {
+	t.Parallel()
+
+	var flushed bool
+	handler := LoggerMiddleware(zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		require.True(t, ok)
+		flusher.Flush()
+
+		_, ok = w.(http.Hijacker)
+		require.True(t, ok)
+		_, _, err := w.(http.Hijacker).Hijack()
+		assert.ErrorIs(t, err, http.ErrNotSupported)
+
+		flushed = true
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/path", nil))
+
+	assert.True(t, flushed)
+	assert.True(t, rec.Flushed)
+}
@@ -1,7 +1,7 @@
 package zapdriver
 
 import (
-	"strings"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -18,6 +18,188 @@ type driverConfig struct {
 
 	// ServiceVersion is added as `ServiceVersionContext()` to all logs when set
 	ServiceVersion string
+
+	// ServiceLocation determines whether ServiceName and ServiceVersion are
+	// added as `ServiceContext()`, as labels, or both
+	ServiceLocation ServiceLocation
+
+	// BuildRevision is the VCS revision the binary was built from, used as
+	// ServiceVersion when it is not set, and BuildLabels are the labels added
+	// by `BuildInfoLabels()`
+	BuildRevision string
+	BuildLabels   map[string]string
+
+	// EmbedStack embeds the stack trace of reported errors in the message when
+	// set to true, and PreserveStackField keeps the `stacktrace` field after
+	// embedding it
+	EmbedStack         bool
+	PreserveStackField bool
+
+	// MaxEntrySize is the maximum encoded size of an entry, entries exceeding
+	// this size are reduced using TruncateStrategy when set
+	MaxEntrySize int
+
+	// TruncateStrategy determines how entries exceeding MaxEntrySize are reduced
+	TruncateStrategy TruncateStrategy
+
+	// MaxStackFrames is the maximum number of frames of the stack traces of
+	// reported errors, stack traces exceeding it are reduced using
+	// StackStrategy when set
+	MaxStackFrames int
+
+	// StackStrategy determines how stack traces exceeding MaxStackFrames are
+	// reduced
+	StackStrategy StackStrategy
+
+	// Clock sets the time of the entries, and of the time windows of the core
+	Clock zapcore.Clock
+
+	// Caller resolves the caller of the entries
+	Caller CallerFunc
+
+	// SourcePrefixes are stripped from the file path of the source location
+	SourcePrefixes []string
+
+	// SourceLocationLevel is the minimum level of the entries with a source
+	// location, when set
+	SourceLocationLevel zapcore.LevelEnabler
+
+	// DisableSourceLocation never adds the source location to the entries when
+	// set to true
+	DisableSourceLocation bool
+
+	// StripZapCaller removes the caller from the entries written to the
+	// wrapped core when set to true
+	StripZapCaller bool
+
+	// ShortSourcePaths reduces the file path of the source location to the
+	// package directory and file name when set to true
+	ShortSourcePaths bool
+
+	// LabelPrefixes are the key prefixes of the fields converted to labels,
+	// instead of `labels.`, when set
+	LabelPrefixes []string
+
+	// DynamicLabels are evaluated and added to every log entry
+	DynamicLabels []dynamicLabel
+
+	// PlatformLabels (optionally) are the labels of the platform, detected when
+	// the first entry is written, and added to every log entry
+	PlatformLabels *cloudRun
+
+	// FatalFlushTimeout is the maximum time spent flushing the core after
+	// writing Panic and Fatal entries, when set
+	FatalFlushTimeout time.Duration
+
+	// Escalations raise the level of the matching entries
+	Escalations []escalation
+
+	// SLOs add the burn labels of an SLO to the matching entries
+	SLOs []sloBudget
+
+	// PermanentLabelsFirst gives the labels added using `With()` precedence
+	// over the labels of the log entry with the same key when set to true
+	PermanentLabelsFirst bool
+
+	// SortedLabels encodes the labels of the entries in key order when set to
+	// true
+	SortedLabels bool
+
+	// Resource is the monitored resource the logs originate from, when known,
+	// for the writers sending entries to the Cloud Logging API
+	Resource *lazyResource
+
+	// ErrorFingerprint adds a stable fingerprint to all logs with level error or
+	// above when set to true
+	ErrorFingerprint bool
+
+	// LogsExplorerLinks adds the Logs Explorer URL of the correlated entries to
+	// all logs with level error or above when set to true
+	LogsExplorerLinks bool
+
+	// ClassifyErrors adds classification labels to all logs with errors when
+	// set to true
+	ClassifyErrors bool
+
+	// ErrorKind returns the kind of the errors which are not classified by
+	// zapdriver (e.g. `grpczap.ErrorKind()`), or an empty string
+	ErrorKind func(error) string
+
+	// BigQueryCompatible writes column-safe keys for all fields and labels,
+	// except for the special Stackdriver keys, when set to true
+	BigQueryCompatible bool
+
+	// OnWrite hooks are called with every entry written to the wrapped core
+	OnWrite []func(zapcore.Entry, []zapcore.Field)
+
+	// OnError hooks are called with every error returned by the wrapped core
+	OnError []func(error)
+
+	// IgnoreErrors exclude the entries they match from the errors reported
+	// using ReportAllErrors
+	IgnoreErrors []ErrorMatcher
+
+	// Reporter sends all entries reported to Error Reporting to the API
+	Reporter *ErrorReporter
+
+	// CaptureStackOnError captures the stack of the caller of the entries with
+	// level error or above without a stack trace when set to true
+	CaptureStackOnError bool
+
+	// Renames maps the keys of the fields renamed by the core to their new key
+	Renames map[string]string
+
+	// SampledEnrichment restricts the enrichment of the entries to the entries
+	// of sampled traces, when set
+	SampledEnrichment *sampledEnrichment
+
+	// Summarizer counts the entries with level error or above, and writes
+	// summaries of them
+	Summarizer *ErrorSummarizer
+
+	// Metadata adds the metadata of a provider to every entry when set
+	Metadata *MetadataCache
+
+	// TraceProject qualifies trace IDs without a project when set, and
+	// DetectTraceProject qualifies them with the project detected using
+	// `ProjectID()` otherwise
+	TraceProject       string
+	DetectTraceProject bool
+
+	// AutoTrace is the trace ID added to the entries without a trace context,
+	// when set
+	AutoTrace string
+
+	// Validate checks the special fields of every entry when set to true, and
+	// panics on invalid entries when ValidateStrict is set to true
+	Validate       bool
+	ValidateStrict bool
+
+	// Strict reports the fields misusing labels or reserved keys when set to
+	// true, and panics on them when StrictPanics is set to true
+	Strict       bool
+	StrictPanics bool
+
+	// Transformers rewrite every entry before it is written to the wrapped core
+	Transformers []Transformer
+
+	// ReservedKeyPolicy determines how fields colliding with reserved keys are
+	// handled
+	ReservedKeyPolicy ReservedKeyPolicy
+
+	// EncoderKeys (optionally) holds the keys reserved by the encoder, when
+	// they differ from the production encoder configuration
+	EncoderKeys *zapcore.EncoderConfig
+
+	// ErrorOutput receives internal warnings, defaults to standard error
+	ErrorOutput zapcore.WriteSyncer
+
+	// NestFields groups fields with dot-separated keys into nested objects when
+	// set to true
+	NestFields bool
+
+	// FieldRules remove fields from the entries, depending on their level
+	FieldRules []fieldRule
 }
 
 // Core is a zapdriver specific core wrapped around the default zap core. It
@@ -26,20 +208,75 @@ type core struct {
 	zapcore.Core
 
 	// permLabels is a collection of labels that have been added to the logger
-	// through the use of `With()`. These labels should never be cleared after
-	// logging a single entry, unlike `tempLabel`.
-	permLabels *labels
-
-	// tempLabels keeps a record of all the labels that need to be applied to the
-	// current log entry. Zap serializes log fields at different parts of the
-	// stack, one such location is when calling `core.With` and the other one is
-	// when calling `core.Write`. This makes it impossible to (for example) take
-	// all `labels.xxx` fields, and wrap them in the `labels` namespace in one go.
+	// through the use of `With()`. Zap serializes log fields at different parts
+	// of the stack, one such location is when calling `core.With` and the other
+	// one is when calling `core.Write`. This makes it impossible to (for
+	// example) take all `labels.xxx` fields, and wrap them in the `labels`
+	// namespace in one go.
 	//
-	// Instead, we have to filter out these labels at both locations, and then add
-	// them back in the proper format right before we call `Write` on the original
-	// Zap core.
-	tempLabels *labels
+	// Instead, we have to filter out these labels at both locations, and then
+	// add them back in the proper format right before we call `Write` on the
+	// original Zap core. The labels of the entry itself are only held by the
+	// `Write` call, as the core is shared by all goroutines using the logger.
+	permLabels *LabelSet
+
+	// permNested is a collection of dot-separated fields that have been added to
+	// the logger through the use of `With()`. Like labels, these are held back
+	// until `Write`, so that they can be grouped together with the fields of the
+	// log entry into a single namespace.
+	permNested []zapcore.Field
+
+	// permFiltered is a collection of fields that have been added to the logger
+	// through the use of `With()`, and that are removed from the entries of
+	// some levels. They are held back until `Write`, where the level of the
+	// entry is known.
+	permFiltered []zapcore.Field
+
+	// sampler (optionally) drops high-volume entries. It is shared between the
+	// core and all the cores derived from it using `With()`.
+	sampler *sampler
+
+	// repeats (optionally) suppresses identical error entries. It is shared
+	// between the core and all the cores derived from it using `With()`.
+	repeats *repeats
+
+	// limiter (optionally) limits the number of entries written per label
+	// value. It is shared between the core and all the cores derived from it
+	// using `With()`.
+	limiter *rateLimiter
+
+	// cardinality (optionally) limits the number of distinct values per label
+	// key. It is shared between the core and all the cores derived from it
+	// using `With()`.
+	cardinality *cardinalityGuard
+
+	// queue (optionally) holds entries to be written asynchronously. It is
+	// shared between the core and all the cores derived from it using `With()`.
+	queue *asyncQueue
+
+	// trace is the trace of the trace context added to the logger through the
+	// use of `With()`, and traced is true when there is one.
+	trace  string
+	traced bool
+
+	// sampled is the sampling decision of the trace context added to the
+	// logger through the use of `With()`.
+	sampled bool
+
+	// errorHTTP is the `ErrorHTTPContext()` field added to the logger through
+	// the use of `With()`. It is held back until `Write`, and only merged into
+	// the error context of reported errors.
+	errorHTTP *zapcore.Field
+
+	// bare (optionally) writes the entries needing none of the zapdriver
+	// features (see `writesFast()`). It is nil when the options of the core
+	// rewrite the entries.
+	bare *bareCore
+
+	// root is the core created by `WrapCore()`, from which the core is derived
+	// using `With()`. It writes the entries produced by the core itself (such
+	// as summaries), which belong to none of the derived loggers.
+	root *core
 
 	// Configuration for the zapdriver core
 	config driverConfig
@@ -69,44 +306,135 @@ func ServiceVersion(version string) func(*core) {
 	}
 }
 
+// zapdriver core option to embed the stack trace of reported errors in the
+// message when set to true, in the format of the Go runtime parsed by Error
+// Reporting, instead of the `stacktrace` field. The field is removed, unless
+// `PreserveStackField()` is set
+func EmbedStack(enabled bool) func(*core) {
+	return func(c *core) {
+		c.config.EmbedStack = enabled
+	}
+}
+
+// zapdriver core option to keep the `stacktrace` field of reported errors when
+// set to true, after embedding their stack trace in the message (see
+// `EmbedStack()`)
+func PreserveStackField(preserve bool) func(*core) {
+	return func(c *core) {
+		c.config.PreserveStackField = preserve
+	}
+}
+
+// zapdriver core option to group fields with dot-separated keys (e.g.
+// `db.query` and `db.rows`) into nested objects (e.g. `db`) when set to true
+func NestDottedKeys(nest bool) func(*core) {
+	return func(c *core) {
+		c.config.NestFields = nest
+	}
+}
+
 // WrapCore returns a `zap.Option` that wraps the default core with the
 // zapdriver one.
 func WrapCore(options ...func(*core)) zap.Option {
 	return zap.WrapCore(func(c zapcore.Core) zapcore.Core {
-		newcore := &core{
-			Core:       c,
-			permLabels: newLabels(),
-			tempLabels: newLabels(),
-		}
-		for _, option := range options {
-			option(newcore)
-		}
-		return newcore
+		return newCore(c, options)
 	})
 }
 
+// newCore returns a zapdriver core wrapping `c`, configured with `options`.
+func newCore(c zapcore.Core, options []func(*core)) *core {
+	newcore := &core{
+		Core:       c,
+		permLabels: NewLabelSet(),
+	}
+	for _, option := range options {
+		option(newcore)
+	}
+	if newcore.config.ServiceVersion == "" {
+		newcore.config.ServiceVersion = newcore.config.BuildRevision
+	}
+	newcore.serviceLabels()
+	newcore.root = newcore
+	if newcore.writesFastConfig() {
+		newcore.bare = &bareCore{}
+	}
+
+	return newcore
+}
+
 // With adds structured context to the Core.
 func (c *core) With(fields []zap.Field) zapcore.Core {
-	var lbls *labels
+	if len(c.config.Renames) > 0 {
+		fields = c.renameFields(fields)
+	}
+	if c.config.Strict {
+		if err := strictFields(fields, c.encoderKeys()); err != nil {
+			c.misused("misused fields in logger.With(): %v", err)
+		}
+	}
+	fields = c.applyReservedKeyPolicy(fields)
+	if project := c.traceProject(); project != "" {
+		fields = qualifyTraces(fields, project)
+	}
+	trace, traced := traceOf(fields)
+	if !traced {
+		trace, traced = c.trace, c.traced
+	}
+	sampled, ok := sampledOf(fields)
+	if !ok {
+		sampled = c.sampled
+	}
+
+	permLabels := c.permLabels.Clone()
+	fields = pruneLabels(permLabels, fields)
+
+	var lbls *LabelSet
 	lbls, fields = c.extractLabels(fields)
+	permLabels.Merge(lbls)
+
+	errorHTTP := c.errorHTTP
+	if held, rest := holdErrorHTTPContext(fields); held != nil {
+		errorHTTP, fields = held, rest
+	}
+
+	permFiltered := c.permFiltered
+	if len(c.config.FieldRules) > 0 {
+		var held []zapcore.Field
+		held, fields = c.holdFilteredFields(fields)
+		permFiltered = append(append([]zapcore.Field{}, c.permFiltered...), held...)
+	}
 
-	// copy permLabels
-	permLabels := newLabels()
-	c.permLabels.mutex.RLock()
-	for k, v := range c.permLabels.store {
-		permLabels.store[k] = v
+	permNested := c.permNested
+	if c.config.NestFields {
+		var nested []zapcore.Field
+		nested, fields = extractNestedFields(fields)
+		permNested = append(append([]zapcore.Field{}, c.permNested...), nested...)
 	}
-	c.permLabels.mutex.RUnlock()
 
-	for k, v := range lbls.store {
-		permLabels.store[k] = v
+	if len(c.config.Transformers) > 0 {
+		_, fields = c.transform(zapcore.Entry{}, fields)
+	}
+	if c.config.BigQueryCompatible {
+		fields = bigQueryFields(fields)
 	}
 
 	return &core{
-		Core:       c.Core.With(fields),
-		permLabels: permLabels,
-		tempLabels: newLabels(),
-		config:     c.config,
+		Core:         c.Core.With(fields),
+		permLabels:   permLabels,
+		permNested:   permNested,
+		permFiltered: permFiltered,
+		sampler:      c.sampler,
+		repeats:      c.repeats,
+		limiter:      c.limiter,
+		cardinality:  c.cardinality,
+		queue:        c.queue,
+		trace:        trace,
+		traced:       traced,
+		sampled:      sampled,
+		errorHTTP:    errorHTTP,
+		bare:         c.bare.derive(),
+		root:         c.root,
+		config:       c.config,
 	}
 }
 
@@ -125,23 +453,117 @@ func (c *core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.Check
 }
 
 func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
-	var lbls *labels
+	if c.flushesOnExit(ent) {
+		defer c.flushFatal()
+	}
+
+	ent = c.withClockAndCaller(ent)
+	if c.writesFast(ent, fields) {
+		if c.config.StripZapCaller {
+			ent.Caller = zapcore.EntryCaller{}
+		}
+
+		if err := c.bare.get(c.Core).Write(ent, fields); err != nil {
+			c.onError(err)
+			return err
+		}
+
+		return nil
+	}
+	if c.config.Metadata != nil {
+		fields = c.config.Metadata.withMetadata(fields)
+	}
+	if len(c.config.Renames) > 0 {
+		fields = c.renameFields(fields)
+	}
+	if c.config.Strict {
+		if err := strictFields(fields, c.encoderKeys()); err != nil {
+			c.misused("misused fields in entry %q: %v", ent.Message, err)
+		}
+	}
+	fields = c.applyReservedKeyPolicy(fields)
+	if project := c.traceProject(); project != "" {
+		fields = qualifyTraces(fields, project)
+	}
+	if c.config.AutoTrace != "" {
+		fields = c.withAutoTrace(fields)
+	}
+	ent = withPanicSeverity(ent, fields)
+	if len(c.config.Escalations) > 0 {
+		ent = c.escalate(ent, fields)
+	}
+	if c.config.CaptureStackOnError && ent.Stack == "" && zapcore.ErrorLevel.Enabled(ent.Level) && !embedsStack(ent.Message) {
+		ent.Stack = captureStack()
+	}
+	if c.config.Summarizer != nil && zapcore.ErrorLevel.Enabled(ent.Level) {
+		c.summarizeError(ent, fields)
+	}
+
+	if c.config.ErrorFingerprint && zapcore.ErrorLevel.Enabled(ent.Level) {
+		fingerprint := errorFingerprint(ent, fields)
+		ent.Message = "[" + fingerprint + "] " + ent.Message
+		fields = append(fields[:len(fields):len(fields)], Label(fingerprintLabel, fingerprint))
+	}
+
+	if c.config.ClassifyErrors {
+		fields = c.classifyErrors(fields)
+	}
+
+	var lbls *LabelSet
 	lbls, fields = c.extractLabels(fields)
 
-	lbls.mutex.RLock()
-	c.tempLabels.mutex.Lock()
-	for k, v := range lbls.store {
-		c.tempLabels.store[k] = v
+	if len(c.config.SLOs) > 0 {
+		c.addSLOBurn(ent, fields, lbls)
+	}
+
+	if len(c.config.FieldRules) > 0 {
+		fields = c.filterFields(ent.Level, append(append([]zapcore.Field{}, c.permFiltered...), fields...))
+	}
+
+	if c.config.NestFields {
+		fields = nestFields(append(append([]zapcore.Field{}, c.permNested...), fields...))
+	}
+
+	allLabels := c.allLabels(lbls)
+	if c.cardinality != nil {
+		c.cardinality.guard(allLabels)
+	}
+	if c.sampler != nil && !c.sampler.sample(ent, allLabels, c.isErrorReport(ent, fields)) {
+		return nil
+	}
+
+	if c.limiter != nil && !c.limiter.allow(c, allLabels) {
+		return nil
+	}
+
+	fields = resolveLazyFields(fields)
+
+	if c.repeats != nil && c.repeats.suppress(c, ent, fields, allLabels) {
+		return nil
+	}
+
+	return c.writeLabeled(ent, fields, allLabels)
+}
+
+// writeLabeled writes the entry once it is known to be written, with all its
+// labels.
+func (c *core) writeLabeled(ent zapcore.Entry, fields []zapcore.Field, allLabels *LabelSet) error {
+	enriched := c.config.SampledEnrichment == nil || c.enriches(ent, fields)
+	if !enriched {
+		fields = c.withoutEnrichment(fields, allLabels)
 	}
-	c.tempLabels.mutex.Unlock()
-	lbls.mutex.RUnlock()
 
-	fields = mergeLabelFields(fields, c.allLabels())
-	fields = c.withSourceLocation(ent, fields)
-	if c.config.ServiceName != "" {
+	fields = c.mergeLabelFields(fields, allLabels)
+	if c.config.LogsExplorerLinks && zapcore.ErrorLevel.Enabled(ent.Level) {
+		fields = c.withLogsExplorerLink(fields)
+	}
+	if enriched {
+		fields = c.withSourceLocation(ent, fields)
+	}
+	if c.config.ServiceName != "" && c.writesServiceContext(ent, fields) {
 		fields = c.withServiceContext(c.config.ServiceName, c.config.ServiceVersion, fields)
 	}
-	if c.config.ReportAllErrors && zapcore.ErrorLevel.Enabled(ent.Level) {
+	if c.config.ReportAllErrors && zapcore.ErrorLevel.Enabled(ent.Level) && !c.ignoresError(ent, fields) {
 		fields = c.withErrorReport(ent, fields)
 		if c.config.ServiceName == "" {
 			// A service name was not set but error report needs it
@@ -149,69 +571,190 @@ func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
 			fields = c.withServiceContext("unknown", c.config.ServiceVersion, fields)
 		}
 	}
+	if c.errorHTTP != nil {
+		fields = c.withErrorHTTPContext(fields)
+	}
+	fields = mergeErrorContext(fields)
+	reported := c.isErrorReport(ent, fields)
+	if ((c.config.EmbedStack && ent.Stack != "") || c.config.Reporter != nil) && reported {
+		if c.config.EmbedStack && ent.Stack != "" {
+			ent.Message = embedStack(ent.Message, ent.Stack)
+			if !c.config.PreserveStackField {
+				ent.Stack = ""
+			}
+		}
+
+		if c.config.Reporter != nil {
+			c.config.Reporter.Report(errorEvent(ent, fields))
+			fields = withoutErrorContext(fields)
+		}
+	}
+
+	if c.config.StripZapCaller {
+		ent.Caller = zapcore.EntryCaller{}
+	}
 
-	c.tempLabels.reset()
+	if c.config.MaxStackFrames > 0 && reported {
+		entries, parts := c.limitStack(ent, fields)
+		for i := range entries {
+			if err := c.finish(entries[i], parts[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
 
-	return c.Core.Write(ent, fields)
+	return c.finish(ent, fields)
+}
+
+// finish transforms and validates the entry, and writes it after reducing its
+// size if needed.
+func (c *core) finish(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent, fields = c.transform(ent, fields)
+	if c.config.BigQueryCompatible {
+		fields = bigQueryFields(fields)
+	}
+	if c.config.Validate {
+		c.validate(ent, fields)
+	}
+
+	if c.config.MaxEntrySize > 0 {
+		entries, parts := c.limitEntry(ent, fields)
+		for i := range entries {
+			if err := c.write(entries[i], parts[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return c.write(ent, fields)
 }
 
 // Sync flushes buffered logs (if any).
 func (c *core) Sync() error {
-	return c.Core.Sync()
+	if c.queue != nil {
+		c.queue.flush()
+	}
+
+	err := c.Core.Sync()
+	if err != nil {
+		c.onError(err)
+	}
+
+	return err
+}
+
+// write writes the entry to the wrapped core, or queues it when the core is
+// configured to write asynchronously.
+func (c *core) write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if c.queue == nil {
+		return c.writeNow(ent, fields)
+	}
+
+	if ent.Level > zapcore.DPanicLevel {
+		c.queue.flush()
+		return c.writeNow(ent, fields)
+	}
+
+	if !c.queue.enqueue(c, ent, fields) {
+		return c.writeNow(ent, fields)
+	}
+
+	return nil
 }
 
-func (c *core) allLabels() *labels {
-	lbls := newLabels()
+// writeNow writes the entry to the wrapped core, and calls the write or error
+// hooks depending on the result.
+func (c *core) writeNow(ent zapcore.Entry, fields []zapcore.Field) error {
+	if err := c.Core.Write(ent, fields); err != nil {
+		c.onError(err)
+		return err
+	}
+
+	for _, hook := range c.config.OnWrite {
+		hook(ent, fields)
+	}
+
+	return nil
+}
+
+func (c *core) onError(err error) {
+	for _, hook := range c.config.OnError {
+		hook(err)
+	}
+}
+
+// allLabels returns the labels of the entry, merged with the labels of the
+// logger. Dynamic and platform labels have the lowest precedence, and the labels of the
+// entry take precedence over the permanent labels, unless the core is
+// configured otherwise (see `PermanentLabelsFirst()`).
+func (c *core) allLabels(entryLabels *LabelSet) *LabelSet {
+	lbls := NewLabelSet()
+	for _, dl := range c.config.DynamicLabels {
+		lbls.Add(dl.key, dl.fn())
+	}
+	if c.config.PlatformLabels != nil {
+		for k, v := range c.config.PlatformLabels.get().labels {
+			lbls.Add(k, v)
+		}
+	}
+
+	return c.mergeEntryLabels(lbls.Merge(c.permLabels), entryLabels)
+}
 
-	lbls.mutex.Lock()
-	c.permLabels.mutex.RLock()
-	for k, v := range c.permLabels.store {
-		lbls.store[k] = v
+// mergeEntryLabels merges labels of the entry into `lbls`, following the
+// precedence of the core.
+func (c *core) mergeEntryLabels(lbls, entryLabels *LabelSet) *LabelSet {
+	if !c.config.PermanentLabelsFirst {
+		return lbls.Merge(entryLabels)
 	}
-	c.permLabels.mutex.RUnlock()
 
-	c.tempLabels.mutex.RLock()
-	for k, v := range c.tempLabels.store {
-		lbls.store[k] = v
+	for k, v := range entryLabels.Map() {
+		if _, ok := c.permLabels.Get(k); !ok {
+			lbls.Add(k, v)
+		}
 	}
-	c.tempLabels.mutex.RUnlock()
-	lbls.mutex.Unlock()
 
 	return lbls
 }
 
-func (c *core) extractLabels(fields []zapcore.Field) (*labels, []zapcore.Field) {
-	lbls := newLabels()
+func (c *core) extractLabels(fields []zapcore.Field) (*LabelSet, []zapcore.Field) {
+	lbls := NewLabelSet()
 	out := []zapcore.Field{}
 
-	lbls.mutex.Lock()
 	for i := range fields {
-		if !isLabelField(fields[i]) {
+		if isLabelsField(fields[i]) {
+			lbls.Merge(fields[i].Interface.(*LabelSet))
+			continue
+		}
+
+		key, ok := c.labelOf(fields[i])
+		if !ok {
 			out = append(out, fields[i])
 			continue
 		}
 
-		lbls.store[strings.Replace(fields[i].Key, "labels.", "", 1)] = fields[i].String
+		lbls.Add(key, labelValue(fields[i]))
 	}
-	lbls.mutex.Unlock()
 
 	return lbls, out
 }
 
 func (c *core) withLabels(fields []zapcore.Field) []zapcore.Field {
-	lbls := newLabels()
+	lbls := NewLabelSet()
 	out := []zapcore.Field{}
 
-	lbls.mutex.Lock()
 	for i := range fields {
-		if isLabelField(fields[i]) {
-			lbls.store[strings.Replace(fields[i].Key, "labels.", "", 1)] = fields[i].String
+		if key, ok := c.labelOf(fields[i]); ok {
+			lbls.Add(key, labelValue(fields[i]))
 			continue
 		}
 
 		out = append(out, fields[i])
 	}
-	lbls.mutex.Unlock()
 
 	return append(out, labelsField(lbls))
 }
@@ -224,11 +767,26 @@ func (c *core) withSourceLocation(ent zapcore.Entry, fields []zapcore.Field) []z
 		}
 	}
 
-	if !ent.Caller.Defined {
+	if !c.addsSourceLocation(ent) {
 		return fields
 	}
 
-	return append(fields, SourceLocation(ent.Caller.PC, ent.Caller.File, ent.Caller.Line, true))
+	source := newSource(ent.Caller.PC, c.sourcePath(ent.Caller.File), ent.Caller.Line, true)
+	if c.config.Caller != nil && ent.Caller.Function != "" {
+		source.Function = ent.Caller.Function
+	}
+
+	return append(fields, zap.Object(sourceKey, source))
+}
+
+// addsSourceLocation returns true if the source location of the caller is added
+// to the entry.
+func (c *core) addsSourceLocation(ent zapcore.Entry) bool {
+	if !ent.Caller.Defined || c.config.DisableSourceLocation {
+		return false
+	}
+
+	return c.config.SourceLocationLevel == nil || c.config.SourceLocationLevel.Enabled(ent.Level)
 }
 
 func (c *core) withServiceContext(name, version string, fields []zapcore.Field) []zapcore.Field {
@@ -242,6 +800,21 @@ func (c *core) withServiceContext(name, version string, fields []zapcore.Field)
 	return append(fields, ServiceContext(name, version))
 }
 
+// isErrorReport returns true if the entry will be reported to Error Reporting.
+func (c *core) isErrorReport(ent zapcore.Entry, fields []zapcore.Field) bool {
+	if c.config.ReportAllErrors && zapcore.ErrorLevel.Enabled(ent.Level) && !c.ignoresError(ent, fields) {
+		return true
+	}
+
+	for i := range fields {
+		if fields[i].Key == contextKey {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (c *core) withErrorReport(ent zapcore.Entry, fields []zapcore.Field) []zapcore.Field {
 	// If the error report was manually set, don't overwrite it
 	for i := range fields {
@@ -254,5 +827,10 @@ func (c *core) withErrorReport(ent zapcore.Entry, fields []zapcore.Field) []zapc
 		return fields
 	}
 
-	return append(fields, ErrorReport(ent.Caller.PC, ent.Caller.File, ent.Caller.Line, true))
+	context := newReportContext(ent.Caller.PC, ent.Caller.File, ent.Caller.Line, true)
+	if c.config.Caller != nil && ent.Caller.Function != "" {
+		context.ReportLocation.Function = ent.Caller.Function
+	}
+
+	return append(fields, zap.Object(contextKey, context))
 }
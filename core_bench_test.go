@@ -0,0 +1,150 @@
+package zapdriver
+
+import (
+	"io"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func newBenchCore(options ...func(*core)) zapcore.Core {
+	c := zapcore.NewCore(
+		zapcore.NewJSONEncoder(NewProductionEncoderConfig()),
+		zapcore.AddSync(io.Discard),
+		zapcore.DebugLevel,
+	)
+
+	return newCore(c, options)
+}
+
+func benchEntry(level zapcore.Level) zapcore.Entry {
+	pc, file, line, ok := runtime.Caller(0)
+
+	return zapcore.Entry{
+		Level:   level,
+		Message: "benchmark",
+		Caller:  zapcore.NewEntryCaller(pc, file, line, ok),
+	}
+}
+
+var benchCases = map[string]struct {
+	options []func(*core)
+	level   zapcore.Level
+	stack   bool
+	fields  []zapcore.Field
+}{
+	"plain": {
+		level:  zapcore.InfoLevel,
+		fields: []zapcore.Field{zap.String("hello", "world")},
+	},
+	"labels": {
+		level:  zapcore.InfoLevel,
+		fields: []zapcore.Field{zap.String("hello", "world"), Label("one", "1"), Label("two", "2")},
+	},
+	"labels map": {
+		level:  zapcore.InfoLevel,
+		fields: []zapcore.Field{zap.String("hello", "world"), LabelsMap(map[string]string{"one": "1", "two": "2"})},
+	},
+	"stacktrace": {
+		level:  zapcore.ErrorLevel,
+		stack:  true,
+		fields: []zapcore.Field{zap.String("hello", "world")},
+	},
+	"error reporting": {
+		options: []func(*core){ReportAllErrors(true), ServiceName("benchmark")},
+		level:   zapcore.ErrorLevel,
+		stack:   true,
+		fields:  []zapcore.Field{zap.String("hello", "world"), Label("one", "1")},
+	},
+}
+
+func BenchmarkWrite(b *testing.B) {
+	for name, bc := range benchCases {
+		bc := bc
+		b.Run(name, func(b *testing.B) {
+			core := newBenchCore(bc.options...).With([]zapcore.Field{Label("perm", "value")})
+			ent := benchEntry(bc.level)
+			if bc.stack {
+				ent.Stack = zap.Stack("").String
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = core.Write(ent, bc.fields)
+			}
+		})
+	}
+}
+
+func BenchmarkWrite_Parallel(b *testing.B) {
+	core := newBenchCore().With([]zapcore.Field{Label("perm", "value")})
+	ent := benchEntry(zapcore.InfoLevel)
+	fields := []zapcore.Field{zap.String("hello", "world"), Label("one", "1")}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = core.Write(ent, fields)
+		}
+	})
+}
+
+// allocBudgets are the maximum number of allocations per Write allowed for each
+// of the benchmark cases. Lower these when the write path is optimized.
+var allocBudgets = map[string]float64{
+	"plain":           16,
+	"labels":          20,
+	"labels map":      18,
+	"stacktrace":      16,
+	"error reporting": 24,
+}
+
+func TestWrite_AllocBudget(t *testing.T) {
+	for name, bc := range benchCases {
+		core := newBenchCore(bc.options...).With([]zapcore.Field{Label("perm", "value")})
+		ent := benchEntry(bc.level)
+		if bc.stack {
+			ent.Stack = zap.Stack("").String
+		}
+
+		allocs := testing.AllocsPerRun(100, func() {
+			_ = core.Write(ent, bc.fields)
+		})
+
+		t.Logf("%s: %v allocs/op", name, allocs)
+		assert.LessOrEqual(t, allocs, allocBudgets[name], name)
+	}
+}
+
+func BenchmarkWrite_FastPath(b *testing.B) {
+	core := newBenchCore(DisableSourceLocation())
+	ent := benchEntry(zapcore.InfoLevel)
+	fields := []zapcore.Field{zap.String("hello", "world")}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = core.Write(ent, fields)
+	}
+}
+
+func TestWrite_FastPathAllocs(t *testing.T) {
+	wrapped := newBenchCore().(*core).Core
+	core := newBenchCore(DisableSourceLocation())
+	ent := benchEntry(zapcore.InfoLevel)
+	fields := []zapcore.Field{zap.String("hello", "world")}
+
+	want := testing.AllocsPerRun(100, func() {
+		_ = wrapped.Write(ent, fields)
+	})
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = core.Write(ent, fields)
+	})
+
+	assert.Equal(t, want, allocs, "the fast path allocates no more than the wrapped core")
+}
@@ -2,6 +2,8 @@ package zapdriver
 
 import (
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -21,7 +23,7 @@ func TestWithLabels(t *testing.T) {
 		Label("two", "value"),
 	}
 
-	labels := newLabels()
+	labels := NewLabelSet()
 	labels.store = map[string]string{"one": "value", "two": "value"}
 
 	want := []zap.Field{
@@ -33,11 +35,10 @@ func TestWithLabels(t *testing.T) {
 }
 
 func TestExtractLabels(t *testing.T) {
-	var lbls *labels
+	var lbls *LabelSet
 	c := &core{
 		Core:       zapcore.NewNopCore(),
-		permLabels: newLabels(),
-		tempLabels: newLabels(),
+		permLabels: NewLabelSet(),
 	}
 
 	fields := []zap.Field{
@@ -157,14 +158,10 @@ func TestWithServiceContext_DoesNotOverwrite(t *testing.T) {
 }
 
 func TestWrite(t *testing.T) {
-	temp := newLabels()
-	temp.store = map[string]string{"one": "1", "two": "2"}
-
 	debugcore, logs := observer.New(zapcore.DebugLevel)
 	core := &core{
 		Core:       debugcore,
-		permLabels: newLabels(),
-		tempLabels: temp,
+		permLabels: NewLabelSet(),
 	}
 
 	fields := []zap.Field{
@@ -184,8 +181,7 @@ func TestWriteDuplicateLabels(t *testing.T) {
 	debugcore, logs := observer.New(zapcore.DebugLevel)
 	core := &core{
 		Core:       debugcore,
-		permLabels: newLabels(),
-		tempLabels: newLabels(),
+		permLabels: NewLabelSet(),
 	}
 
 	fields := []zap.Field{
@@ -202,16 +198,13 @@ func TestWriteDuplicateLabels(t *testing.T) {
 }
 
 func TestWriteConcurrent(t *testing.T) {
-	temp := newLabels()
-	temp.store = map[string]string{"one": "1", "two": "2"}
 	goRoutines := 8
 	counter := int32(10000)
 
 	debugcore, logs := observer.New(zapcore.DebugLevel)
 	core := &core{
 		Core:       debugcore,
-		permLabels: newLabels(),
-		tempLabels: temp,
+		permLabels: NewLabelSet(),
 	}
 
 	fields := []zap.Field{
@@ -240,8 +233,7 @@ func TestWithAndWrite(t *testing.T) {
 	debugcore, logs := observer.New(zapcore.DebugLevel)
 	core := zapcore.Core(&core{
 		Core:       debugcore,
-		permLabels: newLabels(),
-		tempLabels: newLabels(),
+		permLabels: NewLabelSet(),
 	})
 
 	core = core.With([]zapcore.Field{Label("one", "world")})
@@ -258,8 +250,7 @@ func TestWithAndWrite_MultipleEntries(t *testing.T) {
 	debugcore, logs := observer.New(zapcore.DebugLevel)
 	core := zapcore.Core(&core{
 		Core:       debugcore,
-		permLabels: newLabels(),
-		tempLabels: newLabels(),
+		permLabels: NewLabelSet(),
 	})
 
 	core = core.With([]zapcore.Field{Label("one", "world")})
@@ -286,8 +277,7 @@ func TestWithParentNotMutated(t *testing.T) {
 	debugcore, logs := observer.New(zapcore.DebugLevel)
 	core := zapcore.Core(&core{
 		Core:       debugcore,
-		permLabels: newLabels(),
-		tempLabels: newLabels(),
+		permLabels: NewLabelSet(),
 		config: driverConfig{
 			ReportAllErrors: true,
 		},
@@ -315,8 +305,7 @@ func TestWriteReportAllErrors(t *testing.T) {
 	debugcore, logs := observer.New(zapcore.DebugLevel)
 	core := zapcore.Core(&core{
 		Core:       debugcore,
-		permLabels: newLabels(),
-		tempLabels: newLabels(),
+		permLabels: NewLabelSet(),
 		config: driverConfig{
 			ReportAllErrors: true,
 		},
@@ -346,8 +335,7 @@ func TestWriteServiceContext(t *testing.T) {
 	debugcore, logs := observer.New(zapcore.DebugLevel)
 	core := zapcore.Core(&core{
 		Core:       debugcore,
-		permLabels: newLabels(),
-		tempLabels: newLabels(),
+		permLabels: NewLabelSet(),
 		config: driverConfig{
 			ServiceName:    "test service",
 			ServiceVersion: "v0.0.1",
@@ -367,8 +355,7 @@ func TestWriteReportAllErrors_WithServiceContext(t *testing.T) {
 	debugcore, logs := observer.New(zapcore.DebugLevel)
 	core := zapcore.Core(&core{
 		Core:       debugcore,
-		permLabels: newLabels(),
-		tempLabels: newLabels(),
+		permLabels: NewLabelSet(),
 		config: driverConfig{
 			ReportAllErrors: true,
 			ServiceName:     "test service",
@@ -395,8 +382,7 @@ func TestWriteReportAllErrors_InfoLog(t *testing.T) {
 	debugcore, logs := observer.New(zapcore.DebugLevel)
 	core := zapcore.Core(&core{
 		Core:       debugcore,
-		permLabels: newLabels(),
-		tempLabels: newLabels(),
+		permLabels: NewLabelSet(),
 		config: driverConfig{
 			ReportAllErrors: true,
 		},
@@ -414,19 +400,18 @@ func TestWriteReportAllErrors_InfoLog(t *testing.T) {
 }
 
 func TestAllLabels(t *testing.T) {
-	perm := newLabels()
+	perm := NewLabelSet()
 	perm.store = map[string]string{"one": "1", "two": "2", "three": "3"}
 
-	temp := newLabels()
-	temp.store = map[string]string{"one": "ONE", "three": "THREE"}
+	entry := NewLabelSet()
+	entry.store = map[string]string{"one": "ONE", "three": "THREE"}
 
 	core := &core{
 		Core:       zapcore.NewNopCore(),
 		permLabels: perm,
-		tempLabels: temp,
 	}
 
-	out := core.allLabels()
+	out := core.allLabels(entry)
 	require.Len(t, out.store, 3)
 
 	out.mutex.RLock()
@@ -435,3 +420,200 @@ func TestAllLabels(t *testing.T) {
 	assert.Equal(t, out.store["three"], "THREE")
 	out.mutex.RUnlock()
 }
+
+func TestWithSourceLocation_TrimSourcePrefix(t *testing.T) {
+	fields := []zap.Field{zap.String("hello", "world")}
+	pc, file, line, ok := runtime.Caller(0)
+	ent := zapcore.Entry{Caller: zapcore.NewEntryCaller(pc, file, line, ok)}
+
+	c := &core{}
+	TrimSourcePrefix(file[:strings.LastIndex(file, "/")])(c)
+
+	want := []zap.Field{
+		zap.String("hello", "world"),
+		zap.Object(sourceKey, newSource(pc, "core_test.go", line, ok)),
+	}
+
+	assert.Equal(t, want, c.withSourceLocation(ent, fields))
+}
+
+func TestWithSourceLocation_SourceLocationLevel(t *testing.T) {
+	fields := []zap.Field{zap.String("hello", "world")}
+	pc, file, line, ok := runtime.Caller(0)
+
+	c := &core{}
+	SourceLocationLevel(zapcore.WarnLevel)(c)
+
+	ent := zapcore.Entry{Level: zapcore.InfoLevel, Caller: zapcore.NewEntryCaller(pc, file, line, ok)}
+	assert.Equal(t, fields, c.withSourceLocation(ent, fields))
+
+	ent.Level = zapcore.WarnLevel
+	assert.Len(t, c.withSourceLocation(ent, fields), 2)
+}
+
+func TestWrite_DisableSourceLocation(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, zap.AddCaller(), WrapCore(DisableSourceLocation()))
+
+	logger.Info("hello")
+	logger.Info("hello", SourceLocation(runtime.Caller(0)))
+
+	require.Equal(t, 2, logs.Len())
+	assert.NotContains(t, logs.All()[0].ContextMap(), sourceKey)
+	assert.True(t, logs.All()[0].Caller.Defined, "the zap caller is kept")
+	assert.Contains(t, logs.All()[1].ContextMap(), sourceKey)
+}
+
+func TestWrite_StripZapCaller(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, zap.AddCaller(), WrapCore(StripZapCaller(), ReportAllErrors(true)))
+
+	logger.Info("hello")
+	logger.Error("failed")
+
+	require.Equal(t, 2, logs.Len())
+	assert.Contains(t, logs.All()[0].ContextMap(), sourceKey)
+	assert.False(t, logs.All()[0].Caller.Defined)
+
+	report := logs.All()[1].ContextMap()[contextKey].(map[string]interface{})
+	assert.Contains(t, report, "reportLocation")
+	assert.False(t, logs.All()[1].Caller.Defined)
+}
+
+func TestWriteReportAllErrors_Stack(t *testing.T) {
+	var tests = map[string]struct {
+		embed    bool
+		preserve bool
+		message  string
+		stack    string
+	}{
+		"default":   {false, false, "failed", "main.main\n\t/app/main.go:5"},
+		"embedded":  {true, false, "failed\n\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:5", ""},
+		"preserved": {true, true, "failed\n\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:5", "main.main\n\t/app/main.go:5"},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			debugcore, logs := observer.New(zapcore.DebugLevel)
+			core := &core{
+				Core:       debugcore,
+				permLabels: NewLabelSet(),
+				config:     driverConfig{ReportAllErrors: true},
+			}
+			EmbedStack(tt.embed)(core)
+			PreserveStackField(tt.preserve)(core)
+
+			require.NoError(t, core.Write(zapcore.Entry{
+				Level:   zapcore.ErrorLevel,
+				Message: "failed",
+				Caller:  zapcore.NewEntryCaller(runtime.Caller(0)),
+				Stack:   "main.main\n\t/app/main.go:5",
+			}, nil))
+
+			entry := logs.All()[0]
+			assert.Equal(t, tt.message, entry.Message)
+			assert.Equal(t, tt.stack, entry.Stack)
+		})
+	}
+}
+
+// Labels of entries written concurrently by the same logger must not leak into
+// each other, nor be dropped.
+func TestWriteConcurrent_EntryLabels(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore()).With(Label("app", "test"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				logger.Info("hello", zap.Int("goroutine", i), Label("goroutine", strconv.Itoa(i)))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.Equal(t, 8*500, logs.Len())
+	for _, log := range logs.All() {
+		fields := log.ContextMap()
+		want := map[string]interface{}{"app": "test", "goroutine": strconv.FormatInt(fields["goroutine"].(int64), 10)}
+
+		if !assert.Equal(t, want, fields[labelsKey]) {
+			return
+		}
+	}
+}
+
+// Label sets passed as fields are per-entry labels: the core never modifies
+// them, so the same set can be shared by entries written concurrently, along
+// with sets private to each goroutine.
+func TestWriteConcurrent_LabelSetFields(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore()).With(Label("app", "test"))
+
+	shared := NewLabelSet()
+	shared.Add("shared", "true")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				own := NewLabelSet()
+				own.Add("goroutine", strconv.Itoa(i))
+				logger.Info("hello", zap.Int("goroutine", i), shared.Field(), own.Field())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.Equal(t, 8*500, logs.Len())
+	for _, log := range logs.All() {
+		fields := log.ContextMap()
+		want := map[string]interface{}{"app": "test", "shared": "true", "goroutine": strconv.FormatInt(fields["goroutine"].(int64), 10)}
+
+		if !assert.Equal(t, want, fields[labelsKey]) {
+			return
+		}
+	}
+	assert.Equal(t, map[string]string{"shared": "true"}, shared.Map())
+}
+
+// The labels of an entry must not be reset or overwritten by another entry
+// written concurrently by the same logger, while the first one is still being
+// written.
+func TestWriteConcurrent_InterleavedLabels(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	calls := int32(0)
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(DynamicLabel("dynamic", func() string {
+		// Block the first entry while its labels are being collected.
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(entered)
+			<-release
+		}
+
+		return "value"
+	})))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		logger.Info("first", Label("entry", "first"))
+	}()
+
+	<-entered
+	logger.Info("second", Label("entry", "second"))
+	close(release)
+	<-done
+
+	require.Equal(t, 2, logs.Len())
+	for _, log := range logs.All() {
+		assert.Equal(t, map[string]interface{}{"dynamic": "value", "entry": log.Message}, log.ContextMap()[labelsKey])
+	}
+}
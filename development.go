@@ -0,0 +1,143 @@
+package zapdriver
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// Mode determines whether logs are encoded for Stackdriver, or to be read by
+// humans.
+type Mode int
+
+const (
+	// ModeProduction encodes logs as Stackdriver JSON.
+	ModeProduction Mode = iota
+
+	// ModeDevelopment encodes logs using the development encoder.
+	ModeDevelopment
+)
+
+// ModeFromEnv returns the mode set using the `ZAPDRIVER_MODE` environment
+// variable (either "development" or "production"). When unset, the
+// development mode is used if standard error is a terminal, and the process
+// does not run on GCP, so the same code produces Stackdriver JSON in
+// production, and readable output locally.
+func ModeFromEnv() Mode {
+	switch strings.ToLower(os.Getenv("ZAPDRIVER_MODE")) {
+	case "development":
+		return ModeDevelopment
+	case "production":
+		return ModeProduction
+	}
+
+	for _, key := range []string{"K_SERVICE", "KUBERNETES_SERVICE_HOST", "GAE_SERVICE", "FUNCTION_TARGET"} {
+		if os.Getenv(key) != "" {
+			return ModeProduction
+		}
+	}
+
+	if info, err := os.Stderr.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return ModeDevelopment
+	}
+
+	return ModeProduction
+}
+
+// Encoder returns the encoder matching the mode.
+func (m Mode) Encoder() zapcore.Encoder {
+	if m == ModeDevelopment {
+		return NewDevelopmentEncoder()
+	}
+
+	return zapcore.NewJSONEncoder(NewProductionEncoderConfig())
+}
+
+// severityColors are the ANSI escape codes used to colorize the severities.
+var severityColors = map[Severity]string{
+	SeverityDebug:     "\x1b[35m",
+	SeverityInfo:      "\x1b[34m",
+	SeverityNotice:    "\x1b[36m",
+	SeverityWarning:   "\x1b[33m",
+	SeverityError:     "\x1b[31m",
+	SeverityCritical:  "\x1b[1;31m",
+	SeverityAlert:     "\x1b[1;31m",
+	SeverityEmergency: "\x1b[1;31m",
+}
+
+// ColorLevelEncoder maps the Zap log level to the Stackdriver severity, like
+// `EncodeLevel`, colorized for terminals.
+func ColorLevelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	severity := logLevelSeverity[l]
+	enc.AppendString(severityColors[severity] + severity.String() + "\x1b[0m")
+}
+
+// NewDevelopmentEncoder returns a console encoder for local development, which
+// renders entries on a single line, with colorized severities. The special
+// Stackdriver fields (labels, trace, httpRequest, etc.) are rendered with
+// their `logging.googleapis.com/` prefix stripped, and HTTP payloads are
+// summarized (e.g. "GET /path 200 0.25s").
+func NewDevelopmentEncoder() zapcore.Encoder {
+	config := NewDevelopmentEncoderConfig()
+	config.EncodeLevel = ColorLevelEncoder
+	config.EncodeTime = zapcore.TimeEncoderOfLayout("15:04:05.000")
+	config.EncodeDuration = zapcore.StringDurationEncoder
+
+	return &developmentEncoder{Encoder: zapcore.NewConsoleEncoder(config)}
+}
+
+// developmentEncoder rewrites the special Stackdriver fields, before encoding
+// them using the wrapped console encoder.
+type developmentEncoder struct {
+	zapcore.Encoder
+}
+
+func (e *developmentEncoder) Clone() zapcore.Encoder {
+	return &developmentEncoder{Encoder: e.Encoder.Clone()}
+}
+
+func (e *developmentEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	if req, ok := obj.(*HTTPPayload); ok && key == "httpRequest" {
+		e.Encoder.AddString("http", req.summary())
+		return nil
+	}
+
+	return e.Encoder.AddObject(strings.TrimPrefix(key, googleKeyPrefix), obj)
+}
+
+func (e *developmentEncoder) AddString(key, value string) {
+	e.Encoder.AddString(strings.TrimPrefix(key, googleKeyPrefix), value)
+}
+
+func (e *developmentEncoder) AddBool(key string, value bool) {
+	e.Encoder.AddBool(strings.TrimPrefix(key, googleKeyPrefix), value)
+}
+
+func (e *developmentEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	out := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		field.Key = strings.TrimPrefix(field.Key, googleKeyPrefix)
+		if req, ok := field.Interface.(*HTTPPayload); ok && field.Key == "httpRequest" {
+			field = zapcore.Field{Key: "http", Type: zapcore.StringType, String: req.summary()}
+		}
+
+		out[i] = field
+	}
+
+	return e.Encoder.EncodeEntry(ent, out)
+}
+
+// summary returns a human-readable summary of the request.
+func (req *HTTPPayload) summary() string {
+	parts := make([]string, 0, 4)
+	for _, part := range []string{req.RequestMethod, req.RequestURL, strconv.Itoa(req.Status), req.Latency} {
+		if part != "" && part != "0" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,55 @@
+package zapdriver
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestModeFromEnv(t *testing.T) {
+	t.Setenv("ZAPDRIVER_MODE", "development")
+	assert.Equal(t, ModeDevelopment, ModeFromEnv())
+
+	t.Setenv("ZAPDRIVER_MODE", "production")
+	assert.Equal(t, ModeProduction, ModeFromEnv())
+
+	t.Setenv("ZAPDRIVER_MODE", "")
+	t.Setenv("K_SERVICE", "my-service")
+	assert.Equal(t, ModeProduction, ModeFromEnv())
+
+	assert.IsType(t, &developmentEncoder{}, ModeDevelopment.Encoder())
+	assert.NotEqual(t, ModeDevelopment.Encoder(), ModeProduction.Encoder())
+}
+
+func TestDevelopmentEncoder(t *testing.T) {
+	t.Parallel()
+
+	enc := NewDevelopmentEncoder()
+	enc.AddString(traceKey, "projects/p/traces/abc")
+
+	ent := zapcore.Entry{
+		Level:   zapcore.WarnLevel,
+		Time:    time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC),
+		Message: "hello",
+	}
+
+	buf, err := enc.Clone().EncodeEntry(ent, []zapcore.Field{
+		Labels(Label("one", "1")),
+		HTTP(&HTTPPayload{RequestMethod: "GET", RequestURL: "/path", Status: 200, Latency: "0.25s"}),
+		zap.Bool(traceSampledKey, true),
+	})
+	require.NoError(t, err)
+
+	line := buf.String()
+	assert.True(t, strings.HasPrefix(line, "15:04:05.000\t\x1b[33mWARNING\x1b[0m\thello\t"), line)
+	assert.Contains(t, line, `"trace": "projects/p/traces/abc"`)
+	assert.Contains(t, line, `"labels": {"one": "1"}`)
+	assert.Contains(t, line, `"http": "GET /path 200 0.25s"`)
+	assert.Contains(t, line, `"trace_sampled": true`)
+	assert.Equal(t, 1, strings.Count(line, "\n"))
+}
@@ -0,0 +1,103 @@
+package zapdriver
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	diagnosticKey = "logging.googleapis.com/diagnostic"
+
+	// modulePath is the import path of the zapdriver module, used to find its
+	// version in the build information of the binary.
+	modulePath = "github.com/gridwise/zapdriver"
+
+	// instrumentationName is the name of the library in the diagnostic entry.
+	// Cloud Logging expects names prefixed with the language of the library.
+	instrumentationName = "go-zapdriver"
+)
+
+// diagnosticOnce ensures the diagnostic entry is written once per process.
+var diagnosticOnce sync.Once
+
+// Diagnostic adds the instrumentation information of zapdriver (its name and
+// version) to the log entry, in the `logging.googleapis.com/diagnostic` field
+// written by the Google Cloud client libraries, so the Ops Agent and support
+// tooling can identify the library emitting the entries.
+//
+// see: https://cloud.google.com/logging/docs/instrumentation-libraries
+func Diagnostic() zap.Field {
+	return zap.Object(diagnosticKey, diagnostic{
+		Sources: []instrumentationSource{{Name: instrumentationName, Version: moduleVersion()}},
+	})
+}
+
+// LogDiagnostic writes the diagnostic entry (see `Diagnostic()`) using
+// `logger`, once per process, no matter how many times it is called. Call it
+// at startup, like the Google Cloud client libraries do.
+func LogDiagnostic(logger *zap.Logger) {
+	diagnosticOnce.Do(func() {
+		logger.Info("Logging library instrumentation.", Diagnostic())
+	})
+}
+
+// diagnostic is the payload of the diagnostic field.
+type diagnostic struct {
+	Sources []instrumentationSource
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (d diagnostic) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return enc.AddArray("instrumentation_source", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		for _, source := range d.Sources {
+			if err := enc.AppendObject(source); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}))
+}
+
+// instrumentationSource is a library emitting the entries.
+type instrumentationSource struct {
+	Name    string
+	Version string
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (s instrumentationSource) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", s.Name)
+	enc.AddString("version", s.Version)
+
+	return nil
+}
+
+// moduleVersion returns the version of the zapdriver module the binary was
+// built with, or `unknown` when it is not available (e.g. in a local build of
+// the module itself).
+func moduleVersion() string {
+	info, ok := readBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	if info.Main.Path == modulePath && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path != modulePath {
+			continue
+		}
+		if dep.Replace != nil && dep.Replace.Version != "" {
+			return dep.Replace.Version
+		}
+
+		return dep.Version
+	}
+
+	return "unknown"
+}
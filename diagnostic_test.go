@@ -0,0 +1,72 @@
+package zapdriver
+
+import (
+	"runtime/debug"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestDiagnostic(t *testing.T) {
+	defer func(fn func() (*debug.BuildInfo, bool)) { readBuildInfo = fn }(readBuildInfo)
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{
+			Main: debug.Module{Path: "example.com/app", Version: "v1.2.3"},
+			Deps: []*debug.Module{{Path: modulePath, Version: "v1.5.0"}},
+		}, true
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	Diagnostic().AddTo(enc)
+
+	assert.Equal(t, map[string]interface{}{
+		"instrumentation_source": []interface{}{
+			map[string]interface{}{"name": "go-zapdriver", "version": "v1.5.0"},
+		},
+	}, enc.Fields[diagnosticKey])
+}
+
+func TestModuleVersion(t *testing.T) {
+	defer func(fn func() (*debug.BuildInfo, bool)) { readBuildInfo = fn }(readBuildInfo)
+
+	var tests = map[string]struct {
+		info *debug.BuildInfo
+		want string
+	}{
+		"dependency": {&debug.BuildInfo{Deps: []*debug.Module{{Path: modulePath, Version: "v1.5.0"}}}, "v1.5.0"},
+		"replaced": {&debug.BuildInfo{Deps: []*debug.Module{
+			{Path: modulePath, Version: "v1.5.0", Replace: &debug.Module{Path: "example.com/fork", Version: "v1.5.1"}},
+		}}, "v1.5.1"},
+		"main module": {&debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "v1.6.0"}}, "v1.6.0"},
+		"local build": {&debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "(devel)"}}, "unknown"},
+		"missing":     {nil, "unknown"},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			readBuildInfo = func() (*debug.BuildInfo, bool) { return tt.info, tt.info != nil }
+
+			assert.Equal(t, tt.want, moduleVersion())
+		})
+	}
+}
+
+func TestLogDiagnostic(t *testing.T) {
+	diagnosticOnce = sync.Once{}
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore)
+
+	LogDiagnostic(logger)
+	LogDiagnostic(logger)
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, zapcore.InfoLevel, logs.All()[0].Level)
+	assert.Contains(t, logs.All()[0].ContextMap(), diagnosticKey)
+}
@@ -1,8 +1,12 @@
 package zapdriver
 
 import (
+	"reflect"
+	"strings"
 	"time"
 
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
 	"go.uber.org/zap/zapcore"
 )
 
@@ -20,14 +24,14 @@ import (
 // EMERGENCY (800) One or more systems are unusable.
 //
 // See: https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogSeverity
-var logLevelSeverity = map[zapcore.Level]string{
-	zapcore.DebugLevel:  "DEBUG",
-	zapcore.InfoLevel:   "INFO",
-	zapcore.WarnLevel:   "WARNING",
-	zapcore.ErrorLevel:  "ERROR",
-	zapcore.DPanicLevel: "CRITICAL",
-	zapcore.PanicLevel:  "ALERT",
-	zapcore.FatalLevel:  "EMERGENCY",
+var logLevelSeverity = map[zapcore.Level]Severity{
+	zapcore.DebugLevel:  SeverityDebug,
+	zapcore.InfoLevel:   SeverityInfo,
+	zapcore.WarnLevel:   SeverityWarning,
+	zapcore.ErrorLevel:  SeverityError,
+	zapcore.DPanicLevel: SeverityCritical,
+	zapcore.PanicLevel:  SeverityAlert,
+	zapcore.FatalLevel:  SeverityEmergency,
 }
 
 // encoderConfig is the default encoder configuration, slightly tweaked to use
@@ -49,7 +53,61 @@ var encoderConfig = zapcore.EncoderConfig{
 // EncodeLevel maps the internal Zap log level to the appropriate Stackdriver
 // level.
 func EncodeLevel(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
-	enc.AppendString(logLevelSeverity[l])
+	enc.AppendString(logLevelSeverity[l].String())
+}
+
+// LevelMapping returns a level encoder that maps the Zap log levels to the
+// Stackdriver severities in `mapping`, falling back to the default mapping of
+// `EncodeLevel` for levels missing from it, and to DEFAULT for levels missing
+// from both. Use it as the `EncodeLevel` of the
+// encoder configuration, for example to map FatalLevel to EMERGENCY and
+// DPanicLevel to ERROR:
+//
+//	config := zapdriver.NewProductionEncoderConfig()
+//	config.EncodeLevel = zapdriver.LevelMapping(map[zapcore.Level]zapdriver.Severity{
+//		zapcore.DPanicLevel: zapdriver.SeverityError,
+//	})
+func LevelMapping(mapping map[zapcore.Level]Severity) zapcore.LevelEncoder {
+	severities := make(map[zapcore.Level]string, len(logLevelSeverity))
+	for l, s := range logLevelSeverity {
+		severities[l] = s.String()
+	}
+	for l, s := range mapping {
+		severities[l] = s.String()
+	}
+
+	return func(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+		if severity, ok := severities[l]; ok {
+			enc.AppendString(severity)
+			return
+		}
+
+		enc.AppendString(SeverityDefault.String())
+	}
+}
+
+// driverLevelEncoders are the code pointers of the level encoders of this
+// package, shared by all the encoders returned by `LevelMapping()`.
+var driverLevelEncoders = []uintptr{
+	reflect.ValueOf(EncodeLevel).Pointer(),
+	reflect.ValueOf(LevelMapping(nil)).Pointer(),
+}
+
+// isDriverLevelEncoder returns true if `enc` is `EncodeLevel`, or was returned
+// by `LevelMapping()`.
+func isDriverLevelEncoder(enc zapcore.LevelEncoder) bool {
+	if enc == nil {
+		return false
+	}
+
+	p := reflect.ValueOf(enc).Pointer()
+	for _, driver := range driverLevelEncoders {
+		if p == driver {
+			return true
+		}
+	}
+
+	return false
 }
 
 // RFC3339NanoTimeEncoder serializes a time.Time to an RFC3339Nano-formatted
@@ -57,3 +115,222 @@ func EncodeLevel(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
 func RFC3339NanoTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 	enc.AppendString(t.Format(time.RFC3339Nano))
 }
+
+// TimestampFormat is the format of the entry timestamps of the encoder
+// returned by `NewEncoder()`. The legacy fluentd agent and the Ops Agent parse
+// timestamps differently, which is why the format can be selected per
+// deployment.
+type TimestampFormat int
+
+const (
+	// TimestampRFC3339 encodes the timestamp as a RFC3339 string with
+	// nanoseconds precision, in the `timestamp` key. This is the default.
+	TimestampRFC3339 TimestampFormat = iota
+
+	// TimestampTime encodes the timestamp as a RFC3339 string with nanoseconds
+	// precision, in the `time` key.
+	TimestampTime
+
+	// TimestampSplit encodes the seconds and nanoseconds of the timestamp as
+	// integers, in the `timestampSeconds` and `timestampNanos` keys.
+	TimestampSplit
+)
+
+// DurationFormat is the format of the durations of the encoder returned by
+// `NewEncoder()`.
+type DurationFormat int
+
+const (
+	// DurationSeconds encodes durations as a floating-point number of
+	// seconds. This is the default.
+	DurationSeconds DurationFormat = iota
+
+	// DurationProto encodes durations in the JSON format of the protobuf
+	// Duration type: a number of seconds with fractional precision, suffixed
+	// with "s" (e.g. "1.5s"), like the latency of `HTTP()` payloads.
+	DurationProto
+)
+
+// zapdriver encoder option to encode the entry timestamps using `format`
+func EncodeTimestamps(format TimestampFormat) func(*driverEncoder) {
+	return func(e *driverEncoder) {
+		e.timestamps = format
+	}
+}
+
+// zapdriver encoder option to encode durations using `format`, overriding the
+// duration encoder of the encoder configuration
+func EncodeDurations(format DurationFormat) func(*driverEncoder) {
+	return func(e *driverEncoder) {
+		e.encodeDuration = zapcore.SecondsDurationEncoder
+		if format == DurationProto {
+			e.encodeDuration = ProtoDurationEncoder
+		}
+	}
+}
+
+// ProtoDurationEncoder serializes a time.Duration in the JSON format of the
+// protobuf Duration type (e.g. "1.5s").
+func ProtoDurationEncoder(d time.Duration, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(FormatLatency(d))
+}
+
+// NewEncoder returns a JSON encoder formatting entries for Stackdriver at
+// encode time, so it can be composed with any core (e.g. sampling or tee
+// cores), without wrapping the core using `WrapCore()`.
+//
+// The level and message keys of `cfg` are mapped to the keys interpreted by
+// Stackdriver, unless `cfg` is a zapdriver configuration (built with
+// `NewProductionEncoderConfig()` or `EncoderKeys()`, possibly with a custom
+// `LevelMapping()`), whose keys are kept. Timestamps are encoded using the
+// format set with `EncodeTimestamps()` (by default, RFC3339 strings in the
+// `timestamp` key). Unless `cfg` already uses the `severity` level key or a
+// zapdriver level encoder, levels are named after the Stackdriver severities
+// using `EncodeLevel`. Other nil encoders default to the encoders of
+// `NewProductionEncoderConfig()`.
+//
+// Labels added using `Label()`, `Labels()`, `LabelsMap()` or a `LabelSet`,
+// including labels added using `logger.With()`, are grouped in the `labels`
+// payload of the entry.
+func NewEncoder(cfg zapcore.EncoderConfig, options ...func(*driverEncoder)) zapcore.Encoder {
+	e := &driverEncoder{labels: NewLabelSet()}
+	for _, option := range options {
+		option(e)
+	}
+
+	driver := isDriverLevelEncoder(cfg.EncodeLevel)
+	if (cfg.LevelKey != encoderConfig.LevelKey && !driver) || cfg.EncodeLevel == nil {
+		cfg.EncodeLevel = encoderConfig.EncodeLevel
+	}
+
+	if !driver || cfg.LevelKey == "" {
+		cfg.LevelKey = encoderConfig.LevelKey
+	}
+	if !driver || cfg.MessageKey == "" {
+		cfg.MessageKey = encoderConfig.MessageKey
+	}
+
+	switch e.timestamps {
+	case TimestampTime:
+		cfg.TimeKey = "time"
+		cfg.EncodeTime = RFC3339NanoTimeEncoder
+	case TimestampSplit:
+		cfg.TimeKey = ""
+	default:
+		cfg.TimeKey = encoderConfig.TimeKey
+		cfg.EncodeTime = RFC3339NanoTimeEncoder
+	}
+
+	if e.encodeDuration != nil {
+		cfg.EncodeDuration = e.encodeDuration
+	} else if cfg.EncodeDuration == nil {
+		cfg.EncodeDuration = encoderConfig.EncodeDuration
+	}
+	if cfg.EncodeCaller == nil {
+		cfg.EncodeCaller = encoderConfig.EncodeCaller
+	}
+
+	e.Encoder = zapcore.NewJSONEncoder(cfg)
+
+	return e
+}
+
+// driverEncoder groups the labels of the entry, before encoding them using the
+// wrapped JSON encoder.
+type driverEncoder struct {
+	zapcore.Encoder
+
+	// labels are the labels added to the encoder context.
+	labels *LabelSet
+
+	// namespaced is true once a namespace is opened in the encoder context,
+	// after which the fields are no longer top-level fields.
+	namespaced bool
+
+	timestamps     TimestampFormat
+	encodeDuration zapcore.DurationEncoder
+}
+
+func (e *driverEncoder) Clone() zapcore.Encoder {
+	return &driverEncoder{
+		Encoder:    e.Encoder.Clone(),
+		labels:     e.labels.Clone(),
+		namespaced: e.namespaced,
+		timestamps: e.timestamps,
+	}
+}
+
+func (e *driverEncoder) AddString(key, value string) {
+	if !e.namespaced && strings.HasPrefix(key, "labels.") {
+		e.labels.Add(strings.Replace(key, "labels.", "", 1), value)
+		return
+	}
+
+	e.Encoder.AddString(key, value)
+}
+
+func (e *driverEncoder) AddByteString(key string, value []byte) {
+	if !e.namespaced && strings.HasPrefix(key, "labels.") {
+		e.labels.Add(strings.Replace(key, "labels.", "", 1), string(value))
+		return
+	}
+
+	e.Encoder.AddByteString(key, value)
+}
+
+func (e *driverEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	if lbls, ok := obj.(*LabelSet); ok && !e.namespaced && key == labelsKey {
+		e.labels.Merge(lbls)
+		return nil
+	}
+
+	return e.Encoder.AddObject(key, obj)
+}
+
+func (e *driverEncoder) OpenNamespace(key string) {
+	e.namespaced = true
+	e.Encoder.OpenNamespace(key)
+}
+
+func (e *driverEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	lbls := e.labels.Clone()
+	out := make([]zapcore.Field, 0, len(fields)+3)
+
+	if e.timestamps == TimestampSplit {
+		out = append(out,
+			zap.Int64("timestampSeconds", ent.Time.Unix()),
+			zap.Int("timestampNanos", ent.Time.Nanosecond()),
+		)
+	}
+
+	// The labels are encoded before the first namespace of the entry fields, to
+	// remain a top-level field.
+	namespace := -1
+	for i := range fields {
+		switch {
+		case fields[i].Type == zapcore.NamespaceType:
+			if namespace < 0 {
+				namespace = len(out)
+			}
+		case e.namespaced || namespace >= 0:
+		case isLabelField(fields[i]):
+			lbls.Add(labelKey(fields[i]), labelValue(fields[i]))
+			continue
+		case isLabelsField(fields[i]):
+			lbls.Merge(fields[i].Interface.(*LabelSet))
+			continue
+		}
+
+		out = append(out, fields[i])
+	}
+
+	if lbls.Len() > 0 {
+		if namespace < 0 {
+			namespace = len(out)
+		}
+
+		out = append(out[:namespace], append([]zapcore.Field{labelsField(lbls)}, out[namespace:]...)...)
+	}
+
+	return e.Encoder.EncodeEntry(ent, out)
+}
@@ -1,12 +1,15 @@
 package zapdriver_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
 	"github.com/gridwise/zapdriver"
@@ -50,3 +53,149 @@ func TestRFC3339NanoTimeEncoder(t *testing.T) {
 	require.Len(t, enc.elems, 1)
 	assert.Equal(t, ts.Format(time.RFC3339Nano), enc.elems[0].(string))
 }
+
+func TestLevelMapping(t *testing.T) {
+	t.Parallel()
+
+	encode := zapdriver.LevelMapping(map[zapcore.Level]zapdriver.Severity{
+		zapcore.DPanicLevel: zapdriver.SeverityError,
+		zapcore.InfoLevel:   zapdriver.SeverityNotice,
+	})
+
+	var tests = []struct {
+		lvl  zapcore.Level
+		want string
+	}{
+		{zapcore.DebugLevel, "DEBUG"},
+		{zapcore.InfoLevel, "NOTICE"},
+		{zapcore.DPanicLevel, "ERROR"},
+		{zapcore.FatalLevel, "EMERGENCY"},
+		{zapcore.Level(10), "DEFAULT"},
+	}
+
+	for _, tt := range tests {
+		enc := &sliceArrayEncoder{}
+		encode(tt.lvl, enc)
+
+		require.Len(t, enc.elems, 1)
+		assert.Equal(t, tt.want, enc.elems[0].(string))
+	}
+}
+
+func TestNewEncoder(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	core := zapcore.NewCore(zapdriver.NewEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(buf), zapcore.DebugLevel)
+	logger := zap.New(core).With(zapdriver.Label("one", "1"), zapdriver.Label("two", "2"))
+
+	logger.Warn("hello",
+		zapdriver.Label("two", "TWO"),
+		zapdriver.LabelsMap(map[string]string{"three": "3"}),
+		zap.String("hello", "world"),
+		zap.Namespace("ns"),
+		zapdriver.Label("four", "4"),
+	)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+
+	assert.Equal(t, "WARNING", entry["severity"])
+	assert.Equal(t, "hello", entry["message"])
+	assert.NotEmpty(t, entry["timestamp"])
+	assert.Equal(t, "world", entry["hello"])
+	assert.Equal(t, map[string]interface{}{"one": "1", "two": "TWO", "three": "3"}, entry["logging.googleapis.com/labels"])
+	assert.Equal(t, map[string]interface{}{"labels.four": "4"}, entry["ns"])
+	assert.NotContains(t, entry, "labels.one")
+}
+
+func TestNewEncoder_Clone(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	core := zapcore.NewCore(zapdriver.NewEncoder(zapdriver.NewProductionEncoderConfig()), zapcore.AddSync(buf), zapcore.DebugLevel)
+	logger := zap.New(core).With(zapdriver.Label("one", "1"))
+
+	logger.With(zapdriver.Label("two", "2")).Info("child")
+	logger.Info("parent")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var child, parent map[string]interface{}
+	require.NoError(t, json.Unmarshal(lines[0], &child))
+	require.NoError(t, json.Unmarshal(lines[1], &parent))
+
+	assert.Equal(t, map[string]interface{}{"one": "1", "two": "2"}, child["logging.googleapis.com/labels"])
+	assert.Equal(t, map[string]interface{}{"one": "1"}, parent["logging.googleapis.com/labels"])
+}
+
+func TestNewEncoder_LevelMapping(t *testing.T) {
+	t.Parallel()
+
+	config := zapdriver.NewProductionEncoderConfig()
+	config.EncodeLevel = zapdriver.LevelMapping(map[zapcore.Level]zapdriver.Severity{
+		zapcore.WarnLevel: zapdriver.SeverityNotice,
+	})
+
+	buf := &bytes.Buffer{}
+	zap.New(zapcore.NewCore(zapdriver.NewEncoder(config), zapcore.AddSync(buf), zapcore.DebugLevel)).Warn("hello")
+
+	assert.Contains(t, buf.String(), `"severity":"NOTICE"`)
+}
+
+func TestNewEncoder_EncoderKeys(t *testing.T) {
+	t.Parallel()
+
+	config := zapdriver.EncoderKeys(zapdriver.MessageKey("msg"), zapdriver.SeverityKey("level"))
+
+	buf := &bytes.Buffer{}
+	zap.New(zapcore.NewCore(zapdriver.NewEncoder(config), zapcore.AddSync(buf), zapcore.DebugLevel)).Warn("hello")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+
+	assert.Equal(t, "WARNING", entry["level"])
+	assert.Equal(t, "hello", entry["msg"])
+	assert.NotContains(t, entry, "severity")
+	assert.NotContains(t, entry, "message")
+}
+
+func TestNewEncoder_Timestamps(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC)
+	tests := map[zapdriver.TimestampFormat]map[string]interface{}{
+		zapdriver.TimestampRFC3339: {"timestamp": "2020-01-02T03:04:05.000006Z"},
+		zapdriver.TimestampTime:    {"time": "2020-01-02T03:04:05.000006Z"},
+		zapdriver.TimestampSplit:   {"timestampSeconds": float64(ts.Unix()), "timestampNanos": float64(6000)},
+	}
+
+	for format, want := range tests {
+		enc := zapdriver.NewEncoder(zap.NewProductionEncoderConfig(), zapdriver.EncodeTimestamps(format))
+
+		buf, err := enc.EncodeEntry(zapcore.Entry{Time: ts, Message: "hello"}, nil)
+		require.NoError(t, err)
+
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+
+		delete(entry, "severity")
+		delete(entry, "message")
+		assert.Equal(t, want, entry, format)
+	}
+}
+
+func TestNewEncoder_Durations(t *testing.T) {
+	t.Parallel()
+
+	fields := []zapcore.Field{zap.Duration("latency", 1500*time.Millisecond)}
+
+	buf, err := zapdriver.NewEncoder(zap.NewProductionEncoderConfig()).EncodeEntry(zapcore.Entry{}, fields)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `"latency":1.5`)
+
+	buf, err = zapdriver.NewEncoder(zap.NewProductionEncoderConfig(), zapdriver.EncodeDurations(zapdriver.DurationProto)).EncodeEntry(zapcore.Entry{}, fields)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `"latency":"1.5s"`)
+}
@@ -0,0 +1,47 @@
+package zapdriver
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// escalation raises the level of the entries matched by any of its matchers.
+type escalation struct {
+	level    zapcore.Level
+	matchers []ErrorMatcher
+}
+
+// zapdriver core option to raise the entries matched by any of `matchers` to
+// `severity` (e.g. SeverityCritical or SeverityAlert), regardless of the level
+// they are logged at, so that specific errors (data corruption, detected auth
+// bypasses) trip severity-based alerting policies. Entries are never lowered to
+// a less severe level, and only the written entry is affected: entries logged
+// below the level of the logger are still dropped, and escalated entries do not
+// panic or exit like entries logged at the escalated level. When several
+// escalations match, the most severe one applies
+func EscalateErrors(severity Severity, matchers ...ErrorMatcher) func(*core) {
+	return func(c *core) {
+		c.config.Escalations = append(c.config.Escalations, escalation{
+			level:    severity.Level(),
+			matchers: matchers,
+		})
+	}
+}
+
+// escalate returns the entry, with its level raised by the matching
+// escalations.
+func (c *core) escalate(ent zapcore.Entry, fields []zapcore.Field) zapcore.Entry {
+	for _, e := range c.config.Escalations {
+		if e.level <= ent.Level {
+			continue
+		}
+
+		for _, match := range e.matchers {
+			if match(ent, fields) {
+				ent.Level = e.level
+				break
+			}
+		}
+	}
+
+	return ent
+}
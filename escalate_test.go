@@ -0,0 +1,58 @@
+package zapdriver
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestEscalateErrors(t *testing.T) {
+	t.Parallel()
+
+	errCorrupt := errors.New("checksum mismatch")
+	errBypass := errors.New("auth bypass")
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, zap.AddCaller(), WrapCore(
+		ReportAllErrors(true),
+		EscalateErrors(SeverityCritical, ErrorIs(errCorrupt)),
+		EscalateErrors(SeverityAlert, ErrorIs(errBypass), func(ent zapcore.Entry, _ []zapcore.Field) bool {
+			return ent.Message == "suspicious"
+		}),
+	))
+
+	logger.Warn("read failed", zap.Error(fmt.Errorf("block 3: %w", errCorrupt)))
+	logger.Info("suspicious")
+	logger.Error("failed", zap.Error(errCorrupt), zap.NamedError("cause", errBypass))
+	logger.Error("failed", zap.Error(errors.New("boom")))
+
+	require.Equal(t, 4, logs.Len())
+
+	assert.Equal(t, zapcore.DPanicLevel, logs.All()[0].Level)
+	assert.Contains(t, logs.All()[0].ContextMap(), contextKey, "escalated entries are reported")
+	assert.Equal(t, zapcore.PanicLevel, logs.All()[1].Level)
+	assert.Equal(t, zapcore.PanicLevel, logs.All()[2].Level)
+	assert.Equal(t, zapcore.ErrorLevel, logs.All()[3].Level)
+}
+
+func TestEscalateErrors_NeverLowers(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(EscalateErrors(SeverityWarning, func(zapcore.Entry, []zapcore.Field) bool {
+		return true
+	})))
+
+	logger.Info("info")
+	logger.Error("error")
+
+	require.Equal(t, 2, logs.Len())
+	assert.Equal(t, zapcore.WarnLevel, logs.All()[0].Level)
+	assert.Equal(t, zapcore.ErrorLevel, logs.All()[1].Level)
+}
@@ -0,0 +1,100 @@
+package zapdriver
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// logsExplorerKey is the key of the field holding the Logs Explorer URL of the
+// entries correlated with an error entry.
+const logsExplorerKey = "logsExplorerUrl"
+
+// zapdriver core option to add the URL of the Logs Explorer query matching the
+// correlated entries (with the permanent labels of the logger, and the same
+// trace) to all logs with level error or above, as the `logsExplorerUrl` field,
+// so on-call engineers can jump from an alert payload straight to the
+// correlated logs
+func LogsExplorerLinks(enabled bool) func(*core) {
+	return func(c *core) {
+		c.config.LogsExplorerLinks = enabled
+	}
+}
+
+// LogsExplorerFilter returns the Logs Explorer query matching the entries with
+// all the `labels`, and with the `trace` (when not empty).
+//
+// see: https://cloud.google.com/logging/docs/view/logging-query-language
+func LogsExplorerFilter(labels map[string]string, trace string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	terms := make([]string, 0, len(keys)+1)
+	for _, k := range keys {
+		terms = append(terms, "labels."+strconv.Quote(k)+"="+strconv.Quote(labels[k]))
+	}
+	if trace != "" {
+		terms = append(terms, "trace="+strconv.Quote(trace))
+	}
+
+	return strings.Join(terms, " AND ")
+}
+
+// LogsExplorerURL returns the Cloud Console URL of the Logs Explorer, running
+// the `query` in the project `projectID`.
+func LogsExplorerURL(projectID, query string) string {
+	return "https://console.cloud.google.com/logs/query;query=" +
+		strings.ReplaceAll(url.QueryEscape(query), "+", "%20") + "?project=" + url.QueryEscape(projectID)
+}
+
+// LogsExplorerLink returns the Logs Explorer URL of the query matching the
+// entries of the logger: the entries with its permanent labels, and its
+// trace. The project is the one set using `TraceProject()`, or the one
+// detected using `ProjectID()` otherwise. An empty string is
+// returned if the logger does not use a zapdriver core, or has neither labels
+// nor trace.
+func LogsExplorerLink(logger *zap.Logger) string {
+	c, ok := coreOf(logger)
+	if !ok {
+		return ""
+	}
+
+	return c.logsExplorerURL(c.permLabels, c.trace)
+}
+
+// withLogsExplorerLink adds the Logs Explorer URL of the correlated entries to
+// the fields of the entry.
+func (c *core) withLogsExplorerLink(fields []zapcore.Field) []zapcore.Field {
+	trace, ok := traceOf(fields)
+	if !ok {
+		trace = c.trace
+	}
+
+	link := c.logsExplorerURL(c.permLabels, trace)
+	if link == "" {
+		return fields
+	}
+
+	return append(fields[:len(fields):len(fields)], zap.String(logsExplorerKey, link))
+}
+
+func (c *core) logsExplorerURL(lbls *LabelSet, trace string) string {
+	query := LogsExplorerFilter(lbls.Map(), trace)
+	if query == "" {
+		return ""
+	}
+
+	project := c.config.TraceProject
+	if project == "" {
+		project = ProjectID()
+	}
+
+	return LogsExplorerURL(project, query)
+}
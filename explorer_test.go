@@ -0,0 +1,48 @@
+package zapdriver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogsExplorerFilter(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, `labels."app"="api" AND labels."k8s.io/name"="a \"b\"" AND trace="projects/p/traces/1"`,
+		LogsExplorerFilter(map[string]string{"k8s.io/name": `a "b"`, "app": "api"}, "projects/p/traces/1"))
+	assert.Equal(t, `labels."app"="api"`, LogsExplorerFilter(map[string]string{"app": "api"}, ""))
+	assert.Equal(t, "", LogsExplorerFilter(nil, ""))
+}
+
+func TestLogsExplorerURL(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t,
+		"https://console.cloud.google.com/logs/query;query=labels.%22app%22%3D%22my%20api%22?project=my-project",
+		LogsExplorerURL("my-project", `labels."app"="my api"`))
+}
+
+func TestLogsExplorerLinks(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(LogsExplorerLinks(true), TraceProject("my-project")))
+	logger = logger.With(Label("app", "api"), zap.String(traceKey, "projects/my-project/traces/1"))
+
+	logger.Info("info")
+	logger.Error("error", Label("entry", "only"))
+
+	require.Equal(t, 2, logs.Len())
+	assert.NotContains(t, logs.All()[0].ContextMap(), logsExplorerKey)
+
+	want := LogsExplorerURL("my-project", `labels."app"="api" AND trace="projects/my-project/traces/1"`)
+	assert.Equal(t, want, logs.All()[1].ContextMap()[logsExplorerKey])
+	assert.Equal(t, want, LogsExplorerLink(logger))
+
+	assert.Empty(t, LogsExplorerLink(zap.NewNop()))
+}
@@ -0,0 +1,110 @@
+package zapdriver
+
+import (
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// bareCore is the wrapped core with an empty labels field added, used to write
+// the entries which need none of the zapdriver features without copying their
+// fields. It is created on the first entry written this way.
+type bareCore struct {
+	once sync.Once
+	core zapcore.Core
+}
+
+// get returns the wrapped core with the empty labels field.
+func (b *bareCore) get(c zapcore.Core) zapcore.Core {
+	b.once.Do(func() {
+		b.core = c.With([]zapcore.Field{labelsField(NewLabelSet())})
+	})
+
+	return b.core
+}
+
+// derive returns the bare core of a core derived using `With()`, or nil when
+// the fast path is disabled.
+func (b *bareCore) derive() *bareCore {
+	if b == nil {
+		return nil
+	}
+
+	return &bareCore{}
+}
+
+// rewritesEntries returns true if the configuration enables a feature
+// rewriting the entries, which disables the fast path. The other fields do not
+// change the entries eligible for it: they are applied before it (clock and
+// caller), only matter for entries it rejects (stack traces, source locations,
+// labels), or only refine one of the fields below. New fields rewriting the
+// entries must be added here.
+func (c *driverConfig) rewritesEntries() bool {
+	return c.ReportAllErrors || c.ServiceName != "" || c.MaxEntrySize != 0 || c.MaxStackFrames != 0 ||
+		len(c.LabelPrefixes) > 0 || len(c.DynamicLabels) > 0 || c.PlatformLabels != nil ||
+		len(c.Escalations) > 0 || len(c.SLOs) > 0 || c.ErrorFingerprint || c.LogsExplorerLinks ||
+		c.ClassifyErrors || c.BigQueryCompatible || len(c.OnWrite) > 0 || c.Reporter != nil ||
+		c.CaptureStackOnError || len(c.Renames) > 0 || c.SampledEnrichment != nil || c.Summarizer != nil ||
+		c.Metadata != nil || c.TraceProject != "" || c.DetectTraceProject || c.AutoTrace != "" ||
+		c.Validate || c.Strict || len(c.Transformers) > 0 || c.ReservedKeyPolicy != 0 || c.NestFields ||
+		len(c.FieldRules) > 0
+}
+
+// writesFast returns true if writing the entry requires none of the zapdriver
+// features, in which case it is written as-is to the wrapped core, along with
+// the (empty) labels of the core. The entry holds the same fields as with the
+// regular write path, except that the labels are written before the fields of
+// the entry, instead of after them.
+func (c *core) writesFast(ent zapcore.Entry, fields []zapcore.Field) bool {
+	if c.bare == nil || ent.Stack != "" {
+		return false
+	}
+
+	if c.addsSourceLocation(ent) {
+		return false
+	}
+
+	if c.errorHTTP != nil || len(c.permNested) > 0 || c.permLabels.Len() > 0 {
+		return false
+	}
+
+	for i := range fields {
+		if !isPlainField(fields[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// writesFastConfig returns true if the options of the core enable none of the
+// features rewriting the entries. It is computed once, when the core is built.
+func (c *core) writesFastConfig() bool {
+	if c.queue != nil || c.sampler != nil || c.limiter != nil || c.repeats != nil ||
+		c.cardinality != nil || c.errorHTTP != nil {
+		return false
+	}
+
+	return !c.config.rewritesEntries()
+}
+
+// isPlainField returns true if the field is left untouched by the core: it is
+// not a label, a lazy field, or a field merged into the error context.
+func isPlainField(field zapcore.Field) bool {
+	switch field.Key {
+	case removeLabelKey, clearLabelsKey, errorUserKey, errorGroupKey, errorHTTPKey, contextKey, panicKey:
+		return false
+	}
+
+	if strings.HasPrefix(field.Key, "labels.") {
+		return false
+	}
+
+	switch field.Interface.(type) {
+	case *LabelSet, *lazyValue:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,162 @@
+package zapdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestWrite_FastPath(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		fields []zapcore.Field
+		fast   bool
+	}{
+		"plain":         {[]zapcore.Field{zap.String("hello", "world"), zap.Int("count", 3)}, true},
+		"error":         {[]zapcore.Field{zap.Error(errors.New("boom")), zap.Duration("took", time.Second)}, true},
+		"no fields":     {nil, true},
+		"label":         {[]zapcore.Field{Label("one", "1")}, false},
+		"labels":        {[]zapcore.Field{Labels(Label("one", "1"))}, false},
+		"int label":     {[]zapcore.Field{zap.Int("labels.one", 1)}, false},
+		"lazy":          {[]zapcore.Field{Lazy("plan", func() interface{} { return "seq scan" })}, false},
+		"error context": {[]zapcore.Field{ErrorUser("user-1")}, false},
+	}
+
+	for name, tt := range tests {
+		slow, slowOut := newFastPathCore(false)
+		fast, fastOut := newFastPathCore(true)
+		ent := zapcore.Entry{Level: zapcore.ErrorLevel, Message: name}
+
+		assert.Equal(t, tt.fast, fast.writesFast(ent, tt.fields), name)
+
+		require.NoError(t, slow.Write(ent, tt.fields), name)
+		require.NoError(t, fast.Write(ent, tt.fields), name)
+
+		var want, got map[string]interface{}
+		require.NoError(t, json.Unmarshal(slowOut.Bytes(), &want), name)
+		require.NoError(t, json.Unmarshal(fastOut.Bytes(), &got), name)
+		assert.Equal(t, want, got, name)
+	}
+}
+
+func TestWrite_FastPathConditions(t *testing.T) {
+	t.Parallel()
+
+	fields := []zapcore.Field{zap.String("hello", "world")}
+
+	c, _ := newFastPathCore(true)
+	assert.True(t, c.writesFast(zapcore.Entry{}, fields))
+	assert.False(t, c.writesFast(zapcore.Entry{Stack: "stack"}, fields), "stack")
+	assert.False(t, c.writesFast(zapcore.Entry{Caller: zapcore.NewEntryCaller(0, "main.go", 1, true)}, fields), "caller")
+	assert.False(t, c.With([]zapcore.Field{Label("one", "1")}).(*core).writesFast(zapcore.Entry{}, fields), "permanent labels")
+	assert.True(t, c.With([]zapcore.Field{zap.Int("count", 1)}).(*core).writesFast(zapcore.Entry{}, fields))
+
+	for name, option := range map[string]func(*core){
+		"service name":      ServiceName("api"),
+		"report all errors": ReportAllErrors(true),
+		"validate":          ValidateEntries(false),
+		"rename":            RenameFields(map[string]string{"hello": "hi"}),
+		"bigquery":          BigQueryCompatible(true),
+		"async":             Async(16, BlockOnOverflow),
+	} {
+		c, _ := newFastPathCore(true, option)
+		assert.False(t, c.writesFast(zapcore.Entry{}, fields), name)
+	}
+
+	c, _ = newFastPathCore(true, SourceLocationLevel(zapcore.ErrorLevel))
+	caller := zapcore.NewEntryCaller(0, "main.go", 1, true)
+	assert.True(t, c.writesFast(zapcore.Entry{Level: zapcore.InfoLevel, Caller: caller}, fields), "source location level")
+	assert.False(t, c.writesFast(zapcore.Entry{Level: zapcore.ErrorLevel, Caller: caller}, fields), "source location level")
+
+	for name, option := range map[string]func(*core){
+		"strip zap caller": StripZapCaller(),
+		"service version":  ServiceVersion("v1"),
+	} {
+		c, _ := newFastPathCore(true, option)
+		assert.True(t, c.writesFast(zapcore.Entry{}, fields), name)
+	}
+}
+
+func TestDriverConfig_RewritesEntries(t *testing.T) {
+	t.Parallel()
+
+	neutral := map[string]bool{
+		"ServiceVersion": true, "ServiceLocation": true, "BuildRevision": true, "BuildLabels": true,
+		"EmbedStack": true, "PreserveStackField": true, "TruncateStrategy": true, "StackStrategy": true,
+		"Clock": true, "Caller": true, "SourcePrefixes": true, "SourceLocationLevel": true,
+		"DisableSourceLocation": true, "StripZapCaller": true, "ShortSourcePaths": true,
+		"FatalFlushTimeout": true, "PermanentLabelsFirst": true, "SortedLabels": true, "Resource": true,
+		"ErrorKind": true, "OnError": true, "IgnoreErrors": true, "ValidateStrict": true, "StrictPanics": true,
+		"EncoderKeys": true, "ErrorOutput": true,
+	}
+
+	interfaces := map[reflect.Type]interface{}{
+		reflect.TypeOf((*zapcore.Clock)(nil)).Elem():        zapcore.DefaultClock,
+		reflect.TypeOf((*zapcore.LevelEnabler)(nil)).Elem(): zapcore.DebugLevel,
+		reflect.TypeOf((*zapcore.WriteSyncer)(nil)).Elem():  zapcore.AddSync(&bytes.Buffer{}),
+	}
+
+	assert.False(t, (&driverConfig{}).rewritesEntries())
+
+	fields := reflect.TypeOf(driverConfig{})
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Field(i)
+
+		var config driverConfig
+		value := reflect.ValueOf(&config).Elem().Field(i)
+		switch field.Type.Kind() {
+		case reflect.Bool:
+			value.SetBool(true)
+		case reflect.String:
+			value.SetString("value")
+		case reflect.Int, reflect.Int64:
+			value.SetInt(1)
+		case reflect.Slice:
+			value.Set(reflect.MakeSlice(field.Type, 1, 1))
+		case reflect.Map:
+			value.Set(reflect.MakeMap(field.Type))
+			value.SetMapIndex(reflect.New(field.Type.Key()).Elem(), reflect.New(field.Type.Elem()).Elem())
+		case reflect.Ptr:
+			value.Set(reflect.New(field.Type.Elem()))
+		case reflect.Func:
+			value.Set(reflect.MakeFunc(field.Type, func([]reflect.Value) []reflect.Value {
+				return nil
+			}))
+		case reflect.Interface:
+			sample, ok := interfaces[field.Type]
+			require.True(t, ok, "no sample value for %s", field.Name)
+			value.Set(reflect.ValueOf(sample))
+		default:
+			t.Fatalf("no sample value for %s", field.Name)
+		}
+
+		assert.Equal(t, !neutral[field.Name], config.rewritesEntries(), field.Name)
+	}
+}
+
+// newFastPathCore returns a core writing JSON entries to the returned buffer,
+// configured with `options`, with the fast path enabled (when the options allow
+// it) or not.
+func newFastPathCore(fast bool, options ...func(*core)) (*core, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	zcore := zapcore.NewCore(zapcore.NewJSONEncoder(NewProductionEncoderConfig()), zapcore.AddSync(out), zapcore.DebugLevel)
+	if fast {
+		return newCore(zcore, options), out
+	}
+
+	c := &core{Core: zcore, permLabels: NewLabelSet()}
+	for _, option := range options {
+		option(c)
+	}
+
+	return c, out
+}
@@ -0,0 +1,39 @@
+package zapdriver
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// zapdriver core option to flush the core synchronously after writing Panic
+// and Fatal entries, for at most `timeout`, before zap panics or exits the
+// process. The wrapped core (e.g. buffered socket writers) and the error
+// reporter (see `ReportTo()`) are flushed, so the entry holding the cause of
+// the crash reaches Cloud Logging and Error Reporting
+func FlushOnFatal(timeout time.Duration) func(*core) {
+	return func(c *core) {
+		c.config.FatalFlushTimeout = timeout
+	}
+}
+
+// flushesOnExit returns true if the core is flushed after writing the entry.
+func (c *core) flushesOnExit(ent zapcore.Entry) bool {
+	return c.config.FatalFlushTimeout > 0 && ent.Level >= zapcore.PanicLevel
+}
+
+// flushFatal flushes the core and the error reporter, for at most the
+// configured timeout.
+func (c *core) flushFatal() {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.FatalFlushTimeout)
+	defer cancel()
+
+	if c.config.Reporter != nil {
+		if err := c.config.Reporter.FlushContext(ctx); err != nil {
+			c.onError(err)
+		}
+	}
+
+	_ = c.SyncContext(ctx)
+}
@@ -0,0 +1,128 @@
+package zapdriver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// blockingSyncCore is a core that blocks all syncs until it is released.
+type blockingSyncCore struct {
+	zapcore.Core
+	release chan struct{}
+}
+
+func (c *blockingSyncCore) Sync() error {
+	<-c.release
+	return c.Core.Sync()
+}
+
+func TestFlushOnFatal(t *testing.T) {
+	t.Parallel()
+
+	reporter, events := newTestErrorReporter(t, http.StatusOK)
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	c := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+	Async(16, BlockOnOverflow)(c)
+	ReportAllErrors(true)(c)
+	ReportTo(reporter)(c)
+	FlushOnFatal(time.Second)(c)
+
+	require.NoError(t, c.Write(zapcore.Entry{Level: zapcore.FatalLevel, Message: "crashed"}, nil))
+
+	// The entry and the error event are written before Write returns.
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "crashed", logs.All()[0].Message)
+	require.Len(t, events(), 1)
+	assert.Equal(t, "crashed", events()[0].Message)
+}
+
+func TestFlushOnFatal_Timeout(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	blocking := &blockingSyncCore{Core: debugcore, release: make(chan struct{})}
+	defer close(blocking.release)
+
+	var errs []error
+	c := &core{
+		Core:       blocking,
+		permLabels: NewLabelSet(),
+	}
+	OnError(func(err error) { errs = append(errs, err) })(c)
+	FlushOnFatal(20 * time.Millisecond)(c)
+
+	start := time.Now()
+	require.NoError(t, c.Write(zapcore.Entry{Level: zapcore.PanicLevel, Message: "crashed"}, nil))
+
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.Equal(t, 1, logs.Len())
+	require.NotEmpty(t, errs)
+
+	var syncErr *SyncError
+	assert.True(t, errors.As(errs[0], &syncErr))
+}
+
+func TestFlushOnFatal_ReporterTimeout(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	reporter, err := NewErrorReporter("my-service", "v1",
+		ReporterProject("my-project"),
+		ReporterInterval(time.Hour),
+		ReporterConcurrency(1),
+		func(r *errorReporter) { r.endpoint = server.URL },
+	)
+	require.NoError(t, err)
+	t.Cleanup(reporter.Close)
+
+	for _, message := range []string{"one", "two", "three"} {
+		reporter.Report(ErrorEvent{Message: message})
+	}
+
+	var errs []error
+	c := &core{
+		Core:       zapcore.NewNopCore(),
+		permLabels: NewLabelSet(),
+	}
+	OnError(func(err error) { errs = append(errs, err) })(c)
+	ReportTo(reporter)(c)
+	FlushOnFatal(20 * time.Millisecond)(c)
+
+	start := time.Now()
+	require.NoError(t, c.Write(zapcore.Entry{Level: zapcore.FatalLevel, Message: "crashed"}, nil))
+
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	require.NotEmpty(t, errs)
+	assert.ErrorIs(t, errs[0], context.DeadlineExceeded)
+}
+
+func TestFlushOnFatal_Levels(t *testing.T) {
+	t.Parallel()
+
+	c := &core{}
+	FlushOnFatal(time.Second)(c)
+
+	assert.False(t, c.flushesOnExit(zapcore.Entry{Level: zapcore.DPanicLevel}))
+	assert.True(t, c.flushesOnExit(zapcore.Entry{Level: zapcore.PanicLevel}))
+	assert.True(t, c.flushesOnExit(zapcore.Entry{Level: zapcore.FatalLevel}))
+	assert.False(t, (&core{}).flushesOnExit(zapcore.Entry{Level: zapcore.FatalLevel}))
+}
@@ -0,0 +1,107 @@
+package zapdriver
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// fieldRule removes fields from the entries enabled by its level.
+type fieldRule struct {
+	level zapcore.LevelEnabler
+	keys  map[string]bool
+
+	// allow keeps only the fields with the keys, instead of removing them.
+	allow bool
+}
+
+// zapdriver core option to remove the fields with the given `keys` from the
+// entries enabled by `level`, for example to keep verbose fields (request
+// bodies, SQL text) in debug entries only:
+//
+//	zapdriver.DenyFields(zap.InfoLevel, "body", "sql")
+func DenyFields(level zapcore.LevelEnabler, keys ...string) func(*core) {
+	return func(c *core) {
+		c.config.FieldRules = append(c.config.FieldRules, newFieldRule(level, keys, false))
+	}
+}
+
+// zapdriver core option to keep only the fields with the given `keys` in the
+// entries enabled by `level`. Special Stackdriver fields (labels, source
+// location, trace context, HTTP request, error context, etc.) are always kept
+func AllowFields(level zapcore.LevelEnabler, keys ...string) func(*core) {
+	return func(c *core) {
+		c.config.FieldRules = append(c.config.FieldRules, newFieldRule(level, keys, true))
+	}
+}
+
+func newFieldRule(level zapcore.LevelEnabler, keys []string, allow bool) fieldRule {
+	rule := fieldRule{level: level, keys: make(map[string]bool, len(keys)), allow: allow}
+	for _, key := range keys {
+		rule.keys[key] = true
+	}
+
+	return rule
+}
+
+// removes returns true if the rule removes the field, for an entry of any level
+// when `level` is nil.
+func (r fieldRule) removes(field zapcore.Field, level *zapcore.Level) bool {
+	if isStackdriverField(field) || field.Type == zapcore.SkipType {
+		return false
+	}
+
+	if level != nil && !r.level.Enabled(*level) {
+		return false
+	}
+
+	return r.keys[field.Key] != r.allow
+}
+
+// isStackdriverField returns true if the field has any of the keys interpreted
+// by Stackdriver, which field rules never remove.
+func isStackdriverField(field zapcore.Field) bool {
+	switch field.Key {
+	case "httpRequest", protoPayloadKey, sourceKey, traceKey, spanKey, traceSampledKey:
+		return true
+	}
+
+	return isSpecialField(field)
+}
+
+// holdFilteredFields returns the fields added using `With()` which are removed
+// from the entries of some level, and the other fields. The former are held
+// back until `Write`, where the level of the entry is known.
+func (c *core) holdFilteredFields(fields []zapcore.Field) (held, rest []zapcore.Field) {
+	rest = fields[:0:0]
+	for i := range fields {
+		if c.removesField(fields[i], nil) {
+			held = append(held, fields[i])
+		} else {
+			rest = append(rest, fields[i])
+		}
+	}
+
+	return held, rest
+}
+
+// filterFields returns the fields of an entry of `level`, without the fields
+// removed by the field rules.
+func (c *core) filterFields(level zapcore.Level, fields []zapcore.Field) []zapcore.Field {
+	out := fields[:0:0]
+	for i := range fields {
+		if !c.removesField(fields[i], &level) {
+			out = append(out, fields[i])
+		}
+	}
+
+	return out
+}
+
+func (c *core) removesField(field zapcore.Field, level *zapcore.Level) bool {
+	for _, rule := range c.config.FieldRules {
+		if rule.removes(field, level) {
+			return true
+		}
+	}
+
+	return false
+}
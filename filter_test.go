@@ -0,0 +1,70 @@
+package zapdriver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestDenyFields(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(DenyFields(zap.InfoLevel, "body", "sql")))
+	logger = logger.With(zap.String("sql", "SELECT 1"), zap.String("user", "alice"))
+
+	logger.Debug("debug", zap.String("body", "{}"), Label("app", "api"))
+	logger.Info("info", zap.String("body", "{}"), zap.Int("status", 200), Label("app", "api"))
+
+	require.Equal(t, 2, logs.Len())
+
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "SELECT 1", fields["sql"])
+	assert.Equal(t, "{}", fields["body"])
+	assert.Equal(t, "alice", fields["user"])
+
+	fields = logs.All()[1].ContextMap()
+	assert.NotContains(t, fields, "sql")
+	assert.NotContains(t, fields, "body")
+	assert.Equal(t, "alice", fields["user"])
+	assert.Equal(t, int64(200), fields["status"])
+	assert.Equal(t, map[string]interface{}{"app": "api"}, fields[labelsKey])
+}
+
+func TestAllowFields(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(AllowFields(zap.ErrorLevel, "error"), ReportAllErrors(true)))
+	logger = logger.With(zap.String("request", "dump"))
+
+	logger.Error("failed",
+		zap.Error(assert.AnError),
+		zap.String("body", "{}"),
+		Label("app", "api"),
+		HTTP(&HTTPPayload{RequestMethod: "GET"}),
+		SourceLocation(0, "main.go", 1, true),
+		TraceContext("105445aa7843bc8bf206b120001000", "0", true, "my-project")[0],
+	)
+	logger.Warn("warning", zap.String("body", "{}"))
+
+	require.Equal(t, 2, logs.Len())
+
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, assert.AnError.Error(), fields["error"])
+	assert.NotContains(t, fields, "body")
+	assert.NotContains(t, fields, "request")
+	assert.Contains(t, fields, serviceContextKey)
+	assert.Equal(t, map[string]interface{}{"app": "api"}, fields[labelsKey])
+	assert.Contains(t, fields, "httpRequest")
+	assert.Contains(t, fields, sourceKey)
+	assert.Contains(t, fields, traceKey)
+
+	fields = logs.All()[1].ContextMap()
+	assert.Equal(t, "{}", fields["body"])
+	assert.Equal(t, "dump", fields["request"])
+}
@@ -0,0 +1,74 @@
+package zapdriver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	fingerprintLabel = "error_fingerprint"
+
+	// fingerprintFrames is the maximum number of stack frames used to compute
+	// the fingerprint of an error.
+	fingerprintFrames = 16
+)
+
+// zapdriver core option to compute a fingerprint of all logs with level error
+// or above when set to true. The fingerprint is a hash of the error type and
+// the functions of the stack trace (excluding line numbers), so it is stable
+// across refactors that shift line numbers. It is added as the
+// `error_fingerprint` label, and as a prefix to the message, which Error
+// Reporting uses to group errors
+func ErrorFingerprint(enabled bool) func(*core) {
+	return func(c *core) {
+		c.config.ErrorFingerprint = enabled
+	}
+}
+
+// errorFingerprint returns the fingerprint of the entry.
+func errorFingerprint(ent zapcore.Entry, fields []zapcore.Field) string {
+	h := sha256.New()
+
+	for i := range fields {
+		if fields[i].Type == zapcore.ErrorType {
+			fmt.Fprintf(h, "%T\n", fields[i].Interface)
+		}
+	}
+
+	frames := stackFunctions(ent.Stack)
+	if len(frames) == 0 && ent.Caller.Defined {
+		if fn := runtime.FuncForPC(ent.Caller.PC); fn != nil {
+			frames = []string{fn.Name()}
+		}
+	}
+
+	for _, frame := range frames {
+		fmt.Fprintln(h, frame)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// stackFunctions returns the function names of the (top) frames of a stack
+// trace formatted by zap, which alternates function lines with indented
+// `file:line` lines.
+func stackFunctions(stack string) []string {
+	functions := []string{}
+	for _, line := range strings.Split(stack, "\n") {
+		if line == "" || strings.HasPrefix(line, "\t") {
+			continue
+		}
+
+		functions = append(functions, line)
+		if len(functions) == fingerprintFrames {
+			break
+		}
+	}
+
+	return functions
+}
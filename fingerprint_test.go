@@ -0,0 +1,71 @@
+package zapdriver
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const testStack = `main.handler
+	/src/app/main.go:12
+main.main
+	/src/app/main.go:30`
+
+func TestErrorFingerprint(t *testing.T) {
+	t.Parallel()
+
+	ent := zapcore.Entry{Stack: testStack}
+	fields := []zapcore.Field{zap.Error(errors.New("boom"))}
+	fingerprint := errorFingerprint(ent, fields)
+
+	assert.Len(t, fingerprint, 16)
+
+	// Line numbers do not change the fingerprint.
+	shifted := zapcore.Entry{Stack: "main.handler\n\t/src/app/main.go:15\nmain.main\n\t/src/app/main.go:33"}
+	assert.Equal(t, fingerprint, errorFingerprint(shifted, fields))
+
+	// The error type does.
+	assert.NotEqual(t, fingerprint, errorFingerprint(ent, []zapcore.Field{zap.Error(&os.PathError{})}))
+
+	// And so do the functions.
+	other := zapcore.Entry{Stack: "main.other\n\t/src/app/main.go:12"}
+	assert.NotEqual(t, fingerprint, errorFingerprint(other, fields))
+}
+
+func TestErrorFingerprint_Caller(t *testing.T) {
+	t.Parallel()
+
+	pc, file, line, ok := runtime.Caller(0)
+	ent := zapcore.Entry{Caller: zapcore.NewEntryCaller(pc, file, line, ok)}
+
+	assert.NotEqual(t, errorFingerprint(zapcore.Entry{}, nil), errorFingerprint(ent, nil))
+}
+
+func TestWriteErrorFingerprint(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	core := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+	ErrorFingerprint(true)(core)
+
+	fields := []zapcore.Field{zap.Error(errors.New("boom"))}
+	require.NoError(t, core.Write(zapcore.Entry{Level: zapcore.ErrorLevel, Message: "failed", Stack: testStack}, fields))
+	require.NoError(t, core.Write(zapcore.Entry{Level: zapcore.InfoLevel, Message: "hello"}, nil))
+
+	fingerprint := errorFingerprint(zapcore.Entry{Stack: testStack}, fields)
+
+	assert.Equal(t, "["+fingerprint+"] failed", logs.All()[0].Message)
+	labels := logs.All()[0].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, fingerprint, labels[fingerprintLabel])
+
+	assert.Equal(t, "hello", logs.All()[1].Message)
+	assert.Len(t, fields, 1)
+}
@@ -0,0 +1,61 @@
+package zapdriver
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// functionExecutionIDHeader is the request header holding the execution ID
+	// of 1st gen Cloud Functions, set by the function framework.
+	functionExecutionIDHeader = "Function-Execution-Id"
+
+	// executionIDLabel is the label used by the Cloud Functions console to group
+	// the log entries of a single execution.
+	executionIDLabel = "execution_id"
+)
+
+// NewCloudFunctionsLogger builds a logger for Cloud Functions (1st and 2nd gen).
+// Entries below ErrorLevel are written to standard output, and all other
+// entries to standard error, without sampling. The service context is set from
+// the function name (`K_SERVICE`, `FUNCTION_NAME` or `FUNCTION_TARGET`) and
+// version (`K_REVISION` or `X_GOOGLE_FUNCTION_VERSION`).
+//
+// Use `FunctionLogger()` (or `FunctionMiddleware()`) to correlate entries with
+// the execution and trace of each request.
+func NewCloudFunctionsLogger(options ...zap.Option) (*zap.Logger, error) {
+	config := NewConfig()
+	config.Sampling = false
+	config.SplitStreams = true
+	config.ServiceName = firstEnv("K_SERVICE", "FUNCTION_NAME", "FUNCTION_TARGET")
+	config.ServiceVersion = firstEnv("K_REVISION", "X_GOOGLE_FUNCTION_VERSION")
+
+	return config.Build(options...)
+}
+
+// FunctionLogger returns a child logger of `logger`, with the trace context of
+// the request attached (see `RequestLogger()`), and the `execution_id` label set
+// from the `Function-Execution-Id` header, when present. 2nd gen functions run
+// on Cloud Run, and are correlated by trace only.
+func FunctionLogger(logger *zap.Logger, r *http.Request) *zap.Logger {
+	logger = RequestLogger(logger, r)
+
+	if id := r.Header.Get(functionExecutionIDHeader); id != "" {
+		logger = logger.With(Label(executionIDLabel, id))
+	}
+
+	return logger
+}
+
+// FunctionMiddleware returns a HTTP middleware which stores the logger returned
+// by `FunctionLogger()` in the context of every request, to be retrieved using
+// `FromContext()`.
+func FunctionMiddleware(logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := WithLogger(r.Context(), FunctionLogger(logger, r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
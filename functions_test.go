@@ -0,0 +1,67 @@
+package zapdriver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNewCloudFunctionsLogger(t *testing.T) {
+	t.Setenv("K_SERVICE", "")
+	t.Setenv("FUNCTION_NAME", "my-function")
+	t.Setenv("X_GOOGLE_FUNCTION_VERSION", "3")
+
+	logger, err := NewCloudFunctionsLogger()
+	require.NoError(t, err)
+
+	c, ok := logger.Core().(*core)
+	require.True(t, ok)
+	assert.Equal(t, "my-function", c.config.ServiceName)
+	assert.Equal(t, "3", c.config.ServiceVersion)
+}
+
+func TestNewCloudFunctionsLogger_SplitStreams(t *testing.T) {
+	stdout, stderr := captureStreams(t)
+
+	logger, err := NewCloudFunctionsLogger()
+	require.NoError(t, err)
+
+	logger.Info("routine")
+	logger.Error("failed")
+
+	assert.Equal(t, 1, strings.Count(stdout.String(), "\n"), stdout.String())
+	assert.Contains(t, stdout.String(), "routine")
+	assert.Equal(t, 1, strings.Count(stderr.String(), "\n"), stderr.String())
+	assert.Contains(t, stderr.String(), "failed")
+}
+
+func TestFunctionMiddleware(t *testing.T) {
+	defer func(d *projectDetector) { detectedProject = d }(detectedProject)
+	detectedProject = &projectDetector{}
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "my-project")
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	handler := FunctionMiddleware(zap.New(debugcore, WrapCore()))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Info("hello")
+	}))
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set(functionExecutionIDHeader, "abc123")
+	req.Header.Set(cloudTraceHeader, "105445aa7843bc8bf206b120001000/1;o=1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "abc123", fields[labelsKey].(map[string]interface{})[executionIDLabel])
+	assert.Equal(t, "projects/my-project/traces/105445aa7843bc8bf206b120001000", fields[traceKey])
+
+	assert.Empty(t, logs.All()[1].ContextMap()[labelsKey])
+}
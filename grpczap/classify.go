@@ -0,0 +1,41 @@
+package grpczap
+
+import (
+	"strings"
+	"unicode"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorKind returns the kind of an error carrying a gRPC status, as the snake
+// case name of its status code prefixed by `grpc_` (e.g. `grpc_unavailable`),
+// or an empty string if the error carries no status, or the `OK` or `Unknown`
+// code. Use it with `zapdriver.ErrorKinds()` to classify gRPC errors:
+//
+//	zapdriver.WrapCore(zapdriver.ClassifyErrors(true), zapdriver.ErrorKinds(grpczap.ErrorKind))
+func ErrorKind(err error) string {
+	s, ok := status.FromError(err)
+	if err == nil || !ok || s.Code() == codes.OK || s.Code() == codes.Unknown {
+		return ""
+	}
+
+	return "grpc_" + snakeCase(s.Code().String())
+}
+
+// snakeCase converts a camel case name to snake case.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
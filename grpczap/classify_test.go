@@ -0,0 +1,49 @@
+package grpczap_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/gridwise/zapdriver"
+	"github.com/gridwise/zapdriver/grpczap"
+)
+
+func TestErrorKind(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		err  error
+		want string
+	}{
+		{status.Error(codes.Unavailable, "down"), "grpc_unavailable"},
+		{fmt.Errorf("call: %w", status.Error(codes.DeadlineExceeded, "slow")), "grpc_deadline_exceeded"},
+		{status.Error(codes.Unknown, "unknown"), ""},
+		{errors.New("boom"), ""},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, grpczap.ErrorKind(tt.err), fmt.Sprint(tt.err))
+	}
+}
+
+func TestErrorKind_ClassifyErrors(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, zapdriver.WrapCore(zapdriver.ClassifyErrors(true), zapdriver.ErrorKinds(grpczap.ErrorKind)))
+
+	logger.Error("call", zap.Error(status.Error(codes.Unavailable, "down")))
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, map[string]interface{}{"error.kind": "grpc_unavailable"}, logs.All()[0].ContextMap()["logging.googleapis.com/labels"])
+}
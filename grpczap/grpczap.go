@@ -0,0 +1,259 @@
+// Package grpczap provides gRPC client interceptors that propagate the trace
+// context attached by the zapdriver middleware to outbound calls, and log each
+// call in a format understood by Stackdriver.
+package grpczap
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/gridwise/zapdriver"
+)
+
+// UnaryClientInterceptor returns an interceptor propagating the trace context
+// of the call context (as attached by `zapdriver.Middleware()`) to outbound
+// calls, using the `x-cloud-trace-context` and `traceparent` metadata keys,
+// with a new span ID for each call. Metadata already set on the call is left
+// untouched.
+//
+// Each call is logged with a `grpc` payload holding its method, status code,
+// latency and payload sizes, using `logger`, or the logger of the call context
+// (see `zapdriver.FromContext()`) when nil. Failed calls also hold the status
+// details of the error (see `Status()`).
+func UnaryClientInterceptor(logger *zap.Logger) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx = injectTraceMetadata(ctx)
+
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		call := &call{
+			method:       method,
+			code:         status.Code(err),
+			latency:      time.Since(start),
+			requestSize:  size(req),
+			responseSize: size(reply),
+		}
+
+		if err != nil {
+			call.responseSize = 0
+		}
+
+		logCall(loggerFor(ctx, logger), call, err)
+
+		return err
+	}
+}
+
+// StreamClientInterceptor returns the streaming counterpart of
+// `UnaryClientInterceptor()`. The call is logged once the stream ends, with
+// the total size of the messages sent and received on the stream.
+func StreamClientInterceptor(logger *zap.Logger) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx = injectTraceMetadata(ctx)
+		logger := loggerFor(ctx, logger)
+
+		start := time.Now()
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			logCall(logger, &call{
+				method:  method,
+				code:    status.Code(err),
+				latency: time.Since(start),
+			}, err)
+
+			return nil, err
+		}
+
+		return &clientStream{
+			ClientStream: cs,
+			logger:       logger,
+			start:        start,
+			call:         &call{method: method, serverStreams: desc.ServerStreams},
+		}, nil
+	}
+}
+
+// clientStream wraps a client stream to account for the messages sent and
+// received, and to log the call when the stream ends.
+type clientStream struct {
+	grpc.ClientStream
+
+	logger *zap.Logger
+	start  time.Time
+	once   sync.Once
+
+	mutex sync.Mutex
+	call  *call
+}
+
+// SendMsg implements grpc.ClientStream interface.
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.mutex.Lock()
+		s.call.requestSize += size(m)
+		s.mutex.Unlock()
+	} else if err != io.EOF {
+		s.finish(err)
+	}
+
+	return err
+}
+
+// RecvMsg implements grpc.ClientStream interface.
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		s.mutex.Lock()
+		s.call.responseSize += size(m)
+		s.mutex.Unlock()
+
+		// Streams without server streaming end after their single response.
+		if !s.call.serverStreams {
+			s.finish(nil)
+		}
+	case err == io.EOF:
+		s.finish(nil)
+	default:
+		s.finish(err)
+	}
+
+	return err
+}
+
+// finish logs the call, once.
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		s.call.code = status.Code(err)
+		s.call.latency = time.Since(s.start)
+
+		logCall(s.logger, s.call, err)
+	})
+}
+
+// call describes an outbound call.
+type call struct {
+	method       string
+	code         codes.Code
+	latency      time.Duration
+	requestSize  int
+	responseSize int
+
+	// serverStreams is true if the server sends several responses on the
+	// stream, in which case the stream only ends on `io.EOF`.
+	serverStreams bool
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (c *call) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	service, method := splitMethod(c.method)
+
+	enc.AddString("service", service)
+	enc.AddString("method", method)
+	enc.AddString("code", c.code.String())
+	enc.AddString("latency", zapdriver.FormatLatency(c.latency))
+	enc.AddInt("requestSize", c.requestSize)
+	enc.AddInt("responseSize", c.responseSize)
+
+	return nil
+}
+
+// logCall logs the call with a level matching its status code.
+func logCall(logger *zap.Logger, c *call, err error) {
+	fields := []zap.Field{zap.Object("grpc", c)}
+	if err != nil {
+		fields = append(fields, zap.Error(err), Status(err))
+	}
+
+	switch level(c.code) {
+	case zapcore.ErrorLevel:
+		logger.Error("Outbound call failed.", fields...)
+	case zapcore.WarnLevel:
+		logger.Warn("Outbound call failed.", fields...)
+	default:
+		logger.Info("Outbound call.", fields...)
+	}
+}
+
+// level returns the level at which a call with the given status code is
+// logged. Codes caused by the caller are warnings, while codes reporting a
+// failure of the server are errors.
+func level(code codes.Code) zapcore.Level {
+	switch code {
+	case codes.OK:
+		return zapcore.InfoLevel
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented,
+		codes.Internal, codes.Unavailable, codes.DataLoss:
+		return zapcore.ErrorLevel
+	default:
+		return zapcore.WarnLevel
+	}
+}
+
+// injectTraceMetadata returns a copy of the context whose outgoing metadata
+// holds the trace context of the IDs attached to the context, for the keys not
+// already set.
+func injectTraceMetadata(ctx context.Context) context.Context {
+	ids, ok := zapdriver.IDsFromContext(ctx)
+	if !ok || ids.Trace == "" {
+		return ctx
+	}
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+
+	for k, v := range zapdriver.TraceHeaders(ids) {
+		k = strings.ToLower(k)
+		if len(md.Get(k)) == 0 {
+			md.Set(k, v...)
+		}
+	}
+
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// loggerFor returns `logger`, or the logger of the context when nil.
+func loggerFor(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	if logger != nil {
+		return logger
+	}
+
+	return zapdriver.FromContext(ctx)
+}
+
+// size returns the encoded size of a message, or zero if the message is not a
+// protocol buffer.
+func size(m interface{}) int {
+	if msg, ok := m.(proto.Message); ok {
+		return proto.Size(msg)
+	}
+
+	return 0
+}
+
+// splitMethod splits a full method name (`/package.Service/Method`) into its
+// service and method names.
+func splitMethod(full string) (string, string) {
+	full = strings.TrimPrefix(full, "/")
+	if i := strings.LastIndex(full, "/"); i >= 0 {
+		return full[:i], full[i+1:]
+	}
+
+	return "", full
+}
@@ -0,0 +1,166 @@
+package grpczap_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/gridwise/zapdriver"
+	"github.com/gridwise/zapdriver/grpczap"
+)
+
+// dial starts a health server, and returns a client connection to it using the
+// interceptors, along with the incoming metadata of the last call received.
+func dial(t *testing.T, logger *zap.Logger) (*grpc.ClientConn, *metadata.MD) {
+	t.Helper()
+
+	received := &metadata.MD{}
+	capture := func(ctx context.Context) {
+		*received, _ = metadata.FromIncomingContext(ctx)
+	}
+
+	lis := bufconn.Listen(1 << 16)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, h grpc.UnaryHandler) (interface{}, error) {
+			capture(ctx)
+			return h(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+			capture(ss.Context())
+			return h(srv, ss)
+		}),
+	)
+
+	hs := health.NewServer()
+	hs.SetServingStatus("ok", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(grpczap.UnaryClientInterceptor(logger)),
+		grpc.WithStreamInterceptor(grpczap.StreamClientInterceptor(logger)),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn, received
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	conn, received := dial(t, zap.New(core))
+	client := healthpb.NewHealthClient(conn)
+
+	ids := zapdriver.IDs{Trace: "105445aa7843bc8bf206b12000100000", SpanID: "1", Sampled: true}
+	ctx := zapdriver.ContextWithIDs(context.Background(), ids)
+
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "ok"})
+	require.NoError(t, err)
+
+	assert.Len(t, received.Get("x-cloud-trace-context"), 1)
+	assert.True(t, strings.HasPrefix(received.Get("x-cloud-trace-context")[0], ids.Trace+"/"))
+	assert.True(t, strings.HasSuffix(received.Get("x-cloud-trace-context")[0], ";o=1"))
+	assert.Len(t, received.Get("traceparent"), 1)
+	assert.True(t, strings.HasPrefix(received.Get("traceparent")[0], "00-"+ids.Trace+"-"))
+
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"})
+	require.Error(t, err)
+	assert.Empty(t, received.Get("traceparent"))
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, zapcore.InfoLevel, entries[0].Level)
+	assert.Equal(t, "Outbound call.", entries[0].Message)
+
+	payload := entries[0].ContextMap()["grpc"].(map[string]interface{})
+	assert.Equal(t, "grpc.health.v1.Health", payload["service"])
+	assert.Equal(t, "Check", payload["method"])
+	assert.Equal(t, "OK", payload["code"])
+	assert.NotEmpty(t, payload["latency"])
+	assert.Equal(t, 4, payload["requestSize"])
+	assert.Equal(t, 2, payload["responseSize"])
+
+	assert.Equal(t, zapcore.WarnLevel, entries[1].Level)
+	assert.Equal(t, "Outbound call failed.", entries[1].Message)
+	assert.Equal(t, "NotFound", entries[1].ContextMap()["grpc"].(map[string]interface{})["code"])
+	assert.Equal(t, "NotFound", entries[1].ContextMap()["grpcStatus"].(map[string]interface{})["code"])
+}
+
+func TestUnaryClientInterceptor_KeepsMetadata(t *testing.T) {
+	conn, received := dial(t, zap.NewNop())
+	client := healthpb.NewHealthClient(conn)
+
+	ctx := zapdriver.ContextWithIDs(context.Background(), zapdriver.IDs{Trace: "105445aa7843bc8bf206b12000100000"})
+	ctx = metadata.AppendToOutgoingContext(ctx, "traceparent", "custom")
+
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "ok"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"custom"}, received.Get("traceparent"))
+	assert.Len(t, received.Get("x-cloud-trace-context"), 1)
+}
+
+func TestUnaryClientInterceptor_ContextLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	conn, _ := dial(t, nil)
+	client := healthpb.NewHealthClient(conn)
+
+	ctx := zapdriver.WithLogger(context.Background(), zap.New(core))
+
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "ok"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, logs.Len())
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	conn, received := dial(t, zap.New(core))
+	client := healthpb.NewHealthClient(conn)
+
+	ids := zapdriver.IDs{Trace: "105445aa7843bc8bf206b12000100000", SpanID: "1"}
+	ctx, cancel := context.WithCancel(zapdriver.ContextWithIDs(context.Background(), ids))
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "ok"})
+	require.NoError(t, err)
+
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+	assert.Equal(t, 0, logs.Len())
+
+	assert.True(t, strings.HasSuffix(received.Get("x-cloud-trace-context")[0], ";o=0"))
+
+	cancel()
+	_, err = stream.Recv()
+	require.Error(t, err)
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 1)
+
+	payload := entries[0].ContextMap()["grpc"].(map[string]interface{})
+	assert.Equal(t, "Watch", payload["method"])
+	assert.Equal(t, "Canceled", payload["code"])
+	assert.Equal(t, 4, payload["requestSize"])
+	assert.Equal(t, 2, payload["responseSize"])
+}
@@ -0,0 +1,145 @@
+package grpczap
+
+import (
+	"encoding/json"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/gridwise/zapdriver"
+)
+
+// grpcStatusKey is the key of the field holding the gRPC status of an error.
+const grpcStatusKey = "grpcStatus"
+
+// Status adds a `grpcStatus` field holding the code, message and details
+// of the gRPC status of `err`, so the details (such as the field violations of
+// a `BadRequest`, or the retry delay of a `RetryInfo`) can be queried, instead
+// of being flattened into the error message. The field is skipped if `err` is
+// nil, or does not carry a gRPC status.
+//
+// see: https://cloud.google.com/apis/design/errors#error_details
+func Status(err error) zap.Field {
+	s, ok := status.FromError(err)
+	if err == nil || !ok {
+		return zap.Skip()
+	}
+
+	return zap.Object(grpcStatusKey, grpcStatus{s})
+}
+
+// grpcStatus renders a gRPC status.
+type grpcStatus struct {
+	*status.Status
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (s grpcStatus) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("code", s.Code().String())
+	enc.AddString("message", s.Message())
+
+	details := s.Details()
+	if len(details) == 0 {
+		return nil
+	}
+
+	return enc.AddArray("details", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		for _, detail := range details {
+			var err error
+
+			switch d := detail.(type) {
+			case *errdetails.BadRequest:
+				err = enc.AppendObject(badRequest{d})
+			case *errdetails.RetryInfo:
+				err = enc.AppendObject(retryInfo{d})
+			case proto.Message:
+				err = enc.AppendReflected(genericDetail{d})
+			case error:
+				// The type of the detail is not registered.
+				err = enc.AppendObject(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+					enc.AddString("error", d.Error())
+					return nil
+				}))
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}))
+}
+
+// badRequest renders the field violations of a `google.rpc.BadRequest`.
+type badRequest struct {
+	*errdetails.BadRequest
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (d badRequest) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("@type", typeURL(d.BadRequest))
+
+	return enc.AddArray("fieldViolations", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		for _, v := range d.GetFieldViolations() {
+			v := v
+			err := enc.AppendObject(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+				enc.AddString("field", v.GetField())
+				enc.AddString("description", v.GetDescription())
+				return nil
+			}))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}))
+}
+
+// retryInfo renders the retry delay of a `google.rpc.RetryInfo`.
+type retryInfo struct {
+	*errdetails.RetryInfo
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (d retryInfo) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("@type", typeURL(d.RetryInfo))
+	if delay := d.GetRetryDelay(); delay != nil {
+		enc.AddString("retryDelay", zapdriver.FormatLatency(delay.AsDuration()))
+	}
+
+	return nil
+}
+
+// genericDetail renders the other details in the JSON format of protocol
+// buffers, with their `@type`.
+type genericDetail struct {
+	proto.Message
+}
+
+// MarshalJSON implements json.Marshaler interface.
+func (d genericDetail) MarshalJSON() ([]byte, error) {
+	b, err := protojson.Marshal(d.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	fields["@type"], _ = json.Marshal(typeURL(d.Message))
+
+	return json.Marshal(fields)
+}
+
+// typeURL returns the type URL of a message, as used in `google.protobuf.Any`.
+func typeURL(m proto.Message) string {
+	return "type.googleapis.com/" + string(m.ProtoReflect().Descriptor().FullName())
+}
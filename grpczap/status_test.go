@@ -0,0 +1,63 @@
+package grpczap
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestStatus(t *testing.T) {
+	t.Parallel()
+
+	s, err := status.New(codes.InvalidArgument, "invalid request").WithDetails(
+		&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "name", Description: "must not be empty"},
+		}},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(1500 * time.Millisecond)},
+		&errdetails.ErrorInfo{Reason: "EMPTY_NAME", Domain: "example.com"},
+	)
+	require.NoError(t, err)
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	zap.New(core).Error("failed", Status(s.Err()))
+
+	payload := logs.All()[0].ContextMap()[grpcStatusKey].(map[string]interface{})
+	assert.Equal(t, "InvalidArgument", payload["code"])
+	assert.Equal(t, "invalid request", payload["message"])
+
+	details := payload["details"].([]interface{})
+	require.Len(t, details, 3)
+	assert.Equal(t, map[string]interface{}{
+		"@type": "type.googleapis.com/google.rpc.BadRequest",
+		"fieldViolations": []interface{}{
+			map[string]interface{}{"field": "name", "description": "must not be empty"},
+		},
+	}, details[0])
+	assert.Equal(t, map[string]interface{}{
+		"@type":      "type.googleapis.com/google.rpc.RetryInfo",
+		"retryDelay": "1.5s",
+	}, details[1])
+
+	b, err := json.Marshal(details[2])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"@type":"type.googleapis.com/google.rpc.ErrorInfo","reason":"EMPTY_NAME","domain":"example.com"}`, string(b))
+}
+
+func TestStatus_Skipped(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, zapcore.SkipType, Status(nil).Type)
+	assert.Equal(t, zapcore.SkipType, Status(errors.New("boom")).Type)
+	assert.Equal(t, zapcore.ObjectMarshalerType, Status(status.Error(codes.NotFound, "missing")).Type)
+}
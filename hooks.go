@@ -0,0 +1,23 @@
+package zapdriver
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// zapdriver core option to call `hook` with every entry (and its final fields)
+// written to the wrapped core, for example to count log entries by severity.
+// The hook must not retain or modify the fields
+func OnWrite(hook func(zapcore.Entry, []zapcore.Field)) func(*core) {
+	return func(c *core) {
+		c.config.OnWrite = append(c.config.OnWrite, hook)
+	}
+}
+
+// zapdriver core option to call `hook` with every error returned by the
+// wrapped core when writing or syncing entries, for example to detect a broken
+// output pipe. Errors of asynchronous writes are only reported through this hook
+func OnError(hook func(error)) func(*core) {
+	return func(c *core) {
+		c.config.OnError = append(c.config.OnError, hook)
+	}
+}
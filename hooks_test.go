@@ -0,0 +1,76 @@
+package zapdriver
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// failingCore is a core that fails all writes and syncs.
+type failingCore struct {
+	zapcore.Core
+	err error
+}
+
+func (c *failingCore) Write(zapcore.Entry, []zapcore.Field) error { return c.err }
+func (c *failingCore) Sync() error                                { return c.err }
+
+func TestOnWrite(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+
+	counts := map[zapcore.Level]int{}
+	var labels interface{}
+	logger := zap.New(debugcore, WrapCore(OnWrite(func(ent zapcore.Entry, fields []zapcore.Field) {
+		counts[ent.Level]++
+		for i := range fields {
+			if fields[i].Key == labelsKey {
+				labels = fields[i].Interface
+			}
+		}
+	})))
+
+	logger.Info("hello", Label("one", "world"))
+	logger.Info("hello")
+	logger.Warn("hello")
+
+	assert.Equal(t, 3, logs.Len())
+	assert.Equal(t, map[zapcore.Level]int{zapcore.InfoLevel: 2, zapcore.WarnLevel: 1}, counts)
+	assert.NotNil(t, labels)
+}
+
+func TestOnError(t *testing.T) {
+	broken := errors.New("broken pipe")
+	failing := &failingCore{Core: zapcore.NewNopCore(), err: broken}
+
+	var mutex sync.Mutex
+	var errs []error
+	hook := OnError(func(err error) {
+		mutex.Lock()
+		errs = append(errs, err)
+		mutex.Unlock()
+	})
+
+	c := &core{Core: failing, permLabels: NewLabelSet()}
+	hook(c)
+
+	assert.Equal(t, broken, c.Write(zapcore.Entry{}, nil))
+	assert.Equal(t, broken, c.Sync())
+	assert.Equal(t, []error{broken, broken}, errs)
+
+	// Errors of asynchronous writes are reported too.
+	errs = nil
+	Async(4, BlockOnOverflow)(c)
+
+	require.NoError(t, c.Write(zapcore.Entry{}, nil))
+	assert.Equal(t, broken, c.Sync())
+
+	mutex.Lock()
+	assert.Equal(t, []error{broken, broken}, errs)
+	mutex.Unlock()
+}
@@ -15,6 +15,8 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -27,6 +29,32 @@ func HTTP(req *HTTPPayload) zap.Field {
 	return zap.Object("httpRequest", req)
 }
 
+// Latency adds a `latency` field, with the duration formatted as a duration in
+// seconds with up to nine fractional digits, terminated by 's' (e.g. "3.5s").
+// This is the format of the `Duration` protobuf type, used by Cloud Logging.
+func Latency(d time.Duration) zap.Field {
+	return zap.String("latency", FormatLatency(d))
+}
+
+// FormatLatency formats the duration as expected for the `Latency` of the HTTP
+// payload (e.g. "3.5s").
+func FormatLatency(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+
+	seconds := strconv.FormatInt(int64(d/time.Second), 10)
+	nanos := int64(d % time.Second)
+	if nanos == 0 {
+		return sign + seconds + "s"
+	}
+
+	fraction := strconv.FormatInt(nanos+int64(time.Second), 10)[1:]
+
+	return sign + seconds + "." + strings.TrimRight(fraction, "0") + "s"
+}
+
 // HTTPPayload is the complete payload that can be interpreted by
 // Stackdriver as a HTTP request.
 type HTTPPayload struct {
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -24,6 +25,30 @@ func TestHTTP(t *testing.T) {
 	assert.Equal(t, zap.Object("httpRequest", req), field)
 }
 
+func TestLatency(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, zap.String("latency", "3.5s"), zapdriver.Latency(3500*time.Millisecond))
+}
+
+func TestFormatLatency(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[time.Duration]string{
+		0:                                    "0s",
+		time.Nanosecond:                      "0.000000001s",
+		250 * time.Microsecond:               "0.00025s",
+		3500 * time.Millisecond:              "3.5s",
+		2*time.Minute + 5*time.Second:        "125s",
+		90*time.Minute + 123*time.Nanosecond: "5400.000000123s",
+		-1500 * time.Millisecond:             "-1.5s",
+	}
+
+	for d, want := range tests {
+		assert.Equal(t, want, zapdriver.FormatLatency(d), d.String())
+	}
+}
+
 func TestNewHTTP(t *testing.T) {
 	t.Parallel()
 
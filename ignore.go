@@ -0,0 +1,56 @@
+package zapdriver
+
+import (
+	"errors"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// ErrorMatcher reports whether an entry matches, given its fields.
+type ErrorMatcher func(ent zapcore.Entry, fields []zapcore.Field) bool
+
+// zapdriver core option to exclude the entries matched by any of `matchers`
+// from the errors reported by `ReportAllErrors`, so that known-noisy errors
+// (client disconnects, expected retries) are still logged at their level, but
+// are not reported to Error Reporting. Matchers may be called several times
+// per entry, and should be cheap
+func IgnoreErrors(matchers ...ErrorMatcher) func(*core) {
+	return func(c *core) {
+		c.config.IgnoreErrors = append(c.config.IgnoreErrors, matchers...)
+	}
+}
+
+// ErrorIs returns a matcher for the entries holding an error (added using
+// `zap.Error()`) matching any of `targets`, using `errors.Is()`. For example,
+// to ignore the errors of canceled requests:
+//
+//	zapdriver.IgnoreErrors(zapdriver.ErrorIs(context.Canceled))
+func ErrorIs(targets ...error) ErrorMatcher {
+	return func(_ zapcore.Entry, fields []zapcore.Field) bool {
+		for i := range fields {
+			err, ok := fields[i].Interface.(error)
+			if !ok || fields[i].Type != zapcore.ErrorType {
+				continue
+			}
+
+			for _, target := range targets {
+				if errors.Is(err, target) {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+}
+
+// ignoresError returns true if the entry is excluded from the reported errors.
+func (c *core) ignoresError(ent zapcore.Entry, fields []zapcore.Field) bool {
+	for _, match := range c.config.IgnoreErrors {
+		if match(ent, fields) {
+			return true
+		}
+	}
+
+	return false
+}
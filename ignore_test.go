@@ -0,0 +1,58 @@
+package zapdriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestIgnoreErrors(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel), WrapCore(
+		ReportAllErrors(true),
+		EmbedStack(true),
+		ServiceName("service"),
+		IgnoreErrors(
+			ErrorIs(context.Canceled),
+			func(ent zapcore.Entry, _ []zapcore.Field) bool { return ent.Message == "retrying" },
+		),
+	))
+
+	logger.Error("canceled", zap.Error(fmt.Errorf("read: %w", context.Canceled)))
+	logger.Error("retrying")
+	logger.Error("failed", zap.Error(errors.New("boom")))
+
+	require.Equal(t, 3, logs.Len())
+
+	for _, log := range logs.All()[:2] {
+		assert.Equal(t, zapcore.ErrorLevel, log.Level)
+		assert.NotContains(t, log.ContextMap(), contextKey)
+		assert.NotContains(t, log.Message, "\n")
+		assert.NotEmpty(t, log.Stack)
+	}
+
+	reported := logs.All()[2]
+	assert.Contains(t, reported.ContextMap(), contextKey)
+	assert.True(t, strings.HasPrefix(reported.Message, "failed\n"))
+}
+
+func TestErrorIs(t *testing.T) {
+	t.Parallel()
+
+	match := ErrorIs(context.Canceled, context.DeadlineExceeded)
+
+	assert.True(t, match(zapcore.Entry{}, []zapcore.Field{zap.Error(context.DeadlineExceeded)}))
+	assert.True(t, match(zapcore.Entry{}, []zapcore.Field{zap.String("a", "b"), zap.NamedError("cause", context.Canceled)}))
+	assert.False(t, match(zapcore.Entry{}, []zapcore.Field{zap.Error(errors.New("boom"))}))
+	assert.False(t, match(zapcore.Entry{}, nil))
+}
@@ -0,0 +1,82 @@
+package zapdriver
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// podLabelPrefix is the prefix of the labels the GKE logging agent adds to the
+// entries of the containers of a pod, for each label of the pod.
+const podLabelPrefix = "k8s-pod/"
+
+// PodLabel adds the label `key` of the pod as a `k8s-pod/<key>` label, the
+// convention of the GKE logging agent for the labels of pods, so queries on pod
+// labels match both the application entries and the entries of the kubelet
+// and container runtime.
+func PodLabel(key, value string) zap.Field {
+	return Label(podLabelPrefix+key, value)
+}
+
+// zapdriver core option to add the `labels` of the pod as `k8s-pod/<key>`
+// permanent labels (see `PodLabel()`), so the entries interleave with the
+// kubelet and container runtime entries in the GKE Logs tab, and to use the
+// `k8s_container` monitored resource of the pod when not set using
+// `Resource()`. The resource is only used by API writers (see `ResourceOf()`),
+// and is detected when first requested. Use `PodLabelsFromFile()` to read the
+// labels of the pod exposed using the downward API
+func KubernetesPod(labels map[string]string) func(*core) {
+	return func(c *core) {
+		for k, v := range labels {
+			c.permLabels.Add(podLabelPrefix+k, v)
+		}
+
+		if c.config.Resource == nil {
+			c.config.Resource = detectedResource(func() *MonitoredResource {
+				return detectKubernetes(ProjectID())
+			})
+		}
+	}
+}
+
+// PodLabelsFromFile reads the labels of the pod from the file at `path`, as
+// exposed by a downward API volume (one `key="value"` pair per line):
+//
+//	volumes:
+//	  - name: podinfo
+//	    downwardAPI:
+//	      items:
+//	        - path: labels
+//	          fieldRef:
+//	            fieldPath: metadata.labels
+func PodLabelsFromFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	labels := map[string]string{}
+
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		key, quoted := split(line, "=")
+		value, err := strconv.Unquote(quoted)
+		if key == "" || err != nil {
+			return nil, fmt.Errorf("zapdriver: invalid pod label at %s:%d: %q", path, n, line)
+		}
+
+		labels[key] = value
+	}
+
+	return labels, scanner.Err()
+}
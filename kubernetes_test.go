@@ -0,0 +1,85 @@
+package zapdriver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestPodLabel(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, Label("k8s-pod/app", "checkout"), PodLabel("app", "checkout"))
+}
+
+func TestKubernetesPod(t *testing.T) {
+	t.Setenv("POD_NAME", "checkout-7d9f")
+	t.Setenv("CONTAINER_NAME", "app")
+	t.Setenv("NAMESPACE", "shop")
+	newMetadataServer(t, map[string]string{
+		"instance/attributes/cluster-name":     "my-cluster",
+		"instance/attributes/cluster-location": "europe-west1",
+	})
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(KubernetesPod(map[string]string{"app": "checkout", "tier": "web"})))
+	logger.Info("hello", PodLabel("tier", "api"))
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, map[string]interface{}{"k8s-pod/app": "checkout", "k8s-pod/tier": "api"}, logs.All()[0].ContextMap()[labelsKey])
+
+	resource := ResourceOf(logger)
+	require.NotNil(t, resource)
+	assert.Equal(t, "k8s_container", resource.Type)
+	assert.Equal(t, "my-cluster", resource.Labels["cluster_name"])
+	assert.Equal(t, "shop", resource.Labels["namespace_name"])
+	assert.Equal(t, "checkout-7d9f", resource.Labels["pod_name"])
+	assert.Equal(t, "app", resource.Labels["container_name"])
+
+	logger = zap.New(debugcore, WrapCore(Resource("generic_task", nil), KubernetesPod(nil)))
+	assert.Equal(t, "generic_task", ResourceOf(logger).Type)
+}
+
+func TestKubernetesPod_Lazy(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+
+	logger := zap.New(zapcore.NewNopCore(), WrapCore(KubernetesPod(map[string]string{"app": "checkout"})))
+	assert.Zero(t, atomic.LoadInt32(&requests), "the resource is detected when requested")
+
+	require.NotNil(t, ResourceOf(logger))
+	assert.NotZero(t, atomic.LoadInt32(&requests))
+}
+
+func TestPodLabelsFromFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "labels")
+	require.NoError(t, os.WriteFile(path, []byte("app=\"checkout\"\npod-template-hash=\"7d9f\"\n\nversion=\"v1 \\\"beta\\\"\"\n"), 0o600))
+
+	labels, err := PodLabelsFromFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "checkout", "pod-template-hash": "7d9f", "version": `v1 "beta"`}, labels)
+
+	require.NoError(t, os.WriteFile(path, []byte("app=checkout\n"), 0o600))
+	_, err = PodLabelsFromFile(path)
+	assert.EqualError(t, err, `zapdriver: invalid pod label at `+path+`:1: "app=checkout"`)
+
+	_, err = PodLabelsFromFile(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
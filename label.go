@@ -1,6 +1,8 @@
 package zapdriver
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 	"sync"
 
@@ -8,7 +10,11 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
-const labelsKey = "logging.googleapis.com/labels"
+const (
+	labelsKey      = "logging.googleapis.com/labels"
+	removeLabelKey = "zapdriver.removeLabel"
+	clearLabelsKey = "zapdriver.clearLabels"
+)
 
 // Label adds an optional label to the payload.
 //
@@ -24,77 +30,414 @@ func Label(key, value string) zap.Field {
 // string `labels.` and their value type set to StringType. It then wraps those
 // key/value pairs in a top-level `labels` namespace.
 func Labels(fields ...zap.Field) zap.Field {
-	lbls := newLabels()
-
-	lbls.mutex.Lock()
+	lbls := NewLabelSet()
 	for i := range fields {
 		if isLabelField(fields[i]) {
-			lbls.store[strings.Replace(fields[i].Key, "labels.", "", 1)] = fields[i].String
+			lbls.Add(labelKey(fields[i]), labelValue(fields[i]))
 		}
 	}
-	lbls.mutex.Unlock()
 
-	return labelsField(lbls)
+	return lbls.Field()
 }
 
-func isLabelField(field zap.Field) bool {
-	return strings.HasPrefix(field.Key, "labels.") && field.Type == zapcore.StringType
+// LabelsMap adds all key/value pairs of the map as labels, in a single field.
+// Unlike `Label()`, the keys are not prefixed, and the field is already wrapped
+// in the `labels` namespace. The zapdriver core merges it with all other labels
+// of the log entry.
+//
+// The map is copied once, which is cheaper than building one field per label
+// for large label maps, such as labels computed elsewhere in the application.
+func LabelsMap(m map[string]string) zap.Field {
+	store := make(map[string]string, len(m))
+	for k, v := range m {
+		store[k] = v
+	}
+
+	return labelsField(&LabelSet{store: store})
 }
 
-func labelsField(l *labels) zap.Field {
-	return zap.Object(labelsKey, l)
+// RemoveLabel removes the label `key` inherited from the parent logger, when
+// passed to `logger.With()`. It is a pseudo-field, which is not encoded, and has
+// no effect without the zapdriver core.
+func RemoveLabel(key string) zap.Field {
+	return zapcore.Field{Key: removeLabelKey, Type: zapcore.SkipType, String: key}
+}
+
+// ClearLabels removes all labels inherited from the parent logger, when passed
+// to `logger.With()`. Like `RemoveLabel()`, it has no effect without the
+// zapdriver core.
+func ClearLabels() zap.Field {
+	return zapcore.Field{Key: clearLabelsKey, Type: zapcore.SkipType}
 }
 
-func mergeLabelFields(fields []zap.Field, newLabels *labels) []zap.Field {
+// pruneLabels removes the labels targeted by the `RemoveLabel()` and
+// `ClearLabels()` pseudo-fields from the label set, and returns the remaining
+// fields.
+func pruneLabels(lbls *LabelSet, fields []zapcore.Field) []zapcore.Field {
+	out := fields[:0:0]
 	for i := range fields {
-		lbls, ok := fields[i].Interface.(*labels)
-		if !ok {
+		if fields[i].Type != zapcore.SkipType {
+			out = append(out, fields[i])
 			continue
 		}
 
-		newLabels.mutex.RLock()
-		defer newLabels.mutex.RUnlock()
+		switch fields[i].Key {
+		case removeLabelKey:
+			lbls.Delete(fields[i].String)
+		case clearLabelsKey:
+			lbls.reset()
+		default:
+			out = append(out, fields[i])
+		}
+	}
+
+	return out
+}
+
+// zapdriver core option to add `labels` as permanent labels to all logs. Unlike
+// `logger.With(zapdriver.Label(...))`, the labels are stored directly in the
+// core, without wrapping it
+func DefaultLabels(labels map[string]string) func(*core) {
+	return func(c *core) {
+		for k, v := range labels {
+			c.permLabels.Add(k, v)
+		}
+	}
+}
+
+// LabelsOf returns a copy of the permanent labels of the logger core: the
+// labels added using `logger.With()` and `DefaultLabels()`, along with the
+// current value of the dynamic labels and the labels of `Scoped()`, following
+// the precedence of the core.
+// Request-handling code can use it to propagate the labels of the logger to
+// spans, metric exemplars or outbound headers. It returns nil if the logger
+// does not use a zapdriver core.
+func LabelsOf(logger *zap.Logger) map[string]string {
+	c, ok := coreOf(logger)
+	if !ok {
+		return nil
+	}
+
+	return c.allLabels(scopedLabelsOf(logger)).Map()
+}
+
+// zapdriver core option to convert the string-like fields with a key starting
+// with any of the `prefixes` (e.g. `tag.`) to labels, instead of the fields with
+// the `labels.` prefix, so codebases using other conventions can adopt
+// zapdriver without renaming their fields, or avoid collisions with payload
+// fields starting with `labels.`. Fields created using `Label()` are only
+// converted when `labels.` is part of the prefixes
+func LabelPrefix(prefixes ...string) func(*core) {
+	return func(c *core) {
+		c.config.LabelPrefixes = prefixes
+	}
+}
+
+// labelOf returns the key of the label, if the field is a label for the core.
+func (c *core) labelOf(field zap.Field) (string, bool) {
+	if c.config.LabelPrefixes == nil {
+		if isLabelField(field) {
+			return labelKey(field), true
+		}
+
+		return "", false
+	}
+
+	for _, prefix := range c.config.LabelPrefixes {
+		if strings.HasPrefix(field.Key, prefix) && hasLabelType(field) {
+			return field.Key[len(prefix):], true
+		}
+	}
+
+	return "", false
+}
+
+// zapdriver core option to give the labels added using `logger.With()` (or
+// `DefaultLabels()`) precedence over the labels of the log entry with the same
+// key, instead of the opposite. Dynamic labels always have the lowest
+// precedence, and among labels of the same kind, the last one added wins
+func PermanentLabelsFirst(enabled bool) func(*core) {
+	return func(c *core) {
+		c.config.PermanentLabelsFirst = enabled
+	}
+}
+
+// zapdriver core option to encode the labels of the entries in key order when
+// set to true, instead of the random order of Go maps, so golden-file tests and
+// line-based diffs of the output are stable. The labels are sorted on every
+// write, so it is disabled by default
+func SortedLabels(sorted bool) func(*core) {
+	return func(c *core) {
+		c.config.SortedLabels = sorted
+	}
+}
+
+// zapdriver core option to add a label with key `key` to all logs, with its
+// value computed by calling `fn` each time an entry is written. Labels set on
+// the log entry itself (or using `With()`) take precedence
+func DynamicLabel(key string, fn func() string) func(*core) {
+	return func(c *core) {
+		c.config.DynamicLabels = append(c.config.DynamicLabels, dynamicLabel{key: key, fn: fn})
+	}
+}
 
-		lbls.mutex.Lock()
-		defer lbls.mutex.Unlock()
+// dynamicLabel is a label whose value is evaluated at write time.
+type dynamicLabel struct {
+	key string
+	fn  func() string
+}
+
+// isLabelField returns true if the field is a label, created using `Label()`,
+// or any other string-like field with a key starting with `labels.`.
+func isLabelField(field zap.Field) bool {
+	return strings.HasPrefix(field.Key, "labels.") && hasLabelType(field)
+}
+
+// hasLabelType returns true if the value of the field can be rendered as a
+// label value.
+func hasLabelType(field zap.Field) bool {
+	switch field.Type {
+	case zapcore.StringType, zapcore.ByteStringType, zapcore.StringerType, zapcore.ErrorType:
+		return true
+	}
+
+	return false
+}
 
-		for k, v := range newLabels.store {
-			lbls.store[k] = v
+// labelKey returns the key of a label field, without the `labels.` prefix.
+func labelKey(field zap.Field) string {
+	return strings.Replace(field.Key, "labels.", "", 1)
+}
+
+// labelValue renders the value of a label field as a string. Like the zap
+// encoders, panics of `String()` and `Error()` methods are recovered, and nil
+// values are rendered as "<nil>".
+func labelValue(field zap.Field) (value string) {
+	switch field.Type {
+	case zapcore.StringType:
+		return field.String
+	case zapcore.ByteStringType:
+		b, _ := field.Interface.([]byte)
+		return string(b)
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			value = fmt.Sprintf("<PANIC=%v>", err)
 		}
+	}()
+
+	switch v := field.Interface.(type) {
+	case fmt.Stringer:
+		return v.String()
+	case error:
+		return v.Error()
+	}
+
+	return "<nil>"
+}
 
-		return fields
+// isLabelsField returns true if the field is a collection of labels, as
+// returned by `Labels()` or `LabelsMap()`.
+func isLabelsField(field zap.Field) bool {
+	if field.Key != labelsKey || field.Type != zapcore.ObjectMarshalerType {
+		return false
 	}
 
-	return append(fields, labelsField(newLabels))
+	_, ok := field.Interface.(*LabelSet)
+	return ok
 }
 
-type labels struct {
+func labelsField(l *LabelSet) zap.Field {
+	return zap.Object(labelsKey, l)
+}
+
+// mergeLabelFields adds the `labels` field holding `lbls` to the fields. The
+// labels fields left in the fields (e.g. resolved from lazy fields) are labels
+// of the entry, merged into a single field following the precedence of the
+// core. Label sets are never modified, as they may be shared with the caller.
+func (c *core) mergeLabelFields(fields []zap.Field, lbls *LabelSet) []zap.Field {
+	out := fields[:0:0]
+	for i := range fields {
+		if !isLabelsField(fields[i]) {
+			out = append(out, fields[i])
+			continue
+		}
+
+		if lbls == fields[i].Interface.(*LabelSet) {
+			continue
+		}
+
+		lbls = c.mergeEntryLabels(lbls.Clone(), fields[i].Interface.(*LabelSet))
+	}
+
+	if c.config.SortedLabels && !lbls.sorted {
+		lbls = lbls.Clone()
+		lbls.sorted = true
+	}
+
+	return append(out, labelsField(lbls))
+}
+
+// LabelSet is a collection of labels. It can be passed to a logger as a field
+// using `Field()`, in which case the zapdriver core merges it with all other
+// labels of the log entry.
+//
+// A LabelSet is safe for concurrent use. The zero value is an empty set ready
+// to use.
+type LabelSet struct {
 	store map[string]string
-	mutex *sync.RWMutex
+	mutex sync.RWMutex
+
+	// sorted encodes the labels in key order when set to true.
+	sorted bool
 }
 
-func newLabels() *labels {
-	return &labels{store: map[string]string{}, mutex: &sync.RWMutex{}}
+// NewLabelSet returns an empty label set.
+func NewLabelSet() *LabelSet {
+	return &LabelSet{store: map[string]string{}}
 }
 
-func (l *labels) Add(key, value string) {
+// Add sets the label `key` to `value`.
+func (l *LabelSet) Add(key, value string) {
 	l.mutex.Lock()
+	if l.store == nil {
+		l.store = map[string]string{}
+	}
 	l.store[key] = value
 	l.mutex.Unlock()
 }
 
-func (l *labels) reset() {
+// Delete removes the label `key`.
+func (l *LabelSet) Delete(key string) {
 	l.mutex.Lock()
-	l.store = map[string]string{}
+	delete(l.store, key)
 	l.mutex.Unlock()
 }
 
-func (l labels) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+// Get returns the value of the label `key`, and whether it is set.
+func (l *LabelSet) Get(key string) (string, bool) {
+	l.mutex.RLock()
+	v, ok := l.store[key]
+	l.mutex.RUnlock()
+
+	return v, ok
+}
+
+// Len returns the number of labels in the set.
+func (l *LabelSet) Len() int {
 	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return len(l.store)
+}
+
+// Map returns a copy of the labels as a map.
+func (l *LabelSet) Map() map[string]string {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	m := make(map[string]string, len(l.store))
 	for k, v := range l.store {
-		enc.AddString(k, v)
+		m[k] = v
+	}
+
+	return m
+}
+
+// Merge adds all labels of `other` to the set, overwriting existing labels with
+// the same key. It returns the set itself, to allow chaining.
+func (l *LabelSet) Merge(other *LabelSet) *LabelSet {
+	if other == nil || other == l {
+		return l
+	}
+
+	// Copy the labels first, so the two sets are never locked together, which
+	// would deadlock when two sets are merged into each other concurrently.
+	// Small sets are copied on the stack, as the labels of each entry are merged.
+	var buf [16]labelPair
+	labels := buf[:0]
+
+	other.mutex.RLock()
+	for k, v := range other.store {
+		labels = append(labels, labelPair{key: k, value: v})
+	}
+	other.mutex.RUnlock()
+
+	l.mutex.Lock()
+	if l.store == nil {
+		l.store = make(map[string]string, len(labels))
+	}
+	for _, label := range labels {
+		l.store[label.key] = label.value
+	}
+	l.mutex.Unlock()
+
+	return l
+}
+
+// labelPair is a label copied out of a set.
+type labelPair struct {
+	key, value string
+}
+
+// Clone returns a copy of the set.
+func (l *LabelSet) Clone() *LabelSet {
+	return &LabelSet{store: l.Map(), sorted: l.sorted}
+}
+
+// Diff returns the labels of the set that are missing from `other`, or have a
+// different value in `other`.
+func (l *LabelSet) Diff(other *LabelSet) *LabelSet {
+	diff := l.Clone()
+	if other == nil {
+		return diff
+	}
+
+	other.mutex.RLock()
+	for k, v := range other.store {
+		if diff.store[k] == v {
+			delete(diff.store, k)
+		}
+	}
+	other.mutex.RUnlock()
+
+	return diff
+}
+
+// Field returns the set as a `labels` field.
+func (l *LabelSet) Field() zap.Field {
+	return labelsField(l)
+}
+
+func (l *LabelSet) reset() {
+	l.mutex.Lock()
+	l.store = map[string]string{}
+	l.mutex.Unlock()
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (l *LabelSet) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if !l.sorted {
+		for k, v := range l.store {
+			enc.AddString(k, v)
+		}
+
+		return nil
+	}
+
+	keys := make([]string, 0, len(l.store))
+	for k := range l.store {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		enc.AddString(k, l.store[k])
 	}
-	l.mutex.RUnlock()
 
 	return nil
 }
@@ -1,10 +1,20 @@
 package zapdriver
 
 import (
+	"bytes"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestLabel(t *testing.T) {
@@ -23,8 +33,344 @@ func TestLabels(t *testing.T) {
 		Label("hi", "universe"),
 	)
 
-	labels := newLabels()
+	labels := NewLabelSet()
 	labels.store = map[string]string{"hello": "world", "hi": "universe"}
 
 	assert.Equal(t, zap.Object(labelsKey, labels), field)
 }
+
+func TestWriteDynamicLabel(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	c := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+
+	var n int32
+	DynamicLabel("count", func() string { return strconv.Itoa(int(atomic.AddInt32(&n, 1))) })(c)
+	DynamicLabel("leader", func() string { return "dynamic" })(c)
+
+	core := c.With([]zapcore.Field{Label("leader", "permanent")})
+
+	require.NoError(t, core.Write(zapcore.Entry{}, nil))
+	require.NoError(t, core.Write(zapcore.Entry{}, []zapcore.Field{Label("count", "entry")}))
+
+	labels := logs.All()[0].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, "1", labels["count"])
+	assert.Equal(t, "permanent", labels["leader"])
+
+	labels = logs.All()[1].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, "entry", labels["count"])
+	assert.Equal(t, int32(2), atomic.LoadInt32(&n))
+}
+
+func TestWriteDefaultLabels(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(DefaultLabels(map[string]string{"env": "prod", "team": "core"})))
+
+	_, ok := logger.Core().(*core)
+	require.True(t, ok)
+
+	logger.Info("hello", Label("team", "platform"))
+	logger.Info("hello")
+
+	labels := logs.All()[0].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"env": "prod", "team": "platform"}, labels)
+
+	labels = logs.All()[1].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"env": "prod", "team": "core"}, labels)
+}
+
+func TestLabelsMap(t *testing.T) {
+	t.Parallel()
+
+	field := LabelsMap(map[string]string{"hello": "world", "hi": "universe"})
+
+	labels := NewLabelSet()
+	labels.store = map[string]string{"hello": "world", "hi": "universe"}
+
+	assert.Equal(t, zap.Object(labelsKey, labels), field)
+}
+
+func TestWriteLabelsMap(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore())
+
+	logger = logger.With(LabelsMap(map[string]string{"one": "1", "two": "2"}), Labels(Label("three", "3")))
+	logger.Info("hello", LabelsMap(map[string]string{"two": "TWO"}), Label("four", "4"))
+
+	require.Len(t, logs.All()[0].Context, 1)
+
+	labels := logs.All()[0].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"one": "1", "two": "TWO", "three": "3", "four": "4"}, labels)
+}
+
+func TestLabelSet(t *testing.T) {
+	t.Parallel()
+
+	set := NewLabelSet()
+	set.Add("one", "1")
+	set.Add("two", "2")
+
+	clone := set.Clone()
+	clone.Add("two", "TWO")
+	clone.Add("three", "3")
+
+	v, ok := set.Get("two")
+	assert.True(t, ok)
+	assert.Equal(t, "2", v)
+	assert.Equal(t, 2, set.Len())
+
+	assert.Equal(t, map[string]string{"two": "TWO", "three": "3"}, clone.Diff(set).Map())
+	assert.Equal(t, map[string]string{"two": "2"}, set.Diff(clone).Map())
+
+	set.Merge(clone).Merge(set).Merge(nil)
+	assert.Equal(t, map[string]string{"one": "1", "two": "TWO", "three": "3"}, set.Map())
+}
+
+func TestLabelSet_ZeroValue(t *testing.T) {
+	t.Parallel()
+
+	var set LabelSet
+	_, ok := set.Get("one")
+	assert.False(t, ok)
+	assert.Equal(t, 0, set.Len())
+	assert.Empty(t, set.Diff(nil).Map())
+
+	set.Add("one", "1")
+	assert.Equal(t, map[string]string{"one": "1"}, set.Map())
+
+	merged := &LabelSet{}
+	merged.Merge(&set)
+	assert.Equal(t, map[string]string{"one": "1"}, merged.Map())
+
+	enc := zapcore.NewMapObjectEncoder()
+	require.NoError(t, (&LabelSet{}).MarshalLogObject(enc))
+	assert.Empty(t, enc.Fields)
+}
+
+func TestLabelSet_MergeConcurrently(t *testing.T) {
+	t.Parallel()
+
+	a, b := NewLabelSet(), NewLabelSet()
+	a.Add("a", "1")
+	b.Add("b", "2")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() { defer wg.Done(); a.Merge(b) }()
+		go func() { defer wg.Done(); b.Merge(a) }()
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, a.Map())
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, b.Map())
+}
+
+func TestLabelSetField(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	core := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+
+	set := NewLabelSet()
+	set.Add("one", "1")
+
+	require.NoError(t, core.Write(zapcore.Entry{}, []zapcore.Field{set.Field(), Label("two", "2")}))
+
+	labels := logs.All()[0].ContextMap()[labelsKey]
+	assert.Equal(t, map[string]interface{}{"one": "1", "two": "2"}, labels)
+	assert.Equal(t, 1, set.Len())
+}
+
+func TestRemoveLabel(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	parent := zapcore.Core(&core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}).With([]zapcore.Field{Label("one", "1"), Label("two", "2"), zap.String("hello", "world")})
+
+	child := parent.With([]zapcore.Field{RemoveLabel("one"), Label("three", "3")})
+	require.NoError(t, child.Write(zapcore.Entry{}, nil))
+	require.NoError(t, parent.Write(zapcore.Entry{}, nil))
+
+	assert.Equal(t, map[string]interface{}{"two": "2", "three": "3"}, logs.All()[0].ContextMap()[labelsKey])
+	assert.Equal(t, map[string]interface{}{"one": "1", "two": "2"}, logs.All()[1].ContextMap()[labelsKey])
+	assert.Equal(t, "world", logs.All()[0].ContextMap()["hello"])
+
+	cleared := child.With([]zapcore.Field{ClearLabels(), Label("four", "4")})
+	require.NoError(t, cleared.Write(zapcore.Entry{}, nil))
+
+	assert.Equal(t, map[string]interface{}{"four": "4"}, logs.All()[2].ContextMap()[labelsKey])
+}
+
+type panicStringer struct{}
+
+func (panicStringer) String() string { panic("boom") }
+
+func TestLabelValue(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		field zapcore.Field
+		want  string
+	}{
+		"string":      {zap.String("labels.peer", "10.0.0.1"), "10.0.0.1"},
+		"stringer":    {zap.Stringer("labels.peer", net.ParseIP("10.0.0.1")), "10.0.0.1"},
+		"error":       {zap.NamedError("labels.cause", errors.New("timeout")), "timeout"},
+		"byte string": {zap.ByteString("labels.raw", []byte("bytes")), "bytes"},
+		"panic":       {zap.Stringer("labels.bad", panicStringer{}), "<PANIC=boom>"},
+	}
+
+	for name, tt := range tests {
+		assert.True(t, isLabelField(tt.field), name)
+		assert.Equal(t, tt.want, labelValue(tt.field), name)
+	}
+
+	assert.False(t, isLabelField(zap.Int("labels.count", 1)))
+	assert.False(t, isLabelField(zap.Stringer("peer", net.ParseIP("10.0.0.1"))))
+}
+
+func TestWriteStringLikeLabels(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	c := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+
+	require.NoError(t, c.With([]zapcore.Field{zap.Stringer("labels.peer", net.ParseIP("10.0.0.1"))}).Write(zapcore.Entry{}, []zapcore.Field{
+		zap.NamedError("labels.cause", errors.New("timeout")),
+		zap.ByteString("labels.raw", []byte("bytes")),
+	}))
+
+	labels := logs.All()[0].ContextMap()[labelsKey]
+	assert.Equal(t, map[string]interface{}{"peer": "10.0.0.1", "cause": "timeout", "raw": "bytes"}, labels)
+}
+
+func TestLabelPrefix(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(LabelPrefix("tag.", "meta.")))
+	logger = logger.With(zap.String("tag.app", "api"))
+
+	logger.Info("hello", zap.String("meta.env", "prod"), Label("payload", "kept"), zap.Int("tag.count", 1))
+
+	require.Equal(t, 1, logs.Len())
+
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, map[string]interface{}{"app": "api", "env": "prod"}, fields[labelsKey])
+	assert.Equal(t, "kept", fields["labels.payload"])
+	assert.Equal(t, int64(1), fields["tag.count"])
+}
+
+func TestLabelPrefix_Default(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(LabelPrefix("labels.", "tag.")))
+
+	logger.Info("hello", zap.String("tag.app", "api"), Label("env", "prod"))
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, map[string]interface{}{"app": "api", "env": "prod"}, logs.All()[0].ContextMap()[labelsKey])
+}
+
+func TestWriteLabelPrecedence(t *testing.T) {
+	t.Parallel()
+
+	for _, permanentFirst := range []bool{false, true} {
+		debugcore, logs := observer.New(zapcore.DebugLevel)
+		logger := zap.New(debugcore, WrapCore(
+			DynamicLabel("key", func() string { return "dynamic" }),
+			PermanentLabelsFirst(permanentFirst),
+		))
+
+		parent := logger.With(Label("key", "parent"), Label("other", "parent"))
+		child := parent.With(Label("key", "child"))
+
+		logger.Info("hello")
+		child.Info("hello", Label("key", "entry"), Label("key", "last"), Label("new", "entry"))
+		child.Info("hello", LabelsMap(map[string]string{"key": "map"}), Label("key", "entry"))
+		child.Info("hello", Lazy(labelsKey, func() interface{} { return LabelsMap(map[string]string{"other": "lazy"}).Interface }))
+
+		labelsOf := func(i int) map[string]interface{} {
+			return logs.All()[i].ContextMap()[labelsKey].(map[string]interface{})
+		}
+
+		assert.Equal(t, map[string]interface{}{"key": "dynamic"}, labelsOf(0))
+
+		if permanentFirst {
+			assert.Equal(t, map[string]interface{}{"key": "child", "other": "parent", "new": "entry"}, labelsOf(1))
+			assert.Equal(t, map[string]interface{}{"key": "child", "other": "parent"}, labelsOf(2))
+			assert.Equal(t, map[string]interface{}{"key": "child", "other": "parent"}, labelsOf(3))
+			continue
+		}
+
+		assert.Equal(t, map[string]interface{}{"key": "last", "other": "parent", "new": "entry"}, labelsOf(1))
+		assert.Equal(t, map[string]interface{}{"key": "entry", "other": "parent"}, labelsOf(2))
+		assert.Equal(t, map[string]interface{}{"key": "child", "other": "lazy"}, labelsOf(3))
+	}
+}
+
+func TestWriteLabelSetNotModified(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore()).With(Label("env", "prod"))
+
+	set := NewLabelSet()
+	set.Add("hello", "world")
+
+	logger.Info("hello", zap.Object("set", set))
+
+	assert.Equal(t, map[string]string{"hello": "world"}, set.Map())
+	assert.Equal(t, map[string]interface{}{"env": "prod"}, logs.All()[0].ContextMap()[labelsKey])
+	assert.Equal(t, map[string]interface{}{"hello": "world"}, logs.All()[0].ContextMap()["set"])
+}
+
+func TestSortedLabels(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	logger := zap.New(zapcore.NewCore(
+		zapcore.NewJSONEncoder(NewProductionEncoderConfig()),
+		zapcore.AddSync(buf),
+		zapcore.DebugLevel,
+	), WrapCore(SortedLabels(true), BigQueryCompatible(true))).With(Label("z.last", "1"))
+
+	for i := 0; i < 10; i++ {
+		logger.Info("hello", Label("m", "2"), Label("a", "3"), Label("k", "4"))
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		assert.Contains(t, line, `"logging.googleapis.com/labels":{"a":"3","k":"4","m":"2","z_last":"1"}`)
+	}
+}
+
+func TestLabelsOf(t *testing.T) {
+	t.Parallel()
+
+	logger := zap.New(zapcore.NewNopCore(), WrapCore(
+		DefaultLabels(map[string]string{"env": "prod", "tenant": "default"}),
+		DynamicLabel("region", func() string { return "europe-west1" }),
+	))
+	child := logger.With(Label("tenant", "acme"), zap.String("hello", "world"))
+
+	assert.Equal(t, map[string]string{"env": "prod", "tenant": "default", "region": "europe-west1"}, LabelsOf(logger))
+	assert.Equal(t, map[string]string{"env": "prod", "tenant": "acme", "region": "europe-west1"}, LabelsOf(child))
+
+	labels := LabelsOf(child)
+	labels["tenant"] = "modified"
+	assert.Equal(t, "acme", LabelsOf(child)["tenant"], "a copy of the labels is returned")
+
+	assert.Nil(t, LabelsOf(zap.NewNop()))
+}
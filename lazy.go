@@ -0,0 +1,63 @@
+package zapdriver
+
+import (
+	"encoding/json"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Lazy adds a field with the value returned by `fn`, which is only called once
+// the entry is known to be written: after `Check()` confirmed the level is
+// enabled, and after the sampling decisions of the zapdriver core. Use it to
+// defer expensive serialization (large structs, DB explain plans) of entries
+// that are most often dropped, such as debug entries.
+//
+// The value is encoded as if passed to `zap.Any()`. Without the zapdriver core,
+// the value is encoded using its JSON representation. When passed to
+// `logger.With()`, `fn` is called once, when the child logger is created.
+func Lazy(key string, fn func() interface{}) zap.Field {
+	return zapcore.Field{Key: key, Type: zapcore.ReflectType, Interface: &lazyValue{fn: fn}}
+}
+
+// lazyValue holds a value computed on first use.
+type lazyValue struct {
+	fn    func() interface{}
+	once  sync.Once
+	value interface{}
+}
+
+func (v *lazyValue) get() interface{} {
+	v.once.Do(func() {
+		v.value = v.fn()
+	})
+
+	return v.value
+}
+
+// MarshalJSON implements json.Marshaler interface.
+func (v *lazyValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.get())
+}
+
+// resolveLazyFields replaces the `Lazy()` fields by their value. The fields are
+// copied before being modified.
+func resolveLazyFields(fields []zapcore.Field) []zapcore.Field {
+	copied := false
+	for i := range fields {
+		v, ok := fields[i].Interface.(*lazyValue)
+		if !ok || fields[i].Type != zapcore.ReflectType {
+			continue
+		}
+
+		if !copied {
+			fields = append([]zapcore.Field{}, fields...)
+			copied = true
+		}
+
+		fields[i] = zap.Any(fields[i].Key, v.get())
+	}
+
+	return fields
+}
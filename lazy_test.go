@@ -0,0 +1,48 @@
+package zapdriver
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLazy(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	field := Lazy("plan", func() interface{} {
+		calls++
+		return map[string]int{"rows": 1}
+	})
+
+	debugcore, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(debugcore, WrapCore(SampleByLabel("key", 1, 0)))
+
+	logger.Debug("disabled", field)
+	assert.Equal(t, 0, calls)
+
+	logger.Info("sampled")
+	logger.Info("sampled", field)
+	assert.Equal(t, 0, calls)
+
+	logger.Info("written", field)
+	assert.Equal(t, 1, calls)
+
+	require.Equal(t, 2, logs.Len())
+	assert.Equal(t, map[string]int{"rows": 1}, logs.All()[1].ContextMap()["plan"])
+}
+
+func TestLazy_WithoutCore(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	logger := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(zapcore.EncoderConfig{}), zapcore.AddSync(buf), zapcore.DebugLevel))
+
+	logger.Info("hello", Lazy("value", func() interface{} { return []int{1, 2} }))
+	assert.JSONEq(t, `{"value": [1, 2]}`, buf.String())
+}
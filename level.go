@@ -0,0 +1,161 @@
+package zapdriver
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	levelChangedByLabel = "level_changed_by"
+	levelChangedAtLabel = "level_changed_at"
+)
+
+// levelServer holds the configuration of an AtomicLevelServer.
+type levelServer struct {
+	resetAfter time.Duration
+}
+
+// zapdriver level server option to reset the level to its initial value,
+// `d` after it was changed, so that verbose levels are only used temporarily
+func LevelResetAfter(d time.Duration) func(*levelServer) {
+	return func(s *levelServer) {
+		s.resetAfter = d
+	}
+}
+
+// AtomicLevelServer exposes a zap AtomicLevel over HTTP (and signals, see
+// `HandleSignals()`), so that operators can change the level of a running
+// service. Every change is logged, with labels identifying who changed the
+// level, and when.
+type AtomicLevelServer struct {
+	level   zap.AtomicLevel
+	initial zapcore.Level
+	logger  *zap.Logger
+	config  levelServer
+
+	mutex *sync.Mutex
+	timer *time.Timer
+}
+
+// NewAtomicLevelServer returns an AtomicLevelServer for `level`, logging level
+// changes using `logger`.
+func NewAtomicLevelServer(level zap.AtomicLevel, logger *zap.Logger, options ...func(*levelServer)) *AtomicLevelServer {
+	s := &AtomicLevelServer{
+		level:   level,
+		initial: level.Level(),
+		logger:  logger,
+		mutex:   &sync.Mutex{},
+	}
+	for _, option := range options {
+		option(&s.config)
+	}
+
+	return s
+}
+
+// levelPayload is the JSON payload of the HTTP handler.
+type levelPayload struct {
+	Level string `json:"level"`
+}
+
+// ServeHTTP returns the current level on GET requests, and changes it on PUT
+// requests, both using a JSON payload such as `{"level": "debug"}` (Stackdriver
+// severities such as "DEBUG" are accepted as well). The caller is identified
+// by the `X-Goog-Authenticated-User-Email` header set by IAP, or by its remote
+// address.
+func (s *AtomicLevelServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var payload levelPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "invalid payload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		level, err := parseLevel(payload.Level)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		who := r.Header.Get("X-Goog-Authenticated-User-Email")
+		if who == "" {
+			who = r.RemoteAddr
+		}
+
+		s.SetLevel(level, who)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(levelPayload{Level: s.level.Level().String()})
+}
+
+// SetLevel changes the level, and logs the change as made by `changedBy`.
+func (s *AtomicLevelServer) SetLevel(level zapcore.Level, changedBy string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.setLevel(level, changedBy)
+
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	if s.config.resetAfter > 0 && level != s.initial {
+		var timer *time.Timer
+		timer = time.AfterFunc(s.config.resetAfter, func() {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+
+			// Stopping the timer does not stop a callback already waiting for
+			// the mutex, which must not reset a level set since.
+			if s.timer != timer {
+				return
+			}
+			s.timer = nil
+
+			s.setLevel(s.initial, "reset")
+		})
+		s.timer = timer
+	}
+}
+
+// setLevel changes the level. The change is logged before the change when the
+// current level logs it, and after the change otherwise, so that the change is
+// logged when either of the levels enables InfoLevel.
+func (s *AtomicLevelServer) setLevel(level zapcore.Level, changedBy string) {
+	from := s.level.Level()
+	if from == level {
+		return
+	}
+
+	log := func() {
+		s.logger.Info(
+			"Log level changed.",
+			zap.Stringer("from", from),
+			zap.Stringer("to", level),
+			Label(levelChangedByLabel, changedBy),
+			Label(levelChangedAtLabel, time.Now().UTC().Format(time.RFC3339)),
+		)
+	}
+
+	if s.level.Enabled(zapcore.InfoLevel) {
+		log()
+		s.level.SetLevel(level)
+		return
+	}
+
+	s.level.SetLevel(level)
+	log()
+}
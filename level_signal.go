@@ -0,0 +1,40 @@
+//go:build !windows
+// +build !windows
+
+package zapdriver
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// HandleSignals switches the level to DebugLevel on SIGUSR1, and back to the
+// initial level on SIGUSR2. It returns a function to stop handling signals.
+func (s *AtomicLevelServer) HandleSignals() (stop func()) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if sig == syscall.SIGUSR1 {
+					s.SetLevel(zapcore.DebugLevel, "signal SIGUSR1")
+				} else {
+					s.SetLevel(s.initial, "signal SIGUSR2")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package zapdriver
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestAtomicLevelServer_HandleSignals(t *testing.T) {
+	s, level, _ := newTestLevelServer()
+
+	stop := s.HandleSignals()
+	defer stop()
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	assert.Eventually(t, func() bool { return level.Level() == zapcore.DebugLevel }, time.Second, time.Millisecond)
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	assert.Eventually(t, func() bool { return level.Level() == zapcore.InfoLevel }, time.Second, time.Millisecond)
+}
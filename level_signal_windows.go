@@ -0,0 +1,7 @@
+package zapdriver
+
+// HandleSignals is a no-op on Windows, which has no SIGUSR1 and SIGUSR2
+// signals. It returns a function to stop handling signals.
+func (s *AtomicLevelServer) HandleSignals() (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,112 @@
+package zapdriver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newTestLevelServer(options ...func(*levelServer)) (*AtomicLevelServer, zap.AtomicLevel, *observer.ObservedLogs) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	debugcore, logs := observer.New(level)
+	logger := zap.New(debugcore, WrapCore())
+
+	return NewAtomicLevelServer(level, logger, options...), level, logs
+}
+
+func TestAtomicLevelServer_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	s, level, logs := newTestLevelServer()
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assert.JSONEq(t, `{"level": "info"}`, rec.Body.String())
+
+	req := httptest.NewRequest("PUT", "/", strings.NewReader(`{"level": "DEBUG"}`))
+	req.Header.Set("X-Goog-Authenticated-User-Email", "accounts.google.com:jane@example.com")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level": "debug"}`, rec.Body.String())
+	assert.Equal(t, zapcore.DebugLevel, level.Level())
+
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "info", fields["from"])
+	assert.Equal(t, "debug", fields["to"])
+
+	labels := fields[labelsKey].(map[string]interface{})
+	assert.Equal(t, "accounts.google.com:jane@example.com", labels[levelChangedByLabel])
+	assert.NotEmpty(t, labels[levelChangedAtLabel])
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("PUT", "/", strings.NewReader(`{"level": "loud"}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("DELETE", "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestAtomicLevelServer_SetLevel(t *testing.T) {
+	t.Parallel()
+
+	s, level, logs := newTestLevelServer()
+
+	// Logged before raising the level.
+	s.SetLevel(zapcore.ErrorLevel, "test")
+	assert.Equal(t, zapcore.ErrorLevel, level.Level())
+	assert.Equal(t, 1, logs.Len())
+
+	// Logged after lowering the level.
+	s.SetLevel(zapcore.InfoLevel, "test")
+	assert.Equal(t, 2, logs.Len())
+
+	// Not logged when unchanged.
+	s.SetLevel(zapcore.InfoLevel, "test")
+	assert.Equal(t, 2, logs.Len())
+}
+
+func TestAtomicLevelServer_ResetAfter(t *testing.T) {
+	t.Parallel()
+
+	s, level, logs := newTestLevelServer(LevelResetAfter(10 * time.Millisecond))
+	s.SetLevel(zapcore.DebugLevel, "test")
+
+	assert.Eventually(t, func() bool {
+		return level.Level() == zapcore.InfoLevel
+	}, time.Second, time.Millisecond)
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "reset", entries[1].ContextMap()[labelsKey].(map[string]interface{})[levelChangedByLabel])
+}
+
+func TestAtomicLevelServer_ResetAfterStale(t *testing.T) {
+	t.Parallel()
+
+	s, level, _ := newTestLevelServer(LevelResetAfter(10 * time.Millisecond))
+	s.SetLevel(zapcore.DebugLevel, "test")
+
+	// The reset callback fires while the level is being changed again, and
+	// waits for the mutex.
+	s.mutex.Lock()
+	time.Sleep(50 * time.Millisecond)
+	s.timer.Stop()
+	s.timer = nil
+	s.setLevel(zapcore.WarnLevel, "test")
+	s.mutex.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, zapcore.WarnLevel, level.Level())
+}
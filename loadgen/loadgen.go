@@ -0,0 +1,197 @@
+// Package loadgen generates configurable mixes of log entries against a logger,
+// to soak test a logging setup and to size its buffers.
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/gridwise/zapdriver"
+)
+
+// errGenerated is the error attached to generated error entries.
+var errGenerated = errors.New("loadgen: generated error")
+
+// Config describes the mix of entries to generate.
+type Config struct {
+	// Duration is the amount of time to generate entries for.
+	Duration time.Duration
+
+	// Workers is the number of goroutines concurrently writing entries. Defaults
+	// to 1.
+	Workers int
+
+	// MessageSize is the size in bytes of the message of each entry.
+	MessageSize int
+
+	// PayloadSize is the size in bytes of an additional string field added to
+	// each entry. No field is added when set to 0.
+	PayloadSize int
+
+	// Labels is the number of labels added to each entry.
+	Labels int
+
+	// Levels is the relative weight of each level in the generated mix. Defaults
+	// to InfoLevel only.
+	Levels map[zapcore.Level]int
+
+	// ErrorRate is the fraction (between 0 and 1) of entries that get an error
+	// attached and are written at ErrorLevel, regardless of Levels.
+	ErrorRate float64
+
+	// Dropped optionally reports the number of entries dropped by the logger
+	// under test, for example the `DroppedEntries` counter of an asynchronous
+	// core.
+	Dropped func() uint64
+}
+
+// Report contains the statistics collected during a run.
+type Report struct {
+	// Entries is the total number of entries written.
+	Entries uint64
+
+	// Errors is the number of entries written at ErrorLevel as errors.
+	Errors uint64
+
+	// Dropped is the number of entries dropped by the logger, as reported by
+	// `Config.Dropped`.
+	Dropped uint64
+
+	// Elapsed is the actual duration of the run, including the final Sync.
+	Elapsed time.Duration
+
+	// Throughput is the number of entries written per second.
+	Throughput float64
+
+	// Allocs is the number of heap allocations per written entry.
+	Allocs float64
+
+	// AllocBytes is the number of allocated heap bytes per written entry.
+	AllocBytes float64
+}
+
+// Run generates entries against the logger until the configured duration is
+// over or the context is canceled, and syncs the logger before reporting.
+func Run(ctx context.Context, logger *zap.Logger, config Config) Report {
+	workers := config.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	levels := newLevelPicker(config.Levels)
+	message := strings.Repeat("m", config.MessageSize)
+	payload := strings.Repeat("p", config.PayloadSize)
+
+	labels := make([]zap.Field, config.Labels)
+	for i := range labels {
+		labels[i] = zapdriver.Label("loadgen_"+strconv.Itoa(i), "value")
+	}
+
+	var dropped uint64
+	if config.Dropped != nil {
+		dropped = config.Dropped()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.Duration)
+	defer cancel()
+
+	var entries, errs uint64
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func(seed int64) {
+			defer wg.Done()
+
+			rnd := rand.New(rand.NewSource(seed)) // nolint: gosec
+			fields := make([]zap.Field, 0, len(labels)+2)
+
+			for ctx.Err() == nil {
+				fields = append(fields[:0], labels...)
+				if payload != "" {
+					fields = append(fields, zap.String("payload", payload))
+				}
+
+				level := levels.pick(rnd)
+				if config.ErrorRate > 0 && rnd.Float64() < config.ErrorRate {
+					level = zapcore.ErrorLevel
+					fields = append(fields, zap.Error(errGenerated))
+					atomic.AddUint64(&errs, 1)
+				}
+
+				if ce := logger.Check(level, message); ce != nil {
+					ce.Write(fields...)
+				}
+				atomic.AddUint64(&entries, 1)
+			}
+		}(start.UnixNano() + int64(i))
+	}
+	wg.Wait()
+
+	_ = logger.Sync()
+
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	report := Report{
+		Entries: entries,
+		Errors:  errs,
+		Elapsed: elapsed,
+	}
+
+	if config.Dropped != nil {
+		report.Dropped = config.Dropped() - dropped
+	}
+
+	if elapsed > 0 {
+		report.Throughput = float64(entries) / elapsed.Seconds()
+	}
+
+	if entries > 0 {
+		report.Allocs = float64(after.Mallocs-before.Mallocs) / float64(entries)
+		report.AllocBytes = float64(after.TotalAlloc-before.TotalAlloc) / float64(entries)
+	}
+
+	return report
+}
+
+// levelPicker picks a random level, based on the relative weight of each level.
+type levelPicker struct {
+	levels []zapcore.Level
+	total  int
+}
+
+func newLevelPicker(weights map[zapcore.Level]int) *levelPicker {
+	p := &levelPicker{}
+
+	for lvl := zapcore.DebugLevel; lvl <= zapcore.ErrorLevel; lvl++ {
+		for i := 0; i < weights[lvl]; i++ {
+			p.levels = append(p.levels, lvl)
+		}
+	}
+
+	if len(p.levels) == 0 {
+		p.levels = []zapcore.Level{zapcore.InfoLevel}
+	}
+
+	p.total = len(p.levels)
+
+	return p
+}
+
+func (p *levelPicker) pick(rnd *rand.Rand) zapcore.Level {
+	return p.levels[rnd.Intn(p.total)]
+}
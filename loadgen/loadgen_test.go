@@ -0,0 +1,43 @@
+package loadgen_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/gridwise/zapdriver"
+	"github.com/gridwise/zapdriver/loadgen"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core, zapdriver.WrapCore())
+
+	report := loadgen.Run(context.Background(), logger, loadgen.Config{
+		Duration:    50 * time.Millisecond,
+		Workers:     2,
+		MessageSize: 16,
+		PayloadSize: 64,
+		Labels:      3,
+		Levels:      map[zapcore.Level]int{zapcore.DebugLevel: 1, zapcore.InfoLevel: 3},
+		ErrorRate:   0.1,
+		Dropped:     func() uint64 { return 0 },
+	})
+
+	assert.NotZero(t, report.Entries)
+	assert.NotZero(t, report.Throughput)
+	assert.Equal(t, report.Entries, uint64(logs.Len()))
+	assert.Equal(t, report.Errors, uint64(logs.FilterLevelExact(zapcore.ErrorLevel).Len()))
+	assert.Zero(t, report.Dropped)
+
+	entry := logs.All()[0]
+	assert.Len(t, entry.Message, 16)
+	assert.Len(t, entry.ContextMap()["logging.googleapis.com/labels"], 3)
+}
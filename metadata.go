@@ -0,0 +1,67 @@
+package zapdriver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// metadataHost is the host of the GCE metadata server, which can be overridden
+// using the `GCE_METADATA_HOST` environment variable.
+const metadataHost = "metadata.google.internal"
+
+// metadataTimeout bounds the time spent waiting for the metadata server, which
+// is unavailable when not running on GCP.
+const metadataTimeout = time.Second
+
+var metadataClient = &http.Client{Timeout: metadataTimeout}
+
+// metadataValue returns the value at `path` (e.g. `instance/id`) of the
+// metadata server.
+//
+// see: https://cloud.google.com/compute/docs/metadata/overview
+func metadataValue(path string) (string, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = metadataHost
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), metadataTimeout)
+	defer cancel()
+
+	url := "http://" + host + "/computeMetadata/v1/" + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	res, err := metadataClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close() // nolint: errcheck
+
+	if res.StatusCode != http.StatusOK {
+		return "", errors.New("zapdriver: metadata server returned " + res.Status)
+	}
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// MonitoredResource is the resource a log entry originates from.
+//
+// see: https://cloud.google.com/logging/docs/api/v2/resource-list
+type MonitoredResource struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels"`
+}
@@ -0,0 +1,138 @@
+package zapdriver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	cloudTraceHeader  = "X-Cloud-Trace-Context"
+	traceparentHeader = "Traceparent"
+)
+
+// idsContextKey is the context key under which the request IDs are stored.
+type idsContextKey struct{}
+
+// IDs are the identifiers attached by the middleware to a request, which can be
+// used to correlate a request with its log entries.
+type IDs struct {
+	// Trace is the trace ID of the request, as received in the
+	// `X-Cloud-Trace-Context` or `traceparent` request header.
+	Trace string
+
+	// SpanID is the span ID of the request, as received in the trace header.
+	SpanID string
+
+	// Sampled is true if the trace header marked the trace as sampled.
+	Sampled bool
+
+	// OperationID identifies all log entries logged during the request. It is
+	// the trace ID if one is available, or a random ID otherwise.
+	OperationID string
+}
+
+// ContextWithIDs returns a copy of the context holding the given IDs.
+func ContextWithIDs(ctx context.Context, ids IDs) context.Context {
+	return context.WithValue(ctx, idsContextKey{}, ids)
+}
+
+// IDsFromContext returns the IDs attached to the context by the middleware, so
+// they can be echoed back to clients in response bodies or headers. The second
+// return value is false if no IDs are attached.
+func IDsFromContext(ctx context.Context) (IDs, bool) {
+	ids, ok := ctx.Value(idsContextKey{}).(IDs)
+	return ids, ok
+}
+
+// middleware holds the middleware configuration.
+type middleware struct {
+	// responseHeader is the (optional) response header used to echo the
+	// operation ID to the client.
+	responseHeader string
+}
+
+// zapdriver middleware option to echo the operation ID of each request in the
+// response header named `name`
+func ResponseHeader(name string) func(*middleware) {
+	return func(m *middleware) {
+		m.responseHeader = name
+	}
+}
+
+// Middleware returns a HTTP middleware which attaches the trace and operation
+// IDs of every request to the request context. The IDs can be retrieved using
+// `IDsFromContext()`.
+func Middleware(options ...func(*middleware)) func(http.Handler) http.Handler {
+	m := &middleware{}
+	for _, option := range options {
+		option(m)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ids := newIDs(r)
+			if m.responseHeader != "" {
+				w.Header().Set(m.responseHeader, ids.OperationID)
+			}
+
+			next.ServeHTTP(w, r.WithContext(ContextWithIDs(r.Context(), ids)))
+		})
+	}
+}
+
+func newIDs(r *http.Request) IDs {
+	ids := IDs{}
+	ids.Trace, ids.SpanID, ids.Sampled = parseTraceHeaders(r.Header)
+
+	ids.OperationID = ids.Trace
+	if ids.OperationID == "" {
+		ids.OperationID = randomID()
+	}
+
+	return ids
+}
+
+// parseTraceHeaders extracts the trace context from either the
+// `X-Cloud-Trace-Context` header, formatted as `TRACE_ID/SPAN_ID;o=OPTIONS`, or
+// the W3C `traceparent` header, formatted as `VERSION-TRACE_ID-SPAN_ID-FLAGS`.
+func parseTraceHeaders(h http.Header) (trace, spanID string, sampled bool) {
+	if v := h.Get(cloudTraceHeader); v != "" {
+		v, options := split(v, ";")
+		trace, spanID = split(v, "/")
+
+		// The span ID is always a decimal number in this header, even when it
+		// has 16 digits, so it is not parsed by `SpanID()`.
+		if id, err := strconv.ParseUint(spanID, 10, 64); err == nil {
+			spanID = SpanIDFromUint64(id)
+		}
+
+		return trace, spanID, options == "o=1"
+	}
+
+	if parts := strings.Split(h.Get(traceparentHeader), "-"); len(parts) == 4 {
+		// The sampled flag is the lowest bit of the hexadecimal trace flags.
+		flags, err := strconv.ParseUint(parts[3], 16, 8)
+		return parts[1], parts[2], err == nil && flags&1 == 1
+	}
+
+	return "", "", false
+}
+
+func split(s, sep string) (string, string) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):]
+	}
+
+	return s, ""
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
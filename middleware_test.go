@@ -0,0 +1,99 @@
+package zapdriver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDsFromContext(t *testing.T) {
+	t.Parallel()
+
+	_, ok := IDsFromContext(context.Background())
+	assert.False(t, ok)
+
+	ids := IDs{Trace: "trace", OperationID: "trace"}
+	got, ok := IDsFromContext(ContextWithIDs(context.Background(), ids))
+
+	require.True(t, ok)
+	assert.Equal(t, ids, got)
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		header http.Header
+		want   IDs
+	}{
+		"cloud trace": {
+			http.Header{cloudTraceHeader: []string{"105445aa7843bc8bf206b120001000/123;o=1"}},
+			IDs{Trace: "105445aa7843bc8bf206b120001000", SpanID: "000000000000007b", Sampled: true, OperationID: "105445aa7843bc8bf206b120001000"},
+		},
+
+		"cloud trace with 16-digit span": {
+			http.Header{cloudTraceHeader: []string{"105445aa7843bc8bf206b120001000/1234567890123456;o=0"}},
+			IDs{Trace: "105445aa7843bc8bf206b120001000", SpanID: "000462d53c8abac0", OperationID: "105445aa7843bc8bf206b120001000"},
+		},
+
+		"cloud trace without span": {
+			http.Header{cloudTraceHeader: []string{"105445aa7843bc8bf206b120001000"}},
+			IDs{Trace: "105445aa7843bc8bf206b120001000", OperationID: "105445aa7843bc8bf206b120001000"},
+		},
+
+		"traceparent": {
+			http.Header{traceparentHeader: []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}},
+			IDs{Trace: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true, OperationID: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		},
+
+		"traceparent with other flags": {
+			http.Header{traceparentHeader: []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03"}},
+			IDs{Trace: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true, OperationID: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		},
+
+		"traceparent not sampled": {
+			http.Header{traceparentHeader: []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-02"}},
+			IDs{Trace: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", OperationID: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		},
+
+		"traceparent invalid flags": {
+			http.Header{traceparentHeader: []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-x1"}},
+			IDs{Trace: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", OperationID: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			var got IDs
+			handler := Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = IDsFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header = tt.header
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMiddleware_ResponseHeader(t *testing.T) {
+	t.Parallel()
+
+	var got IDs
+	handler := Middleware(ResponseHeader("X-Request-Id"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = IDsFromContext(r.Context())
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	assert.Len(t, got.OperationID, 32)
+	assert.Equal(t, got.OperationID, rec.Header().Get("X-Request-Id"))
+}
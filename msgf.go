@@ -0,0 +1,102 @@
+package zapdriver
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Message is a formatted message, with the arguments of its format as
+// structured fields.
+type Message struct {
+	// Text is the formatted message.
+	Text string
+
+	// Fields are the arguments of the format, named `arg0`, `arg1`, ... after
+	// their position, or after the name given using `Arg()`.
+	Fields []zap.Field
+}
+
+// Msgf formats the message like `fmt.Sprintf`, and returns it along with the
+// format arguments as structured fields, so the message is readable while
+// queries can still filter on the underlying values:
+//
+//	msg := zapdriver.Msgf("User %s bought %d items", zapdriver.Arg("user", id), n)
+//	logger.Info(msg.Text, msg.Fields...) // {"message": "User 42 bought 3 items", "user": "42", "arg1": 3}
+func Msgf(format string, args ...interface{}) Message {
+	fields := make([]zap.Field, len(args))
+	for i, arg := range args {
+		if named, ok := arg.(namedArg); ok {
+			fields[i] = zap.Any(named.name, named.value)
+			continue
+		}
+
+		fields[i] = zap.Any("arg"+strconv.Itoa(i), arg)
+	}
+
+	return Message{Text: fmt.Sprintf(format, args...), Fields: fields}
+}
+
+// Arg names a format argument of `Msgf()`, which is formatted like `value`.
+func Arg(name string, value interface{}) interface{} {
+	return namedArg{name: name, value: value}
+}
+
+// namedArg is a format argument with the name of its field.
+type namedArg struct {
+	name  string
+	value interface{}
+}
+
+// Format implements fmt.Formatter interface, formatting the value with the same
+// verb and flags.
+func (a namedArg) Format(f fmt.State, verb rune) {
+	directive := "%"
+	for _, flag := range "+-# 0" {
+		if f.Flag(int(flag)) {
+			directive += string(flag)
+		}
+	}
+	if width, ok := f.Width(); ok {
+		directive += strconv.Itoa(width)
+	}
+	if precision, ok := f.Precision(); ok {
+		directive += "." + strconv.Itoa(precision)
+	}
+
+	fmt.Fprintf(f, directive+string(verb), a.value)
+}
+
+// Debugf logs a message formatted by `Msgf()` at the Debug level.
+func Debugf(logger *zap.Logger, format string, args ...interface{}) {
+	logf(logger, zapcore.DebugLevel, format, args)
+}
+
+// Infof logs a message formatted by `Msgf()` at the Info level.
+func Infof(logger *zap.Logger, format string, args ...interface{}) {
+	logf(logger, zapcore.InfoLevel, format, args)
+}
+
+// Warnf logs a message formatted by `Msgf()` at the Warn level.
+func Warnf(logger *zap.Logger, format string, args ...interface{}) {
+	logf(logger, zapcore.WarnLevel, format, args)
+}
+
+// Errorf logs a message formatted by `Msgf()` at the Error level.
+func Errorf(logger *zap.Logger, format string, args ...interface{}) {
+	logf(logger, zapcore.ErrorLevel, format, args)
+}
+
+// logf logs the formatted message, unless the level is disabled, in which case
+// the message is not formatted. The caller of the exported helpers is reported
+// as the caller of the entry.
+func logf(logger *zap.Logger, level zapcore.Level, format string, args []interface{}) {
+	logger = logger.WithOptions(zap.AddCallerSkip(2))
+	if ce := logger.Check(level, ""); ce != nil {
+		msg := Msgf(format, args...)
+		ce.Entry.Message = msg.Text
+		ce.Write(msg.Fields...)
+	}
+}
@@ -0,0 +1,55 @@
+package zapdriver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestMsgf(t *testing.T) {
+	t.Parallel()
+
+	msg := Msgf("User %s bought %03d items for %.2f", Arg("user", "ada"), 7, Arg("total", 12.5))
+
+	assert.Equal(t, "User ada bought 007 items for 12.50", msg.Text)
+	assert.Equal(t, []zap.Field{
+		zap.Any("user", "ada"),
+		zap.Any("arg1", 7),
+		zap.Any("total", 12.5),
+	}, msg.Fields)
+}
+
+func TestMsgf_Flags(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "[  -42] [+3] [0x1f]", Msgf("[%5d] [%+d] [%#x]", Arg("a", -42), Arg("b", 3), Arg("c", 31)).Text)
+}
+
+func TestInfof(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(debugcore, zap.AddCaller())
+
+	Debugf(logger, "disabled %d", 1)
+	Infof(logger, "order %s shipped", Arg("orderId", "o-1"))
+	Warnf(logger, "retry %d of %d", 2, 3)
+	Errorf(logger, "failed")
+
+	require.Equal(t, 3, logs.Len())
+
+	assert.Equal(t, "order o-1 shipped", logs.All()[0].Message)
+	assert.Equal(t, map[string]interface{}{"orderId": "o-1"}, logs.All()[0].ContextMap())
+	assert.True(t, strings.HasSuffix(logs.All()[0].Caller.File, "msgf_test.go"), logs.All()[0].Caller.File)
+
+	assert.Equal(t, zapcore.WarnLevel, logs.All()[1].Level)
+	assert.Equal(t, map[string]interface{}{"arg0": int64(2), "arg1": int64(3)}, logs.All()[1].ContextMap())
+
+	assert.Equal(t, zapcore.ErrorLevel, logs.All()[2].Level)
+	assert.Empty(t, logs.All()[2].Context)
+}
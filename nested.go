@@ -0,0 +1,94 @@
+package zapdriver
+
+import (
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// googleKeyPrefix is the prefix of all the special keys interpreted by
+// Stackdriver. These keys contain dots, but should never be nested.
+const googleKeyPrefix = "logging.googleapis.com/"
+
+// isNestedField returns true if the field key contains a dot-separated
+// namespace (e.g. `db.query`) that should be expanded into a nested object.
+// The keys of the error context (e.g. `context.user`) are merged into the
+// error context instead.
+func isNestedField(field zapcore.Field) bool {
+	if strings.HasPrefix(field.Key, googleKeyPrefix) {
+		return false
+	}
+
+	switch field.Key {
+	case errorUserKey, errorGroupKey, errorHTTPKey:
+		return false
+	}
+
+	i := strings.Index(field.Key, ".")
+
+	return i > 0 && i < len(field.Key)-1
+}
+
+// nestFields groups all fields sharing the same dot-separated namespace into a
+// single object field, keeping the order in which the namespaces first appear.
+//
+// For example, `db.query` and `db.rows` are rewritten into a single `db` object
+// field containing the `query` and `rows` keys.
+func nestFields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, 0, len(fields))
+	groups := map[string]*fieldGroup{}
+
+	for i := range fields {
+		if !isNestedField(fields[i]) {
+			out = append(out, fields[i])
+			continue
+		}
+
+		field := fields[i]
+		j := strings.Index(field.Key, ".")
+		namespace := field.Key[:j]
+		field.Key = field.Key[j+1:]
+
+		if group, ok := groups[namespace]; ok {
+			group.fields = append(group.fields, field)
+			continue
+		}
+
+		group := &fieldGroup{fields: []zapcore.Field{field}}
+		groups[namespace] = group
+		out = append(out, zap.Object(namespace, group))
+	}
+
+	return out
+}
+
+// fieldGroup is a collection of fields that share the same namespace.
+type fieldGroup struct {
+	fields []zapcore.Field
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (g fieldGroup) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, field := range nestFields(g.fields) {
+		field.AddTo(enc)
+	}
+
+	return nil
+}
+
+func extractNestedFields(fields []zapcore.Field) ([]zapcore.Field, []zapcore.Field) {
+	nested := []zapcore.Field{}
+	out := []zapcore.Field{}
+
+	for i := range fields {
+		if isNestedField(fields[i]) {
+			nested = append(nested, fields[i])
+			continue
+		}
+
+		out = append(out, fields[i])
+	}
+
+	return nested, out
+}
@@ -0,0 +1,97 @@
+package zapdriver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNestFields(t *testing.T) {
+	t.Parallel()
+
+	fields := []zap.Field{
+		zap.String("hello", "world"),
+		zap.String("db.query", "SELECT 1"),
+		zap.Int("db.rows", 1),
+		zap.String("db.conn.host", "localhost"),
+		zap.String(traceKey, "trace"),
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range nestFields(fields) {
+		field.AddTo(enc)
+	}
+
+	want := map[string]interface{}{
+		"hello": "world",
+		"db": map[string]interface{}{
+			"query": "SELECT 1",
+			"rows":  int64(1),
+			"conn":  map[string]interface{}{"host": "localhost"},
+		},
+		traceKey: "trace",
+	}
+
+	assert.Equal(t, want, enc.Fields)
+}
+
+func TestIsNestedField(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]bool{
+		"db.query":     true,
+		"db":           false,
+		".db":          false,
+		"db.":          false,
+		labelsKey:      false,
+		sourceKey:      false,
+		"a.b.c.d.e.f.": true,
+		errorUserKey:   false,
+		errorGroupKey:  false,
+		errorHTTPKey:   false,
+	}
+
+	for key, want := range tests {
+		assert.Equal(t, want, isNestedField(zap.String(key, "")), key)
+	}
+}
+
+func TestWriteNestDottedKeys(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	core := zapcore.Core(&core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+		config: driverConfig{
+			NestFields: true,
+		},
+	})
+
+	core = core.With([]zapcore.Field{zap.String("db.name", "users"), Label("one", "world")})
+	err := core.Write(zapcore.Entry{}, []zapcore.Field{zap.String("db.query", "SELECT 1")})
+	require.NoError(t, err)
+
+	db := logs.All()[0].ContextMap()["db"].(map[string]interface{})
+	assert.Equal(t, "users", db["name"])
+	assert.Equal(t, "SELECT 1", db["query"])
+
+	labels := logs.All()[0].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, "world", labels["one"])
+}
+
+func TestWriteNestDottedKeys_ErrorContext(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, zap.AddCaller(), WrapCore(NestDottedKeys(true), ReportAllErrors(true)))
+
+	logger.Error("failed", ErrorUser("jane"), zap.String("db.query", "SELECT 1"))
+
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "jane", fields[contextKey].(map[string]interface{})["user"])
+	assert.Equal(t, map[string]interface{}{"query": "SELECT 1"}, fields["db"])
+}
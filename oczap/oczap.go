@@ -0,0 +1,38 @@
+// Package oczap converts OpenCensus spans into the trace context fields of
+// zapdriver, so entries written in a span are correlated with it in Cloud
+// Trace.
+package oczap
+
+import (
+	"context"
+
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+
+	"github.com/gridwise/zapdriver"
+)
+
+// TraceFromSpan returns the trace, span and sampled fields of the span, with
+// the trace resource name referencing the project `projectID`. When the
+// project is empty, it is detected using `zapdriver.ProjectID()`. It returns no
+// fields for a nil span.
+//
+//	ctx, span := trace.StartSpan(ctx, "handler")
+//	defer span.End()
+//
+//	logger := logger.With(oczap.TraceFromSpan(span, "my-project")...)
+func TraceFromSpan(span *trace.Span, projectID string) []zap.Field {
+	if span == nil {
+		return nil
+	}
+
+	sc := span.SpanContext()
+
+	return zapdriver.TraceContext(sc.TraceID.String(), zapdriver.SpanIDFromBytes(sc.SpanID), sc.IsSampled(), projectID)
+}
+
+// TraceFromContext returns the trace context fields of the span of the
+// context, if any (see `TraceFromSpan()`).
+func TraceFromContext(ctx context.Context, projectID string) []zap.Field {
+	return TraceFromSpan(trace.FromContext(ctx), projectID)
+}
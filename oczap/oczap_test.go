@@ -0,0 +1,53 @@
+package oczap_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/gridwise/zapdriver/oczap"
+)
+
+func TestTraceFromSpan(t *testing.T) {
+	t.Parallel()
+
+	ctx, span := trace.StartSpan(context.Background(), "test", trace.WithSampler(trace.AlwaysSample()))
+	defer span.End()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	zap.New(debugcore).Info("hello", oczap.TraceFromContext(ctx, "my-project")...)
+
+	require.Equal(t, 1, logs.Len())
+
+	sc := span.SpanContext()
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "projects/my-project/traces/"+sc.TraceID.String(), fields["logging.googleapis.com/trace"])
+	assert.Equal(t, sc.SpanID.String(), fields["logging.googleapis.com/spanId"])
+	assert.Equal(t, true, fields["logging.googleapis.com/trace_sampled"])
+}
+
+func TestTraceFromSpan_NotSampled(t *testing.T) {
+	t.Parallel()
+
+	_, span := trace.StartSpan(context.Background(), "test", trace.WithSampler(trace.NeverSample()))
+	defer span.End()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	zap.New(debugcore).Info("hello", oczap.TraceFromSpan(span, "my-project")...)
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, false, logs.All()[0].ContextMap()["logging.googleapis.com/trace_sampled"])
+}
+
+func TestTraceFromSpan_Nil(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, oczap.TraceFromSpan(nil, "my-project"))
+	assert.Empty(t, oczap.TraceFromContext(context.Background(), "my-project"))
+}
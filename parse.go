@@ -0,0 +1,86 @@
+package zapdriver
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ParsedEntry is the typed representation of a log entry emitted by
+// zapdriver. Special Stackdriver fields are decoded into their own struct
+// fields, all other fields are kept in Payload.
+type ParsedEntry struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Severity   Severity  `json:"severity"`
+	Message    string    `json:"message"`
+	Logger     string    `json:"logger,omitempty"`
+	Caller     string    `json:"caller,omitempty"`
+	Stacktrace string    `json:"stacktrace,omitempty"`
+
+	Labels         map[string]string     `json:"logging.googleapis.com/labels,omitempty"`
+	SourceLocation *ParsedSourceLocation `json:"logging.googleapis.com/sourceLocation,omitempty"`
+	Operation      *ParsedOperation      `json:"logging.googleapis.com/operation,omitempty"`
+	HTTPRequest    *HTTPPayload          `json:"httpRequest,omitempty"`
+	Trace          string                `json:"logging.googleapis.com/trace,omitempty"`
+	SpanID         string                `json:"logging.googleapis.com/spanId,omitempty"`
+	TraceSampled   bool                  `json:"logging.googleapis.com/trace_sampled,omitempty"`
+	ServiceContext *ParsedServiceContext `json:"serviceContext,omitempty"`
+	ErrorContext   *ParsedErrorContext   `json:"context,omitempty"`
+
+	// Payload contains all the fields that are not special Stackdriver fields.
+	Payload map[string]interface{} `json:"-"`
+}
+
+// ParsedSourceLocation is the decoded `SourceLocation()` field.
+type ParsedSourceLocation struct {
+	File     string `json:"file"`
+	Line     string `json:"line"`
+	Function string `json:"function"`
+}
+
+// ParsedOperation is the decoded `Operation()` field.
+type ParsedOperation struct {
+	ID       string `json:"id"`
+	Producer string `json:"producer"`
+	First    bool   `json:"first"`
+	Last     bool   `json:"last"`
+}
+
+// ParsedServiceContext is the decoded `ServiceContext()` field.
+type ParsedServiceContext struct {
+	Name    string `json:"service"`
+	Version string `json:"version"`
+}
+
+// ParsedErrorContext is the decoded `ErrorReport()` field.
+type ParsedErrorContext struct {
+	ReportLocation struct {
+		File     string `json:"filePath"`
+		Line     int    `json:"lineNumber"`
+		Function string `json:"functionName"`
+	} `json:"reportLocation"`
+}
+
+// parsedKeys are the keys decoded into the struct fields of ParsedEntry.
+var parsedKeys = []string{
+	"timestamp", "severity", "message", "logger", "caller", "stacktrace",
+	labelsKey, sourceKey, operationKey, "httpRequest", traceKey, spanKey,
+	traceSampledKey, serviceContextKey, contextKey,
+}
+
+// ParseEntry parses a single JSON log line emitted by zapdriver.
+func ParseEntry(line []byte) (ParsedEntry, error) {
+	var entry ParsedEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return ParsedEntry{}, err
+	}
+
+	if err := json.Unmarshal(line, &entry.Payload); err != nil {
+		return ParsedEntry{}, err
+	}
+
+	for _, key := range parsedKeys {
+		delete(entry.Payload, key)
+	}
+
+	return entry, nil
+}
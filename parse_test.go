@@ -0,0 +1,65 @@
+package zapdriver_test
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/gridwise/zapdriver"
+)
+
+func TestParseEntry(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	core := zapcore.NewCore(
+		zapcore.NewJSONEncoder(zapdriver.NewProductionEncoderConfig()),
+		zapcore.AddSync(buf),
+		zapcore.DebugLevel,
+	)
+	logger := zap.New(core, zapdriver.WrapCore(
+		zapdriver.ReportAllErrors(true),
+		zapdriver.ServiceName("my service"),
+	), zap.AddCaller())
+
+	pc, file, line, ok := runtime.Caller(0)
+	logger.Error(
+		"Something happened!",
+		zap.Error(errors.New("boom")),
+		zapdriver.Label("hello", "world"),
+		zapdriver.OperationStart("id", "producer"),
+		zapdriver.HTTP(&zapdriver.HTTPPayload{RequestMethod: "GET", Status: 500}),
+		zapdriver.SourceLocation(pc, file, line, ok),
+	)
+
+	entry, err := zapdriver.ParseEntry(buf.Bytes())
+	require.NoError(t, err)
+
+	assert.Equal(t, zapdriver.SeverityError, entry.Severity)
+	assert.Equal(t, "Something happened!", entry.Message)
+	assert.False(t, entry.Timestamp.IsZero())
+	assert.Equal(t, map[string]string{"hello": "world"}, entry.Labels)
+	assert.Equal(t, "id", entry.Operation.ID)
+	assert.True(t, entry.Operation.First)
+	assert.Equal(t, 500, entry.HTTPRequest.Status)
+	assert.Equal(t, file, entry.SourceLocation.File)
+	assert.Equal(t, "my service", entry.ServiceContext.Name)
+	assert.NotEmpty(t, entry.ErrorContext.ReportLocation.Function)
+	assert.Equal(t, map[string]interface{}{"error": "boom"}, entry.Payload)
+}
+
+func TestParseEntry_Invalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := zapdriver.ParseEntry([]byte(`{"severity": "LOUD"}`))
+	assert.Error(t, err)
+
+	_, err = zapdriver.ParseEntry([]byte(`nope`))
+	assert.Error(t, err)
+}
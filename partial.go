@@ -0,0 +1,71 @@
+package zapdriver
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxPartialFailureSamples is the maximum number of errors included in a
+// partial failure summary.
+const maxPartialFailureSamples = 10
+
+// PartialFailure adds a structured summary of a partially failed batch
+// operation under `key`, containing the number of failed and total items, and
+// a bounded sample of the errors that caused the failures.
+//
+// Example: { "failed": 2, "succeeded": 8, "total": 10, "samples": ["timeout"] }.
+func PartialFailure(key string, failed, total int, samples []error) zap.Field {
+	return zap.Object(key, newPartialFailure(failed, total, samples))
+}
+
+// partialFailure is the summary of a partially failed batch operation.
+type partialFailure struct {
+	Failed  int      `json:"failed"`
+	Total   int      `json:"total"`
+	Samples []string `json:"samples"`
+
+	// Omitted is the number of errors not included in the samples.
+	Omitted int `json:"omitted"`
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (p partialFailure) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt("failed", p.Failed)
+	enc.AddInt("succeeded", p.Total-p.Failed)
+	enc.AddInt("total", p.Total)
+
+	if len(p.Samples) > 0 {
+		_ = enc.AddArray("samples", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+			for _, sample := range p.Samples {
+				arr.AppendString(sample)
+			}
+
+			return nil
+		}))
+	}
+
+	if p.Omitted > 0 {
+		enc.AddInt("omitted", p.Omitted)
+	}
+
+	return nil
+}
+
+func newPartialFailure(failed, total int, samples []error) *partialFailure {
+	p := &partialFailure{Failed: failed, Total: total}
+
+	for _, err := range samples {
+		if err == nil {
+			continue
+		}
+
+		if len(p.Samples) == maxPartialFailureSamples {
+			p.Omitted++
+			continue
+		}
+
+		p.Samples = append(p.Samples, err.Error())
+	}
+
+	return p
+}
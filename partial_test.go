@@ -0,0 +1,42 @@
+package zapdriver
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestPartialFailure(t *testing.T) {
+	t.Parallel()
+
+	samples := []error{errors.New("one"), nil, errors.New("two")}
+	field := PartialFailure("batch", 3, 10, samples)
+
+	enc := zapcore.NewMapObjectEncoder()
+	field.AddTo(enc)
+
+	want := map[string]interface{}{
+		"failed":    3,
+		"succeeded": 7,
+		"total":     10,
+		"samples":   []interface{}{"one", "two"},
+	}
+
+	assert.Equal(t, want, enc.Fields["batch"])
+}
+
+func TestNewPartialFailure_BoundedSamples(t *testing.T) {
+	t.Parallel()
+
+	samples := make([]error, maxPartialFailureSamples+5)
+	for i := range samples {
+		samples[i] = errors.New("failed")
+	}
+
+	got := newPartialFailure(len(samples), 100, samples)
+
+	assert.Len(t, got.Samples, maxPartialFailureSamples)
+	assert.Equal(t, 5, got.Omitted)
+}
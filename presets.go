@@ -0,0 +1,103 @@
+package zapdriver
+
+import (
+	"os"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewGKE builds a logger for GKE workloads. Entries below ErrorLevel are
+// written to standard output, and all other entries to standard error, so the
+// GKE logging agent assigns the right severity even to lines it fails to parse.
+// The `k8s_container` monitored resource is detected (see `DetectResource()`),
+// and trace IDs are qualified with the project of the cluster, both detected
+// when first needed.
+//
+// The service context is set from the `ZAPDRIVER_SERVICE_NAME` and
+// `ZAPDRIVER_SERVICE_VERSION` environment variables, when set.
+func NewGKE(options ...zap.Option) (*zap.Logger, error) {
+	config := presetConfig()
+	config.SplitStreams = true
+	config.options = []func(*core){DetectResource(), detectTraceProject()}
+
+	return config.Build(options...)
+}
+
+// NewCloudRun builds a logger for Cloud Run services and jobs. Entries below
+// ErrorLevel are written to standard output, and all other entries to
+// standard error. The `cloud_run_revision` resource and the Cloud Run labels
+// are detected when first needed (see `DetectCloudRun()`), trace IDs are
+// qualified with the project of the service, and the service context is set
+// from the service name (`K_SERVICE`) and revision (`K_REVISION`).
+func NewCloudRun(options ...zap.Option) (*zap.Logger, error) {
+	config := presetConfig()
+	config.SplitStreams = true
+	config.ServiceName = firstEnv("ZAPDRIVER_SERVICE_NAME", "K_SERVICE")
+	config.ServiceVersion = firstEnv("ZAPDRIVER_SERVICE_VERSION", "K_REVISION")
+	config.options = []func(*core){DetectCloudRun(), detectTraceProject()}
+
+	return config.Build(options...)
+}
+
+// NewGCE builds a logger for Compute Engine instances, writing all entries to
+// standard error for the Ops Agent to collect. The `gce_instance` monitored
+// resource is detected (see `DetectResource()`), and trace IDs are qualified
+// with the project of the instance, both when first needed.
+//
+// The service context is set from the `ZAPDRIVER_SERVICE_NAME` and
+// `ZAPDRIVER_SERVICE_VERSION` environment variables, when set.
+func NewGCE(options ...zap.Option) (*zap.Logger, error) {
+	config := presetConfig()
+	config.options = []func(*core){DetectResource(), detectTraceProject()}
+
+	return config.Build(options...)
+}
+
+// NewLocal builds a logger for local development, writing DebugLevel and above
+// entries to standard error using the development encoder (see
+// `NewDevelopmentEncoder()`), which renders severities, labels and the source
+// location in a human-friendly format. Entries are not sampled.
+func NewLocal(options ...zap.Option) (*zap.Logger, error) {
+	config := presetConfig()
+	config.Development = true
+	config.Level = zapcore.DebugLevel
+	config.Sampling = false
+
+	return config.Build(options...)
+}
+
+// NewAuto builds a logger using the preset of the environment the process runs
+// in: `NewCloudRun()` when the `K_SERVICE` environment variable is set,
+// `NewGKE()` when running on Kubernetes, `NewGCE()` otherwise, as long as the
+// metadata server responds. `NewLocal()` is used when the metadata server is
+// unavailable, which takes up to a second to detect.
+func NewAuto(options ...zap.Option) (*zap.Logger, error) {
+	return detectPreset()(options...)
+}
+
+// detectPreset returns the preset of the current environment.
+func detectPreset() func(...zap.Option) (*zap.Logger, error) {
+	if _, err := metadataValue("instance/id"); err != nil {
+		return NewLocal
+	}
+
+	if os.Getenv("K_SERVICE") != "" {
+		return NewCloudRun
+	}
+
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return NewGKE
+	}
+
+	return NewGCE
+}
+
+// presetConfig returns the default configuration of the presets.
+func presetConfig() Config {
+	config := NewConfig()
+	config.ServiceName = os.Getenv("ZAPDRIVER_SERVICE_NAME")
+	config.ServiceVersion = os.Getenv("ZAPDRIVER_SERVICE_VERSION")
+
+	return config
+}
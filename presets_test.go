@@ -0,0 +1,105 @@
+package zapdriver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNewAuto(t *testing.T) {
+	metadata := map[string]string{
+		"project/project-id": "my-project",
+		"instance/id":        "123",
+		"instance/zone":      "projects/1/zones/europe-west1-b",
+		"instance/region":    "projects/1/regions/europe-west1",
+	}
+
+	var tests = map[string]struct {
+		env      map[string]string
+		resource string
+		debug    bool
+	}{
+		"cloud run": {map[string]string{"K_SERVICE": "my-service", "K_REVISION": "my-service-00001-abc"}, "cloud_run_revision", false},
+		"gke":       {map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1"}, "k8s_container", false},
+		"gce":       {nil, "gce_instance", false},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("K_SERVICE", "")
+			t.Setenv("KUBERNETES_SERVICE_HOST", "")
+			t.Setenv("ZAPDRIVER_SERVICE_NAME", "")
+			t.Setenv("ZAPDRIVER_SERVICE_VERSION", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			newMetadataServer(t, metadata)
+
+			logger, err := NewAuto()
+			require.NoError(t, err)
+
+			require.NotNil(t, ResourceOf(logger))
+			assert.Equal(t, tt.resource, ResourceOf(logger).Type)
+			assert.Equal(t, tt.debug, logger.Core().Enabled(zapcore.DebugLevel))
+		})
+	}
+
+	t.Run("local", func(t *testing.T) {
+		t.Setenv("GCE_METADATA_HOST", "127.0.0.1:1")
+
+		logger, err := NewAuto()
+		require.NoError(t, err)
+
+		assert.Nil(t, ResourceOf(logger))
+		assert.True(t, logger.Core().Enabled(zapcore.DebugLevel))
+	})
+}
+
+func TestNewCloudRun(t *testing.T) {
+	t.Setenv("ZAPDRIVER_SERVICE_NAME", "")
+	t.Setenv("ZAPDRIVER_SERVICE_VERSION", "")
+	t.Setenv("K_SERVICE", "my-service")
+	t.Setenv("K_REVISION", "my-service-00001-abc")
+	newMetadataServer(t, map[string]string{"instance/id": "00bf4bf02d"})
+
+	logger, err := NewCloudRun()
+	require.NoError(t, err)
+
+	c, ok := logger.Core().(*core)
+	require.True(t, ok)
+	assert.Equal(t, "my-service", c.config.ServiceName)
+	assert.Equal(t, "my-service-00001-abc", c.config.ServiceVersion)
+	assert.Equal(t, "00bf4bf02d", c.config.PlatformLabels.get().labels["instanceId"])
+}
+
+func TestPresets_SplitStreams(t *testing.T) {
+	presets := map[string]func(...zap.Option) (*zap.Logger, error){
+		"gke":       NewGKE,
+		"cloud run": NewCloudRun,
+	}
+
+	for name, preset := range presets {
+		preset := preset
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("K_SERVICE", "my-service")
+			newMetadataServer(t, map[string]string{"instance/id": "00bf4bf02d"})
+			stdout, stderr := captureStreams(t)
+
+			logger, err := preset()
+			require.NoError(t, err)
+
+			logger.Info("routine")
+			logger.Error("failed")
+
+			assert.Equal(t, 1, strings.Count(stdout.String(), "\n"), stdout.String())
+			assert.Contains(t, stdout.String(), `"severity":"INFO"`)
+			assert.Equal(t, 1, strings.Count(stderr.String(), "\n"), stderr.String())
+			assert.Contains(t, stderr.String(), `"severity":"ERROR"`)
+		})
+	}
+}
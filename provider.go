@@ -0,0 +1,199 @@
+package zapdriver
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Metadata holds the labels and fields added to the entries by a
+// MetadataCache.
+type Metadata struct {
+	Labels map[string]string
+	Fields []zap.Field
+}
+
+// MetadataProvider provides metadata changing at runtime, such as a spot VM
+// preemption notice, the current deployment phase, or feature flags.
+type MetadataProvider interface {
+	Metadata(ctx context.Context) (Metadata, error)
+}
+
+// MetadataProviderFunc is an adapter to use a function as a MetadataProvider.
+type MetadataProviderFunc func(ctx context.Context) (Metadata, error)
+
+// Metadata implements MetadataProvider interface.
+func (f MetadataProviderFunc) Metadata(ctx context.Context) (Metadata, error) {
+	return f(ctx)
+}
+
+// metadataCache holds the configuration of a MetadataCache.
+type metadataCache struct {
+	timeout time.Duration
+	onError func(error)
+}
+
+// zapdriver metadata cache option to cancel the calls to the provider after
+// `timeout`, instead of the refresh interval
+func MetadataTimeout(timeout time.Duration) func(*metadataCache) {
+	return func(m *metadataCache) {
+		m.timeout = timeout
+	}
+}
+
+// zapdriver metadata cache option to call `fn` with every error returned by the
+// provider. The previous metadata is kept on errors
+func MetadataOnError(fn func(error)) func(*metadataCache) {
+	return func(m *metadataCache) {
+		m.onError = fn
+	}
+}
+
+// zapdriver core option to add the metadata cached by `cache` to every entry.
+// The labels and fields of the entry take precedence over the metadata
+func EnrichFromMetadata(cache *MetadataCache) func(*core) {
+	return func(c *core) {
+		c.config.Metadata = cache
+	}
+}
+
+// MetadataCache refreshes the metadata of a provider in the background, so
+// writing an entry never blocks on the provider (see `EnrichFromMetadata()`).
+// Metadata can also be pushed to the cache using `Set()`, for providers
+// subscribing to changes.
+type MetadataCache struct {
+	provider MetadataProvider
+	interval time.Duration
+	config   metadataCache
+
+	// fields holds the []zapcore.Field of the current metadata.
+	fields atomic.Value
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewMetadataCache returns a MetadataCache calling `provider` right away, then
+// every `interval` (or every minute, if `interval` is not positive). Use
+// `Close()` to stop it.
+func NewMetadataCache(provider MetadataProvider, interval time.Duration, options ...func(*metadataCache)) *MetadataCache {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	m := &MetadataCache{
+		provider: provider,
+		interval: interval,
+		config:   metadataCache{timeout: interval},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, option := range options {
+		option(&m.config)
+	}
+
+	m.fields.Store([]zapcore.Field(nil))
+
+	go m.run()
+
+	return m
+}
+
+// Set replaces the cached metadata.
+func (m *MetadataCache) Set(metadata Metadata) {
+	fields := make([]zapcore.Field, 0, len(metadata.Fields)+1)
+	if len(metadata.Labels) > 0 {
+		// The labels are added as a label set, which is recognised even when
+		// the `LabelPrefix()` option replaces the prefix of `Label()`.
+		fields = append(fields, LabelsMap(metadata.Labels))
+	}
+	fields = append(fields, metadata.Fields...)
+
+	m.fields.Store(fields)
+}
+
+// Refresh calls the provider, and caches the metadata it returns.
+func (m *MetadataCache) Refresh(ctx context.Context) error {
+	if m.config.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.config.timeout)
+		defer cancel()
+	}
+
+	metadata, err := m.provider.Metadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.Set(metadata)
+
+	return nil
+}
+
+// Close stops refreshing the metadata. The cached metadata is still added to
+// the entries.
+func (m *MetadataCache) Close() {
+	close(m.stop)
+	<-m.done
+}
+
+func (m *MetadataCache) run() {
+	defer close(m.done)
+
+	m.refresh()
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.refresh()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// refresh refreshes the metadata, reporting errors to the error handler.
+func (m *MetadataCache) refresh() {
+	if err := m.Refresh(context.Background()); err != nil && m.config.onError != nil {
+		m.config.onError(err)
+	}
+}
+
+// withMetadata returns the fields of the entry, preceded by the cached
+// metadata. The metadata fields with the key of a field of the entry are
+// skipped, so the entry has no duplicate keys. The labels are merged with the
+// labels of the entry instead.
+func (m *MetadataCache) withMetadata(fields []zapcore.Field) []zapcore.Field {
+	metadata := m.fields.Load().([]zapcore.Field)
+	if len(metadata) == 0 {
+		return fields
+	}
+
+	out := make([]zapcore.Field, 0, len(metadata)+len(fields))
+	for i := range metadata {
+		if !isLabelsField(metadata[i]) && hasKey(fields, metadata[i].Key) {
+			continue
+		}
+
+		out = append(out, metadata[i])
+	}
+
+	return append(out, fields...)
+}
+
+// hasKey returns true if any of the fields has the key.
+func hasKey(fields []zapcore.Field, key string) bool {
+	for i := range fields {
+		if fields[i].Key == key {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,122 @@
+package zapdriver
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestEnrichFromMetadata(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	provider := MetadataProviderFunc(func(ctx context.Context) (Metadata, error) {
+		if atomic.AddInt32(&calls, 1) > 1 {
+			return Metadata{}, errors.New("unavailable")
+		}
+
+		return Metadata{
+			Labels: map[string]string{"phase": "canary", "preempted": "false"},
+			Fields: []zap.Field{zap.Bool("newCheckout", true)},
+		}, nil
+	})
+
+	var refreshErr error
+	cache := NewMetadataCache(provider, time.Hour, MetadataOnError(func(err error) { refreshErr = err }))
+	defer cache.Close()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(EnrichFromMetadata(cache)))
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 }, time.Second, time.Millisecond)
+
+	logger.Info("hello", Label("phase", "rollout"))
+	require.Error(t, cache.Refresh(context.Background()))
+	logger.Info("world")
+
+	cache.Set(Metadata{Labels: map[string]string{"preempted": "true"}})
+	logger.Info("preempted")
+
+	require.Equal(t, 3, logs.Len())
+	assert.Equal(t, map[string]interface{}{"phase": "rollout", "preempted": "false"}, logs.All()[0].ContextMap()[labelsKey])
+	assert.Equal(t, true, logs.All()[0].ContextMap()["newCheckout"])
+	assert.Equal(t, map[string]interface{}{"phase": "canary", "preempted": "false"}, logs.All()[1].ContextMap()[labelsKey], "metadata is kept on errors")
+	assert.Equal(t, map[string]interface{}{"preempted": "true"}, logs.All()[2].ContextMap()[labelsKey])
+	assert.NotContains(t, logs.All()[2].ContextMap(), "newCheckout")
+	assert.NoError(t, refreshErr, "explicit refreshes return their errors")
+}
+
+func TestEnrichFromMetadata_LabelPrefix(t *testing.T) {
+	t.Parallel()
+
+	cache := NewMetadataCache(MetadataProviderFunc(func(ctx context.Context) (Metadata, error) {
+		return Metadata{Labels: map[string]string{"phase": "canary"}}, nil
+	}), time.Hour)
+	defer cache.Close()
+	require.NoError(t, cache.Refresh(context.Background()))
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(LabelPrefix("tag."), EnrichFromMetadata(cache)))
+
+	logger.Info("hello", zap.String("tag.app", "api"))
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, map[string]interface{}{"phase": "canary", "app": "api"}, logs.All()[0].ContextMap()[labelsKey])
+}
+
+func TestEnrichFromMetadata_EntryFieldsWin(t *testing.T) {
+	t.Parallel()
+
+	cache := NewMetadataCache(MetadataProviderFunc(func(ctx context.Context) (Metadata, error) {
+		return Metadata{
+			Labels: map[string]string{"phase": "canary"},
+			Fields: []zap.Field{zap.Bool("newCheckout", true), zap.String("region", "eu")},
+		}, nil
+	}), 0)
+	defer cache.Close()
+	require.NoError(t, cache.Refresh(context.Background()))
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(EnrichFromMetadata(cache)))
+
+	logger.Info("hello", zap.Bool("newCheckout", false), LabelsMap(map[string]string{"app": "api"}))
+
+	require.Equal(t, 1, logs.Len())
+	entry := logs.All()[0]
+
+	var keys []string
+	for _, field := range entry.Context {
+		keys = append(keys, field.Key)
+	}
+	assert.ElementsMatch(t, []string{"region", "newCheckout", labelsKey}, keys)
+	assert.Equal(t, false, entry.ContextMap()["newCheckout"])
+	assert.Equal(t, map[string]interface{}{"phase": "canary", "app": "api"}, entry.ContextMap()[labelsKey])
+}
+
+func TestMetadataCache_Timeout(t *testing.T) {
+	t.Parallel()
+
+	provider := MetadataProviderFunc(func(ctx context.Context) (Metadata, error) {
+		<-ctx.Done()
+		return Metadata{}, ctx.Err()
+	})
+
+	errs := make(chan error, 1)
+	cache := NewMetadataCache(provider, time.Hour, MetadataTimeout(time.Millisecond), MetadataOnError(func(err error) { errs <- err }))
+	defer cache.Close()
+
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("provider not cancelled")
+	}
+}
@@ -0,0 +1,390 @@
+package zapdriver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const defaultPubSubEndpoint = "https://pubsub.googleapis.com/v1/"
+
+// pubSub holds the configuration of a PubSubCore.
+type pubSub struct {
+	client        *http.Client
+	project       string
+	orderingLabel string
+	batchSize     int
+	maxPending    int
+	interval      time.Duration
+	endpoint      string
+	onError       func(error)
+}
+
+// zapdriver pub/sub option to use `client` to call the Pub/Sub API. The client
+// is responsible for authenticating the requests, for example using
+// `golang.org/x/oauth2/google.DefaultClient`. It is required, as the API
+// rejects unauthenticated requests
+func PubSubClient(client *http.Client) func(*pubSub) {
+	return func(p *pubSub) {
+		p.client = client
+	}
+}
+
+// zapdriver pub/sub option to publish to the topic of project `id`, instead of
+// the project returned by `ProjectID()`
+func PubSubProject(id string) func(*pubSub) {
+	return func(p *pubSub) {
+		p.project = id
+	}
+}
+
+// zapdriver pub/sub option to use the value of the label `key` of the entries
+// as the ordering key of the messages, so subscribers with message ordering
+// enabled receive the entries with the same label value in order. Ordered
+// messages must be published to a regional endpoint (see `PubSubEndpoint()`)
+func PubSubOrderingLabel(key string) func(*pubSub) {
+	return func(p *pubSub) {
+		p.orderingLabel = key
+	}
+}
+
+// zapdriver pub/sub option to publish at most `n` messages per API request.
+// Full batches are published without waiting for the end of the interval
+func PubSubBatchSize(n int) func(*pubSub) {
+	return func(p *pubSub) {
+		p.batchSize = n
+	}
+}
+
+// zapdriver pub/sub option to queue at most `n` messages waiting to be
+// published, instead of 10000. The messages written while the queue is full are
+// dropped, and counted by `Dropped()`
+func PubSubMaxPending(n int) func(*pubSub) {
+	return func(p *pubSub) {
+		p.maxPending = n
+	}
+}
+
+// zapdriver pub/sub option to publish the pending messages every `interval`
+func PubSubInterval(interval time.Duration) func(*pubSub) {
+	return func(p *pubSub) {
+		p.interval = interval
+	}
+}
+
+// zapdriver pub/sub option to call the Pub/Sub API at `endpoint` (e.g.
+// `https://europe-west1-pubsub.googleapis.com/v1/`), instead of the global
+// endpoint
+func PubSubEndpoint(endpoint string) func(*pubSub) {
+	return func(p *pubSub) {
+		p.endpoint = endpoint
+	}
+}
+
+// zapdriver pub/sub option to call `fn` with every error returned by the API,
+// for the batches published in the background
+func PubSubOnError(fn func(error)) func(*pubSub) {
+	return func(p *pubSub) {
+		p.onError = fn
+	}
+}
+
+// PubSubCore is a zapcore.Core publishing Stackdriver-formatted entries to a
+// Pub/Sub topic, for pipelines fed from Pub/Sub (e.g. Dataflow, or a SIEM),
+// instead of or in addition to Cloud Logging. Each message holds an encoded
+// entry, with its severity as the `severity` attribute. Messages are published
+// in batches, in the background. Batches the API fails to accept are dropped,
+// as are the messages written while too many messages are waiting to be
+// published (see `PubSubMaxPending()`).
+//
+// It is meant to be used as a secondary core, along with the main core of the
+// logger, so the labels of the entries are merged by the zapdriver core:
+//
+//	topic, err := zapdriver.NewPubSubCore("logs", zap.InfoLevel, zapdriver.PubSubClient(client))
+//	logger := zap.New(zapcore.NewTee(core, topic), zapdriver.WrapCore())
+//	defer topic.Close()
+//
+// see: https://cloud.google.com/pubsub/docs/reference/rest/v1/projects.topics/publish
+type PubSubCore struct {
+	zapcore.LevelEnabler
+
+	enc       zapcore.Encoder
+	publisher *pubSubPublisher
+}
+
+// pubSubMessage is a message of the `topics.publish` API.
+type pubSubMessage struct {
+	Data        []byte            `json:"data"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	OrderingKey string            `json:"orderingKey,omitempty"`
+}
+
+// pubSubPublisher publishes the messages of the core in batches. It is shared
+// between the core and all the cores derived from it using `With()`.
+type pubSubPublisher struct {
+	url    string
+	config pubSub
+
+	mutex   *sync.Mutex
+	pending []pubSubMessage
+	dropped uint64
+
+	// publishing serializes the API requests, so batches are published in
+	// order.
+	publishing *sync.Mutex
+
+	full    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	closing *sync.Once
+}
+
+// NewPubSubCore returns a core publishing the entries enabled by `enab` to the
+// Pub/Sub topic `topic`. Use `Close()` to stop it.
+func NewPubSubCore(topic string, enab zapcore.LevelEnabler, options ...func(*pubSub)) (*PubSubCore, error) {
+	p := &pubSubPublisher{
+		config: pubSub{
+			batchSize:  100,
+			maxPending: 10000,
+			interval:   time.Second,
+			endpoint:   defaultPubSubEndpoint,
+		},
+		mutex:      &sync.Mutex{},
+		publishing: &sync.Mutex{},
+		full:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		closing:    &sync.Once{},
+	}
+	for _, option := range options {
+		option(&p.config)
+	}
+
+	if p.config.client == nil {
+		return nil, errors.New("zapdriver: no client configured to publish entries with (see PubSubClient())")
+	}
+	if p.config.project == "" {
+		p.config.project = ProjectID()
+	}
+	if p.config.project == "" {
+		return nil, errors.New("zapdriver: no project configured to publish entries to")
+	}
+	if p.config.batchSize < 1 {
+		p.config.batchSize = 1
+	}
+	if p.config.maxPending < p.config.batchSize {
+		p.config.maxPending = p.config.batchSize
+	}
+
+	p.url = p.config.endpoint + "projects/" + p.config.project + "/topics/" + topic + ":publish"
+
+	go p.run()
+
+	return &PubSubCore{
+		LevelEnabler: enab,
+		enc:          NewEncoder(NewProductionEncoderConfig()),
+		publisher:    p,
+	}, nil
+}
+
+// With implements zapcore.Core interface.
+func (c *PubSubCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+
+	return &PubSubCore{LevelEnabler: c.LevelEnabler, enc: enc, publisher: c.publisher}
+}
+
+// Check implements zapcore.Core interface.
+func (c *PubSubCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+// Write implements zapcore.Core interface. The entry is queued, to be published
+// with the next batch. Entries below the level of the core are ignored, as the
+// zapdriver core writes the entries enabled by any core of a tee to all of them.
+func (c *PubSubCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !c.Enabled(ent.Level) {
+		return nil
+	}
+
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	msg := pubSubMessage{
+		Data:       bytes.TrimRight(append([]byte{}, buf.Bytes()...), "\n"),
+		Attributes: map[string]string{"severity": SeverityFromLevel(ent.Level).String()},
+	}
+	if c.publisher.config.orderingLabel != "" {
+		msg.OrderingKey = orderingKey(fields, c.publisher.config.orderingLabel)
+	}
+
+	c.publisher.add(msg)
+
+	return nil
+}
+
+// Sync implements zapcore.Core interface. It publishes the pending messages,
+// and returns the first error returned by the API.
+func (c *PubSubCore) Sync() error {
+	return c.publisher.flush()
+}
+
+// Close stops the core, after publishing the pending messages. It can be
+// called several times.
+func (c *PubSubCore) Close() error {
+	c.publisher.closing.Do(func() {
+		close(c.publisher.stop)
+	})
+	<-c.publisher.done
+
+	return c.publisher.flush()
+}
+
+// Dropped returns the number of messages which were not published, because the
+// API failed to accept them, or because the queue was full.
+func (c *PubSubCore) Dropped() uint64 {
+	return atomic.LoadUint64(&c.publisher.dropped)
+}
+
+// orderingKey returns the value of the label `key` of the entry, as merged by
+// the zapdriver core.
+func orderingKey(fields []zapcore.Field, key string) string {
+	for i := range fields {
+		if !isLabelsField(fields[i]) {
+			continue
+		}
+
+		if v, ok := fields[i].Interface.(*LabelSet).Get(key); ok {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// add queues the message, and wakes up the publisher when a batch is full. The
+// message is dropped if the queue is full.
+func (p *pubSubPublisher) add(msg pubSubMessage) {
+	p.mutex.Lock()
+	if len(p.pending) >= p.config.maxPending {
+		p.mutex.Unlock()
+		atomic.AddUint64(&p.dropped, 1)
+
+		return
+	}
+
+	p.pending = append(p.pending, msg)
+	full := len(p.pending) >= p.config.batchSize
+	p.mutex.Unlock()
+
+	if full {
+		select {
+		case p.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (p *pubSubPublisher) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.config.interval)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case <-ticker.C:
+			err = p.flush()
+		case <-p.full:
+			err = p.flush()
+		case <-p.stop:
+			return
+		}
+
+		if err != nil && p.config.onError != nil {
+			p.config.onError(err)
+		}
+	}
+}
+
+// flush publishes the pending messages, in batches of the maximum size. A
+// failed batch is dropped, and the next batches are still published. The first
+// error is returned.
+func (p *pubSubPublisher) flush() error {
+	p.publishing.Lock()
+	defer p.publishing.Unlock()
+
+	p.mutex.Lock()
+	pending := p.pending
+	p.pending = nil
+	p.mutex.Unlock()
+
+	var first error
+	for len(pending) > 0 {
+		n := len(pending)
+		if n > p.config.batchSize {
+			n = p.config.batchSize
+		}
+
+		if err := p.publish(pending[:n]); err != nil {
+			atomic.AddUint64(&p.dropped, uint64(n))
+			if first == nil {
+				first = err
+			}
+		}
+		pending = pending[n:]
+	}
+
+	return first
+}
+
+// publishRequest is the payload of the `topics.publish` API.
+type publishRequest struct {
+	Messages []pubSubMessage `json:"messages"`
+}
+
+func (p *pubSubPublisher) publish(messages []pubSubMessage) error {
+	body, err := json.Marshal(publishRequest{Messages: messages})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := p.config.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close() // nolint: errcheck
+
+	if res.StatusCode != http.StatusOK {
+		return errors.New("zapdriver: pub/sub API returned " + res.Status)
+	}
+
+	return nil
+}
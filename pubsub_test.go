@@ -0,0 +1,125 @@
+package zapdriver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newTestPubSubCore(t *testing.T, status int, options ...func(*pubSub)) (*PubSubCore, func() [][]pubSubMessage) {
+	api := newFakeAPI(t, status)
+
+	options = append([]func(*pubSub){
+		PubSubClient(api.Client()),
+		PubSubProject("my-project"),
+		PubSubInterval(time.Hour),
+		PubSubEndpoint(api.URL + "/"),
+	}, options...)
+
+	core, err := NewPubSubCore("logs", zapcore.InfoLevel, options...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = core.Close() })
+
+	return core, func() [][]pubSubMessage {
+		batches := [][]pubSubMessage{}
+		api.requests(func(path string, payload json.RawMessage) {
+			assert.Equal(t, "/projects/my-project/topics/logs:publish", path)
+
+			var req publishRequest
+			assert.NoError(t, json.Unmarshal(payload, &req))
+			batches = append(batches, req.Messages)
+		})
+
+		return batches
+	}
+}
+
+func TestPubSubCore(t *testing.T) {
+	t.Parallel()
+
+	topic, batches := newTestPubSubCore(t, http.StatusOK, PubSubOrderingLabel("tenant"), PubSubBatchSize(2))
+
+	debugcore, _ := observer.New(zapcore.DebugLevel)
+	logger := zap.New(zapcore.NewTee(debugcore, topic), WrapCore()).With(Label("tenant", "acme"))
+
+	logger.Debug("ignored")
+	logger.Info("hello", zap.Int("count", 1))
+	logger.Warn("careful", Label("tenant", "globex"))
+	logger.Error("failed")
+	require.NoError(t, topic.Close())
+
+	var messages []pubSubMessage
+	for _, batch := range batches() {
+		assert.LessOrEqual(t, len(batch), 2)
+		messages = append(messages, batch...)
+	}
+	require.Len(t, messages, 3)
+
+	assert.Equal(t, "acme", messages[0].OrderingKey)
+	assert.Equal(t, map[string]string{"severity": "INFO"}, messages[0].Attributes)
+	assert.Equal(t, "globex", messages[1].OrderingKey)
+	assert.Equal(t, "WARNING", messages[1].Attributes["severity"])
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(messages[0].Data, &entry))
+	assert.Equal(t, "hello", entry["message"])
+	assert.Equal(t, float64(1), entry["count"])
+	assert.Equal(t, map[string]interface{}{"tenant": "acme"}, entry[labelsKey])
+}
+
+func TestPubSubCore_Sync(t *testing.T) {
+	t.Parallel()
+
+	topic, batches := newTestPubSubCore(t, http.StatusForbidden, PubSubBatchSize(1))
+
+	logger := zap.New(topic, WrapCore())
+	logger.Info("hello")
+	logger.Info("world")
+
+	assert.Error(t, logger.Sync())
+	assert.Len(t, batches(), 2, "the batches after a failed batch are published")
+	assert.Equal(t, uint64(2), topic.Dropped())
+
+	assert.NoError(t, logger.Sync(), "failed batches are dropped")
+	assert.Len(t, batches(), 2)
+
+	assert.NoError(t, topic.Close())
+	assert.NoError(t, topic.Close(), "the core can be closed twice")
+}
+
+func TestPubSubCore_MaxPending(t *testing.T) {
+	t.Parallel()
+
+	topic, batches := newTestPubSubCore(t, http.StatusOK, PubSubBatchSize(2), PubSubMaxPending(3))
+
+	// A full batch wakes up the publisher, so the pending messages are
+	// published by Sync only once the publisher is stopped.
+	require.NoError(t, topic.Close())
+
+	logger := zap.New(topic, WrapCore())
+	for i := 0; i < 5; i++ {
+		logger.Info("hello")
+	}
+	require.NoError(t, logger.Sync())
+
+	var messages int
+	for _, batch := range batches() {
+		messages += len(batch)
+	}
+	assert.Equal(t, 3, messages)
+	assert.Equal(t, uint64(2), topic.Dropped())
+}
+
+func TestNewPubSubCore_NoClient(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewPubSubCore("logs", zapcore.InfoLevel, PubSubProject("my-project"))
+	assert.Error(t, err)
+}
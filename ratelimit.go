@@ -0,0 +1,165 @@
+package zapdriver
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// rateLimitMessage is the message of the entries summarizing the entries
+// suppressed by the rate limiter.
+const rateLimitMessage = "Rate limit exceeded, entries suppressed."
+
+// rateLimiter limits the number of entries written per value of a single label.
+// It is shared between the core and all the cores derived from it using
+// `With()`.
+type rateLimiter struct {
+	// label is the key of the label used to tell entries apart.
+	label string
+
+	// limit is the number of entries with the same label value written per
+	// period.
+	limit uint64
+	per   time.Duration
+
+	mutex     *sync.Mutex
+	counts    map[string]uint64
+	resetAt   time.Time
+	now       func() time.Time
+	afterFunc func(time.Duration, func()) func()
+
+	// stop stops the timer writing the summaries at the end of the period,
+	// armed once entries are suppressed.
+	stop func()
+}
+
+// rateSummary is the number of entries suppressed for a label value during a
+// period.
+type rateSummary struct {
+	value      string
+	suppressed uint64
+}
+
+// zapdriver core option to write at most `n` entries `per` period for each
+// value of the label `key`, so a single misbehaving tenant or handler can't
+// consume the entire logging budget. Entries without the label are not
+// limited. The number of entries suppressed for a label value is written in a
+// summary entry, with the label and without the fields added with `With()`,
+// when the period ends
+func RateLimitByLabel(key string, n int, per time.Duration) func(*core) {
+	return func(c *core) {
+		c.limiter = &rateLimiter{
+			label:  key,
+			limit:  uint64(n),
+			per:    per,
+			mutex:  &sync.Mutex{},
+			counts:    map[string]uint64{},
+			now:       c.now,
+			afterFunc: c.afterFunc,
+		}
+	}
+}
+
+// allow returns true if the entry of the core should be written. The summaries
+// of the previous period are written through the root core of `c` when the
+// period ends, or when the entry is the first one after its end.
+func (r *rateLimiter) allow(c *core, lbls *LabelSet) bool {
+	value, ok := lbls.Get(r.label)
+
+	r.mutex.Lock()
+
+	var summaries []rateSummary
+	if now := r.now(); !now.Before(r.resetAt) {
+		summaries = r.reset()
+		r.resetAt = now.Add(r.per)
+	}
+
+	allowed := true
+	if ok {
+		r.counts[value]++
+		allowed = r.counts[value] <= r.limit
+
+		// The summaries are written once the period ends, even if no entry is
+		// written afterwards.
+		if !allowed && r.stop == nil {
+			root := c.root
+			if root == nil {
+				root = c
+			}
+
+			period := r.resetAt
+			r.stop = r.afterFunc(period.Sub(r.now()), func() { r.expire(root, period) })
+		}
+	}
+
+	r.mutex.Unlock()
+
+	c.writeRateSummaries(summaries)
+
+	return allowed
+}
+
+// expire ends the period ending at `period`, and writes its summaries with
+// `root`. It does nothing if the period was already replaced, as stopping the
+// timer does not stop a callback already waiting for the mutex.
+func (r *rateLimiter) expire(root *core, period time.Time) {
+	r.mutex.Lock()
+	if !r.resetAt.Equal(period) {
+		r.mutex.Unlock()
+		return
+	}
+	summaries := r.reset()
+	r.resetAt = time.Time{}
+	r.mutex.Unlock()
+
+	root.writeRateSummaries(summaries)
+}
+
+// reset clears the counts of the current period, and returns its summaries. It
+// must be called with the mutex held.
+func (r *rateLimiter) reset() []rateSummary {
+	var summaries []rateSummary
+	for v, n := range r.counts {
+		if n > r.limit {
+			summaries = append(summaries, rateSummary{value: v, suppressed: n - r.limit})
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].value < summaries[j].value })
+
+	r.counts = map[string]uint64{}
+	if r.stop != nil {
+		r.stop()
+		r.stop = nil
+	}
+
+	return summaries
+}
+
+// writeRateSummaries writes a warning entry for each summary, with the root
+// core, so the summaries do not hold the fields added to `c` with `With()`.
+func (c *core) writeRateSummaries(summaries []rateSummary) {
+	root := c.root
+	if root == nil {
+		root = c
+	}
+
+	for _, s := range summaries {
+		lbls := NewLabelSet()
+		lbls.Add(c.limiter.label, s.value)
+
+		summary := zapcore.Entry{
+			Level:   zapcore.WarnLevel,
+			Time:    root.now(),
+			Message: rateLimitMessage,
+		}
+
+		_ = root.write(summary, []zapcore.Field{
+			zap.Uint64("suppressedEntries", s.suppressed),
+			zap.String("period", FormatLatency(c.limiter.per)),
+			lbls.Field(),
+		})
+	}
+}
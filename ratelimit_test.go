@@ -0,0 +1,125 @@
+package zapdriver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRateLimitByLabel(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	c := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+	RateLimitByLabel("tenant", 2, time.Minute)(c)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.limiter.now = func() time.Time { return now }
+
+	noisy := c.With([]zapcore.Field{Label("tenant", "noisy")})
+	write := func(c zapcore.Core, message string, fields ...zapcore.Field) {
+		require.NoError(t, c.Write(zapcore.Entry{Level: zapcore.InfoLevel, Message: message}, fields))
+	}
+
+	for i := 0; i < 5; i++ {
+		write(noisy, "noisy")
+		write(c, "quiet", Label("tenant", "quiet"))
+		write(c, "untagged")
+	}
+	write(c, "other", Label("tenant", "other"))
+
+	assert.Equal(t, 2, logs.FilterMessage("noisy").Len())
+	assert.Equal(t, 2, logs.FilterMessage("quiet").Len())
+	assert.Equal(t, 5, logs.FilterMessage("untagged").Len())
+	assert.Equal(t, 1, logs.FilterMessage("other").Len())
+	assert.Equal(t, 0, logs.FilterMessage(rateLimitMessage).Len())
+
+	logs.TakeAll()
+	now = now.Add(time.Minute)
+	write(noisy, "noisy")
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 3)
+
+	for i, tenant := range []string{"noisy", "quiet"} {
+		assert.Equal(t, zapcore.WarnLevel, entries[i].Level)
+		assert.Equal(t, rateLimitMessage, entries[i].Message)
+		assert.Equal(t, uint64(3), entries[i].ContextMap()["suppressedEntries"])
+		assert.Equal(t, "60s", entries[i].ContextMap()["period"])
+		assert.Equal(t, map[string]interface{}{"tenant": tenant}, entries[i].ContextMap()[labelsKey])
+	}
+
+	assert.Equal(t, "noisy", entries[2].Message)
+}
+
+func TestRateLimitByLabel_SummaryOnExpiry(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(RateLimitByLabel("tenant", 1, 20*time.Millisecond)))
+
+	noisy := logger.With(zap.String("request", "first"), Label("tenant", "noisy"))
+	for i := 0; i < 3; i++ {
+		noisy.Info("noisy")
+	}
+
+	// The summary is written when the period ends, without any further entry.
+	require.Eventually(t, func() bool {
+		return logs.FilterMessage(rateLimitMessage).Len() == 1
+	}, time.Second, time.Millisecond)
+
+	summary := logs.FilterMessage(rateLimitMessage).All()[0]
+	assert.Equal(t, uint64(2), summary.ContextMap()["suppressedEntries"])
+	assert.NotContains(t, summary.ContextMap(), "request")
+	assert.Equal(t, "noisy", summary.ContextMap()[labelsKey].(map[string]interface{})["tenant"])
+
+	// The next period starts with the next entry.
+	noisy.Info("noisy")
+	assert.Equal(t, 2, logs.FilterMessage("noisy").Len())
+}
+
+func TestRateLimitByLabel_Clock(t *testing.T) {
+	clock := newManualClock()
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(Clock(clock), RateLimitByLabel("tenant", 1, time.Minute)))
+
+	noisy := logger.With(Label("tenant", "noisy"))
+	noisy.Info("noisy")
+	noisy.Info("noisy")
+
+	// The period follows the clock of the core, not the real time.
+	time.Sleep(10 * time.Millisecond)
+	require.Zero(t, logs.FilterMessage(rateLimitMessage).Len())
+
+	clock.Add(time.Minute)
+	require.Eventually(t, func() bool {
+		return logs.FilterMessage(rateLimitMessage).Len() == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestRateLimitByLabel_StaleExpiry(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	c := newCore(debugcore, []func(*core){RateLimitByLabel("tenant", 1, time.Minute)})
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.limiter.now = func() time.Time { return now }
+
+	lbls := NewLabelSet()
+	lbls.Add("tenant", "noisy")
+	require.True(t, c.limiter.allow(c, lbls))
+	require.False(t, c.limiter.allow(c, lbls))
+	stale := c.limiter.resetAt
+
+	// A new period starts before the callback of the previous one runs.
+	now = now.Add(time.Minute)
+	require.True(t, c.limiter.allow(c, lbls))
+	summaries := logs.FilterMessage(rateLimitMessage).Len()
+
+	c.limiter.expire(c, stale)
+	assert.Equal(t, uint64(1), c.limiter.counts["noisy"], "the counts of the new period are kept")
+	assert.Equal(t, summaries, logs.FilterMessage(rateLimitMessage).Len())
+}
@@ -0,0 +1,195 @@
+package zapdriver
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// panicKey is the key of the pseudo-field marking the entries of recovered
+// panics, which the core writes with the CRITICAL severity.
+const panicKey = "zapdriver.panic"
+
+// recoverer holds the panic recovery configuration.
+type recoverer struct {
+	// repanic re-panics with the recovered value after logging it.
+	repanic bool
+}
+
+// zapdriver recovery option to panic again with the recovered value, after it
+// has been logged, when set to true
+func Repanic(repanic bool) func(*recoverer) {
+	return func(r *recoverer) {
+		r.repanic = repanic
+	}
+}
+
+// Recover recovers a panic, and logs it in the format parsed by Error
+// Reporting. It must be deferred directly:
+//
+//	defer zapdriver.Recover(logger)
+//
+// The panic is logged with level Error, which the zapdriver core raises to the
+// CRITICAL severity when writing the entry, so that it does not panic again
+// when the logger is in development mode.
+func Recover(logger *zap.Logger, options ...func(*recoverer)) {
+	if v := recover(); v != nil {
+		newRecoverer(options).log(recoveryLogger(logger), v)
+	}
+}
+
+// CapturePanic logs a panic in the format parsed by Error Reporting, like
+// `Recover()`, then flushes the logger and panics again with the recovered
+// value, so the program still crashes, but with a reported error event. It
+// must be deferred directly, typically at the top of `main()` or of a
+// goroutine:
+//
+//	defer zapdriver.CapturePanic(logger)
+func CapturePanic(logger *zap.Logger) {
+	if v := recover(); v != nil {
+		(&recoverer{repanic: true}).log(recoveryLogger(logger), v)
+	}
+}
+
+// Go runs `fn` in a new goroutine, recovering and logging its panics like
+// `Recover()`, as panics in background goroutines otherwise crash the program
+// without any reported error event.
+func Go(logger *zap.Logger, fn func(), options ...func(*recoverer)) {
+	go func() {
+		defer Recover(logger, options...)
+		fn()
+	}()
+}
+
+// RecoverAndLog returns a HTTP middleware which recovers panics of the handler,
+// logs them in the format parsed by Error Reporting with the `httpRequest`
+// context of the request, and responds with status 500. Panics with
+// `http.ErrAbortHandler`, which abort the response, are not logged, and are
+// left to the server.
+func RecoverAndLog(logger *zap.Logger, options ...func(*recoverer)) func(http.Handler) http.Handler {
+	r := newRecoverer(options)
+	logger = recoveryLogger(logger)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+
+				payload := requestPayload(req)
+				payload.Status = http.StatusInternalServerError
+				payload.Latency = FormatLatency(time.Since(start))
+
+				if !r.repanic {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+
+				r.log(logger, v, HTTP(payload))
+			}()
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+func newRecoverer(options []func(*recoverer)) *recoverer {
+	r := &recoverer{}
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// recoveryLogger disables the stack trace of zap for the logger, as the stack
+// trace of the panic is embedded in the message.
+func recoveryLogger(logger *zap.Logger) *zap.Logger {
+	return logger.WithOptions(zap.AddStacktrace(zap.LevelEnablerFunc(func(zapcore.Level) bool {
+		return false
+	})))
+}
+
+// log logs the recovered value `v` from within the deferred function. The
+// message contains the runtime stack trace, formatted like an unrecovered
+// panic, and the error context points at the location of the panic.
+func (r *recoverer) log(logger *zap.Logger, v interface{}, fields ...zap.Field) {
+	message := "panic: " + fmt.Sprint(v) + "\n\n" + panicStack()
+
+	if pc, file, line, ok := panicLocation(); ok {
+		fields = append(fields, ErrorReport(pc, file, line, ok))
+	}
+
+	logger.Error(message, append(fields, zapcore.Field{Key: panicKey, Type: zapcore.SkipType})...)
+
+	if r.repanic {
+		// Flush the entry, as the program is likely to crash.
+		_ = logger.Sync()
+		panic(v)
+	}
+}
+
+// panicStack returns the stack trace of the goroutine without the frames of the
+// recovery functions, so that it starts at the frame that panicked, like the
+// stack trace of an unrecovered panic.
+func panicStack() string {
+	stack := string(debug.Stack())
+
+	lines := strings.Split(stack, "\n")
+	for i := 1; i+1 < len(lines); i += 2 {
+		if strings.HasPrefix(lines[i], "panic(") {
+			return strings.Join(append(lines[:1], lines[i+2:]...), "\n")
+		}
+	}
+
+	return stack
+}
+
+// withPanicSeverity returns the entry raised to DPanicLevel (the CRITICAL
+// severity) when it is the entry of a recovered panic.
+func withPanicSeverity(ent zapcore.Entry, fields []zapcore.Field) zapcore.Entry {
+	for i := range fields {
+		if fields[i].Key == panicKey && fields[i].Type == zapcore.SkipType {
+			if ent.Level < zapcore.DPanicLevel {
+				ent.Level = zapcore.DPanicLevel
+			}
+
+			break
+		}
+	}
+
+	return ent
+}
+
+// panicLocation returns the location of the frame that panicked, which is the
+// first frame after the runtime panic functions.
+func panicLocation() (uintptr, string, int, bool) {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+
+	panicking := false
+	for {
+		frame, more := frames.Next()
+		if frame.Function == "runtime.gopanic" {
+			panicking = true
+		} else if panicking && !strings.HasPrefix(frame.Function, "runtime.") {
+			return frame.PC, frame.File, frame.Line, true
+		}
+
+		if !more {
+			return 0, "", 0, false
+		}
+	}
+}
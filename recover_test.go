@@ -0,0 +1,155 @@
+package zapdriver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRecover(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, zap.AddStacktrace(zapcore.ErrorLevel))
+
+	func() {
+		defer Recover(logger)
+		panic("boom")
+	}()
+
+	require.Equal(t, 1, logs.Len())
+
+	entry := logs.All()[0]
+	assert.Equal(t, zapcore.ErrorLevel, entry.Level)
+	assert.True(t, strings.HasPrefix(entry.Message, "panic: boom\n\ngoroutine "), entry.Message)
+	assert.NotContains(t, entry.Message, "runtime/debug.Stack")
+	assert.NotContains(t, entry.Message, "zapdriver.Recover")
+	assert.Contains(t, strings.SplitN(entry.Message, "\n", 5)[3], "TestRecover")
+	assert.Empty(t, entry.Stack)
+
+	location := entry.ContextMap()[contextKey].(map[string]interface{})["reportLocation"].(map[string]interface{})
+	assert.Contains(t, location["filePath"], "recover_test.go")
+	assert.Contains(t, location["functionName"], "TestRecover")
+}
+
+func TestRecover_Development(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(), zap.Development())
+
+	assert.NotPanics(t, func() {
+		defer Recover(logger)
+		panic("boom")
+	})
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, zapcore.DPanicLevel, logs.All()[0].Level)
+}
+
+func TestRecover_Repanic(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		defer Recover(zap.New(debugcore), Repanic(true))
+		panic("boom")
+	})
+
+	assert.Equal(t, 1, logs.Len())
+}
+
+func TestCapturePanic(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		defer CapturePanic(zap.New(debugcore))
+		panic("boom")
+	})
+
+	require.Equal(t, 1, logs.Len())
+	assert.True(t, strings.HasPrefix(logs.All()[0].Message, "panic: boom\n\n"))
+}
+
+func TestGo(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+
+	Go(zap.New(debugcore), func() {
+		panic("boom")
+	})
+
+	require.Eventually(t, func() bool { return logs.Len() == 1 }, time.Second, time.Millisecond)
+
+	entry := logs.All()[0]
+	assert.Equal(t, zapcore.ErrorLevel, entry.Level)
+	assert.True(t, strings.HasPrefix(entry.Message, "panic: boom\n\n"))
+
+	location := entry.ContextMap()[contextKey].(map[string]interface{})["reportLocation"].(map[string]interface{})
+	assert.Contains(t, location["functionName"], "TestGo")
+}
+
+func TestRecoverAndLog(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	handler := RecoverAndLog(zap.New(debugcore))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/path", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.Equal(t, 1, logs.Len())
+
+	request := logs.All()[0].ContextMap()["httpRequest"].(map[string]interface{})
+	assert.Equal(t, "http://example.com/path", request["requestUrl"])
+	assert.Equal(t, 500, request["status"])
+	assert.Regexp(t, `^\d+(\.\d+)?s$`, request["latency"])
+}
+
+func TestRecover_CaptureStackOnError(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, zap.AddCaller(), WrapCore(ReportAllErrors(true), EmbedStack(true), CaptureStackOnError(true)))
+
+	func() {
+		defer Recover(logger)
+		panic("boom")
+	}()
+
+	require.Equal(t, 1, logs.Len())
+
+	entry := logs.All()[0]
+	assert.True(t, strings.HasPrefix(entry.Message, "panic: boom\n\ngoroutine "), entry.Message)
+	assert.Equal(t, 1, strings.Count(entry.Message, "\n\ngoroutine "), "the stack of the panic is not captured again")
+	assert.Empty(t, entry.Stack)
+}
+
+func TestRecoverAndLog_AbortHandler(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	handler := RecoverAndLog(zap.New(debugcore))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	})
+	assert.Zero(t, logs.Len())
+}
@@ -0,0 +1,155 @@
+package zapdriver
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// repeatCountLabel is the label holding the number of identical entries
+// suppressed since the entry was last written.
+const repeatCountLabel = "repeat_count"
+
+// repeatsPurgeSize is the number of tracked entries above which expired entries
+// are purged.
+const repeatsPurgeSize = 1000
+
+// repeats suppresses identical error entries within a window. It is shared
+// between the core and all the cores derived from it using `With()`.
+type repeats struct {
+	window time.Duration
+
+	mutex     *sync.Mutex
+	entries   map[uint64]*repeat
+	now       func() time.Time
+	afterFunc func(time.Duration, func()) func()
+}
+
+// repeat tracks the last written entry of a set of identical entries, and the
+// last suppressed one.
+type repeat struct {
+	writtenAt  time.Time
+	suppressed int
+
+	// core, entry, fields and labels are the last suppressed entry, written with
+	// the `repeat_count` label when the window expires (by timer) before any
+	// identical entry is written.
+	core   *core
+	entry  zapcore.Entry
+	fields []zapcore.Field
+	labels *LabelSet
+	stop   func()
+}
+
+// zapdriver core option to collapse identical (same level, message, fields and
+// labels) entries with level error or above, logged within `window` of the
+// last written one. The next identical entry written after the window carries
+// the number of suppressed entries in the `repeat_count` label. When there is
+// none, the last suppressed entry is written with the label once the window
+// expires
+func SuppressRepeats(window time.Duration) func(*core) {
+	return func(c *core) {
+		c.repeats = &repeats{
+			window:    window,
+			mutex:     &sync.Mutex{},
+			entries:   map[uint64]*repeat{},
+			now:       c.now,
+			afterFunc: c.afterFunc,
+		}
+	}
+}
+
+// suppress returns true if the entry of the core is a repeat that must be
+// dropped. When the entry is written after suppressed repeats, the
+// `repeat_count` label is added to the labels.
+func (r *repeats) suppress(c *core, ent zapcore.Entry, fields []zapcore.Field, lbls *LabelSet) bool {
+	if !zapcore.ErrorLevel.Enabled(ent.Level) {
+		return false
+	}
+
+	key := hashEntry(ent, fields, lbls)
+
+	r.mutex.Lock()
+
+	now := r.now()
+	if last, ok := r.entries[key]; ok && now.Sub(last.writtenAt) < r.window {
+		last.suppressed++
+		last.core, last.entry, last.fields, last.labels = c, ent, fields, lbls
+		if last.stop == nil {
+			last.stop = r.afterFunc(r.window-now.Sub(last.writtenAt), func() { r.expire(key, last) })
+		}
+
+		r.mutex.Unlock()
+		return true
+	}
+
+	if last, ok := r.entries[key]; ok && last.suppressed > 0 {
+		last.stop()
+		lbls.Add(repeatCountLabel, strconv.Itoa(last.suppressed))
+	}
+
+	var expired []*repeat
+	if len(r.entries) >= repeatsPurgeSize {
+		for k, e := range r.entries {
+			if k != key && now.Sub(e.writtenAt) >= r.window {
+				delete(r.entries, k)
+				if e.suppressed > 0 {
+					e.stop()
+					expired = append(expired, e)
+				}
+			}
+		}
+	}
+
+	r.entries[key] = &repeat{writtenAt: now}
+
+	r.mutex.Unlock()
+
+	for _, e := range expired {
+		e.write()
+	}
+
+	return false
+}
+
+// expire writes the last suppressed entry of `last` when its window expires,
+// unless an identical entry was written (or the entry purged) in the meantime.
+func (r *repeats) expire(key uint64, last *repeat) {
+	r.mutex.Lock()
+	if r.entries[key] != last {
+		r.mutex.Unlock()
+		return
+	}
+	delete(r.entries, key)
+	r.mutex.Unlock()
+
+	last.write()
+}
+
+// write writes the last suppressed entry, with the number of suppressed
+// entries. It must be called once the repeat is no longer tracked.
+func (e *repeat) write() {
+	lbls := e.labels.Clone()
+	lbls.Add(repeatCountLabel, strconv.Itoa(e.suppressed))
+
+	_ = e.core.writeLabeled(e.entry, e.fields, lbls)
+}
+
+// hashEntry returns a hash of the level, message, fields and labels of the
+// entry.
+func hashEntry(ent zapcore.Entry, fields []zapcore.Field, lbls *LabelSet) uint64 {
+	enc := zapcore.NewMapObjectEncoder()
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+
+	h := fnv.New64a()
+	// Maps are printed with sorted keys, so the hash is stable.
+	fmt.Fprint(h, ent.Level, "\x00", ent.Message, "\x00", enc.Fields, "\x00", lbls.Map())
+
+	return h.Sum64()
+}
@@ -0,0 +1,129 @@
+package zapdriver
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSuppressRepeats(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	c := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+	SuppressRepeats(time.Minute)(c)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.repeats.now = func() time.Time { return now }
+
+	write := func(level zapcore.Level, message string, fields ...zapcore.Field) {
+		require.NoError(t, c.Write(zapcore.Entry{Level: level, Message: message}, fields))
+	}
+
+	for i := 0; i < 5; i++ {
+		write(zapcore.ErrorLevel, "failed", zap.Error(errors.New("connection refused")))
+		write(zapcore.InfoLevel, "failed", zap.Error(errors.New("connection refused")))
+	}
+	write(zapcore.ErrorLevel, "failed", zap.Error(errors.New("timeout")))
+	write(zapcore.ErrorLevel, "failed", zap.Error(errors.New("connection refused")), Label("dependency", "db"))
+
+	assert.Equal(t, 3, logs.FilterLevelExact(zapcore.ErrorLevel).Len())
+	assert.Equal(t, 5, logs.FilterLevelExact(zapcore.InfoLevel).Len())
+	assert.Equal(t, 8, logs.Len())
+
+	now = now.Add(time.Minute)
+	write(zapcore.ErrorLevel, "failed", zap.Error(errors.New("connection refused")))
+
+	entries := logs.TakeAll()
+	labels := entries[len(entries)-1].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, "4", labels[repeatCountLabel])
+
+	write(zapcore.ErrorLevel, "failed", zap.Error(errors.New("connection refused")))
+	assert.Equal(t, 0, logs.Len())
+}
+
+func TestSuppressRepeats_TrailingSummary(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(SuppressRepeats(20*time.Millisecond)))
+
+	for i := 0; i < 3; i++ {
+		logger.Error("failed", zap.String("dependency", "db"))
+	}
+	require.Equal(t, 1, logs.Len())
+
+	// The count is written once the window expires, without any further entry.
+	require.Eventually(t, func() bool {
+		return logs.Len() == 2
+	}, time.Second, time.Millisecond)
+
+	summary := logs.All()[1]
+	assert.Equal(t, "failed", summary.Message)
+	assert.Equal(t, "db", summary.ContextMap()["dependency"])
+	assert.Equal(t, "2", summary.ContextMap()[labelsKey].(map[string]interface{})[repeatCountLabel])
+
+	// The count is not written again with the next identical entry.
+	logger.Error("failed", zap.String("dependency", "db"))
+	require.Equal(t, 3, logs.Len())
+	assert.NotContains(t, logs.All()[2].ContextMap()[labelsKey], repeatCountLabel)
+}
+
+func TestSuppressRepeats_Clock(t *testing.T) {
+	clock := newManualClock()
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(Clock(clock), SuppressRepeats(time.Minute)))
+
+	logger.Error("failed")
+	logger.Error("failed")
+
+	// The window follows the clock of the core, not the real time.
+	time.Sleep(10 * time.Millisecond)
+	clock.Add(30 * time.Second)
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, 1, logs.Len())
+
+	clock.Add(30 * time.Second)
+	require.Eventually(t, func() bool {
+		return logs.Len() == 2
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, "1", logs.All()[1].ContextMap()[labelsKey].(map[string]interface{})[repeatCountLabel])
+}
+
+func TestSuppressRepeats_Purge(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	c := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+	SuppressRepeats(time.Minute)(c)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.repeats.now = func() time.Time { return now }
+
+	write := func(message string) {
+		require.NoError(t, c.Write(zapcore.Entry{Level: zapcore.ErrorLevel, Message: message}, nil))
+	}
+
+	for i := 0; i < repeatsPurgeSize; i++ {
+		write(strconv.Itoa(i))
+		write(strconv.Itoa(i))
+	}
+	require.Equal(t, repeatsPurgeSize, logs.Len())
+
+	now = now.Add(time.Minute)
+	write("new")
+
+	// All the expired entries are purged, with the count of the suppressed ones.
+	assert.Len(t, c.repeats.entries, 1)
+	assert.Equal(t, 2*repeatsPurgeSize+1, logs.Len())
+	repeated := logs.FilterMessage("0").All()
+	require.Len(t, repeated, 2)
+	assert.Equal(t, "1", repeated[1].ContextMap()[labelsKey].(map[string]interface{})[repeatCountLabel])
+}
@@ -0,0 +1,384 @@
+// Package replay converts archived zapdriver JSON logs into Cloud Logging
+// entries, and writes them to the Cloud Logging API with their original
+// timestamps, for example to backfill logs after a logging agent outage.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gridwise/zapdriver"
+)
+
+const defaultLoggingEndpoint = "https://logging.googleapis.com/v2/entries:write"
+
+// Keys of the special fields interpreted by Cloud Logging.
+const (
+	labelsKey         = "logging.googleapis.com/labels"
+	sourceLocationKey = "logging.googleapis.com/sourceLocation"
+	operationKey      = "logging.googleapis.com/operation"
+	traceKey          = "logging.googleapis.com/trace"
+	spanKey           = "logging.googleapis.com/spanId"
+	traceSampledKey   = "logging.googleapis.com/trace_sampled"
+	insertIDKey       = "logging.googleapis.com/insertId"
+	httpRequestKey    = "httpRequest"
+)
+
+// zapSeverities maps the level names of the zap encoders to the Cloud Logging
+// severities, for logs not encoded using the zapdriver encoder configuration.
+var zapSeverities = map[string]string{
+	"debug":  "DEBUG",
+	"info":   "INFO",
+	"warn":   "WARNING",
+	"error":  "ERROR",
+	"dpanic": "CRITICAL",
+	"panic":  "ALERT",
+	"fatal":  "EMERGENCY",
+}
+
+// Entry is a Cloud Logging LogEntry, in the JSON format of the API. The log
+// name and monitored resource are set by the Importer.
+//
+// see: https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry
+type Entry struct {
+	Timestamp      time.Time              `json:"timestamp"`
+	Severity       string                 `json:"severity,omitempty"`
+	InsertID       string                 `json:"insertId,omitempty"`
+	Labels         map[string]string      `json:"labels,omitempty"`
+	HTTPRequest    json.RawMessage        `json:"httpRequest,omitempty"`
+	SourceLocation json.RawMessage        `json:"sourceLocation,omitempty"`
+	Operation      json.RawMessage        `json:"operation,omitempty"`
+	Trace          string                 `json:"trace,omitempty"`
+	SpanID         string                 `json:"spanId,omitempty"`
+	TraceSampled   bool                   `json:"traceSampled,omitempty"`
+	JSONPayload    map[string]interface{} `json:"jsonPayload,omitempty"`
+}
+
+// ParseEntry converts a log line written by a zapdriver logger into a Cloud
+// Logging entry, the way the logging agent does: the special fields
+// (severity, timestamp, labels, source location, operation, trace context and
+// HTTP request) are moved to the entry, and the other fields become its JSON
+// payload.
+//
+// Timestamps are read from the `timestamp` or `time` keys (as RFC3339 strings,
+// or as `{"seconds", "nanos"}` objects), from the `timestampSeconds` and
+// `timestampNanos` keys, or from the `ts` key of the zap encoders (a floating
+// point number of seconds). Levels of the zap encoders are mapped to the
+// corresponding severities.
+func ParseEntry(line []byte) (Entry, error) {
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(line, &payload); err != nil {
+		return Entry{}, err
+	}
+
+	entry := Entry{}
+
+	ts, err := parseTimestamp(payload)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry.Timestamp = ts
+
+	for _, key := range []string{"severity", "level"} {
+		if raw, ok := take(payload, key); ok {
+			var severity string
+			if err := json.Unmarshal(raw, &severity); err != nil {
+				return Entry{}, fmt.Errorf("replay: invalid %s: %w", key, err)
+			}
+
+			if s, ok := zapSeverities[strings.ToLower(severity)]; ok {
+				severity = s
+			}
+			entry.Severity = strings.ToUpper(severity)
+
+			break
+		}
+	}
+
+	if raw, ok := take(payload, labelsKey); ok {
+		if err := json.Unmarshal(raw, &entry.Labels); err != nil {
+			return Entry{}, fmt.Errorf("replay: invalid labels: %w", err)
+		}
+	}
+
+	if raw, ok := take(payload, insertIDKey); ok {
+		_ = json.Unmarshal(raw, &entry.InsertID)
+	}
+	if raw, ok := take(payload, traceKey); ok {
+		_ = json.Unmarshal(raw, &entry.Trace)
+	}
+	if raw, ok := take(payload, spanKey); ok {
+		_ = json.Unmarshal(raw, &entry.SpanID)
+	}
+	if raw, ok := take(payload, traceSampledKey); ok {
+		_ = json.Unmarshal(raw, &entry.TraceSampled)
+	}
+
+	entry.HTTPRequest, _ = take(payload, httpRequestKey)
+	entry.SourceLocation, _ = take(payload, sourceLocationKey)
+	entry.Operation, _ = take(payload, operationKey)
+
+	if len(payload) > 0 {
+		entry.JSONPayload = make(map[string]interface{}, len(payload))
+		for k, raw := range payload {
+			var v interface{}
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return Entry{}, err
+			}
+
+			entry.JSONPayload[k] = v
+		}
+	}
+
+	return entry, nil
+}
+
+// take removes the key from the payload, and returns its value.
+func take(payload map[string]json.RawMessage, key string) (json.RawMessage, bool) {
+	raw, ok := payload[key]
+	delete(payload, key)
+
+	return raw, ok
+}
+
+// parseTimestamp removes the timestamp from the payload, and returns it.
+func parseTimestamp(payload map[string]json.RawMessage) (time.Time, error) {
+	for _, key := range []string{"timestamp", "time"} {
+		raw, ok := take(payload, key)
+		if !ok {
+			continue
+		}
+
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return time.Parse(time.RFC3339Nano, s)
+		}
+
+		var split struct {
+			Seconds int64 `json:"seconds"`
+			Nanos   int64 `json:"nanos"`
+		}
+		if err := json.Unmarshal(raw, &split); err != nil {
+			return time.Time{}, fmt.Errorf("replay: invalid %s: %w", key, err)
+		}
+
+		return time.Unix(split.Seconds, split.Nanos).UTC(), nil
+	}
+
+	if raw, ok := take(payload, "timestampSeconds"); ok {
+		var seconds, nanos int64
+		if err := json.Unmarshal(raw, &seconds); err != nil {
+			return time.Time{}, fmt.Errorf("replay: invalid timestampSeconds: %w", err)
+		}
+		if raw, ok := take(payload, "timestampNanos"); ok {
+			_ = json.Unmarshal(raw, &nanos)
+		}
+
+		return time.Unix(seconds, nanos).UTC(), nil
+	}
+
+	if raw, ok := take(payload, "ts"); ok {
+		var seconds float64
+		if err := json.Unmarshal(raw, &seconds); err != nil {
+			return time.Time{}, fmt.Errorf("replay: invalid ts: %w", err)
+		}
+
+		whole, frac := math.Modf(seconds)
+		return time.Unix(int64(whole), int64(frac*1e9)).UTC(), nil
+	}
+
+	return time.Time{}, errors.New("replay: missing timestamp")
+}
+
+// importer holds the configuration of an Importer.
+type importer struct {
+	client    *http.Client
+	project   string
+	resource  *zapdriver.MonitoredResource
+	batchSize int
+	endpoint  string
+}
+
+// replay importer option to use `client` to call the Cloud Logging API. The
+// client is responsible for authenticating the requests, for example using
+// `golang.org/x/oauth2/google.DefaultClient`
+func ImportClient(client *http.Client) func(*importer) {
+	return func(i *importer) {
+		i.client = client
+	}
+}
+
+// replay importer option to write the entries to project `id`, instead of
+// the project returned by `zapdriver.ProjectID()`
+func ImportProject(id string) func(*importer) {
+	return func(i *importer) {
+		i.project = id
+	}
+}
+
+// replay importer option to set the monitored resource of the entries,
+// instead of the `global` resource
+func ImportResource(resourceType string, labels map[string]string) func(*importer) {
+	return func(i *importer) {
+		i.resource = &zapdriver.MonitoredResource{Type: resourceType, Labels: labels}
+	}
+}
+
+// replay importer option to write at most `n` entries per API request
+func ImportBatchSize(n int) func(*importer) {
+	return func(i *importer) {
+		i.batchSize = n
+	}
+}
+
+// ImportStats are the statistics of an import.
+type ImportStats struct {
+	// Written is the number of entries written to the API.
+	Written int
+
+	// Skipped is the number of lines which could not be parsed as entries.
+	Skipped int
+}
+
+// Importer writes archived zapdriver logs to the Cloud Logging API.
+//
+// see: https://cloud.google.com/logging/docs/reference/v2/rest/v2/entries/write
+type Importer struct {
+	logName string
+	config  importer
+}
+
+// NewImporter returns an Importer writing entries to the log `logID` (e.g.
+// "stdout", or "run.googleapis.com%2Fstderr" for Cloud Run logs).
+func NewImporter(logID string, options ...func(*importer)) (*Importer, error) {
+	i := &Importer{
+		config: importer{
+			client:    http.DefaultClient,
+			resource:  &zapdriver.MonitoredResource{Type: "global"},
+			batchSize: 500,
+			endpoint:  defaultLoggingEndpoint,
+		},
+	}
+	for _, option := range options {
+		option(&i.config)
+	}
+
+	if i.config.project == "" {
+		i.config.project = zapdriver.ProjectID()
+	}
+	if i.config.project == "" {
+		return nil, errors.New("replay: no project configured to write entries to")
+	}
+	if i.config.batchSize < 1 {
+		i.config.batchSize = 1
+	}
+
+	i.logName = "projects/" + i.config.project + "/logs/" + logID
+
+	return i, nil
+}
+
+// Import reads newline-delimited JSON entries from `r` (e.g. an archived log
+// file, or a Cloud Storage object reader), and writes them to the API in
+// batches. Lines which cannot be parsed are skipped. Import stops at the first
+// API error.
+func (i *Importer) Import(ctx context.Context, r io.Reader) (ImportStats, error) {
+	stats := ImportStats{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*zapdriver.DefaultMaxEntrySize)
+
+	batch := make([]Entry, 0, i.config.batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := i.write(ctx, batch); err != nil {
+			return err
+		}
+
+		stats.Written += len(batch)
+		batch = batch[:0]
+
+		return nil
+	}
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		entry, err := ParseEntry(line)
+		if err != nil {
+			stats.Skipped++
+			continue
+		}
+
+		batch = append(batch, entry)
+		if len(batch) < i.config.batchSize {
+			continue
+		}
+
+		if err := flush(); err != nil {
+			return stats, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return stats, err
+	}
+
+	return stats, flush()
+}
+
+// writeRequest is the payload of the `entries.write` API.
+type writeRequest struct {
+	LogName  string                       `json:"logName"`
+	Resource *zapdriver.MonitoredResource `json:"resource"`
+	Entries  []Entry                      `json:"entries"`
+
+	// PartialSuccess writes the valid entries of a batch, even if some
+	// entries of the batch are rejected.
+	PartialSuccess bool `json:"partialSuccess"`
+}
+
+func (i *Importer) write(ctx context.Context, entries []Entry) error {
+	body, err := json.Marshal(writeRequest{
+		LogName:        i.logName,
+		Resource:       i.config.resource,
+		Entries:        entries,
+		PartialSuccess: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.config.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := i.config.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close() // nolint: errcheck
+
+	if res.StatusCode != http.StatusOK {
+		return errors.New("replay: logging API returned " + res.Status)
+	}
+
+	return nil
+}
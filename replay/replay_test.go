@@ -0,0 +1,136 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/gridwise/zapdriver"
+)
+
+func TestParseEntry(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zapdriver.NewProductionEncoderConfig()), zapcore.AddSync(buf), zapcore.DebugLevel)
+	logger := zap.New(core, zap.AddCaller(), zapdriver.WrapCore())
+
+	logger.Warn("hello",
+		zapdriver.Label("tenant", "acme"),
+		zap.String("user", "jane"),
+		zapdriver.OperationStart("op", "producer"),
+	)
+
+	entry, err := ParseEntry(buf.Bytes())
+	require.NoError(t, err)
+
+	assert.WithinDuration(t, time.Now(), entry.Timestamp, time.Minute)
+	assert.Equal(t, "WARNING", entry.Severity)
+	assert.Equal(t, map[string]string{"tenant": "acme"}, entry.Labels)
+	assert.Contains(t, string(entry.SourceLocation), "replay_test.go")
+	assert.JSONEq(t, `{"id": "op", "producer": "producer", "first": true, "last": false}`, string(entry.Operation))
+	assert.Equal(t, map[string]interface{}{"message": "hello", "user": "jane", "caller": entry.JSONPayload["caller"]}, entry.JSONPayload)
+}
+
+func TestParseEntry_Formats(t *testing.T) {
+	t.Parallel()
+
+	want := time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC)
+	tests := map[string]string{
+		"timestamp":        `{"timestamp": "2020-01-02T03:04:05.000006Z", "severity": "INFO"}`,
+		"time":             `{"time": "2020-01-02T03:04:05.000006Z", "level": "info"}`,
+		"timestamp object": `{"timestamp": {"seconds": 1577934245, "nanos": 6000}, "level": "INFO"}`,
+		"split":            `{"timestampSeconds": 1577934245, "timestampNanos": 6000, "level": "info"}`,
+		"zap":              `{"ts": 1577934245.000006, "level": "info"}`,
+	}
+
+	for name, line := range tests {
+		entry, err := ParseEntry([]byte(line))
+		require.NoError(t, err, name)
+
+		assert.WithinDuration(t, want, entry.Timestamp, time.Microsecond, name)
+		assert.Equal(t, "INFO", entry.Severity, name)
+		assert.Empty(t, entry.JSONPayload, name)
+	}
+
+	entry, err := ParseEntry([]byte(`{"ts": 1, "level": "dpanic", "logging.googleapis.com/trace": "projects/p/traces/t", "logging.googleapis.com/trace_sampled": true}`))
+	require.NoError(t, err)
+	assert.Equal(t, "CRITICAL", entry.Severity)
+	assert.Equal(t, "projects/p/traces/t", entry.Trace)
+	assert.True(t, entry.TraceSampled)
+
+	_, err = ParseEntry([]byte(`{"message": "no timestamp"}`))
+	assert.EqualError(t, err, "replay: missing timestamp")
+
+	_, err = ParseEntry([]byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestImporter(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	requests := []writeRequest{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req writeRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		mutex.Lock()
+		requests = append(requests, req)
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	importer, err := NewImporter("stdout", ImportProject("my-project"), ImportBatchSize(2), ImportResource("k8s_container", map[string]string{"namespace_name": "default"}))
+	require.NoError(t, err)
+	importer.config.endpoint = server.URL
+
+	logs := strings.Join([]string{
+		`{"timestamp": "2020-01-02T03:04:05Z", "severity": "INFO", "message": "one"}`,
+		`garbage`,
+		``,
+		`{"timestamp": "2020-01-02T03:04:06Z", "severity": "INFO", "message": "two"}`,
+		`{"timestamp": "2020-01-02T03:04:07Z", "severity": "ERROR", "message": "three"}`,
+	}, "\n")
+
+	stats, err := importer.Import(context.Background(), strings.NewReader(logs))
+	require.NoError(t, err)
+	assert.Equal(t, ImportStats{Written: 3, Skipped: 1}, stats)
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, "projects/my-project/logs/stdout", requests[0].LogName)
+	assert.Equal(t, "k8s_container", requests[0].Resource.Type)
+	assert.Len(t, requests[0].Entries, 2)
+	assert.Equal(t, "ERROR", requests[1].Entries[0].Severity)
+	assert.Equal(t, "three", requests[1].Entries[0].JSONPayload["message"])
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 7, 0, time.UTC), requests[1].Entries[0].Timestamp)
+}
+
+func TestImporter_APIError(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	importer, err := NewImporter("stdout", ImportProject("my-project"))
+	require.NoError(t, err)
+	importer.config.endpoint = server.URL
+
+	stats, err := importer.Import(context.Background(), strings.NewReader(`{"timestamp": "2020-01-02T03:04:05Z"}`))
+	assert.EqualError(t, err, "replay: logging API returned 403 Forbidden")
+	assert.Equal(t, 0, stats.Written)
+}
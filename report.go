@@ -1,12 +1,21 @@
 package zapdriver
 
 import (
+	"net/http"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"runtime"
 )
 
-const contextKey = "context"
+const (
+	contextKey    = "context"
+	errorUserKey  = "context.user"
+	errorGroupKey = "context.group"
+	errorHTTPKey  = "context.httpRequest"
+)
 
 // ErrorReport adds the correct Stackdriver "context" field for getting the log line
 // reported as error.
@@ -16,6 +25,136 @@ func ErrorReport(pc uintptr, file string, line int, ok bool) zap.Field {
 	return zap.Object(contextKey, newReportContext(pc, file, line, ok))
 }
 
+// ErrorUser adds the ID of the user affected by the reported error. It needs
+// to be used in combination with `ErrorReport()` (or the `ReportAllErrors` core
+// option), and the zapdriver core to be merged into the error context.
+func ErrorUser(id string) zap.Field {
+	return zap.String(errorUserKey, id)
+}
+
+// ErrorGroup adds a stable grouping key to the reported error, so that errors
+// can be grouped by an application-defined fingerprint instead of by their
+// source location. Error Reporting groups errors without a stack trace by their
+// reported location, so the key replaces the function name of the reported
+// location. Like `ErrorUser()`, it needs the zapdriver core to be merged into
+// the error context.
+func ErrorGroup(key string) zap.Field {
+	return zap.String(errorGroupKey, key)
+}
+
+// ErrorHTTPContext adds the HTTP request during which the reported error
+// occurred, with the status code of its response (omitted when 0), so error
+// groups show which endpoints fail. Like `ErrorUser()`, it needs the zapdriver
+// core to be merged into the error context. The `LoggerMiddleware` attaches it
+// to the reported errors of the request loggers automatically.
+func ErrorHTTPContext(r *http.Request, status int) zap.Field {
+	context := newErrorHTTPContext(r)
+	context.Status = status
+
+	return zap.Object(errorHTTPKey, context)
+}
+
+// errorHTTPContext is the HTTP request during which an error occurred.
+type errorHTTPContext struct {
+	Method    string
+	URL       string
+	UserAgent string
+	Referrer  string
+	RemoteIP  string
+	Status    int
+
+	// status is the status code written by the handler, when recorded by the
+	// middleware.
+	status *int32
+}
+
+func newErrorHTTPContext(r *http.Request) *errorHTTPContext {
+	return &errorHTTPContext{
+		Method:    r.Method,
+		URL:       requestURL(r),
+		UserAgent: r.UserAgent(),
+		Referrer:  r.Referer(),
+		RemoteIP:  remoteIP(r),
+	}
+}
+
+// resolve returns a copy of the context, with the status code recorded so far.
+func (context *errorHTTPContext) resolve() *errorHTTPContext {
+	if context.status == nil {
+		return context
+	}
+
+	resolved := *context
+	resolved.Status = int(atomic.LoadInt32(context.status))
+	resolved.status = nil
+
+	return &resolved
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (context errorHTTPContext) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("method", context.Method)
+	enc.AddString("url", context.URL)
+	if context.UserAgent != "" {
+		enc.AddString("userAgent", context.UserAgent)
+	}
+	if context.Referrer != "" {
+		enc.AddString("referrer", context.Referrer)
+	}
+	if context.Status != 0 {
+		enc.AddInt("responseStatusCode", context.Status)
+	}
+	if context.RemoteIP != "" {
+		enc.AddString("remoteIp", context.RemoteIP)
+	}
+
+	return nil
+}
+
+// holdErrorHTTPContext separates the last `ErrorHTTPContext()` field, if any,
+// from the other fields.
+func holdErrorHTTPContext(fields []zapcore.Field) (*zapcore.Field, []zapcore.Field) {
+	var held *zapcore.Field
+	rest := fields[:0:0]
+
+	for i := range fields {
+		if fields[i].Key == errorHTTPKey {
+			field := fields[i]
+			held = &field
+			continue
+		}
+
+		rest = append(rest, fields[i])
+	}
+
+	if held == nil {
+		return nil, fields
+	}
+
+	return held, rest
+}
+
+// withErrorHTTPContext adds the `ErrorHTTPContext()` field of the logger, when
+// the entry has an error context, and no HTTP context of its own.
+func (c *core) withErrorHTTPContext(fields []zapcore.Field) []zapcore.Field {
+	reported := false
+	for i := range fields {
+		if fields[i].Key == errorHTTPKey {
+			return fields
+		}
+
+		if _, ok := fields[i].Interface.(*reportContext); ok && fields[i].Key == contextKey {
+			reported = true
+		}
+	}
+
+	if !reported {
+		return fields
+	}
+
+	return append(fields[:len(fields):len(fields)], *c.errorHTTP)
+}
+
 // reportLocation is the source code location information associated with the log entry
 // for the purpose of reporting an error,
 // if any.
@@ -37,15 +176,96 @@ func (location reportLocation) MarshalLogObject(enc zapcore.ObjectEncoder) error
 // reportContext is the context information attached to a log for reporting errors
 type reportContext struct {
 	ReportLocation reportLocation `json:"reportLocation"`
+
+	// User is the ID of the user affected by the error, if any.
+	User string `json:"user"`
+
+	// HTTPRequest is the HTTP request during which the error occurred, if any.
+	HTTPRequest *errorHTTPContext `json:"httpRequest"`
 }
 
 // MarshalLogObject implements zapcore.ObjectMarshaller interface.
 func (context reportContext) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	_ = enc.AddObject("reportLocation", context.ReportLocation)
+	if context.User != "" {
+		enc.AddString("user", context.User)
+	}
+	if context.HTTPRequest != nil {
+		_ = enc.AddObject("httpRequest", context.HTTPRequest)
+	}
 
 	return nil
 }
 
+// mergeErrorContext moves the `ErrorUser()`, `ErrorGroup()` and
+// `ErrorHTTPContext()` fields into the error context of the entry. The fields are left untouched when the entry has
+// no error context.
+func mergeErrorContext(fields []zapcore.Field) []zapcore.Field {
+	var user, group, httpRequest *zapcore.Field
+	report := -1
+
+	for i := range fields {
+		switch fields[i].Key {
+		case errorUserKey:
+			user = &fields[i]
+		case errorGroupKey:
+			group = &fields[i]
+		case errorHTTPKey:
+			httpRequest = &fields[i]
+		case contextKey:
+			if _, ok := fields[i].Interface.(*reportContext); ok {
+				report = i
+			}
+		}
+	}
+
+	if report == -1 || (user == nil && group == nil && httpRequest == nil) {
+		return fields
+	}
+
+	context := *fields[report].Interface.(*reportContext)
+	if user != nil {
+		context.User = user.String
+	}
+	if group != nil {
+		context.ReportLocation.Function = group.String
+	}
+	if httpRequest != nil {
+		if c, ok := httpRequest.Interface.(*errorHTTPContext); ok {
+			context.HTTPRequest = c.resolve()
+		}
+	}
+
+	out := make([]zapcore.Field, 0, len(fields))
+	for i := range fields {
+		switch {
+		case fields[i].Key == errorUserKey, fields[i].Key == errorGroupKey, fields[i].Key == errorHTTPKey:
+			continue
+		case i == report:
+			out = append(out, zap.Object(contextKey, &context))
+		default:
+			out = append(out, fields[i])
+		}
+	}
+
+	return out
+}
+
+// withoutErrorContext removes the error context of the entry, for the entries
+// sent to the Error Reporting API instead.
+func withoutErrorContext(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, 0, len(fields))
+	for i := range fields {
+		if _, ok := fields[i].Interface.(*reportContext); ok && fields[i].Key == contextKey {
+			continue
+		}
+
+		out = append(out, fields[i])
+	}
+
+	return out
+}
+
 func newReportContext(pc uintptr, file string, line int, ok bool) *reportContext {
 	if !ok {
 		return nil
@@ -66,3 +286,30 @@ func newReportContext(pc uintptr, file string, line int, ok bool) *reportContext
 
 	return context
 }
+
+// embedStack appends the stack trace to the message, in the format of the Go
+// runtime (as printed on panics), which is the format Error Reporting parses
+// to extract the stack trace of Go errors.
+//
+// Zap stack traces list the function name and location of each frame, but the
+// runtime format expects the function to be followed by its arguments.
+func embedStack(message, stack string) string {
+	var b strings.Builder
+	b.Grow(len(message) + len(stack) + 64)
+
+	b.WriteString(message)
+	b.WriteString("\n\ngoroutine 1 [running]:\n")
+
+	for i, line := range strings.Split(stack, "\n") {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+
+		b.WriteString(line)
+		if line != "" && !strings.HasPrefix(line, "\t") && !strings.HasSuffix(line, ")") {
+			b.WriteString("()")
+		}
+	}
+
+	return b.String()
+}
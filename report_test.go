@@ -5,6 +5,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestErrorReport(t *testing.T) {
@@ -13,7 +16,7 @@ func TestErrorReport(t *testing.T) {
 	got := ErrorReport(runtime.Caller(0)).Interface.(*reportContext)
 
 	assert.Contains(t, got.ReportLocation.File, "zapdriver/report_test.go")
-	assert.Equal(t, 13, got.ReportLocation.Line)
+	assert.Equal(t, 16, got.ReportLocation.Line)
 	assert.Contains(t, got.ReportLocation.Function, "zapdriver.TestErrorReport")
 }
 
@@ -23,6 +26,61 @@ func TestNewReportContext(t *testing.T) {
 	got := newReportContext(runtime.Caller(0))
 
 	assert.Contains(t, got.ReportLocation.File, "zapdriver/report_test.go")
-	assert.Equal(t, 23, got.ReportLocation.Line)
+	assert.Equal(t, 26, got.ReportLocation.Line)
 	assert.Contains(t, got.ReportLocation.Function, "zapdriver.TestNewReportContext")
 }
+
+func TestMergeErrorContext(t *testing.T) {
+	t.Parallel()
+
+	pc, file, line, ok := runtime.Caller(0)
+	fields := []zap.Field{
+		ErrorUser("user-1"),
+		ErrorReport(pc, file, line, ok),
+		ErrorGroup("group-1"),
+		zap.String("hello", "world"),
+	}
+
+	got := mergeErrorContext(fields)
+	require.Len(t, got, 2)
+	assert.Equal(t, zap.String("hello", "world"), got[1])
+
+	context := got[0].Interface.(*reportContext)
+	assert.Equal(t, "user-1", context.User)
+	assert.Equal(t, "group-1", context.ReportLocation.Function)
+	assert.Equal(t, line, context.ReportLocation.Line)
+
+	// The original error report is not mutated.
+	assert.Contains(t, fields[1].Interface.(*reportContext).ReportLocation.Function, "TestMergeErrorContext")
+}
+
+func TestMergeErrorContext_NoReport(t *testing.T) {
+	t.Parallel()
+
+	fields := []zap.Field{ErrorUser("user-1")}
+
+	assert.Equal(t, fields, mergeErrorContext(fields))
+}
+
+func TestReportContext_MarshalLogObject(t *testing.T) {
+	t.Parallel()
+
+	enc := zapcore.NewMapObjectEncoder()
+	require.NoError(t, reportContext{User: "user-1"}.MarshalLogObject(enc))
+
+	assert.Equal(t, "user-1", enc.Fields["user"])
+
+	enc = zapcore.NewMapObjectEncoder()
+	require.NoError(t, reportContext{}.MarshalLogObject(enc))
+
+	assert.NotContains(t, enc.Fields, "user")
+}
+
+func TestEmbedStack(t *testing.T) {
+	t.Parallel()
+
+	stack := "main.run\n\t/app/main.go:12\nmain.main\n\t/app/main.go:5"
+	want := "failed\n\ngoroutine 1 [running]:\nmain.run()\n\t/app/main.go:12\nmain.main()\n\t/app/main.go:5"
+
+	assert.Equal(t, want, embedStack("failed", stack))
+}
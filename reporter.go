@@ -0,0 +1,396 @@
+package zapdriver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const defaultErrorReportingEndpoint = "https://clouderrorreporting.googleapis.com/v1beta1/projects/%s/events:report"
+
+// errorReporter holds the configuration of an ErrorReporter.
+type errorReporter struct {
+	client      *http.Client
+	project     string
+	interval    time.Duration
+	concurrency int
+	endpoint    string
+	onError     func(error)
+}
+
+// zapdriver error reporter option to use `client` to call the Error Reporting
+// API. The client is responsible for authenticating the requests, for example
+// using `golang.org/x/oauth2/google.DefaultClient`
+func ReporterClient(client *http.Client) func(*errorReporter) {
+	return func(r *errorReporter) {
+		r.client = client
+	}
+}
+
+// zapdriver error reporter option to report errors to project `id`, instead of
+// the project returned by `ProjectID()`
+func ReporterProject(id string) func(*errorReporter) {
+	return func(r *errorReporter) {
+		r.project = id
+	}
+}
+
+// zapdriver error reporter option to aggregate identical errors over
+// `interval` (10 seconds by default, or if `interval` is not positive), before
+// sending them to the API
+func ReporterInterval(interval time.Duration) func(*errorReporter) {
+	return func(r *errorReporter) {
+		r.interval = interval
+	}
+}
+
+// zapdriver error reporter option to send at most `n` concurrent requests to
+// the API
+func ReporterConcurrency(n int) func(*errorReporter) {
+	return func(r *errorReporter) {
+		r.concurrency = n
+	}
+}
+
+// zapdriver error reporter option to call `fn` with every error returned by the
+// API
+func ReporterOnError(fn func(error)) func(*errorReporter) {
+	return func(r *errorReporter) {
+		r.onError = fn
+	}
+}
+
+// zapdriver core option to send all entries reported to Error Reporting (see
+// `ReportAllErrors()` and `ErrorReport()`) to the Error Reporting API using
+// `reporter`, in addition to writing them to the wrapped core. The entries are
+// written without their error context, so Error Reporting does not also parse
+// them from the logs
+func ReportTo(reporter *ErrorReporter) func(*core) {
+	return func(c *core) {
+		c.config.Reporter = reporter
+	}
+}
+
+// ErrorEvent is an error sent to the Error Reporting API.
+type ErrorEvent struct {
+	Time     time.Time
+	Message  string
+	User     string
+	File     string
+	Line     int
+	Function string
+}
+
+// key identifies identical events.
+func (e ErrorEvent) key() string {
+	return e.Message + "\x00" + e.File + "\x00" + strconv.Itoa(e.Line) + "\x00" + e.Function + "\x00" + e.User
+}
+
+// pendingEvent is an aggregated event, waiting to be sent.
+type pendingEvent struct {
+	event ErrorEvent
+	count int
+}
+
+// ErrorReporter sends errors to the Error Reporting API. Identical events
+// (same message, location and user) reported within an interval are
+// aggregated, and sent as a single event, with the number of occurrences
+// appended to the first line of the message. Events are sent in the background,
+// with bounded concurrency, so that a hot error path neither exhausts the API
+// quota nor adds latency to the caller.
+//
+// see: https://cloud.google.com/error-reporting/reference/rest/v1beta1/projects.events/report
+type ErrorReporter struct {
+	service string
+	version string
+	config  errorReporter
+
+	mutex   *sync.Mutex
+	pending map[string]*pendingEvent
+	order   []string
+	closed  bool
+
+	sem    chan struct{}
+	wg     *sync.WaitGroup
+	stop   chan struct{}
+	done   chan struct{}
+	failed uint64
+}
+
+// NewErrorReporter returns an ErrorReporter reporting errors of the service
+// `service`, with version `version` (optional).
+func NewErrorReporter(service, version string, options ...func(*errorReporter)) (*ErrorReporter, error) {
+	r := &ErrorReporter{
+		service: service,
+		version: version,
+		config: errorReporter{
+			client:      http.DefaultClient,
+			interval:    10 * time.Second,
+			concurrency: 4,
+		},
+		mutex:   &sync.Mutex{},
+		pending: map[string]*pendingEvent{},
+		wg:      &sync.WaitGroup{},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, option := range options {
+		option(&r.config)
+	}
+
+	if r.config.project == "" {
+		r.config.project = ProjectID()
+	}
+	if r.config.project == "" {
+		return nil, errors.New("zapdriver: no project configured to report errors to")
+	}
+	if r.config.endpoint == "" {
+		r.config.endpoint = fmt.Sprintf(defaultErrorReportingEndpoint, r.config.project)
+	}
+	if r.config.concurrency < 1 {
+		r.config.concurrency = 1
+	}
+	if r.config.interval <= 0 {
+		r.config.interval = 10 * time.Second
+	}
+
+	r.sem = make(chan struct{}, r.config.concurrency)
+	go r.run()
+
+	return r, nil
+}
+
+// Report queues the event, to be sent at the end of the current interval. The
+// events reported after `Close()` are dropped, and counted as failed.
+func (r *ErrorReporter) Report(event ErrorEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	key := event.key()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		atomic.AddUint64(&r.failed, 1)
+		return
+	}
+
+	if p, ok := r.pending[key]; ok {
+		p.count++
+		return
+	}
+
+	r.pending[key] = &pendingEvent{event: event, count: 1}
+	r.order = append(r.order, key)
+}
+
+// Flush sends all pending events, and waits for all requests to complete.
+func (r *ErrorReporter) Flush() {
+	r.flush()
+	r.wg.Wait()
+}
+
+// FlushContext sends all pending events, and waits for all requests to
+// complete, or for the context to be done. Requests still running when the
+// context is done are abandoned, and FlushContext returns without waiting for
+// them.
+func (r *ErrorReporter) FlushContext(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		r.flush()
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops the reporter, after sending all pending events. Events
+// reported after Close are dropped, and counted as failed.
+func (r *ErrorReporter) Close() {
+	r.mutex.Lock()
+	closed := r.closed
+	r.closed = true
+	r.mutex.Unlock()
+
+	if !closed {
+		close(r.stop)
+	}
+	<-r.done
+}
+
+// Failed returns the number of events the API failed to accept.
+func (r *ErrorReporter) Failed() uint64 {
+	return atomic.LoadUint64(&r.failed)
+}
+
+func (r *ErrorReporter) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.config.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.flush()
+		case <-r.stop:
+			r.Flush()
+			return
+		}
+	}
+}
+
+// flush starts sending the pending events, in the order they were first
+// reported, without waiting for the requests. The events are sent by at most
+// `concurrency` workers, which share the request slots of the reporter with the
+// workers of the previous flushes.
+func (r *ErrorReporter) flush() {
+	r.mutex.Lock()
+	pending, order := r.pending, r.order
+	r.pending, r.order = map[string]*pendingEvent{}, nil
+	r.mutex.Unlock()
+
+	if len(order) == 0 {
+		return
+	}
+
+	queue := make(chan *pendingEvent, len(order))
+	for _, key := range order {
+		queue <- pending[key]
+	}
+	close(queue)
+
+	workers := r.config.concurrency
+	if workers > len(order) {
+		workers = len(order)
+	}
+
+	r.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer r.wg.Done()
+
+			for p := range queue {
+				r.sem <- struct{}{}
+				err := r.send(p)
+				<-r.sem
+
+				if err != nil {
+					atomic.AddUint64(&r.failed, 1)
+					if r.config.onError != nil {
+						r.config.onError(err)
+					}
+				}
+			}
+		}()
+	}
+}
+
+// reportedErrorEvent is the payload of the `events.report` API.
+type reportedErrorEvent struct {
+	EventTime      time.Time `json:"eventTime"`
+	ServiceContext struct {
+		Service string `json:"service"`
+		Version string `json:"version,omitempty"`
+	} `json:"serviceContext"`
+	Message string `json:"message"`
+	Context struct {
+		User           string          `json:"user,omitempty"`
+		ReportLocation *reportLocation `json:"reportLocation,omitempty"`
+	} `json:"context"`
+}
+
+func (r *ErrorReporter) send(p *pendingEvent) error {
+	payload := reportedErrorEvent{EventTime: p.event.Time.UTC(), Message: p.event.Message}
+	payload.ServiceContext.Service = r.service
+	payload.ServiceContext.Version = r.version
+	payload.Context.User = p.event.User
+
+	if p.event.File != "" {
+		payload.Context.ReportLocation = &reportLocation{
+			File:     p.event.File,
+			Line:     p.event.Line,
+			Function: p.event.Function,
+		}
+	}
+
+	if p.count > 1 {
+		payload.Message = withOccurrences(p.event.Message, p.count, r.config.interval)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := r.config.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close() // nolint: errcheck
+
+	if res.StatusCode != http.StatusOK {
+		return errors.New("zapdriver: error reporting API returned " + res.Status)
+	}
+
+	return nil
+}
+
+// withOccurrences appends the number of occurrences to the first line of the
+// message, so that the stack trace (if any) can still be parsed.
+func withOccurrences(message string, count int, interval time.Duration) string {
+	suffix := fmt.Sprintf(" (%d occurrences in %s)", count, interval)
+
+	if i := strings.IndexByte(message, '\n'); i >= 0 {
+		return message[:i] + suffix + message[i:]
+	}
+
+	return message + suffix
+}
+
+// errorEvent returns the event of an entry reported to Error Reporting.
+func errorEvent(ent zapcore.Entry, fields []zapcore.Field) ErrorEvent {
+	event := ErrorEvent{Time: ent.Time, Message: ent.Message}
+	if ent.Stack != "" && !embedsStack(ent.Message) {
+		// The API only parses stack traces embedded in the message.
+		event.Message = embedStack(ent.Message, ent.Stack)
+	}
+
+	for i := range fields {
+		if context, ok := fields[i].Interface.(*reportContext); ok && context != nil {
+			event.User = context.User
+			event.File = context.ReportLocation.File
+			event.Line = context.ReportLocation.Line
+			event.Function = context.ReportLocation.Function
+		}
+	}
+
+	return event
+}
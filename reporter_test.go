@@ -0,0 +1,239 @@
+package zapdriver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// fakeAPI is a fake Google API, responding to every request with a fixed
+// status, and recording the JSON payloads of the requests.
+type fakeAPI struct {
+	*httptest.Server
+
+	mutex    *sync.Mutex
+	paths    []string
+	payloads []json.RawMessage
+}
+
+func newFakeAPI(t *testing.T, status int) *fakeAPI {
+	api := &fakeAPI{mutex: &sync.Mutex{}}
+
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload json.RawMessage
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+
+		api.mutex.Lock()
+		api.paths = append(api.paths, r.URL.Path)
+		api.payloads = append(api.payloads, payload)
+		api.mutex.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(api.Close)
+
+	return api
+}
+
+// requests calls `fn` with the path and payload of each request received so
+// far, in order.
+func (api *fakeAPI) requests(fn func(path string, payload json.RawMessage)) {
+	api.mutex.Lock()
+	paths, payloads := append([]string{}, api.paths...), append([]json.RawMessage{}, api.payloads...)
+	api.mutex.Unlock()
+
+	for i := range paths {
+		fn(paths[i], payloads[i])
+	}
+}
+
+func newTestErrorReporter(t *testing.T, status int, options ...func(*errorReporter)) (*ErrorReporter, func() []reportedErrorEvent) {
+	api := newFakeAPI(t, status)
+
+	options = append([]func(*errorReporter){
+		ReporterProject("my-project"),
+		ReporterInterval(time.Hour),
+		func(r *errorReporter) { r.endpoint = api.URL },
+	}, options...)
+
+	reporter, err := NewErrorReporter("my-service", "v1", options...)
+	require.NoError(t, err)
+	t.Cleanup(reporter.Close)
+
+	return reporter, func() []reportedErrorEvent {
+		events := []reportedErrorEvent{}
+		api.requests(func(_ string, payload json.RawMessage) {
+			var event reportedErrorEvent
+			assert.NoError(t, json.Unmarshal(payload, &event))
+			events = append(events, event)
+		})
+
+		return events
+	}
+}
+
+func TestErrorReporter(t *testing.T) {
+	t.Parallel()
+
+	reporter, events := newTestErrorReporter(t, http.StatusOK, ReporterConcurrency(2))
+
+	for i := 0; i < 3; i++ {
+		reporter.Report(ErrorEvent{Message: "failed\n\ngoroutine 1 [running]:", File: "main.go", Line: 12})
+	}
+	reporter.Report(ErrorEvent{Message: "other", User: "jane"})
+	reporter.Flush()
+
+	got := events()
+	require.Len(t, got, 2)
+
+	byMessage := map[string]reportedErrorEvent{}
+	for _, e := range got {
+		byMessage[e.Message] = e
+	}
+
+	aggregated := byMessage["failed (3 occurrences in 1h0m0s)\n\ngoroutine 1 [running]:"]
+	assert.Equal(t, "my-service", aggregated.ServiceContext.Service)
+	assert.Equal(t, "v1", aggregated.ServiceContext.Version)
+	require.NotNil(t, aggregated.Context.ReportLocation)
+	assert.Equal(t, 12, aggregated.Context.ReportLocation.Line)
+
+	assert.Equal(t, "jane", byMessage["other"].Context.User)
+	assert.Nil(t, byMessage["other"].Context.ReportLocation)
+	assert.Zero(t, reporter.Failed())
+}
+
+func TestErrorReporter_Failed(t *testing.T) {
+	t.Parallel()
+
+	var errs []error
+	reporter, _ := newTestErrorReporter(t, http.StatusTooManyRequests, ReporterOnError(func(err error) {
+		errs = append(errs, err)
+	}))
+
+	reporter.Report(ErrorEvent{Message: "failed"})
+	reporter.Flush()
+
+	assert.Equal(t, uint64(1), reporter.Failed())
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "429")
+}
+
+func TestNewErrorReporter_NoProject(t *testing.T) {
+	defer func(d *projectDetector) { detectedProject = d }(detectedProject)
+	detectedProject = &projectDetector{}
+	detectedProject.once.Do(func() {})
+
+	_, err := NewErrorReporter("my-service", "")
+	assert.Error(t, err)
+}
+
+func TestReportTo(t *testing.T) {
+	t.Parallel()
+
+	reporter, events := newTestErrorReporter(t, http.StatusOK)
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	c := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+	ReportAllErrors(true)(c)
+	ReportTo(reporter)(c)
+
+	pc, file, line, ok := runtime.Caller(0)
+	require.NoError(t, c.Write(zapcore.Entry{Level: zapcore.ErrorLevel, Message: "failed", Caller: zapcore.NewEntryCaller(pc, file, line, ok)}, nil))
+	require.NoError(t, c.Write(zapcore.Entry{Level: zapcore.InfoLevel, Message: "ok"}, nil))
+	reporter.Flush()
+
+	assert.Equal(t, 2, logs.Len())
+
+	got := events()
+	require.Len(t, got, 1)
+	assert.Equal(t, "failed", got[0].Message)
+	assert.Equal(t, line, got[0].Context.ReportLocation.Line)
+	assert.NotContains(t, logs.All()[0].ContextMap(), contextKey, "reported entries are not parsed from the logs")
+}
+
+func TestReportTo_EmbeddedStack(t *testing.T) {
+	t.Parallel()
+
+	reporter, events := newTestErrorReporter(t, http.StatusOK)
+
+	debugcore, _ := observer.New(zapcore.DebugLevel)
+	c := newCore(debugcore, []func(*core){ReportAllErrors(true), EmbedStack(true), PreserveStackField(true), ReportTo(reporter)})
+
+	stack := "main.main()\n\t/app/main.go:12 +0x1d"
+	require.NoError(t, c.Write(zapcore.Entry{Level: zapcore.ErrorLevel, Message: "failed", Stack: stack}, nil))
+	reporter.Flush()
+
+	got := events()
+	require.Len(t, got, 1)
+	assert.Equal(t, 1, strings.Count(got[0].Message, "main.main()"), got[0].Message)
+}
+
+func TestNewErrorReporter_Interval(t *testing.T) {
+	t.Parallel()
+
+	reporter, _ := newTestErrorReporter(t, http.StatusOK, ReporterInterval(0))
+	assert.Equal(t, 10*time.Second, reporter.config.interval)
+}
+
+func TestErrorReporter_ReportAfterClose(t *testing.T) {
+	t.Parallel()
+
+	reporter, events := newTestErrorReporter(t, http.StatusOK)
+	reporter.Close()
+
+	reporter.Report(ErrorEvent{Message: "failed"})
+	reporter.Flush()
+
+	assert.Empty(t, events())
+	assert.Equal(t, uint64(1), reporter.Failed())
+}
+
+func TestErrorReporter_FlushDoesNotBlock(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	reporter, err := NewErrorReporter("my-service", "v1",
+		ReporterProject("my-project"),
+		ReporterInterval(time.Hour),
+		ReporterConcurrency(1),
+		func(r *errorReporter) { r.endpoint = server.URL },
+	)
+	require.NoError(t, err)
+
+	for _, message := range []string{"one", "two", "three"} {
+		reporter.Report(ErrorEvent{Message: message})
+	}
+
+	flushed := make(chan struct{})
+	go func() {
+		reporter.flush()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+	case <-time.After(time.Second):
+		t.Fatal("flush waits for the requests")
+	}
+
+	close(release)
+	reporter.Close()
+}
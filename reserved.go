@@ -0,0 +1,157 @@
+package zapdriver
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// reservedKeySuffix is appended to the key of fields colliding with reserved
+// keys, when using ReservedKeyRename.
+const reservedKeySuffix = "_"
+
+// ReservedKeyPolicy determines how fields colliding with reserved keys (such as
+// `severity`, or `logging.googleapis.com/trace` with an unexpected type) are
+// handled.
+type ReservedKeyPolicy int
+
+const (
+	// ReservedKeyAllow writes colliding fields as-is. It is the default.
+	ReservedKeyAllow ReservedKeyPolicy = iota
+
+	// ReservedKeyOverwrite silently drops colliding fields, so that the value
+	// set by zapdriver (or the encoder) is used.
+	ReservedKeyOverwrite
+
+	// ReservedKeyRename appends an underscore to the key of colliding fields
+	// (e.g. `severity_`).
+	ReservedKeyRename
+
+	// ReservedKeyDrop drops colliding fields, and writes a warning to the error
+	// output of the core.
+	ReservedKeyDrop
+)
+
+// zapdriver core option to handle fields colliding with reserved keys using
+// `policy`
+func ReservedKeys(policy ReservedKeyPolicy) func(*core) {
+	return func(c *core) {
+		c.config.ReservedKeyPolicy = policy
+	}
+}
+
+// zapdriver core option to treat the keys of `cfg` (e.g. built with
+// `EncoderKeys()`) as the keys reserved by the encoder, instead of the keys of
+// `NewProductionEncoderConfig()`
+func ReservedEncoderKeys(cfg zapcore.EncoderConfig) func(*core) {
+	return func(c *core) {
+		c.config.EncoderKeys = &cfg
+	}
+}
+
+// zapdriver core option to write internal warnings to `w`, instead of standard
+// error
+func ErrorOutput(w zapcore.WriteSyncer) func(*core) {
+	return func(c *core) {
+		c.config.ErrorOutput = w
+	}
+}
+
+// encoderKeys returns the encoder configuration holding the keys reserved by the
+// encoder of the core.
+func (c *core) encoderKeys() *zapcore.EncoderConfig {
+	if c.config.EncoderKeys != nil {
+		return c.config.EncoderKeys
+	}
+
+	return &encoderConfig
+}
+
+// isReservedKeyCollision returns true if the field uses a key reserved by the
+// encoder configured with `keys`, or a special Stackdriver key with a different
+// type than the one set by the matching zapdriver field constructor.
+func isReservedKeyCollision(field zapcore.Field, keys *zapcore.EncoderConfig) bool {
+	var ok bool
+
+	if field.Key != "" {
+		switch field.Key {
+		case keys.TimeKey, keys.LevelKey, keys.MessageKey, keys.NameKey, keys.CallerKey, keys.StacktraceKey:
+			return true
+		}
+	}
+
+	switch field.Key {
+	case traceKey, spanKey:
+		return field.Type != zapcore.StringType
+	case traceSampledKey:
+		return field.Type != zapcore.BoolType
+	case sourceKey:
+		_, ok = field.Interface.(*source)
+	case operationKey:
+		_, ok = field.Interface.(*operation)
+	case labelsKey:
+		_, ok = field.Interface.(*LabelSet)
+	case "httpRequest":
+		_, ok = field.Interface.(*HTTPPayload)
+	case serviceContextKey:
+		_, ok = field.Interface.(*serviceContext)
+	case contextKey:
+		_, ok = field.Interface.(*reportContext)
+	case protoPayloadKey:
+		_, ok = field.Interface.(*protoPayload)
+	default:
+		return false
+	}
+
+	return !ok
+}
+
+// applyReservedKeyPolicy handles the fields colliding with reserved keys.
+func (c *core) applyReservedKeyPolicy(fields []zapcore.Field) []zapcore.Field {
+	if c.config.ReservedKeyPolicy == ReservedKeyAllow {
+		return fields
+	}
+
+	var out []zapcore.Field
+	for i := range fields {
+		if !isReservedKeyCollision(fields[i], c.encoderKeys()) {
+			if out != nil {
+				out = append(out, fields[i])
+			}
+			continue
+		}
+
+		if out == nil {
+			out = append(make([]zapcore.Field, 0, len(fields)), fields[:i]...)
+		}
+
+		switch c.config.ReservedKeyPolicy {
+		case ReservedKeyRename:
+			field := fields[i]
+			field.Key += reservedKeySuffix
+			out = append(out, field)
+		case ReservedKeyDrop:
+			c.warn("dropped field with reserved key %q", fields[i].Key)
+		}
+	}
+
+	if out == nil {
+		return fields
+	}
+
+	return out
+}
+
+// warn writes an internal warning to the error output, formatted like the
+// internal errors of zap.
+func (c *core) warn(format string, args ...interface{}) {
+	w := c.config.ErrorOutput
+	if w == nil {
+		w = zapcore.Lock(os.Stderr)
+	}
+
+	fmt.Fprintf(w, "%v zapdriver warning: %s\n", time.Now().UTC(), fmt.Sprintf(format, args...))
+	_ = w.Sync()
+}
@@ -0,0 +1,123 @@
+package zapdriver
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestIsReservedKeyCollision(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		field zapcore.Field
+		want  bool
+	}{
+		"regular":          {zap.String("hello", "world"), false},
+		"severity":         {zap.String("severity", "loud"), true},
+		"message":          {zap.Int("message", 1), true},
+		"trace":            {TraceContext("abc", "0", true, "p")[0], false},
+		"trace int":        {zap.Int(traceKey, 1), true},
+		"trace sampled":    {zap.String(traceSampledKey, "yes"), true},
+		"source location":  {SourceLocation(0, "file.go", 1, true), false},
+		"source string":    {zap.String(sourceKey, "file.go"), true},
+		"labels":           {Labels(Label("hello", "world")), false},
+		"labels string":    {zap.String(labelsKey, "hello"), true},
+		"http request":     {HTTP(&HTTPPayload{}), false},
+		"http string":      {zap.String("httpRequest", "GET /"), true},
+		"service context":  {ServiceContext("name", "v1"), false},
+		"error context":    {zap.String(contextKey, "ctx"), true},
+		"operation":        {OperationStart("id", "producer"), false},
+		"operation string": {zap.String(operationKey, "id"), true},
+	}
+
+	for name, tt := range tests {
+		assert.Equal(t, tt.want, isReservedKeyCollision(tt.field, &encoderConfig), name)
+	}
+}
+
+func TestWriteReservedKeys(t *testing.T) {
+	fields := []zapcore.Field{
+		zap.String("hello", "world"),
+		zap.String("severity", "loud"),
+		zap.Int(traceKey, 1),
+	}
+
+	var tests = map[string]struct {
+		policy ReservedKeyPolicy
+		want   map[string]interface{}
+		warned bool
+	}{
+		"allow": {
+			ReservedKeyAllow,
+			map[string]interface{}{"hello": "world", "severity": "loud", traceKey: int64(1)},
+			false,
+		},
+		"overwrite": {
+			ReservedKeyOverwrite,
+			map[string]interface{}{"hello": "world"},
+			false,
+		},
+		"rename": {
+			ReservedKeyRename,
+			map[string]interface{}{"hello": "world", "severity_": "loud", traceKey + "_": int64(1)},
+			false,
+		},
+		"drop": {
+			ReservedKeyDrop,
+			map[string]interface{}{"hello": "world"},
+			true,
+		},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			debugcore, logs := observer.New(zapcore.DebugLevel)
+			output := &bytes.Buffer{}
+			core := &core{
+				Core:       debugcore,
+				permLabels: NewLabelSet(),
+			}
+			ReservedKeys(tt.policy)(core)
+			ErrorOutput(zapcore.AddSync(output))(core)
+
+			require.NoError(t, core.With(fields[1:2]).Write(zapcore.Entry{}, append(fields[:1:1], fields[2])))
+
+			got := logs.All()[0].ContextMap()
+			delete(got, labelsKey)
+
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.warned, output.Len() > 0)
+			if tt.warned {
+				assert.Contains(t, output.String(), `zapdriver warning: dropped field with reserved key "severity"`)
+			}
+		})
+	}
+}
+
+func TestWriteReservedKeys_EncoderKeys(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	core := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+	ReservedKeys(ReservedKeyRename)(core)
+	ReservedEncoderKeys(EncoderKeys(MessageKey("msg"), SeverityKey("level")))(core)
+
+	require.NoError(t, core.Write(zapcore.Entry{}, []zapcore.Field{
+		zap.String("msg", "field"),
+		zap.String("level", "loud"),
+		zap.String("severity", "quiet"),
+	}))
+
+	got := logs.All()[0].ContextMap()
+	delete(got, labelsKey)
+
+	assert.Equal(t, map[string]interface{}{"msg_": "field", "level_": "loud", "severity": "quiet"}, got)
+}
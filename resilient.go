@@ -0,0 +1,171 @@
+package zapdriver
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// resilientWriter holds the configuration of a ResilientWriter.
+type resilientWriter struct {
+	retries  int
+	backoff  time.Duration
+	fallback zapcore.WriteSyncer
+}
+
+// zapdriver resilient writer option to retry failed writes `retries` times,
+// waiting `backoff` before the first retry, and doubling the wait after every
+// retry
+func WriteRetries(retries int, backoff time.Duration) func(*resilientWriter) {
+	return func(w *resilientWriter) {
+		w.retries = retries
+		w.backoff = backoff
+	}
+}
+
+// zapdriver resilient writer option to write entries to `fallback` (e.g.
+// standard error, or a local file) when all attempts to write them to the
+// primary writer failed
+func WriteFallback(fallback zapcore.WriteSyncer) func(*resilientWriter) {
+	return func(w *resilientWriter) {
+		w.fallback = fallback
+	}
+}
+
+// WriteStats are the counters of a ResilientWriter.
+type WriteStats struct {
+	// Failed is the number of failed writes to the primary writer, including
+	// retried ones.
+	Failed uint64
+
+	// Fallback is the number of entries written to the fallback writer.
+	Fallback uint64
+
+	// Dropped is the number of entries that could not be written at all.
+	Dropped uint64
+}
+
+// ResilientWriter is a zapcore.WriteSyncer which retries failed writes to the
+// primary writer (with backoff), before writing them to a fallback writer, so
+// that a transient error (such as EPIPE on standard output) does not silently
+// drop entries.
+type ResilientWriter struct {
+	primary zapcore.WriteSyncer
+	config  resilientWriter
+
+	mutex contextMutex
+	stats WriteStats
+}
+
+// NewResilientWriter returns a ResilientWriter writing to `primary`.
+func NewResilientWriter(primary zapcore.WriteSyncer, options ...func(*resilientWriter)) *ResilientWriter {
+	w := &ResilientWriter{primary: primary, mutex: newContextMutex()}
+	for _, option := range options {
+		option(&w.config)
+	}
+
+	return w
+}
+
+// Write implements zapcore.WriteSyncer interface. Partial writes are resumed
+// from the first unwritten byte, so that retries never duplicate data. The
+// writer is not locked while waiting for the backoff, unless the entry was
+// partially written, so that other entries are not interleaved with it. The
+// whole entry is written to the fallback writer, even when part of it reached
+// the primary writer.
+func (w *ResilientWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	locked := true
+	defer func() {
+		if locked {
+			w.mutex.Unlock()
+		}
+	}()
+
+	written := 0
+	backoff := w.config.backoff
+
+	var err error
+	for attempt := 0; attempt <= w.config.retries; attempt++ {
+		if attempt > 0 {
+			if written == 0 {
+				w.mutex.Unlock()
+				locked = false
+			}
+
+			time.Sleep(backoff)
+			backoff *= 2
+
+			if !locked {
+				w.mutex.Lock()
+				locked = true
+			}
+		}
+
+		var n int
+		n, err = w.primary.Write(p[written:])
+		written += n
+		if err == nil && written == len(p) {
+			return len(p), nil
+		}
+
+		atomic.AddUint64(&w.stats.Failed, 1)
+	}
+
+	if w.config.fallback != nil {
+		_, ferr := w.config.fallback.Write(p)
+		if ferr == nil {
+			atomic.AddUint64(&w.stats.Fallback, 1)
+			return len(p), nil
+		}
+
+		err = multierr.Append(err, ferr)
+	}
+
+	atomic.AddUint64(&w.stats.Dropped, 1)
+
+	return written, err
+}
+
+// Sync implements zapcore.WriteSyncer interface, syncing both the primary and
+// the fallback writers.
+func (w *ResilientWriter) Sync() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	err := w.primary.Sync()
+	if w.config.fallback != nil {
+		err = multierr.Append(err, w.config.fallback.Sync())
+	}
+
+	return err
+}
+
+// SyncContext implements the ContextSyncer interface, syncing both the primary
+// and the fallback writers (after waiting for a concurrent write) until the
+// context is done.
+func (w *ResilientWriter) SyncContext(ctx context.Context) error {
+	if err := w.mutex.LockContext(ctx); err != nil {
+		return err
+	}
+	defer w.mutex.Unlock()
+
+	err := syncContext(ctx, w.primary)
+	if w.config.fallback != nil {
+		err = multierr.Append(err, syncContext(ctx, w.config.fallback))
+	}
+
+	return err
+}
+
+// Stats returns the current counters of the writer.
+func (w *ResilientWriter) Stats() WriteStats {
+	return WriteStats{
+		Failed:   atomic.LoadUint64(&w.stats.Failed),
+		Fallback: atomic.LoadUint64(&w.stats.Fallback),
+		Dropped:  atomic.LoadUint64(&w.stats.Dropped),
+	}
+}
@@ -0,0 +1,127 @@
+package zapdriver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+// flakyWriter fails the first `failures` writes, writing `partial` bytes on
+// each failed write.
+type flakyWriter struct {
+	bytes.Buffer
+	failures int
+	partial  int
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	if w.failures > 0 {
+		w.failures--
+		n, _ := w.Buffer.Write(p[:w.partial])
+		return n, syscall.EPIPE
+	}
+
+	return w.Buffer.Write(p)
+}
+
+func (w *flakyWriter) Sync() error {
+	return nil
+}
+
+func TestResilientWriter_Retry(t *testing.T) {
+	t.Parallel()
+
+	primary := &flakyWriter{failures: 2, partial: 2}
+	w := NewResilientWriter(primary, WriteRetries(2, time.Millisecond))
+
+	n, err := w.Write([]byte("hello\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, "hello\n", primary.String())
+	assert.Equal(t, WriteStats{Failed: 2}, w.Stats())
+}
+
+func TestResilientWriter_Fallback(t *testing.T) {
+	t.Parallel()
+
+	primary := &flakyWriter{failures: 3}
+	fallback := &bytes.Buffer{}
+	w := NewResilientWriter(primary, WriteRetries(1, time.Millisecond), WriteFallback(zapcore.AddSync(fallback)))
+
+	_, err := w.Write([]byte("one\n"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("two\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "two\n", primary.String())
+	assert.Equal(t, "one\n", fallback.String())
+	assert.Equal(t, WriteStats{Failed: 3, Fallback: 1}, w.Stats())
+	assert.NoError(t, w.Sync())
+}
+
+func TestResilientWriter_FallbackPartial(t *testing.T) {
+	t.Parallel()
+
+	primary := &flakyWriter{failures: 2, partial: 2}
+	fallback := &bytes.Buffer{}
+	w := NewResilientWriter(primary, WriteRetries(1, time.Millisecond), WriteFallback(zapcore.AddSync(fallback)))
+
+	_, err := w.Write([]byte("hello\n"))
+	require.NoError(t, err)
+
+	// The fallback gets the whole entry, not only the unwritten part.
+	assert.Equal(t, "hello\n", fallback.String())
+}
+
+func TestResilientWriter_BackoffUnlocked(t *testing.T) {
+	t.Parallel()
+
+	w := NewResilientWriter(&flakyWriter{failures: 2}, WriteRetries(1, time.Second))
+
+	go func() {
+		_, _ = w.Write([]byte("one\n"))
+	}()
+
+	// A write waiting for its backoff does not hold the lock.
+	require.Eventually(t, func() bool {
+		return w.Stats().Failed == 1
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, w.SyncContext(ctx))
+}
+
+func TestResilientWriter_Dropped(t *testing.T) {
+	t.Parallel()
+
+	w := NewResilientWriter(&flakyWriter{failures: 1})
+
+	_, err := w.Write([]byte("one\n"))
+	assert.True(t, errors.Is(err, syscall.EPIPE))
+	assert.Equal(t, WriteStats{Failed: 1, Dropped: 1}, w.Stats())
+}
+
+func TestResilientWriter_SyncContextLocked(t *testing.T) {
+	w := NewResilientWriter(zapcore.AddSync(&bytes.Buffer{}))
+
+	// A write is stuck, holding the lock.
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := w.SyncContext(ctx)
+
+	var syncErr *SyncError
+	require.True(t, errors.As(err, &syncErr))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
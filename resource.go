@@ -0,0 +1,148 @@
+package zapdriver
+
+import (
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// namespaceFile holds the namespace of the pod, when running on Kubernetes.
+var namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// zapdriver core option to set the monitored resource the logs originate from,
+// e.g. `gce_instance`, `k8s_container`, `cloud_run_revision` or `generic_task`.
+// The resource is not written to the entries: it is used by the writers sending
+// entries to the Cloud Logging API (see `ResourceOf()`), while the resource of
+// entries written to standard output is set by the logging agent
+func Resource(resourceType string, labels map[string]string) func(*core) {
+	return func(c *core) {
+		c.config.Resource = knownResource(&MonitoredResource{Type: resourceType, Labels: labels})
+	}
+}
+
+// zapdriver core option to detect the monitored resource the logs originate
+// from, when not set using `Resource()` (or `DetectCloudRun()`). Cloud Run, GKE
+// and GCE are detected, with the `global` resource used otherwise. Detection
+// queries the metadata server, so it is delayed until the resource is first
+// requested with `ResourceOf()`. Like the one set with `Resource()`, the
+// resource is only used by API writers
+func DetectResource() func(*core) {
+	return func(c *core) {
+		if c.config.Resource == nil {
+			c.config.Resource = detectedResource(detectResource)
+		}
+	}
+}
+
+// ResourceOf returns the monitored resource of the logger core, detecting it
+// on the first call if needed, or nil if the logger does not use a zapdriver
+// core, or no resource was set. It is meant for writers sending entries to the
+// Cloud Logging API, which need the resource of each entry.
+func ResourceOf(logger *zap.Logger) *MonitoredResource {
+	c, ok := coreOf(logger)
+	if !ok {
+		return nil
+	}
+
+	return c.config.Resource.get()
+}
+
+// lazyResource is a monitored resource, detected when first requested, so that
+// building the core never waits for the metadata server.
+type lazyResource struct {
+	once     sync.Once
+	detect   func() *MonitoredResource
+	resource *MonitoredResource
+}
+
+// knownResource returns the lazy resource of `resource`.
+func knownResource(resource *MonitoredResource) *lazyResource {
+	return detectedResource(func() *MonitoredResource { return resource })
+}
+
+// detectedResource returns the lazy resource returned by `detect`.
+func detectedResource(detect func() *MonitoredResource) *lazyResource {
+	return &lazyResource{detect: detect}
+}
+
+// get returns the resource, detecting it on the first call. It returns nil for
+// a nil lazy resource.
+func (r *lazyResource) get() *MonitoredResource {
+	if r == nil {
+		return nil
+	}
+
+	r.once.Do(func() {
+		r.resource = r.detect()
+	})
+
+	return r.resource
+}
+
+// detectResource returns the monitored resource of the current environment.
+//
+// see: https://cloud.google.com/logging/docs/api/v2/resource-list
+func detectResource() *MonitoredResource {
+	if resource, _ := detectCloudRun(); resource != nil {
+		return resource
+	}
+
+	project, _ := metadataValue("project/project-id")
+
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return detectKubernetes(project)
+	}
+
+	if id, err := metadataValue("instance/id"); err == nil {
+		// The zone is formatted as `projects/<number>/zones/<zone>`.
+		zone, _ := metadataValue("instance/zone")
+
+		return &MonitoredResource{
+			Type: "gce_instance",
+			Labels: map[string]string{
+				"project_id":  project,
+				"instance_id": id,
+				"zone":        path.Base(zone),
+			},
+		}
+	}
+
+	return &MonitoredResource{Type: "global", Labels: map[string]string{"project_id": project}}
+}
+
+// detectKubernetes returns the `k8s_container` resource of the current pod.
+// The namespace, pod and container names are read from the `NAMESPACE`,
+// `POD_NAME` and `CONTAINER_NAME` environment variables (usually set using the
+// downward API), falling back to the service account namespace and the host
+// name for the namespace and pod names.
+func detectKubernetes(project string) *MonitoredResource {
+	cluster, _ := metadataValue("instance/attributes/cluster-name")
+	location, _ := metadataValue("instance/attributes/cluster-location")
+
+	namespace := os.Getenv("NAMESPACE")
+	if namespace == "" {
+		if b, err := os.ReadFile(namespaceFile); err == nil {
+			namespace = strings.TrimSpace(string(b))
+		}
+	}
+
+	pod := os.Getenv("POD_NAME")
+	if pod == "" {
+		pod, _ = os.Hostname()
+	}
+
+	return &MonitoredResource{
+		Type: "k8s_container",
+		Labels: map[string]string{
+			"project_id":     project,
+			"location":       location,
+			"cluster_name":   cluster,
+			"namespace_name": namespace,
+			"pod_name":       pod,
+			"container_name": os.Getenv("CONTAINER_NAME"),
+		},
+	}
+}
@@ -0,0 +1,111 @@
+package zapdriver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestResource(t *testing.T) {
+	logger := zap.New(zapcore.NewNopCore(), WrapCore(
+		Resource("generic_task", map[string]string{"job": "worker"}),
+		DetectResource(),
+	))
+
+	assert.Equal(t, &MonitoredResource{Type: "generic_task", Labels: map[string]string{"job": "worker"}}, ResourceOf(logger))
+	assert.Nil(t, ResourceOf(zap.NewNop()))
+}
+
+func TestDetectResource_Lazy(t *testing.T) {
+	t.Setenv("K_SERVICE", "")
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte("my-project"))
+	}))
+	defer server.Close()
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+
+	logger := zap.New(zapcore.NewNopCore(), WrapCore(DetectResource()))
+	assert.Zero(t, atomic.LoadInt32(&requests), "the resource is detected when requested")
+
+	require.NotNil(t, ResourceOf(logger))
+	assert.NotZero(t, atomic.LoadInt32(&requests))
+}
+
+func TestDetectResource(t *testing.T) {
+	defer func(f string) { namespaceFile = f }(namespaceFile)
+	namespaceFile = filepath.Join(t.TempDir(), "namespace")
+	require.NoError(t, os.WriteFile(namespaceFile, []byte("default\n"), 0o600))
+
+	metadata := map[string]string{
+		"project/project-id":                   "my-project",
+		"instance/id":                          "123",
+		"instance/zone":                        "projects/1/zones/europe-west1-b",
+		"instance/attributes/cluster-name":     "my-cluster",
+		"instance/attributes/cluster-location": "europe-west1",
+	}
+
+	var tests = map[string]struct {
+		env      map[string]string
+		metadata map[string]string
+		want     *MonitoredResource
+	}{
+		"kubernetes": {
+			map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1", "POD_NAME": "pod-1", "CONTAINER_NAME": "app"},
+			metadata,
+			&MonitoredResource{Type: "k8s_container", Labels: map[string]string{
+				"project_id":     "my-project",
+				"location":       "europe-west1",
+				"cluster_name":   "my-cluster",
+				"namespace_name": "default",
+				"pod_name":       "pod-1",
+				"container_name": "app",
+			}},
+		},
+
+		"gce": {
+			nil,
+			metadata,
+			&MonitoredResource{Type: "gce_instance", Labels: map[string]string{
+				"project_id":  "my-project",
+				"instance_id": "123",
+				"zone":        "europe-west1-b",
+			}},
+		},
+
+		"global": {
+			nil,
+			map[string]string{"project/project-id": "my-project"},
+			&MonitoredResource{Type: "global", Labels: map[string]string{"project_id": "my-project"}},
+		},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("K_SERVICE", "")
+			t.Setenv("KUBERNETES_SERVICE_HOST", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			newMetadataServer(t, tt.metadata)
+
+			c := &core{permLabels: NewLabelSet()}
+			DetectResource()(c)
+
+			assert.Equal(t, tt.want, c.config.Resource.get())
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package zapdriver
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// sampledEnrichment holds the fields only added to the entries of sampled
+// traces.
+type sampledEnrichment struct {
+	keys map[string]bool
+}
+
+// zapdriver core option to only enrich the entries of sampled traces (with
+// `trace_sampled` set to true), aligning the cost of logging with the sampling
+// decisions of tracing in high-QPS services. The other entries are written
+// without source location, without the labels of `BuildInfoLabels()`, and
+// without the fields of the entry with any of the `keys` (e.g. large payloads).
+// Entries with level error or above, and entries without trace context, are
+// always enriched
+func EnrichSampledTraces(keys ...string) func(*core) {
+	return func(c *core) {
+		e := &sampledEnrichment{keys: make(map[string]bool, len(keys))}
+		for _, key := range keys {
+			e.keys[key] = true
+		}
+
+		c.config.SampledEnrichment = e
+	}
+}
+
+// sampledOf returns the sampling decision of the trace context of the fields,
+// and whether there is one.
+func sampledOf(fields []zapcore.Field) (bool, bool) {
+	for i := range fields {
+		if fields[i].Key == traceSampledKey && fields[i].Type == zapcore.BoolType {
+			return fields[i].Integer == 1, true
+		}
+	}
+
+	return false, false
+}
+
+// enriches returns true if the entry is enriched.
+func (c *core) enriches(ent zapcore.Entry, fields []zapcore.Field) bool {
+	if zapcore.ErrorLevel.Enabled(ent.Level) {
+		return true
+	}
+
+	if _, traced := traceOf(fields); !traced && !c.traced {
+		return true
+	}
+
+	if sampled, ok := sampledOf(fields); ok {
+		return sampled
+	}
+
+	return c.sampled
+}
+
+// withoutEnrichment removes the enrichment fields from the fields, and the
+// build information labels from `lbls`, unless their value was overridden.
+func (c *core) withoutEnrichment(fields []zapcore.Field, lbls *LabelSet) []zapcore.Field {
+	for key, value := range c.config.BuildLabels {
+		if v, ok := lbls.Get(key); ok && v == value {
+			lbls.Delete(key)
+		}
+	}
+
+	if len(c.config.SampledEnrichment.keys) == 0 {
+		return fields
+	}
+
+	out := make([]zapcore.Field, 0, len(fields))
+	for i := range fields {
+		if !c.config.SampledEnrichment.keys[fields[i].Key] {
+			out = append(out, fields[i])
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,75 @@
+package zapdriver
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestEnrichSampledTraces(t *testing.T) {
+	defer func(fn func() (*debug.BuildInfo, bool)) { readBuildInfo = fn }(readBuildInfo)
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{GoVersion: "go1.21.0", Main: debug.Module{Path: "example.com/app", Version: "v1.2.3"}}, true
+	}
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, zap.AddCaller(), WrapCore(
+		BuildInfoLabels(),
+		EnrichSampledTraces("request"),
+	)).With(Label("tenant", "acme"))
+
+	sampled := logger.With(TraceContext("105445aa7843bc8bf206b12000100000", "1", true, "my-project")...)
+	unsampled := logger.With(TraceContext("105445aa7843bc8bf206b12000100000", "1", false, "my-project")...)
+
+	sampled.Info("hello", zap.String("request", "{...}"))
+	unsampled.Info("hello", zap.String("request", "{...}"), zap.Int("count", 1))
+	unsampled.Info("hello", zap.Bool(traceSampledKey, true))
+	unsampled.Error("failed", zap.String("request", "{...}"))
+	logger.Info("hello")
+
+	require.Equal(t, 5, logs.Len())
+
+	enriched := func(i int) {
+		t.Helper()
+
+		ctx := logs.All()[i].ContextMap()
+		assert.Contains(t, ctx, sourceKey, i)
+		assert.Contains(t, ctx[labelsKey], "go.version", i)
+	}
+
+	enriched(0)
+	assert.Contains(t, logs.All()[0].ContextMap(), "request")
+
+	ctx := logs.All()[1].ContextMap()
+	assert.NotContains(t, ctx, sourceKey)
+	assert.NotContains(t, ctx, "request")
+	assert.Equal(t, int64(1), ctx["count"])
+	assert.Equal(t, map[string]interface{}{"tenant": "acme"}, ctx[labelsKey])
+
+	enriched(2)
+	enriched(3)
+	assert.Contains(t, logs.All()[3].ContextMap(), "request", "errors are always enriched")
+
+	enriched(4)
+}
+
+func TestEnrichSampledTraces_UserLabels(t *testing.T) {
+	defer func(fn func() (*debug.BuildInfo, bool)) { readBuildInfo = fn }(readBuildInfo)
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{GoVersion: "go1.21.0", Main: debug.Module{Path: "example.com/app", Version: "v1.2.3"}}, true
+	}
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(BuildInfoLabels(), EnrichSampledTraces()))
+
+	unsampled := logger.With(TraceContext("105445aa7843bc8bf206b12000100000", "1", false, "my-project")...)
+	unsampled.Info("hello", Label("go.version", "custom"))
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, map[string]interface{}{"go.version": "custom"}, logs.All()[0].ContextMap()[labelsKey])
+}
@@ -0,0 +1,95 @@
+package zapdriver
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// sampler dedupes high-volume entries, based on their level, message and the
+// value of a single label. Unlike the zap sampler, it runs after the labels of
+// the entry are known, and never drops entries reported to Error Reporting.
+type sampler struct {
+	// label is the key of the label used to tell entries apart.
+	label string
+
+	// first entries with the same level, message and label value are logged
+	// every tick, after which only every `thereafter`th entry is logged.
+	first      uint64
+	thereafter uint64
+	tick       time.Duration
+
+	// never is the minimum level of entries that are never sampled.
+	never zapcore.Level
+
+	mutex   *sync.Mutex
+	counts  map[string]uint64
+	resetAt time.Time
+	now     func() time.Time
+}
+
+func newSampler(now func() time.Time) *sampler {
+	return &sampler{
+		tick:   time.Second,
+		never:  zapcore.FatalLevel + 1,
+		mutex:  &sync.Mutex{},
+		counts: map[string]uint64{},
+		now:    now,
+	}
+}
+
+// zapdriver core option to sample entries per level, message and value of the
+// label `key`. Each tick (one second), the first `first` entries are logged,
+// after which only every `thereafter`th entry is logged. Entries reported to
+// Error Reporting are never sampled
+func SampleByLabel(key string, first, thereafter int) func(*core) {
+	return func(c *core) {
+		if c.sampler == nil {
+			c.sampler = newSampler(c.now)
+		}
+
+		c.sampler.label = key
+		c.sampler.first = uint64(first)
+		c.sampler.thereafter = uint64(thereafter)
+	}
+}
+
+// zapdriver core option to never sample entries with level `level` or above
+func NeverSample(level zapcore.Level) func(*core) {
+	return func(c *core) {
+		if c.sampler == nil {
+			c.sampler = newSampler(c.now)
+		}
+
+		c.sampler.never = level
+	}
+}
+
+// sample returns true if the entry should be logged.
+func (s *sampler) sample(ent zapcore.Entry, lbls *LabelSet, reported bool) bool {
+	if s.first == 0 || reported || ent.Level >= s.never {
+		return true
+	}
+
+	lbls.mutex.RLock()
+	key := ent.Level.String() + "\x00" + lbls.store[s.label] + "\x00" + ent.Message
+	lbls.mutex.RUnlock()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if now := s.now(); now.After(s.resetAt) {
+		s.counts = map[string]uint64{}
+		s.resetAt = now.Add(s.tick)
+	}
+
+	s.counts[key]++
+	n := s.counts[key]
+
+	if n <= s.first {
+		return true
+	}
+
+	return s.thereafter > 0 && (n-s.first)%s.thereafter == 0
+}
@@ -0,0 +1,73 @@
+package zapdriver
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSampler(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	s := newSampler(time.Now)
+	s.label, s.first, s.thereafter = "handler", 2, 3
+	s.now = func() time.Time { return now }
+
+	one := NewLabelSet()
+	one.Add("handler", "one")
+	two := NewLabelSet()
+	two.Add("handler", "two")
+
+	ent := zapcore.Entry{Level: zapcore.InfoLevel, Message: "hello"}
+
+	var got []bool
+	for i := 0; i < 6; i++ {
+		got = append(got, s.sample(ent, one, false))
+	}
+	assert.Equal(t, []bool{true, true, false, false, true, false}, got)
+
+	assert.True(t, s.sample(ent, two, false))
+	assert.True(t, s.sample(ent, one, true))
+
+	now = now.Add(2 * time.Second)
+	assert.True(t, s.sample(ent, one, false))
+}
+
+func TestWriteSampleByLabel(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	c := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+		config: driverConfig{
+			ReportAllErrors: true,
+		},
+	}
+	SampleByLabel("handler", 1, 0)(c)
+	NeverSample(zapcore.WarnLevel)(c)
+
+	core := c.With([]zapcore.Field{Label("handler", "one")})
+
+	pc, file, line, ok := runtime.Caller(0)
+	for _, level := range []zapcore.Level{zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel} {
+		for i := 0; i < 3; i++ {
+			err := core.Write(zapcore.Entry{
+				Level:  level,
+				Caller: zapcore.NewEntryCaller(pc, file, line, ok),
+			}, []zapcore.Field{Label("two", "worlds")})
+			require.NoError(t, err)
+		}
+	}
+
+	assert.Equal(t, 1, logs.FilterLevelExact(zapcore.InfoLevel).Len())
+	assert.Equal(t, 3, logs.FilterLevelExact(zapcore.WarnLevel).Len())
+	assert.Equal(t, 3, logs.FilterLevelExact(zapcore.ErrorLevel).Len())
+
+	labels := logs.All()[0].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, "worlds", labels["two"])
+}
@@ -0,0 +1,158 @@
+package zapdriver
+
+import (
+	"encoding/json"
+	"sort"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// schemaURI is the JSON Schema dialect of the schemas returned by `Schema()`.
+const schemaURI = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema returns a JSON Schema describing the entries written by a zapdriver
+// logger, using the encoder configuration `config` (e.g.
+// `NewProductionEncoderConfig()`) and the zapdriver core `options`, so that
+// BigQuery table schemas or Dataflow parsers can be generated from it instead
+// of being reverse-engineered from sample entries.
+//
+// The schema describes the keys written by the encoder and the core, such as
+// the severity, labels, source location, trace context and error report
+// context. The fields of the entries are application-defined, and allowed as
+// additional properties.
+func Schema(config zapcore.EncoderConfig, options ...func(*core)) ([]byte, error) {
+	return json.MarshalIndent(newCore(nil, options).schema(config), "", "  ")
+}
+
+// schema returns the JSON Schema of the entries written by the core.
+func (c *core) schema(config zapcore.EncoderConfig) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+
+	add := func(key string, schema map[string]interface{}, always bool) {
+		if key == "" {
+			return
+		}
+
+		properties[key] = schema
+		if always {
+			required = append(required, key)
+		}
+	}
+
+	add(config.TimeKey, map[string]interface{}{"type": "string", "format": "date-time"}, true)
+	add(config.LevelKey, map[string]interface{}{"type": "string", "enum": schemaSeverities()}, true)
+	add(config.MessageKey, stringSchema(), true)
+	add(config.NameKey, stringSchema(), false)
+	add(config.CallerKey, stringSchema(), false)
+	add(config.StacktraceKey, stringSchema(), false)
+
+	labels := map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": stringSchema(),
+	}
+	if c.config.BigQueryCompatible {
+		labels["propertyNames"] = map[string]interface{}{"pattern": "^[A-Za-z_][A-Za-z0-9_]*$"}
+	}
+	add(labelsKey, labels, true)
+
+	add(sourceKey, objectSchema(map[string]interface{}{
+		"file":     stringSchema(),
+		"line":     stringSchema(),
+		"function": stringSchema(),
+	}), false)
+	add(operationKey, objectSchema(map[string]interface{}{
+		"id":       stringSchema(),
+		"producer": stringSchema(),
+		"first":    boolSchema(),
+		"last":     boolSchema(),
+	}), false)
+	add(traceKey, stringSchema(), c.config.AutoTrace != "")
+	add(spanKey, stringSchema(), false)
+	add(traceSampledKey, boolSchema(), false)
+	add("httpRequest", objectSchema(map[string]interface{}{
+		"requestMethod":                  stringSchema(),
+		"requestUrl":                     stringSchema(),
+		"requestSize":                    stringSchema(),
+		"status":                         integerSchema(),
+		"responseSize":                   stringSchema(),
+		"userAgent":                      stringSchema(),
+		"remoteIp":                       stringSchema(),
+		"serverIp":                       stringSchema(),
+		"referer":                        stringSchema(),
+		"latency":                        stringSchema(),
+		"cacheLookup":                    boolSchema(),
+		"cacheHit":                       boolSchema(),
+		"cacheValidatedWithOriginServer": boolSchema(),
+		"cacheFillBytes":                 stringSchema(),
+		"protocol":                       stringSchema(),
+	}), false)
+
+	add(serviceContextKey, objectSchema(map[string]interface{}{
+		"service": stringSchema(),
+		"version": stringSchema(),
+	}), c.config.ServiceName != "" && c.config.ServiceLocation != ServiceInLabels)
+	add(contextKey, objectSchema(map[string]interface{}{
+		"reportLocation": objectSchema(map[string]interface{}{
+			"filePath":     stringSchema(),
+			"lineNumber":   integerSchema(),
+			"functionName": stringSchema(),
+		}),
+		"user": stringSchema(),
+		"httpRequest": objectSchema(map[string]interface{}{
+			"method":             stringSchema(),
+			"url":                stringSchema(),
+			"userAgent":          stringSchema(),
+			"referrer":           stringSchema(),
+			"responseStatusCode": integerSchema(),
+			"remoteIp":           stringSchema(),
+		}),
+	}), false)
+
+	if c.config.LogsExplorerLinks {
+		add(logsExplorerKey, map[string]interface{}{"type": "string", "format": "uri"}, false)
+	}
+
+	sort.Strings(required)
+
+	return map[string]interface{}{
+		"$schema":              schemaURI,
+		"title":                "zapdriver log entry",
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": true,
+	}
+}
+
+// schemaSeverities returns the names of the severities, in increasing order.
+func schemaSeverities() []string {
+	severities := make([]Severity, 0, len(severityNames))
+	for s := range severityNames {
+		severities = append(severities, s)
+	}
+	sort.Slice(severities, func(i, j int) bool { return severities[i] < severities[j] })
+
+	names := make([]string, len(severities))
+	for i, s := range severities {
+		names[i] = s.String()
+	}
+
+	return names
+}
+
+func stringSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "string"}
+}
+
+func boolSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "boolean"}
+}
+
+func integerSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "integer"}
+}
+
+func objectSchema(properties map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
@@ -0,0 +1,54 @@
+package zapdriver
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	raw, err := Schema(NewProductionEncoderConfig())
+	require.NoError(t, err)
+
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &schema))
+
+	assert.Equal(t, schemaURI, schema["$schema"])
+	assert.Equal(t, true, schema["additionalProperties"])
+	assert.Equal(t, []interface{}{labelsKey, "message", "severity", "timestamp"}, schema["required"])
+
+	properties := schema["properties"].(map[string]interface{})
+	for _, key := range []string{"caller", "logger", "stacktrace", sourceKey, operationKey, traceKey, spanKey, traceSampledKey, "httpRequest", contextKey} {
+		assert.Contains(t, properties, key)
+	}
+	assert.NotContains(t, properties, logsExplorerKey)
+
+	severity := properties["severity"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"DEFAULT", "DEBUG", "INFO", "NOTICE", "WARNING", "ERROR", "CRITICAL", "ALERT", "EMERGENCY"}, severity["enum"])
+
+	labels := properties[labelsKey].(map[string]interface{})
+	assert.NotContains(t, labels, "propertyNames")
+}
+
+func TestSchema_Options(t *testing.T) {
+	t.Parallel()
+
+	raw, err := Schema(EncoderKeys(MessageKey("msg")), ServiceName("service"), BigQueryCompatible(true), LogsExplorerLinks(true))
+	require.NoError(t, err)
+
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &schema))
+
+	assert.Equal(t, []interface{}{labelsKey, "msg", "serviceContext", "severity", "timestamp"}, schema["required"])
+
+	properties := schema["properties"].(map[string]interface{})
+	assert.NotContains(t, properties, "message")
+	assert.Contains(t, properties, logsExplorerKey)
+
+	labels := properties[labelsKey].(map[string]interface{})
+	assert.Contains(t, labels, "propertyNames")
+}
@@ -0,0 +1,94 @@
+package zapdriver
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Scoped returns a lightweight, request-scoped logger, which applies the given
+// labels (created using `Label()`) to every entry it writes. Unlike
+// `logger.With()`, the labels are added to each entry at write time, so they are
+// never promoted to the permanent labels of any core, and creating a scoped
+// logger does not copy the labels or the encoder of the parent logger.
+func Scoped(logger *zap.Logger, labels ...zap.Field) *zap.Logger {
+	field := Labels(labels...)
+
+	return logger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return &scopedCore{Core: c, labels: field}
+	}))
+}
+
+// scopedCore adds a fixed set of labels to every entry written to the wrapped
+// core.
+type scopedCore struct {
+	zapcore.Core
+
+	labels zap.Field
+}
+
+// With adds structured context to the Core.
+func (c *scopedCore) With(fields []zapcore.Field) zapcore.Core {
+	return &scopedCore{Core: c.Core.With(fields), labels: c.labels}
+}
+
+// Check determines whether the supplied Entry should be logged.
+func (c *scopedCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+// SyncContext implements the ContextSyncer interface.
+func (c *scopedCore) SyncContext(ctx context.Context) error {
+	return syncContext(ctx, c.Core)
+}
+
+// coreOf returns the zapdriver core of the logger, unwrapping the cores of the
+// scoped loggers, and false if the logger does not use a zapdriver core.
+func coreOf(logger *zap.Logger) (*core, bool) {
+	c := logger.Core()
+	for {
+		scoped, ok := c.(*scopedCore)
+		if !ok {
+			break
+		}
+
+		c = scoped.Core
+	}
+
+	driver, ok := c.(*core)
+
+	return driver, ok
+}
+
+// scopedLabelsOf returns the labels of the scoped loggers the logger derives
+// from, the labels of the outermost scope taking precedence.
+func scopedLabelsOf(logger *zap.Logger) *LabelSet {
+	var scopes []*LabelSet
+	for c := logger.Core(); ; {
+		scoped, ok := c.(*scopedCore)
+		if !ok {
+			break
+		}
+
+		scopes = append(scopes, scoped.labels.Interface.(*LabelSet))
+		c = scoped.Core
+	}
+
+	lbls := NewLabelSet()
+	for i := len(scopes) - 1; i >= 0; i-- {
+		lbls.Merge(scopes[i])
+	}
+
+	return lbls
+}
+
+// Write adds the scoped labels to the entry, before writing it to the wrapped
+// core. Labels of the entry itself take precedence over the scoped labels.
+func (c *scopedCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, append([]zapcore.Field{c.labels}, fields...))
+}
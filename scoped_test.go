@@ -0,0 +1,79 @@
+package zapdriver
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestScoped(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore()).With(Label("app", "test"))
+
+	scoped := Scoped(logger, Label("request", "1"))
+	scoped.Info("hello", Label("entry", "value"))
+	scoped.With(zap.String("hello", "world")).Info("hello", Label("request", "override"))
+	logger.Info("parent")
+
+	require.Equal(t, 3, logs.Len())
+
+	labels := logs.All()[0].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"app": "test", "request": "1", "entry": "value"}, labels)
+
+	labels = logs.All()[1].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"app": "test", "request": "override"}, labels)
+	assert.Equal(t, "world", logs.All()[1].ContextMap()["hello"])
+
+	labels = logs.All()[2].ContextMap()[labelsKey].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"app": "test"}, labels)
+}
+
+func TestScoped_Concurrent(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(request string) {
+			defer wg.Done()
+
+			scoped := Scoped(logger, Label("request", request))
+			for j := 0; j < 100; j++ {
+				scoped.Info(request)
+			}
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+
+	require.Equal(t, 800, logs.Len())
+	for _, entry := range logs.All() {
+		labels := entry.ContextMap()[labelsKey].(map[string]interface{})
+		assert.Equal(t, entry.Message, labels["request"])
+	}
+}
+
+func TestScoped_Lookups(t *testing.T) {
+	debugcore, _ := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(Async(10, DropOnOverflow), Resource("global", nil))).With(Label("app", "test"))
+	defer CloseAsync(logger)
+
+	scoped := Scoped(Scoped(logger, Label("request", "1"), Label("tenant", "a")), Label("request", "2"))
+
+	assert.Equal(t, map[string]string{"app": "test", "request": "2", "tenant": "a"}, LabelsOf(scoped))
+	require.NotNil(t, ResourceOf(scoped))
+	assert.Equal(t, "global", ResourceOf(scoped).Type)
+	assert.Zero(t, DroppedEntries(scoped))
+	assert.NoError(t, SyncContext(context.Background(), scoped))
+
+	c, ok := coreOf(scoped)
+	require.True(t, ok)
+	assert.NotNil(t, c.queue)
+}
@@ -7,6 +7,60 @@ import (
 
 const serviceContextKey = "serviceContext"
 
+// Keys of the labels holding the service name and version, when the service
+// context is written as labels.
+const (
+	serviceNameLabel    = "service_name"
+	serviceVersionLabel = "service_version"
+)
+
+// ServiceLocation is where a core writes the service name and version of the
+// entries.
+type ServiceLocation int
+
+const (
+	// ServiceInContext writes the service name and version as the
+	// `serviceContext` of the entries.
+	ServiceInContext ServiceLocation = iota
+
+	// ServiceInLabels writes the service name and version as the
+	// `service_name` and `service_version` labels of the entries, for sinks
+	// routing entries by label. Entries reported to Error Reporting still hold
+	// the `serviceContext` it requires.
+	ServiceInLabels
+
+	// ServiceInBoth writes the service name and version both as the
+	// `serviceContext` and as labels of the entries.
+	ServiceInBoth
+)
+
+// zapdriver core option to write the `ServiceName()` and `ServiceVersion()` of
+// the core at `location`, instead of the `serviceContext` of the entries
+func ServiceContextLocation(location ServiceLocation) func(*core) {
+	return func(c *core) {
+		c.config.ServiceLocation = location
+	}
+}
+
+// serviceLabels adds the service name and version to the permanent labels of
+// the core, when they are written as labels.
+func (c *core) serviceLabels() {
+	if c.config.ServiceLocation == ServiceInContext || c.config.ServiceName == "" {
+		return
+	}
+
+	c.permLabels.Add(serviceNameLabel, c.config.ServiceName)
+	if c.config.ServiceVersion != "" {
+		c.permLabels.Add(serviceVersionLabel, c.config.ServiceVersion)
+	}
+}
+
+// writesServiceContext returns true if the service context is added to the
+// entry.
+func (c *core) writesServiceContext(ent zapcore.Entry, fields []zapcore.Field) bool {
+	return c.config.ServiceLocation != ServiceInLabels || c.isErrorReport(ent, fields)
+}
+
 // ServiceContext adds the correct service information adding the log line
 // It is a required field if an error needs to be reported.
 //
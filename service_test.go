@@ -1,9 +1,14 @@
 package zapdriver
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestServiceContext(t *testing.T) {
@@ -23,3 +28,47 @@ func TestNewServiceContext(t *testing.T) {
 	assert.Equal(t, "test service name", got.Name)
 	assert.Equal(t, "v1", got.Version)
 }
+
+func TestServiceContextLocation(t *testing.T) {
+	t.Parallel()
+
+	service := map[string]interface{}{"service": "checkout", "version": "v2"}
+	labels := map[string]interface{}{"service_name": "checkout", "service_version": "v2"}
+
+	tests := []struct {
+		location        ServiceLocation
+		context, labels bool
+	}{
+		{ServiceInContext, true, false},
+		{ServiceInLabels, false, true},
+		{ServiceInBoth, true, true},
+	}
+
+	for _, tt := range tests {
+		debugcore, logs := observer.New(zapcore.DebugLevel)
+		logger := zap.New(debugcore, zap.AddCaller(), WrapCore(
+			ReportAllErrors(true),
+			ServiceName("checkout"),
+			ServiceVersion("v2"),
+			ServiceContextLocation(tt.location),
+		))
+
+		logger.Info("hello")
+		logger.Error("failed", zap.Error(errors.New("boom")))
+		require.Equal(t, 2, logs.Len())
+
+		info := logs.All()[0].ContextMap()
+		if tt.context {
+			assert.Equal(t, service, info[serviceContextKey])
+		} else {
+			assert.NotContains(t, info, serviceContextKey)
+		}
+		if tt.labels {
+			assert.Equal(t, labels, info[labelsKey])
+		} else {
+			assert.Empty(t, info[labelsKey])
+		}
+
+		assert.Equal(t, service, logs.All()[1].ContextMap()[serviceContextKey], "reported errors require the service context")
+	}
+}
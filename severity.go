@@ -0,0 +1,100 @@
+package zapdriver
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Severity is the severity of a log entry, as defined by Stackdriver. Higher
+// values are more severe, so severities can be ordered and compared.
+//
+// see: https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogSeverity
+type Severity int
+
+// The severities supported by Stackdriver.
+const (
+	SeverityDefault   Severity = 0
+	SeverityDebug     Severity = 100
+	SeverityInfo      Severity = 200
+	SeverityNotice    Severity = 300
+	SeverityWarning   Severity = 400
+	SeverityError     Severity = 500
+	SeverityCritical  Severity = 600
+	SeverityAlert     Severity = 700
+	SeverityEmergency Severity = 800
+)
+
+var severityNames = map[Severity]string{
+	SeverityDefault:   "DEFAULT",
+	SeverityDebug:     "DEBUG",
+	SeverityInfo:      "INFO",
+	SeverityNotice:    "NOTICE",
+	SeverityWarning:   "WARNING",
+	SeverityError:     "ERROR",
+	SeverityCritical:  "CRITICAL",
+	SeverityAlert:     "ALERT",
+	SeverityEmergency: "EMERGENCY",
+}
+
+// severityLevel maps the Stackdriver severities to the closest Zap log level.
+var severityLevel = map[Severity]zapcore.Level{
+	SeverityDefault:   zapcore.InfoLevel,
+	SeverityDebug:     zapcore.DebugLevel,
+	SeverityInfo:      zapcore.InfoLevel,
+	SeverityNotice:    zapcore.InfoLevel,
+	SeverityWarning:   zapcore.WarnLevel,
+	SeverityError:     zapcore.ErrorLevel,
+	SeverityCritical:  zapcore.DPanicLevel,
+	SeverityAlert:     zapcore.PanicLevel,
+	SeverityEmergency: zapcore.FatalLevel,
+}
+
+// ParseSeverity parses a severity name (e.g. "WARNING"), case-insensitively.
+func ParseSeverity(name string) (Severity, error) {
+	name = strings.ToUpper(name)
+	for severity, n := range severityNames {
+		if n == name {
+			return severity, nil
+		}
+	}
+
+	return SeverityDefault, fmt.Errorf("zapdriver: unknown severity %q", name)
+}
+
+// SeverityFromLevel returns the Stackdriver severity matching the Zap log level.
+func SeverityFromLevel(l zapcore.Level) Severity {
+	return logLevelSeverity[l]
+}
+
+// String returns the name of the severity (e.g. "WARNING").
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Level returns the Zap log level closest to the severity.
+func (s Severity) Level() zapcore.Level {
+	return severityLevel[s]
+}
+
+// MarshalText implements encoding.TextMarshaler interface.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler interface.
+func (s *Severity) UnmarshalText(text []byte) error {
+	severity, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+
+	*s = severity
+
+	return nil
+}
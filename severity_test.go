@@ -0,0 +1,54 @@
+package zapdriver_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/gridwise/zapdriver"
+)
+
+func TestParseSeverity(t *testing.T) {
+	t.Parallel()
+
+	severity, err := zapdriver.ParseSeverity("warning")
+	require.NoError(t, err)
+	assert.Equal(t, zapdriver.SeverityWarning, severity)
+
+	_, err = zapdriver.ParseSeverity("loud")
+	assert.Error(t, err)
+}
+
+func TestSeverity_Order(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, zapdriver.SeverityNotice > zapdriver.SeverityInfo)
+	assert.True(t, zapdriver.SeverityEmergency > zapdriver.SeverityAlert)
+}
+
+func TestSeverity_Levels(t *testing.T) {
+	t.Parallel()
+
+	for lvl := zapcore.DebugLevel; lvl <= zapcore.FatalLevel; lvl++ {
+		assert.Equal(t, lvl, zapdriver.SeverityFromLevel(lvl).Level())
+	}
+
+	assert.Equal(t, zapcore.InfoLevel, zapdriver.SeverityNotice.Level())
+}
+
+func TestSeverity_Text(t *testing.T) {
+	t.Parallel()
+
+	b, err := json.Marshal(map[string]zapdriver.Severity{"severity": zapdriver.SeverityCritical})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"severity": "CRITICAL"}`, string(b))
+
+	var got map[string]zapdriver.Severity
+	require.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, zapdriver.SeverityCritical, got["severity"])
+
+	assert.Equal(t, "Severity(42)", zapdriver.Severity(42).String())
+}
@@ -0,0 +1,213 @@
+package zapdriver
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultMaxEntrySize is the maximum size of a LogEntry accepted by Cloud
+// Logging.
+//
+// see: https://cloud.google.com/logging/quotas
+const DefaultMaxEntrySize = 256 * 1024
+
+const (
+	truncatedKey   = "truncated"
+	splitProducer  = "github.com/gridwise/zapdriver"
+	truncateMarker = "..."
+)
+
+// TruncateStrategy determines how entries exceeding the maximum entry size are
+// reduced in size.
+type TruncateStrategy int
+
+const (
+	// TruncateMessage truncates the message of the entry first, and then the
+	// largest string fields if the entry is still too large.
+	TruncateMessage TruncateStrategy = iota
+
+	// TruncateFields truncates the largest string fields of the entry first, and
+	// then the message if the entry is still too large.
+	TruncateFields
+
+	// SplitEntry splits the fields of the entry across multiple entries that
+	// share the same message, correlated using an `Operation()` field. Fields
+	// that are too large to fit in a single entry are truncated.
+	SplitEntry
+)
+
+// zapdriver core option to guard against entries larger than `bytes` (encoded
+// as JSON) by reducing them using `strategy`, instead of letting Cloud Logging
+// drop them silently
+func MaxEntrySize(bytes int, strategy TruncateStrategy) func(*core) {
+	return func(c *core) {
+		c.config.MaxEntrySize = bytes
+		c.config.TruncateStrategy = strategy
+	}
+}
+
+// entrySize returns the encoded size of the entry. Fields added to the logger
+// using `With()` are not accounted for.
+func entrySize(ent zapcore.Entry, fields []zapcore.Field) int {
+	buf, err := zapcore.NewJSONEncoder(encoderConfig).EncodeEntry(ent, fields)
+	if err != nil {
+		return 0
+	}
+	defer buf.Free()
+
+	return buf.Len()
+}
+
+// limitEntry returns one or more entries that fit within the maximum entry
+// size.
+func (c *core) limitEntry(ent zapcore.Entry, fields []zapcore.Field) ([]zapcore.Entry, [][]zapcore.Field) {
+	max := c.config.MaxEntrySize
+
+	size := entrySize(ent, fields)
+	if size <= max {
+		return []zapcore.Entry{ent}, [][]zapcore.Field{fields}
+	}
+
+	switch c.config.TruncateStrategy {
+	case TruncateFields:
+		fields = truncateFields(ent, fields, max)
+		ent.Message = truncateMessage(ent, fields, max)
+	case SplitEntry:
+		return splitEntry(ent, fields, max)
+	default:
+		ent.Message = truncateMessage(ent, fields, max)
+		fields = truncateFields(ent, fields, max)
+	}
+
+	return []zapcore.Entry{ent}, [][]zapcore.Field{fields}
+}
+
+// truncateMessage truncates the message of the entry, until the entry fits in
+// `max` bytes, or the message is empty.
+func truncateMessage(ent zapcore.Entry, fields []zapcore.Field, max int) string {
+	fields = withTruncatedMarker(append([]zapcore.Field{}, fields...))
+
+	excess := entrySize(ent, fields) - max
+	if excess <= 0 {
+		return ent.Message
+	}
+
+	return truncateString(ent.Message, len(ent.Message)-excess)
+}
+
+// truncateFields truncates the largest string fields of the entry, until the
+// entry fits in `max` bytes, or no string fields can be truncated any further.
+func truncateFields(ent zapcore.Entry, fields []zapcore.Field, max int) []zapcore.Field {
+	fields = withTruncatedMarker(append([]zapcore.Field{}, fields...))
+
+	indices := []int{}
+	for i := range fields {
+		if fields[i].Type == zapcore.StringType {
+			indices = append(indices, i)
+		}
+	}
+
+	sort.SliceStable(indices, func(a, b int) bool {
+		return len(fields[indices[a]].String) > len(fields[indices[b]].String)
+	})
+
+	for _, i := range indices {
+		excess := entrySize(ent, fields) - max
+		if excess <= 0 {
+			break
+		}
+
+		fields[i].String = truncateString(fields[i].String, len(fields[i].String)-excess)
+	}
+
+	return fields
+}
+
+// splitEntry distributes the regular fields of the entry across as many entries
+// as needed. Special Stackdriver fields are added to each of these entries.
+func splitEntry(ent zapcore.Entry, fields []zapcore.Field, max int) ([]zapcore.Entry, [][]zapcore.Field) {
+	common := []zapcore.Field{}
+	regular := []zapcore.Field{}
+	for i := range fields {
+		if isSpecialField(fields[i]) {
+			common = append(common, fields[i])
+			continue
+		}
+
+		regular = append(regular, fields[i])
+	}
+
+	// Reserve space for the operation field shared by all the split entries.
+	id := randomID()
+	common = append(common, Operation(id, splitProducer, false, false))
+
+	parts := [][]zapcore.Field{}
+	part := append([]zapcore.Field{}, common...)
+	for _, field := range regular {
+		candidate := append(append([]zapcore.Field{}, part...), field)
+		if entrySize(ent, candidate) <= max || len(part) == len(common) {
+			part = candidate
+			continue
+		}
+
+		parts = append(parts, part)
+		part = append(append([]zapcore.Field{}, common...), field)
+	}
+	parts = append(parts, part)
+
+	entries := make([]zapcore.Entry, len(parts))
+	for i := range parts {
+		parts[i][len(common)-1] = Operation(id, splitProducer, i == 0, i == len(parts)-1)
+		if entrySize(ent, parts[i]) > max {
+			parts[i] = truncateFields(ent, parts[i], max)
+		}
+
+		entries[i] = ent
+	}
+
+	return entries, parts
+}
+
+// isSpecialField returns true if the field is interpreted by Stackdriver, and
+// should therefore be kept on every split entry.
+func isSpecialField(field zapcore.Field) bool {
+	switch field.Key {
+	case serviceContextKey, contextKey:
+		return true
+	}
+
+	return strings.HasPrefix(field.Key, googleKeyPrefix)
+}
+
+func withTruncatedMarker(fields []zapcore.Field) []zapcore.Field {
+	for i := range fields {
+		if fields[i].Key == truncatedKey {
+			return fields
+		}
+	}
+
+	return append(fields, zap.Bool(truncatedKey, true))
+}
+
+// truncateString truncates the string to (at most) `n` bytes, including the
+// truncation marker, without splitting multi-byte characters.
+func truncateString(s string, n int) string {
+	n -= len(truncateMarker)
+	if n <= 0 {
+		return ""
+	}
+
+	if n >= len(s) {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n] + truncateMarker
+}
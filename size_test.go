@@ -0,0 +1,108 @@
+package zapdriver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTruncateString(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "hello", truncateString("hello", 10))
+	assert.Equal(t, "hel...", truncateString("hello world", 6))
+	assert.Equal(t, "h...", truncateString("hé", 5))
+	assert.Equal(t, "", truncateString("hello", 2))
+}
+
+func TestWriteMaxEntrySize(t *testing.T) {
+	big := strings.Repeat("a", 2048)
+
+	var tests = map[string]struct {
+		strategy TruncateStrategy
+		message  string
+		field    string
+	}{
+		"TruncateMessage": {TruncateMessage, "", big},
+		"TruncateFields":  {TruncateFields, big, ""},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			debugcore, logs := observer.New(zapcore.DebugLevel)
+			core := &core{
+				Core:       debugcore,
+				permLabels: NewLabelSet(),
+			}
+			MaxEntrySize(1024, tt.strategy)(core)
+
+			err := core.Write(zapcore.Entry{Message: big}, []zapcore.Field{zap.String("payload", big)})
+			require.NoError(t, err)
+			require.Equal(t, 1, logs.Len())
+
+			entry := logs.All()[0]
+			assert.True(t, entrySize(entry.Entry, entry.Context) <= 1024)
+			assert.Equal(t, true, entry.ContextMap()[truncatedKey])
+			assert.Less(t, len(entry.Message), len(big))
+			assert.Less(t, len(entry.ContextMap()["payload"].(string)), len(big))
+		})
+	}
+}
+
+func TestWriteMaxEntrySize_Fits(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	core := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+	MaxEntrySize(1024, TruncateMessage)(core)
+
+	err := core.Write(zapcore.Entry{Message: "hello"}, []zapcore.Field{zap.String("hello", "world")})
+	require.NoError(t, err)
+
+	assert.Equal(t, "hello", logs.All()[0].Message)
+	assert.NotContains(t, logs.All()[0].ContextMap(), truncatedKey)
+}
+
+func TestWriteMaxEntrySize_SplitEntry(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	core := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+	MaxEntrySize(1024, SplitEntry)(core)
+
+	medium := strings.Repeat("a", 300)
+	err := core.Write(zapcore.Entry{Message: "hello"}, []zapcore.Field{
+		Label("one", "world"),
+		zap.String("a", medium),
+		zap.String("b", medium),
+		zap.String("c", medium),
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, logs.Len())
+
+	first := logs.All()[0].ContextMap()
+	last := logs.All()[1].ContextMap()
+
+	assert.Contains(t, first, "a")
+	assert.Contains(t, first, "b")
+	assert.Contains(t, last, "c")
+	assert.Contains(t, last, labelsKey)
+
+	firstOp := first[operationKey].(map[string]interface{})
+	lastOp := last[operationKey].(map[string]interface{})
+	assert.Equal(t, firstOp["id"], lastOp["id"])
+	assert.Equal(t, true, firstOp["first"])
+	assert.Equal(t, true, lastOp["last"])
+
+	for _, entry := range logs.All() {
+		assert.True(t, entrySize(entry.Entry, entry.Context) <= 1024)
+	}
+}
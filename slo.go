@@ -0,0 +1,72 @@
+package zapdriver
+
+import (
+	"errors"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// sloNameLabel and sloBurnLabel are the labels of the entries burning the
+	// error budget of an SLO.
+	sloNameLabel = "slo.name"
+	sloBurnLabel = "slo.burn"
+)
+
+// sloBudget matches the entries burning the error budget of an SLO.
+type sloBudget struct {
+	name     string
+	matchers []ErrorMatcher
+}
+
+// zapdriver core option to add the `slo.name` label (set to `name`) and the
+// `slo.burn` label (set to "true") to the entries matched by any of `matchers`,
+// so log-based metrics can compute the burn rate of the error budget of the
+// SLO, without a separate metrics pipeline. When the matchers of several SLOs
+// match an entry, the first SLO applies. The matchers are given the fields of
+// the entry, without its labels
+func SLOBurn(name string, matchers ...ErrorMatcher) func(*core) {
+	return func(c *core) {
+		c.config.SLOs = append(c.config.SLOs, sloBudget{name: name, matchers: matchers})
+	}
+}
+
+// StatusAtLeast returns a matcher for the entries with an HTTP status code of
+// at least `code`, either in their `httpRequest` payload or carried by their
+// error (through a `StatusCode() int` method). For example, to count server
+// errors against an availability SLO:
+//
+//	zapdriver.SLOBurn("availability", zapdriver.StatusAtLeast(500))
+func StatusAtLeast(code int) ErrorMatcher {
+	return func(_ zapcore.Entry, fields []zapcore.Field) bool {
+		for i := range fields {
+			switch v := fields[i].Interface.(type) {
+			case *HTTPPayload:
+				if v != nil && v.Status >= code {
+					return true
+				}
+			case error:
+				var coder statusCoder
+				if fields[i].Type == zapcore.ErrorType && errors.As(v, &coder) && coder.StatusCode() >= code {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+}
+
+// addSLOBurn adds the labels of the first SLO whose budget is burnt by the
+// entry to the labels of the entry.
+func (c *core) addSLOBurn(ent zapcore.Entry, fields []zapcore.Field, lbls *LabelSet) {
+	for _, slo := range c.config.SLOs {
+		for _, match := range slo.matchers {
+			if match(ent, fields) {
+				lbls.Add(sloNameLabel, slo.name)
+				lbls.Add(sloBurnLabel, "true")
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package zapdriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSLOBurn(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(
+		SLOBurn("availability", StatusAtLeast(500)),
+		SLOBurn("latency", ErrorIs(context.DeadlineExceeded)),
+	))
+
+	logger.Error("request failed", HTTP(&HTTPPayload{Status: 503}))
+	logger.Error("request failed", zap.Error(fmt.Errorf("upstream: %w", statusError(502))), zap.Error(context.DeadlineExceeded))
+	logger.Error("request timed out", zap.Error(fmt.Errorf("query: %w", context.DeadlineExceeded)))
+	logger.Warn("bad request", HTTP(&HTTPPayload{Status: 400}), zap.Error(statusError(404)))
+	logger.Error("failed", zap.Error(errors.New("boom")), Label("app", "api"))
+
+	require.Equal(t, 5, logs.Len())
+	assert.Equal(t, map[string]interface{}{sloNameLabel: "availability", sloBurnLabel: "true"}, logs.All()[0].ContextMap()[labelsKey])
+	assert.Equal(t, map[string]interface{}{sloNameLabel: "availability", sloBurnLabel: "true"}, logs.All()[1].ContextMap()[labelsKey], "the first SLO applies")
+	assert.Equal(t, map[string]interface{}{sloNameLabel: "latency", sloBurnLabel: "true"}, logs.All()[2].ContextMap()[labelsKey])
+	assert.Empty(t, logs.All()[3].ContextMap()[labelsKey])
+	assert.Equal(t, map[string]interface{}{"app": "api"}, logs.All()[4].ContextMap()[labelsKey])
+}
+
+func TestSLOBurn_LabelPrefix(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(
+		LabelPrefix("tag."),
+		SLOBurn("availability", StatusAtLeast(500)),
+	))
+
+	logger.Error("request failed", HTTP(&HTTPPayload{Status: 503}), zap.String("tag.app", "api"))
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, map[string]interface{}{"app": "api", sloNameLabel: "availability", sloBurnLabel: "true"}, logs.All()[0].ContextMap()[labelsKey])
+}
@@ -0,0 +1,196 @@
+package zapdriver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net"
+	"time"
+)
+
+// socketWriter holds the configuration of a SocketWriter.
+type socketWriter struct {
+	gzip         bool
+	batchSize    int
+	dialTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// zapdriver socket writer option to compress each batch of entries as a
+// separate gzip member when set to true
+func SocketGzip(enabled bool) func(*socketWriter) {
+	return func(w *socketWriter) {
+		w.gzip = enabled
+	}
+}
+
+// zapdriver socket writer option to buffer entries until at least `bytes` bytes
+// are pending (or `Sync()` is called), instead of writing each entry as soon as
+// it is logged
+func SocketBatchSize(bytes int) func(*socketWriter) {
+	return func(w *socketWriter) {
+		w.batchSize = bytes
+	}
+}
+
+// zapdriver socket writer option to bound each connection and write to the
+// socket by `Write()`, `Sync()` and `Close()` to `timeout` (5 seconds by
+// default), so that a stuck peer cannot block logging indefinitely. Entries
+// which could not be written in time are dropped
+func SocketWriteTimeout(timeout time.Duration) func(*socketWriter) {
+	return func(w *socketWriter) {
+		w.writeTimeout = timeout
+	}
+}
+
+// SocketWriter is a zapcore.WriteSyncer writing newline-delimited JSON entries
+// to a TCP or Unix domain socket, for example the TCP input of a logging
+// sidecar such as fluent-bit. The connection is established lazily, and
+// re-established on the next write after a failure.
+type SocketWriter struct {
+	network string
+	address string
+	config  socketWriter
+
+	mutex contextMutex
+	conn  net.Conn
+	buf   *bytes.Buffer
+}
+
+// NewSocketWriter returns a SocketWriter for the `network` ("tcp" or "unix")
+// socket at `address`.
+func NewSocketWriter(network, address string, options ...func(*socketWriter)) *SocketWriter {
+	w := &SocketWriter{
+		network: network,
+		address: address,
+		config:  socketWriter{dialTimeout: 5 * time.Second, writeTimeout: 5 * time.Second},
+		mutex:   newContextMutex(),
+		buf:     &bytes.Buffer{},
+	}
+	for _, option := range options {
+		option(&w.config)
+	}
+
+	return w
+}
+
+// Write implements zapcore.WriteSyncer interface. Each write is framed as a
+// single line.
+func (w *SocketWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.buf.Write(p)
+	if len(p) == 0 || p[len(p)-1] != '\n' {
+		w.buf.WriteByte('\n')
+	}
+
+	if w.buf.Len() < w.config.batchSize {
+		return len(p), nil
+	}
+
+	if err := w.flushTimeout(); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Sync implements zapcore.WriteSyncer interface, and writes all pending
+// entries to the socket.
+func (w *SocketWriter) Sync() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.flushTimeout()
+}
+
+// SyncContext implements the ContextSyncer interface. The connection and the
+// write of the pending entries, and the wait for a concurrent write, are bounded
+// by the context.
+func (w *SocketWriter) SyncContext(ctx context.Context) error {
+	if err := w.mutex.LockContext(ctx); err != nil {
+		return err
+	}
+	defer w.mutex.Unlock()
+
+	pending := bytes.Count(w.buf.Bytes(), []byte{'\n'})
+	if err := w.flush(ctx); err != nil {
+		return &SyncError{Dropped: pending, Err: err}
+	}
+
+	return nil
+}
+
+// Close writes all pending entries, and closes the connection.
+func (w *SocketWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	err := w.flushTimeout()
+	if w.conn != nil {
+		if cerr := w.conn.Close(); err == nil {
+			err = cerr
+		}
+		w.conn = nil
+	}
+
+	return err
+}
+
+// flushTimeout writes the pending entries, for at most the write timeout.
+func (w *SocketWriter) flushTimeout() error {
+	ctx := context.Background()
+	if w.config.writeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.config.writeTimeout)
+		defer cancel()
+	}
+
+	return w.flush(ctx)
+}
+
+// flush writes the pending entries. Entries are dropped when the write fails,
+// so that a broken connection cannot grow the buffer indefinitely.
+func (w *SocketWriter) flush(ctx context.Context) error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	defer w.buf.Reset()
+
+	payload := w.buf.Bytes()
+	if w.config.gzip {
+		compressed := &bytes.Buffer{}
+		gz := gzip.NewWriter(compressed)
+		_, _ = gz.Write(payload)
+		if err := gz.Close(); err != nil {
+			return err
+		}
+
+		payload = compressed.Bytes()
+	}
+
+	if w.conn == nil {
+		dialer := &net.Dialer{Timeout: w.config.dialTimeout}
+		conn, err := dialer.DialContext(ctx, w.network, w.address)
+		if err != nil {
+			return err
+		}
+
+		w.conn = conn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = w.conn.SetWriteDeadline(deadline)
+		defer w.conn.SetWriteDeadline(time.Time{}) // nolint: errcheck
+	}
+
+	if _, err := w.conn.Write(payload); err != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package zapdriver
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func listen(t *testing.T, network, address string) (net.Listener, chan net.Conn) {
+	l, err := net.Listen(network, address)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	conns := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			conns <- conn
+		}
+	}()
+
+	return l, conns
+}
+
+func TestSocketWriter(t *testing.T) {
+	l, conns := listen(t, "tcp", "127.0.0.1:0")
+
+	w := NewSocketWriter("tcp", l.Addr().String())
+	logger := zap.New(zapcore.NewCore(
+		zapcore.NewJSONEncoder(NewProductionEncoderConfig()),
+		w,
+		zapcore.DebugLevel,
+	), WrapCore())
+
+	logger.Info("one")
+	logger.Info("two")
+
+	conn := <-conns
+	r := bufio.NewReader(conn)
+	for _, msg := range []string{"one", "two"} {
+		line, err := r.ReadBytes('\n')
+		require.NoError(t, err)
+
+		entry, err := ParseEntry(line)
+		require.NoError(t, err)
+		assert.Equal(t, msg, entry.Message)
+	}
+
+	require.NoError(t, w.Close())
+}
+
+func TestSocketWriter_GzipBatch(t *testing.T) {
+	address := filepath.Join(t.TempDir(), "sidecar.sock")
+	_, conns := listen(t, "unix", address)
+
+	w := NewSocketWriter("unix", address, SocketGzip(true), SocketBatchSize(1024))
+
+	_, err := w.Write([]byte(`{"message":"one"}`))
+	require.NoError(t, err)
+	_, err = w.Write([]byte(`{"message":"two"}` + "\n"))
+	require.NoError(t, err)
+
+	// Nothing is written until the batch is full, or synced.
+	select {
+	case <-conns:
+		t.Fatal("unexpected connection before sync")
+	default:
+	}
+
+	require.NoError(t, w.Close())
+
+	conn := <-conns
+	gz, err := gzip.NewReader(conn)
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"message\":\"one\"}\n{\"message\":\"two\"}\n", string(b))
+}
+
+func TestSocketWriter_DialError(t *testing.T) {
+	w := NewSocketWriter("unix", filepath.Join(t.TempDir(), "missing.sock"))
+
+	_, err := w.Write([]byte("{}"))
+	assert.Error(t, err)
+
+	// The failed entry is dropped.
+	assert.NoError(t, w.Sync())
+}
+
+func TestSocketWriter_SyncContext(t *testing.T) {
+	w := NewSocketWriter("unix", filepath.Join(t.TempDir(), "missing.sock"), SocketBatchSize(1024))
+
+	_, err := w.Write([]byte("{}"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("{}"))
+	require.NoError(t, err)
+
+	err = w.SyncContext(context.Background())
+
+	var syncErr *SyncError
+	require.True(t, errors.As(err, &syncErr))
+	assert.Equal(t, 2, syncErr.Dropped)
+
+	// The failed entries are dropped.
+	assert.NoError(t, w.SyncContext(context.Background()))
+}
+
+func TestSocketWriter_SyncContextLocked(t *testing.T) {
+	w := NewSocketWriter("unix", filepath.Join(t.TempDir(), "missing.sock"))
+
+	// A write is stuck, holding the lock.
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := w.SyncContext(ctx)
+
+	var syncErr *SyncError
+	require.True(t, errors.As(err, &syncErr))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSocketWriter_WriteTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zapdriver.sock")
+	_, conns := listen(t, "unix", path)
+
+	w := NewSocketWriter("unix", path, SocketWriteTimeout(20*time.Millisecond))
+
+	// The peer never reads, so the write blocks once the socket buffer is full.
+	start := time.Now()
+	_, err := w.Write(bytes.Repeat([]byte("x"), 16<<20))
+
+	assert.Error(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	conn := <-conns
+	_ = conn.Close()
+}
@@ -3,6 +3,7 @@ package zapdriver
 import (
 	"runtime"
 	"strconv"
+	"strings"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -17,6 +18,82 @@ func SourceLocation(pc uintptr, file string, line int, ok bool) zap.Field {
 	return zap.Object(sourceKey, newSource(pc, file, line, ok))
 }
 
+// zapdriver core option to strip the first matching prefix of `prefixes` (e.g.
+// GOPATH or module directories) from the file path of the source location
+func TrimSourcePrefix(prefixes ...string) func(*core) {
+	return func(c *core) {
+		c.config.SourcePrefixes = append(c.config.SourcePrefixes, prefixes...)
+	}
+}
+
+// zapdriver core option to reduce the file path of the source location to the
+// package directory and file name (e.g. `zapdriver/core.go`) when set to true
+func ShortSourcePaths(short bool) func(*core) {
+	return func(c *core) {
+		c.config.ShortSourcePaths = short
+	}
+}
+
+// zapdriver core option to only add the source location to entries with level
+// `level` or above, so high-volume debug and info entries skip the field while
+// errors keep it. Only the field is skipped: the caller of every entry is still
+// resolved by zap when the logger is built with `zap.AddCaller()`
+func SourceLocationLevel(level zapcore.Level) func(*core) {
+	return func(c *core) {
+		c.config.SourceLocationLevel = level
+	}
+}
+
+// zapdriver core option to never add the source location to the entries, for
+// deployments relying on the `caller` field of the zap encoder instead. Source
+// locations added using `SourceLocation()` are kept
+func DisableSourceLocation() func(*core) {
+	return func(c *core) {
+		c.config.DisableSourceLocation = true
+	}
+}
+
+// zapdriver core option to remove the caller from the entries after the source
+// location (and the report location of reported errors) has been resolved, so
+// the zap encoder does not write the `caller` field as well
+func StripZapCaller() func(*core) {
+	return func(c *core) {
+		c.config.StripZapCaller = true
+	}
+}
+
+// sourcePath returns the file path as configured to be used in the source
+// location.
+func (c *core) sourcePath(file string) string {
+	for _, prefix := range c.config.SourcePrefixes {
+		if strings.HasPrefix(file, prefix) {
+			file = strings.TrimPrefix(file[len(prefix):], "/")
+			break
+		}
+	}
+
+	if c.config.ShortSourcePaths {
+		file = shortPath(file)
+	}
+
+	return file
+}
+
+// shortPath returns the last directory and the file name of the path.
+func shortPath(file string) string {
+	i := strings.LastIndexByte(file, '/')
+	if i == -1 {
+		return file
+	}
+
+	j := strings.LastIndexByte(file[:i], '/')
+	if j == -1 {
+		return file
+	}
+
+	return file[j+1:]
+}
+
 // source is the source code location information associated with the log entry,
 // if any.
 type source struct {
@@ -26,3 +26,31 @@ func TestNewSource(t *testing.T) {
 	assert.Equal(t, "23", got.Line)
 	assert.Contains(t, got.Function, "zapdriver.TestNewSource")
 }
+
+func TestSourcePath(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		options []func(*core)
+		file    string
+		want    string
+	}{
+		"default":        {nil, "/go/src/github.com/org/repo/pkg/file.go", "/go/src/github.com/org/repo/pkg/file.go"},
+		"trim prefix":    {[]func(*core){TrimSourcePrefix("/build", "/go/src/")}, "/go/src/github.com/org/repo/pkg/file.go", "github.com/org/repo/pkg/file.go"},
+		"trim no slash":  {[]func(*core){TrimSourcePrefix("/go/src")}, "/go/src/github.com/org/repo/pkg/file.go", "github.com/org/repo/pkg/file.go"},
+		"no match":       {[]func(*core){TrimSourcePrefix("/build")}, "/go/src/pkg/file.go", "/go/src/pkg/file.go"},
+		"short":          {[]func(*core){ShortSourcePaths(true)}, "/go/src/github.com/org/repo/pkg/file.go", "pkg/file.go"},
+		"short no dir":   {[]func(*core){ShortSourcePaths(true)}, "file.go", "file.go"},
+		"short one dir":  {[]func(*core){ShortSourcePaths(true)}, "pkg/file.go", "pkg/file.go"},
+		"trim and short": {[]func(*core){TrimSourcePrefix("/go/src/"), ShortSourcePaths(true)}, "/go/src/file.go", "file.go"},
+	}
+
+	for name, tt := range tests {
+		c := &core{}
+		for _, option := range tt.options {
+			option(c)
+		}
+
+		assert.Equal(t, tt.want, c.sourcePath(tt.file), name)
+	}
+}
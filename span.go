@@ -0,0 +1,50 @@
+package zapdriver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SpanID normalizes a span ID into the 16-character hexadecimal representation
+// expected by Cloud Logging to link entries to Cloud Trace spans. It accepts
+// both the hexadecimal representation (as used by W3C trace context and
+// OpenTelemetry), and the decimal representation (as used by the
+// `X-Cloud-Trace-Context` header).
+//
+// A 16-character string consisting of hexadecimal digits is always interpreted
+// as hexadecimal.
+func SpanID(id string) (string, error) {
+	if len(id) == 16 && isHex(id) {
+		return strings.ToLower(id), nil
+	}
+
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("zapdriver: invalid span ID %q", id)
+	}
+
+	return SpanIDFromUint64(n), nil
+}
+
+// SpanIDFromUint64 returns the hexadecimal representation of a numeric span ID.
+func SpanIDFromUint64(id uint64) string {
+	return fmt.Sprintf("%016x", id)
+}
+
+// SpanIDFromBytes returns the hexadecimal representation of an 8-byte span ID,
+// as used by OpenCensus and OpenTelemetry.
+func SpanIDFromBytes(id [8]byte) string {
+	return SpanIDFromUint64(binary.BigEndian.Uint64(id[:]))
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,39 @@
+package zapdriver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpanID(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]string{
+		"123":                  "000000000000007b",
+		"0":                    "0000000000000000",
+		"18446744073709551615": "ffffffffffffffff",
+		"00f067aa0ba902b7":     "00f067aa0ba902b7",
+		"00F067AA0BA902B7":     "00f067aa0ba902b7",
+		"1234567890123456":     "1234567890123456",
+	}
+
+	for in, want := range tests {
+		got, err := SpanID(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"", "abc", "18446744073709551616", "00f067aa0ba902b7ff", "-1"} {
+		_, err := SpanID(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestSpanIDFromBytes(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "00f067aa0ba902b7", SpanIDFromBytes([8]byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}))
+	assert.Equal(t, "000000000000007b", SpanIDFromUint64(123))
+}
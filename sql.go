@@ -0,0 +1,272 @@
+package zapdriver
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	sqlKey          = "sql"
+	sqlOperationKey = "sql.operation"
+
+	// maxSQLStatementSize is the maximum size of the statements of the SQL
+	// payloads, larger statements are truncated.
+	maxSQLStatementSize = 1024
+)
+
+// SQLPayload describes a database call.
+type SQLPayload struct {
+	// Statement is the sanitized text of the statement (see `SanitizeSQL()`).
+	Statement string `json:"statement"`
+
+	// Operation is the SQL command of the statement (e.g. "SELECT"), in upper
+	// case.
+	Operation string `json:"operation"`
+
+	// Latency is the duration of the call, in the format of `FormatLatency()`.
+	Latency string `json:"latency"`
+
+	// Rows is the number of rows returned or affected by the statement, or -1
+	// when it is unknown.
+	Rows int64 `json:"rows"`
+}
+
+// NewSQL returns the payload of a database call running `statement`. The
+// statement is sanitized using `SanitizeSQL()`, and `rows` is the number of
+// rows returned or affected, or -1 when it is unknown.
+func NewSQL(statement string, latency time.Duration, rows int64) *SQLPayload {
+	return &SQLPayload{
+		Statement: truncateString(SanitizeSQL(statement), maxSQLStatementSize),
+		Operation: sqlOperation(statement),
+		Latency:   FormatLatency(latency),
+		Rows:      rows,
+	}
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaller interface.
+func (p SQLPayload) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("statement", p.Statement)
+	if p.Operation != "" {
+		enc.AddString("operation", p.Operation)
+	}
+	enc.AddString("latency", p.Latency)
+	if p.Rows >= 0 {
+		enc.AddInt64("rows", p.Rows)
+	}
+
+	return nil
+}
+
+// SQL adds the `sql` payload of a database call.
+func SQL(payload *SQLPayload) zap.Field {
+	return zap.Object(sqlKey, payload)
+}
+
+// LogQuery logs a database call with its `sql` payload (see `NewSQL()`), and
+// the `sql.operation` label, using a logger holding the trace context of the
+// request (e.g. the logger returned by `FromContext()`) to correlate the call
+// with the request. Failed calls are logged with the Error level, except
+// `sql.ErrNoRows`.
+//
+//	start := time.Now()
+//	res, err := db.ExecContext(ctx, query, args...)
+//	rows, _ := res.RowsAffected()
+//	zapdriver.LogQuery(zapdriver.FromContext(ctx), query, time.Since(start), rows, err)
+func LogQuery(logger *zap.Logger, statement string, latency time.Duration, rows int64, err error) {
+	payload := NewSQL(statement, latency, rows)
+
+	fields := []zap.Field{SQL(payload)}
+	if payload.Operation != "" {
+		fields = append(fields, Label(sqlOperationKey, payload.Operation))
+	}
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.Error("Database call failed.", append(fields, zap.Error(err))...)
+		return
+	}
+
+	logger.Info("Database call.", fields...)
+}
+
+// SanitizeSQL replaces the string and numeric literals of a SQL statement by
+// `?`, removes its comments, and collapses its whitespace, so that the
+// statement holds no sensitive values, and identical statements with different
+// values can be grouped. Placeholders (`?`, `$1`, `:name` or `@name`) and
+// quoted identifiers are left untouched.
+func SanitizeSQL(statement string) string {
+	var b strings.Builder
+	b.Grow(len(statement))
+
+	space := false
+	write := func(s string) {
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteString(s)
+	}
+
+	for i := 0; i < len(statement); {
+		c := statement[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			i++
+
+		case c == '-' && strings.HasPrefix(statement[i:], "--"):
+			end := strings.IndexByte(statement[i:], '\n')
+			if end < 0 {
+				end = len(statement) - i
+			}
+			space = true
+			i += end
+
+		case c == '/' && strings.HasPrefix(statement[i:], "/*"):
+			end := strings.Index(statement[i+2:], "*/")
+			if end < 0 {
+				end = len(statement) - i - 4
+			}
+			space = true
+			i += end + 4
+
+		case c == '\'':
+			write("?")
+			i = skipSQLString(statement, i)
+
+		case c == '"' || c == '`':
+			j := strings.IndexByte(statement[i+1:], c)
+			if j < 0 {
+				j = len(statement) - i - 2
+			}
+			write(statement[i : i+j+2])
+			i += j + 2
+
+		case c == '$' && dollarQuoteEnd(statement, i) > 0:
+			// Dollar-quoted strings (`$$...$$` or `$tag$...$tag$`).
+			write("?")
+			i = dollarQuoteEnd(statement, i)
+
+		case isSQLIdentifier(c) || c == '$' || c == ':' || c == '@':
+			// Identifiers, keywords and placeholders (with their digits).
+			j := i + 1
+			for j < len(statement) && (isSQLIdentifier(statement[j]) || isSQLDigit(statement[j])) {
+				j++
+			}
+
+			// Prefixed string literals (e.g. `E'...'`, `X'...'` or `U&'...'`).
+			prefix := strings.ToUpper(statement[i:j])
+			if prefix == "U" && strings.HasPrefix(statement[j:], "&'") {
+				prefix, j = "U&", j+1
+			}
+			if j < len(statement) && statement[j] == '\'' && isSQLStringPrefix(prefix) {
+				write("?")
+				i = skipSQLString(statement, j)
+				continue
+			}
+
+			write(statement[i:j])
+			i = j
+
+		case isSQLDigit(c) || (c == '.' && i+1 < len(statement) && isSQLDigit(statement[i+1])):
+			// Numbers, including hexadecimal ones (e.g. `0xDEADBEEF`).
+			j := i + 1
+			if c == '0' && j < len(statement) && (statement[j] == 'x' || statement[j] == 'X') {
+				j++
+			}
+			for j < len(statement) && (isSQLIdentifier(statement[j]) || isSQLDigit(statement[j]) || statement[j] == '.') {
+				j++
+			}
+			write("?")
+			i = j
+
+		default:
+			write(statement[i : i+1])
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+// sqlOperation returns the SQL command of a statement, in upper case.
+func sqlOperation(statement string) string {
+	statement = SanitizeSQL(statement)
+
+	end := 0
+	for end < len(statement) && isSQLIdentifier(statement[end]) {
+		end++
+	}
+
+	return strings.ToUpper(statement[:end])
+}
+
+func isSQLIdentifier(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isSQLDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isSQLStringPrefix returns true if the prefix of a string literal changes its
+// type: escape strings (`E`), binary and hexadecimal strings (`B` and `X`),
+// national strings (`N`) and Unicode strings (`U&`).
+func isSQLStringPrefix(prefix string) bool {
+	switch prefix {
+	case "E", "B", "X", "N", "U&":
+		return true
+	}
+
+	return false
+}
+
+// skipSQLString returns the index following the string literal starting with
+// the quote at index `i`. Quotes are escaped by doubling them, or using a
+// backslash (as in MySQL, or PostgreSQL escape strings). Unterminated strings
+// extend to the end of the statement.
+func skipSQLString(statement string, i int) int {
+	for j := i + 1; j < len(statement); j++ {
+		switch statement[j] {
+		case '\\':
+			j++
+		case '\'':
+			if j+1 < len(statement) && statement[j+1] == '\'' {
+				j++
+				continue
+			}
+
+			return j + 1
+		}
+	}
+
+	return len(statement)
+}
+
+// dollarQuoteEnd returns the index following the dollar-quoted string starting
+// at index `i`, or zero if there is none (e.g. for `$1` placeholders).
+// Unterminated strings extend to the end of the statement.
+func dollarQuoteEnd(statement string, i int) int {
+	j := i + 1
+	if j < len(statement) && isSQLIdentifier(statement[j]) {
+		for j < len(statement) && (isSQLIdentifier(statement[j]) || isSQLDigit(statement[j])) {
+			j++
+		}
+	}
+	if j >= len(statement) || statement[j] != '$' {
+		return 0
+	}
+
+	tag := statement[i : j+1]
+	end := strings.Index(statement[j+1:], tag)
+	if end < 0 {
+		return len(statement)
+	}
+
+	return j + 1 + end + len(tag)
+}
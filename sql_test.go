@@ -0,0 +1,81 @@
+package zapdriver
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSanitizeSQL(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"SELECT * FROM users WHERE id = 42":                   "SELECT * FROM users WHERE id = ?",
+		"SELECT * FROM t WHERE name = 'O''Brien' AND x > 1.5": "SELECT * FROM t WHERE name = ? AND x > ?",
+		"SELECT a1, \"Col 2\" FROM t2 WHERE id = $1":          "SELECT a1, \"Col 2\" FROM t2 WHERE id = $1",
+		"INSERT INTO t (a, b)\n\tVALUES (:a, @b)":             "INSERT INTO t (a, b) VALUES (:a, @b)",
+		"SELECT 1 -- comment\nFROM dual /* hint */ LIMIT 10":  "SELECT ? FROM dual LIMIT ?",
+		"SELECT `key` FROM t WHERE v IN (1, 2, 3)":            "SELECT `key` FROM t WHERE v IN (?, ?, ?)",
+		"SELECT 'unterminated":                                "SELECT ?",
+		`SELECT * FROM t WHERE p = 'a\'secret'`:               "SELECT * FROM t WHERE p = ?",
+		`SELECT * FROM t WHERE p = 'a\\' AND q = 'b'`:         "SELECT * FROM t WHERE p = ? AND q = ?",
+		`SELECT E'a\'hunter2', e'x'`:                          "SELECT ?, ?",
+		"SELECT X'DEADBEEF', B'0101', N'name', U&'d\\0061'":   "SELECT ?, ?, ?, ?",
+		"SELECT 0xDEADBEEF, 0XCAFE, 1e5":                      "SELECT ?, ?, ?",
+		"SELECT $$secret$$, $tag$it's $$ secret$tag$ FROM t":  "SELECT ?, ? FROM t",
+		"SELECT $$unterminated":                               "SELECT ?",
+		"SELECT * FROM t WHERE a = $1 AND b = $2":             "SELECT * FROM t WHERE a = $1 AND b = $2",
+		"SELECT e, x FROM t":                                  "SELECT e, x FROM t",
+	}
+
+	for statement, want := range tests {
+		assert.Equal(t, want, SanitizeSQL(statement), statement)
+	}
+}
+
+func TestNewSQL(t *testing.T) {
+	t.Parallel()
+
+	payload := NewSQL("  select name from users where id = 1", 1500*time.Millisecond, 1)
+	assert.Equal(t, &SQLPayload{
+		Statement: "select name from users where id = ?",
+		Operation: "SELECT",
+		Latency:   "1.5s",
+		Rows:      1,
+	}, payload)
+
+	long := NewSQL("SELECT "+strings.Repeat("a, ", 1000)+"b FROM t", 0, -1)
+	assert.Len(t, long.Statement, maxSQLStatementSize)
+
+	enc := zapcore.NewMapObjectEncoder()
+	require.NoError(t, long.MarshalLogObject(enc))
+	assert.NotContains(t, enc.Fields, "rows")
+}
+
+func TestLogQuery(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore())
+
+	LogQuery(logger, "SELECT 1", time.Millisecond, 1, nil)
+	LogQuery(logger, "SELECT name FROM users WHERE id = 2", time.Millisecond, 0, sql.ErrNoRows)
+	LogQuery(logger, "DELETE FROM users", time.Millisecond, -1, errors.New("boom"))
+
+	require.Equal(t, 3, logs.Len())
+	assert.Equal(t, zapcore.InfoLevel, logs.All()[0].Level)
+	assert.Equal(t, zapcore.InfoLevel, logs.All()[1].Level)
+
+	failed := logs.All()[2]
+	assert.Equal(t, zapcore.ErrorLevel, failed.Level)
+	assert.Equal(t, "boom", failed.ContextMap()["error"])
+	assert.Equal(t, map[string]interface{}{"sql.operation": "DELETE"}, failed.ContextMap()[labelsKey])
+}
@@ -0,0 +1,372 @@
+// Package sqlzap provides a database/sql driver wrapper logging each database
+// call in a format understood by Stackdriver, with the trace context of the
+// call context.
+package sqlzap
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/gridwise/zapdriver"
+)
+
+// Wrap returns a driver logging the statements run on the connections of `d`
+// (see `zapdriver.LogQuery()`), using `logger`, or the logger of the call
+// context (see `zapdriver.FromContext()`) when nil. Queries are logged once
+// their rows are closed, with the number of rows read.
+//
+//	sql.Register("postgres-zap", sqlzap.Wrap(&pq.Driver{}, nil))
+//	db, err := sql.Open("postgres-zap", dsn)
+func Wrap(d driver.Driver, logger *zap.Logger) driver.Driver {
+	return &wrappedDriver{Driver: d, logger: logger}
+}
+
+// wrappedDriver wraps the connections of a driver.
+type wrappedDriver struct {
+	driver.Driver
+
+	logger *zap.Logger
+}
+
+// Open implements driver.Driver interface.
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wrappedConn{Conn: conn, logger: d.logger}, nil
+}
+
+// loggerFor returns the logger of the call.
+func loggerFor(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	if logger != nil {
+		return logger
+	}
+
+	return zapdriver.FromContext(ctx)
+}
+
+// wrappedConn logs the statements run on a connection.
+type wrappedConn struct {
+	driver.Conn
+
+	logger *zap.Logger
+}
+
+// PrepareContext implements driver.ConnPrepareContext interface.
+func (c *wrappedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped := &wrappedStmt{Stmt: stmt, conn: c.Conn, query: query, logger: c.logger}
+
+	// database/sql handles statements with a column converter differently, so
+	// the interface is only implemented when the statement implements it.
+	if _, ok := stmt.(driver.ColumnConverter); ok { // nolint: staticcheck
+		return &convertingStmt{wrappedStmt: wrapped}, nil
+	}
+
+	return wrapped, nil
+}
+
+// Prepare implements driver.Conn interface.
+func (c *wrappedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// BeginTx implements driver.ConnBeginTx interface.
+func (c *wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+
+	return c.Conn.Begin() // nolint: staticcheck
+}
+
+// ExecContext implements driver.ExecerContext interface.
+func (c *wrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	res, err := e.ExecContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		return nil, err
+	}
+
+	logExec(loggerFor(ctx, c.logger), query, time.Since(start), res, err)
+
+	return res, err
+}
+
+// QueryContext implements driver.QueryerContext interface.
+func (c *wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		return nil, err
+	}
+
+	return wrapRows(loggerFor(ctx, c.logger), query, start, rows, err)
+}
+
+// Ping implements driver.Pinger interface.
+func (c *wrappedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+
+	return nil
+}
+
+// ResetSession implements driver.SessionResetter interface.
+func (c *wrappedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+// CheckNamedValue implements driver.NamedValueChecker interface.
+func (c *wrappedConn) CheckNamedValue(v *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(v)
+	}
+
+	return driver.ErrSkip
+}
+
+// IsValid implements driver.Validator interface, so database/sql discards the
+// connections marked bad by the driver.
+func (c *wrappedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+
+	return true
+}
+
+// wrappedStmt logs the executions of a prepared statement.
+type wrappedStmt struct {
+	driver.Stmt
+
+	conn   driver.Conn
+	query  string
+	logger *zap.Logger
+}
+
+// CheckNamedValue implements driver.NamedValueChecker interface. database/sql
+// only uses the checker of the connection when the statement has none, so the
+// checker of the connection is used when the statement does not implement it.
+func (s *wrappedStmt) CheckNamedValue(v *driver.NamedValue) error {
+	if n, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(v)
+	}
+	if n, ok := s.conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(v)
+	}
+
+	return driver.ErrSkip
+}
+
+// convertingStmt is a wrapped statement implementing driver.ColumnConverter.
+type convertingStmt struct {
+	*wrappedStmt
+}
+
+// ColumnConverter implements driver.ColumnConverter interface.
+func (s *convertingStmt) ColumnConverter(idx int) driver.ValueConverter {
+	return s.Stmt.(driver.ColumnConverter).ColumnConverter(idx) // nolint: staticcheck
+}
+
+// ExecContext implements driver.StmtExecContext interface.
+func (s *wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+
+	var res driver.Result
+	var err error
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = e.ExecContext(ctx, args)
+	} else {
+		res, err = s.Stmt.Exec(values(args)) // nolint: staticcheck
+	}
+
+	logExec(loggerFor(ctx, s.logger), s.query, time.Since(start), res, err)
+
+	return res, err
+}
+
+// QueryContext implements driver.StmtQueryContext interface.
+func (s *wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+
+	var rows driver.Rows
+	var err error
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(values(args)) // nolint: staticcheck
+	}
+
+	return wrapRows(loggerFor(ctx, s.logger), s.query, start, rows, err)
+}
+
+// values converts named values to the positional values of the legacy calls.
+func values(args []driver.NamedValue) []driver.Value {
+	out := make([]driver.Value, len(args))
+	for i := range args {
+		out[i] = args[i].Value
+	}
+
+	return out
+}
+
+// logExec logs a statement execution, with the number of affected rows.
+func logExec(logger *zap.Logger, query string, latency time.Duration, res driver.Result, err error) {
+	rows := int64(-1)
+	if err == nil && res != nil {
+		if n, rerr := res.RowsAffected(); rerr == nil {
+			rows = n
+		}
+	}
+
+	zapdriver.LogQuery(logger, query, latency, rows, err)
+}
+
+// wrapRows returns rows logging the query when they are closed, or logs the
+// failed query.
+func wrapRows(logger *zap.Logger, query string, start time.Time, rows driver.Rows, err error) (driver.Rows, error) {
+	if err != nil {
+		zapdriver.LogQuery(logger, query, time.Since(start), -1, err)
+		return nil, err
+	}
+
+	return &wrappedRows{Rows: rows, logger: logger, query: query, start: start}, nil
+}
+
+// wrappedRows counts the rows read, and logs the query when closed.
+type wrappedRows struct {
+	driver.Rows
+
+	logger *zap.Logger
+	query  string
+	start  time.Time
+
+	once  sync.Once
+	count int64
+	err   error
+}
+
+// Next implements driver.Rows interface.
+func (r *wrappedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	switch {
+	case err == nil:
+		r.count++
+	case err != io.EOF:
+		r.err = err
+	}
+
+	return err
+}
+
+// Close implements driver.Rows interface.
+func (r *wrappedRows) Close() error {
+	err := r.Rows.Close()
+
+	r.once.Do(func() {
+		zapdriver.LogQuery(r.logger, r.query, time.Since(r.start), r.count, r.err)
+	})
+
+	return err
+}
+
+// HasNextResultSet implements driver.RowsNextResultSet interface.
+func (r *wrappedRows) HasNextResultSet() bool {
+	if rows, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return rows.HasNextResultSet()
+	}
+
+	return false
+}
+
+// NextResultSet implements driver.RowsNextResultSet interface. The rows of all
+// result sets are counted.
+func (r *wrappedRows) NextResultSet() error {
+	if rows, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return rows.NextResultSet()
+	}
+
+	return io.EOF
+}
+
+// ColumnTypeScanType implements driver.RowsColumnTypeScanType interface.
+func (r *wrappedRows) ColumnTypeScanType(index int) reflect.Type {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return rows.ColumnTypeScanType(index)
+	}
+
+	// The default of database/sql, when the interface is not implemented.
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+// ColumnTypeDatabaseTypeName implements driver.RowsColumnTypeDatabaseTypeName
+// interface.
+func (r *wrappedRows) ColumnTypeDatabaseTypeName(index int) string {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return rows.ColumnTypeDatabaseTypeName(index)
+	}
+
+	return ""
+}
+
+// ColumnTypeLength implements driver.RowsColumnTypeLength interface.
+func (r *wrappedRows) ColumnTypeLength(index int) (int64, bool) {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return rows.ColumnTypeLength(index)
+	}
+
+	return 0, false
+}
+
+// ColumnTypeNullable implements driver.RowsColumnTypeNullable interface.
+func (r *wrappedRows) ColumnTypeNullable(index int) (bool, bool) {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return rows.ColumnTypeNullable(index)
+	}
+
+	return false, false
+}
+
+// ColumnTypePrecisionScale implements driver.RowsColumnTypePrecisionScale
+// interface.
+func (r *wrappedRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if rows, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return rows.ColumnTypePrecisionScale(index)
+	}
+
+	return 0, 0, false
+}
@@ -0,0 +1,266 @@
+package sqlzap_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/gridwise/zapdriver"
+	"github.com/gridwise/zapdriver/sqlzap"
+)
+
+// fakeDriver returns `rows` rows to every query, and fails the statements
+// containing "fail".
+type fakeDriver struct{ rows int }
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{rows: d.rows}, nil }
+
+type fakeConn struct{ rows int }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{query: query, rows: c.rows}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type fakeStmt struct {
+	query string
+	rows  int
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	if s.query == "fail" {
+		return nil, errors.New("boom")
+	}
+
+	return driver.RowsAffected(3), nil
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &fakeRows{left: s.rows, sets: 1}, nil
+}
+
+type fakeRows struct{ left, sets int }
+
+func (r *fakeRows) Columns() []string { return []string{"id"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) ColumnTypeDatabaseTypeName(int) string             { return "INT8" }
+func (r *fakeRows) ColumnTypeNullable(int) (bool, bool)               { return true, true }
+func (r *fakeRows) HasNextResultSet() bool                            { return r.sets > 0 }
+func (r *fakeRows) ColumnTypeScanType(int) reflect.Type               { return reflect.TypeOf(int64(0)) }
+func (r *fakeRows) ColumnTypeLength(int) (int64, bool)                { return 0, false }
+func (r *fakeRows) ColumnTypePrecisionScale(int) (int64, int64, bool) { return 0, 0, false }
+
+func (r *fakeRows) NextResultSet() error {
+	if r.sets == 0 {
+		return io.EOF
+	}
+
+	r.sets--
+	r.left = 1
+
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.left == 0 {
+		return io.EOF
+	}
+
+	r.left--
+	dest[0] = int64(r.left)
+
+	return nil
+}
+
+func TestWrap(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, zapdriver.WrapCore())
+
+	sql.Register("fake-zap", sqlzap.Wrap(&fakeDriver{rows: 2}, nil))
+	db, err := sql.Open("fake-zap", "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := zapdriver.WithLogger(context.Background(), logger)
+
+	_, err = db.ExecContext(ctx, "UPDATE users SET name = 'bob' WHERE id = 42")
+	require.NoError(t, err)
+
+	rows, err := db.QueryContext(ctx, "SELECT id FROM users")
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Close())
+
+	_, err = db.ExecContext(ctx, "fail")
+	assert.Error(t, err)
+
+	require.Equal(t, 3, logs.Len())
+
+	exec := logs.All()[0]
+	assert.Equal(t, "Database call.", exec.Message)
+	payload := exec.ContextMap()["sql"].(map[string]interface{})
+	assert.Equal(t, "UPDATE users SET name = ? WHERE id = ?", payload["statement"])
+	assert.Equal(t, "UPDATE", payload["operation"])
+	assert.Equal(t, int64(3), payload["rows"])
+	assert.Equal(t, map[string]interface{}{"sql.operation": "UPDATE"}, exec.ContextMap()["logging.googleapis.com/labels"])
+
+	query := logs.All()[1].ContextMap()["sql"].(map[string]interface{})
+	assert.Equal(t, "SELECT id FROM users", query["statement"])
+	assert.Equal(t, int64(2), query["rows"])
+
+	failed := logs.All()[2]
+	assert.Equal(t, zapcore.ErrorLevel, failed.Level)
+	assert.Equal(t, "Database call failed.", failed.Message)
+	assert.Equal(t, "boom", failed.ContextMap()["error"])
+}
+
+func TestWrap_Rows(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+
+	sql.Register("fake-zap-rows", sqlzap.Wrap(&fakeDriver{rows: 2}, zap.New(debugcore, zapdriver.WrapCore())))
+	db, err := sql.Open("fake-zap-rows", "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id FROM users")
+	require.NoError(t, err)
+
+	types, err := rows.ColumnTypes()
+	require.NoError(t, err)
+	require.Len(t, types, 1)
+	assert.Equal(t, "INT8", types[0].DatabaseTypeName())
+	assert.Equal(t, reflect.TypeOf(int64(0)), types[0].ScanType())
+	nullable, ok := types[0].Nullable()
+	assert.True(t, nullable && ok)
+
+	sets := 0
+	for {
+		for rows.Next() {
+		}
+		sets++
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	require.NoError(t, rows.Close())
+	assert.Equal(t, 2, sets)
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, int64(3), logs.All()[0].ContextMap()["sql"].(map[string]interface{})["rows"])
+}
+
+// money is a value only the statements of checkingDriver can convert.
+type money struct{ cents int64 }
+
+// checkingDriver returns connections marked bad after each statement, with
+// statements converting money values, using a named value checker, or a column
+// converter when `columns` is set.
+type checkingDriver struct {
+	columns bool
+	opened  int
+}
+
+func (d *checkingDriver) Open(string) (driver.Conn, error) {
+	d.opened++
+
+	return &checkingConn{fakeConn: fakeConn{rows: 1}, columns: d.columns}, nil
+}
+
+type checkingConn struct {
+	fakeConn
+
+	columns bool
+	bad     bool
+}
+
+func (c *checkingConn) IsValid() bool { return !c.bad }
+
+func (c *checkingConn) Prepare(query string) (driver.Stmt, error) {
+	stmt := &badStmt{fakeStmt: &fakeStmt{query: query, rows: c.rows}, conn: c}
+	if c.columns {
+		return &convertingFakeStmt{badStmt: stmt}, nil
+	}
+
+	return &checkingFakeStmt{badStmt: stmt}, nil
+}
+
+// badStmt marks its connection bad once executed.
+type badStmt struct {
+	*fakeStmt
+
+	conn *checkingConn
+}
+
+func (s *badStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.bad = true
+
+	return s.fakeStmt.Exec(args)
+}
+
+type moneyConverter struct{}
+
+func (moneyConverter) ConvertValue(v interface{}) (driver.Value, error) { return convertMoney(v) }
+
+func convertMoney(v interface{}) (driver.Value, error) {
+	if m, ok := v.(money); ok {
+		return m.cents, nil
+	}
+
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+type checkingFakeStmt struct{ *badStmt }
+
+func (s *checkingFakeStmt) CheckNamedValue(v *driver.NamedValue) (err error) {
+	v.Value, err = convertMoney(v.Value)
+	return err
+}
+
+type convertingFakeStmt struct{ *badStmt }
+
+func (s *convertingFakeStmt) NumInput() int { return 1 }
+
+func (s *convertingFakeStmt) ColumnConverter(int) driver.ValueConverter {
+	return moneyConverter{}
+}
+
+func TestWrap_Checkers(t *testing.T) {
+	for name, columns := range map[string]bool{"named value checker": false, "column converter": true} {
+		d := &checkingDriver{columns: columns}
+		db := sql.OpenDB(connector{sqlzap.Wrap(d, zap.NewNop())})
+
+		for i := 0; i < 2; i++ {
+			stmt, err := db.Prepare("UPDATE accounts SET balance = ?")
+			require.NoError(t, err, name)
+
+			_, err = stmt.Exec(money{cents: 100})
+			require.NoError(t, err, name)
+			require.NoError(t, stmt.Close())
+		}
+		require.NoError(t, db.Close())
+
+		assert.Equal(t, 2, d.opened, "%s: the connections marked bad are not reused", name)
+	}
+}
+
+// connector opens the connections of a driver.
+type connector struct{ d driver.Driver }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c connector) Driver() driver.Driver                        { return c.d }
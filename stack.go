@@ -0,0 +1,242 @@
+package zapdriver
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// elidedFramesMarker replaces the frames removed from a stack trace, like
+	// the Go runtime does for deep stacks.
+	elidedFramesMarker = "...additional frames elided..."
+
+	// stackProducer is the operation producer of the entries holding the
+	// frames split from a stack trace.
+	stackProducer = "github.com/gridwise/zapdriver/stack"
+)
+
+// StackStrategy determines how stack traces with more frames than the maximum
+// are reduced.
+type StackStrategy int
+
+const (
+	// TrimStack keeps the top frames of the stack trace, and the frame of the
+	// panic site (if any), removing the other frames.
+	TrimStack StackStrategy = iota
+
+	// SplitStack keeps the same frames as TrimStack in the entry, and writes
+	// the other frames in follow-up entries, correlated with the entry using an
+	// `Operation()` field.
+	SplitStack
+)
+
+// zapdriver core option to limit the stack traces of reported errors to
+// `frames` frames (plus the frame of the panic site), reducing the other
+// frames using `strategy`. Large stack traces otherwise exceed the maximum
+// entry size, and truncating them mid-frame breaks Error Reporting parsing. The
+// `stacktrace` field (see `PreserveStackField()`) is always trimmed, its frames
+// being split only from the message
+func MaxStackFrames(frames int, strategy StackStrategy) func(*core) {
+	return func(c *core) {
+		c.config.MaxStackFrames = frames
+		c.config.StackStrategy = strategy
+	}
+}
+
+// zapdriver core option to capture the stack of the caller of the entries with
+// level error or above logged without a stack trace (e.g. using `zap.Error()`
+// only) when set to true. The stack is captured at write time, skipping the
+// frames of zap and of the cores, and embedded in the message of reported
+// errors like the stack traces added using `zap.AddStacktrace()`, so Error
+// Reporting has frames to group them on
+func CaptureStackOnError(enabled bool) func(*core) {
+	return func(c *core) {
+		c.config.CaptureStackOnError = enabled
+	}
+}
+
+// captureStack returns the stack of the caller of the logger, in the format of
+// the stack traces of zap entries.
+func captureStack() string {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+
+	var b strings.Builder
+	skipping := true
+	for {
+		frame, more := frames.Next()
+		if skipping && isLoggerFrame(frame.Function, frame.File) {
+			if !more {
+				break
+			}
+			continue
+		}
+		skipping = false
+
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(frame.Function)
+		b.WriteString("\n\t")
+		b.WriteString(frame.File)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(frame.Line))
+
+		if !more {
+			break
+		}
+	}
+
+	return b.String()
+}
+
+// isLoggerFrame returns true if the function belongs to zap, or to zapdriver
+// (such as the cores, or the recovery functions), except for its tests.
+func isLoggerFrame(function, file string) bool {
+	if strings.HasPrefix(function, "go.uber.org/zap.") || strings.HasPrefix(function, "go.uber.org/zap/") {
+		return true
+	}
+
+	return (strings.HasPrefix(function, "github.com/gridwise/zapdriver.") ||
+		strings.HasPrefix(function, "github.com/gridwise/zapdriver/")) && !strings.HasSuffix(file, "_test.go")
+}
+
+// embedsStack returns true if the message holds a stack trace in the format of
+// the Go runtime, such as the messages of the recovered panics.
+func embedsStack(message string) bool {
+	return strings.Contains(message, "\n\ngoroutine ")
+}
+
+// limitStack reduces the stack trace embedded in the message of the entry, and
+// the `stacktrace` field, to the maximum number of frames. Using the SplitStack
+// strategy, the frames removed from the message are returned in follow-up
+// entries, in chunks of the maximum number of frames.
+func (c *core) limitStack(ent zapcore.Entry, fields []zapcore.Field) ([]zapcore.Entry, [][]zapcore.Field) {
+	if frames := splitFrames(ent.Stack); len(frames) > c.config.MaxStackFrames {
+		kept, _ := trimFrames(frames, c.config.MaxStackFrames)
+		ent.Stack = strings.Join(kept, "\n")
+	}
+
+	head, frames, ok := splitStack(ent.Message)
+	if !ok || len(frames) <= c.config.MaxStackFrames {
+		return []zapcore.Entry{ent}, [][]zapcore.Field{fields}
+	}
+
+	kept, elided := trimFrames(frames, c.config.MaxStackFrames)
+	ent.Message = head + "\n" + strings.Join(kept, "\n")
+
+	if c.config.StackStrategy != SplitStack || len(elided) == 0 {
+		return []zapcore.Entry{ent}, [][]zapcore.Field{fields}
+	}
+
+	chunks := (len(elided) + c.config.MaxStackFrames - 1) / c.config.MaxStackFrames
+	id := randomID()
+
+	entries := []zapcore.Entry{ent}
+	parts := [][]zapcore.Field{append(fields[:len(fields):len(fields)], Operation(id, stackProducer, true, false))}
+
+	// Follow-up entries keep the special fields of the entry, except the error
+	// context, so they are not reported as errors of their own.
+	common := []zapcore.Field{}
+	for i := range fields {
+		if isSpecialField(fields[i]) && fields[i].Key != contextKey && fields[i].Key != serviceContextKey {
+			common = append(common, fields[i])
+		}
+	}
+
+	title := strings.SplitN(ent.Message, "\n", 2)[0]
+	for i := 0; i < chunks; i++ {
+		end := (i + 1) * c.config.MaxStackFrames
+		if end > len(elided) {
+			end = len(elided)
+		}
+
+		followUp := ent
+		followUp.Stack = ""
+		followUp.Message = title + " (stack trace continued, part " + strconv.Itoa(i+2) + "/" +
+			strconv.Itoa(chunks+1) + ")\n" + strings.Join(elided[i*c.config.MaxStackFrames:end], "\n")
+
+		entries = append(entries, followUp)
+		parts = append(parts, append(append([]zapcore.Field{}, common...), Operation(id, stackProducer, false, i == chunks-1)))
+	}
+
+	return entries, parts
+}
+
+// splitStack splits a message holding a stack trace in the format of the Go
+// runtime into its head (the message and the goroutine header) and its frames.
+// Each frame holds the function line, and the indented location lines that
+// follow it.
+func splitStack(message string) (string, []string, bool) {
+	i := strings.Index(message, "\n\ngoroutine ")
+	if i < 0 {
+		return "", nil, false
+	}
+
+	j := strings.IndexByte(message[i+2:], '\n')
+	if j < 0 {
+		return "", nil, false
+	}
+
+	return message[:i+2+j], splitFrames(message[i+3+j:]), true
+}
+
+// splitFrames splits a stack trace, without the goroutine header, into its
+// frames.
+func splitFrames(stack string) []string {
+	if stack == "" {
+		return nil
+	}
+
+	frames := []string{}
+	for _, line := range strings.Split(strings.TrimRight(stack, "\n"), "\n") {
+		if strings.HasPrefix(line, "\t") && len(frames) > 0 {
+			frames[len(frames)-1] += "\n" + line
+			continue
+		}
+
+		frames = append(frames, line)
+	}
+
+	return frames
+}
+
+// trimFrames returns the top `n` frames, and the panic frame followed by the
+// frame of the panic site, when they are not part of the top frames. Removed
+// frames are replaced by a marker in the kept frames, and returned in order.
+func trimFrames(frames []string, n int) ([]string, []string) {
+	keep := make([]bool, len(frames))
+	for i := 0; i < n && i < len(frames); i++ {
+		keep[i] = true
+	}
+
+	for i := range frames {
+		if strings.HasPrefix(frames[i], "panic(") {
+			keep[i] = true
+			if i+1 < len(frames) {
+				keep[i+1] = true
+			}
+
+			break
+		}
+	}
+
+	kept, elided := []string{}, []string{}
+	for i := range frames {
+		if keep[i] {
+			kept = append(kept, frames[i])
+			continue
+		}
+
+		if i == 0 || keep[i-1] {
+			kept = append(kept, elidedFramesMarker)
+		}
+
+		elided = append(elided, frames[i])
+	}
+
+	return kept, elided
+}
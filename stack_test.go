@@ -0,0 +1,166 @@
+package zapdriver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTrimFrames(t *testing.T) {
+	t.Parallel()
+
+	frames := []string{"f0", "f1", "f2", "f3", "panic({0x1, 0x2})", "site", "f6", "f7"}
+
+	kept, elided := trimFrames(frames, 2)
+	assert.Equal(t, []string{"f0", "f1", elidedFramesMarker, "panic({0x1, 0x2})", "site", elidedFramesMarker}, kept)
+	assert.Equal(t, []string{"f2", "f3", "f6", "f7"}, elided)
+
+	kept, elided = trimFrames(frames[:4], 3)
+	assert.Equal(t, []string{"f0", "f1", "f2", elidedFramesMarker}, kept)
+	assert.Equal(t, []string{"f3"}, elided)
+}
+
+func TestSplitStack(t *testing.T) {
+	t.Parallel()
+
+	head, frames, ok := splitStack("boom\n\ngoroutine 1 [running]:\nmain.a()\n\t/a.go:1 +0x1\nmain.b()\n\t/b.go:2\n")
+	require.True(t, ok)
+	assert.Equal(t, "boom\n\ngoroutine 1 [running]:", head)
+	assert.Equal(t, []string{"main.a()\n\t/a.go:1 +0x1", "main.b()\n\t/b.go:2"}, frames)
+
+	_, _, ok = splitStack("boom")
+	assert.False(t, ok)
+}
+
+func TestMaxStackFrames_Split(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, zap.AddCaller(), WrapCore(ReportAllErrors(true), EmbedStack(true), MaxStackFrames(2, SplitStack)))
+
+	var stack []string
+	for i := 0; i < 5; i++ {
+		stack = append(stack, fmt.Sprintf("main.f%d\n\t/main.go:%d", i, i))
+	}
+
+	ce := logger.Check(zapcore.ErrorLevel, "failed")
+	ce.Stack = strings.Join(stack, "\n")
+	ce.Write(Label("one", "1"))
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, "failed\n\ngoroutine 1 [running]:\nmain.f0()\n\t/main.go:0\nmain.f1()\n\t/main.go:1\n"+elidedFramesMarker, entries[0].Message)
+	assert.Contains(t, entries[0].ContextMap(), contextKey)
+
+	assert.Equal(t, "failed (stack trace continued, part 2/3)\nmain.f2()\n\t/main.go:2\nmain.f3()\n\t/main.go:3", entries[1].Message)
+	assert.Equal(t, "failed (stack trace continued, part 3/3)\nmain.f4()\n\t/main.go:4", entries[2].Message)
+
+	id := entries[0].ContextMap()[operationKey].(map[string]interface{})["id"]
+	for i, entry := range entries {
+		assert.Equal(t, zapcore.ErrorLevel, entry.Level)
+
+		op := entry.ContextMap()[operationKey].(map[string]interface{})
+		assert.Equal(t, id, op["id"])
+		assert.Equal(t, i == 0, op["first"])
+		assert.Equal(t, i == 2, op["last"])
+
+		assert.Equal(t, map[string]interface{}{"one": "1"}, entry.ContextMap()[labelsKey])
+		if i > 0 {
+			assert.NotContains(t, entry.ContextMap(), contextKey)
+			assert.NotContains(t, entry.ContextMap(), serviceContextKey)
+		}
+	}
+}
+
+func recurse(n int) {
+	if n == 0 {
+		panic("deep")
+	}
+
+	recurse(n - 1)
+}
+
+func TestMaxStackFrames_Trim(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(MaxStackFrames(3, TrimStack)))
+
+	func() {
+		defer Recover(logger)
+		recurse(50)
+	}()
+
+	require.Equal(t, 1, logs.Len())
+
+	message := logs.All()[0].Message
+	_, frames, ok := splitStack(message)
+	require.True(t, ok)
+
+	// The top frame is the panic site, without the recovery frames.
+	assert.Less(t, len(frames), 10, message)
+	assert.Equal(t, elidedFramesMarker, frames[len(frames)-1])
+	assert.Contains(t, frames[0], "zapdriver.recurse", message)
+	assert.NotContains(t, message, "panic(", message)
+}
+
+func TestMaxStackFrames_StackField(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, zap.AddStacktrace(zapcore.ErrorLevel),
+		WrapCore(ReportAllErrors(true), EmbedStack(true), PreserveStackField(true), MaxStackFrames(2, SplitStack)))
+
+	stack := "main.a()\n\t/app/main.go:1\nmain.b()\n\t/app/main.go:2\nmain.c()\n\t/app/main.go:3\nmain.d()\n\t/app/main.go:4"
+	ce := logger.Check(zapcore.ErrorLevel, "failed")
+	ce.Stack = stack
+	ce.Write()
+
+	require.Equal(t, 2, logs.Len())
+	assert.Equal(t, "main.a()\n\t/app/main.go:1\nmain.b()\n\t/app/main.go:2\n"+elidedFramesMarker, logs.All()[0].Stack)
+	assert.Empty(t, logs.All()[1].Stack)
+}
+
+func TestCaptureStackOnError(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, zap.AddCaller(), WrapCore(ReportAllErrors(true), EmbedStack(true), CaptureStackOnError(true)))
+
+	logger.Warn("warning")
+	logger.Error("failed", zap.Error(errors.New("boom")))
+	logger.WithOptions(zap.AddStacktrace(zapcore.ErrorLevel)).Error("traced")
+
+	require.Equal(t, 3, logs.Len())
+
+	assert.Equal(t, "warning", logs.All()[0].Message)
+
+	message := logs.All()[1].Message
+	_, frames, ok := splitStack(message)
+	require.True(t, ok, message)
+	assert.Contains(t, frames[0], "zapdriver.TestCaptureStackOnError", message)
+	assert.Contains(t, frames[0], "\n\t", "frames hold their location")
+	assert.Empty(t, logs.All()[1].Stack)
+	assert.NotContains(t, message, "go.uber.org/zap")
+
+	message = logs.All()[2].Message
+	assert.Contains(t, message, "zapdriver.TestCaptureStackOnError")
+	assert.Equal(t, 1, strings.Count(message, "goroutine 1 [running]:"), "stack traces of the entries are kept")
+}
+
+func TestIsLoggerFrame(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, isLoggerFrame("go.uber.org/zap.(*Logger).Error", "/go/zap/logger.go"))
+	assert.True(t, isLoggerFrame("go.uber.org/zap/zapcore.(*CheckedEntry).Write", "/go/zap/zapcore/entry.go"))
+	assert.True(t, isLoggerFrame("github.com/gridwise/zapdriver.(*core).Write", "/src/zapdriver/core.go"))
+	assert.True(t, isLoggerFrame("github.com/gridwise/zapdriver.Recover", "/src/zapdriver/recover.go"))
+	assert.True(t, isLoggerFrame("github.com/gridwise/zapdriver/grpczap.logCall", "/src/zapdriver/grpczap/grpczap.go"))
+	assert.False(t, isLoggerFrame("github.com/gridwise/zapdriver.TestCaptureStackOnError", "/src/zapdriver/stack_test.go"))
+	assert.False(t, isLoggerFrame("github.com/gridwise/zapdriverx.Handler", "/src/zapdriverx/handler.go"))
+	assert.False(t, isLoggerFrame("main.main", "/src/app/main.go"))
+}
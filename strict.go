@@ -0,0 +1,56 @@
+package zapdriver
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// zapdriver core option to reject the fields which would silently produce
+// broken entries: `labels.*` fields which are not strings (and are written as
+// regular fields instead of labels), and fields colliding with reserved keys.
+// In development mode (see `ModeFromEnv()`), e.g. in CI, misuses panic, so
+// they are caught before reaching production. Otherwise, a warning is written
+// to the error output of the core
+func StrictMode() func(*core) {
+	return func(c *core) {
+		c.config.Strict = true
+		c.config.StrictPanics = ModeFromEnv() == ModeDevelopment
+	}
+}
+
+// strictFields returns the errors of all the misused fields, before they are
+// handled by the reserved keys policy.
+func strictFields(fields []zapcore.Field, keys *zapcore.EncoderConfig) error {
+	var err error
+	for i := range fields {
+		err = multierr.Append(err, strictField(fields[i], keys))
+	}
+
+	return err
+}
+
+// misused reports misused fields, as described by the format.
+func (c *core) misused(format string, args ...interface{}) {
+	if c.config.StrictPanics {
+		panic("zapdriver: " + fmt.Sprintf(format, args...))
+	}
+
+	c.warn(format, args...)
+}
+
+// strictField returns an error if the field is a label which is not a string,
+// or collides with a reserved key.
+func strictField(field zapcore.Field, keys *zapcore.EncoderConfig) error {
+	if strings.HasPrefix(field.Key, "labels.") && !hasLabelType(field) {
+		return fmt.Errorf("%s: label is not a string", field.Key)
+	}
+
+	if isReservedKeyCollision(field, keys) {
+		return fmt.Errorf("%s: collides with a reserved key", field.Key)
+	}
+
+	return nil
+}
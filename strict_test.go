@@ -0,0 +1,73 @@
+package zapdriver
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestStrictFields(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		field zapcore.Field
+		err   string
+	}{
+		"label":      {Label("one", "1"), ""},
+		"int label":  {zap.Int("labels.one", 1), "labels.one: label is not a string"},
+		"bool label": {zap.Bool("labels.one", true), "labels.one: label is not a string"},
+		"labels":     {Labels(Label("one", "1")), ""},
+		"reserved":   {zap.String("severity", "DEBUG"), "severity: collides with a reserved key"},
+		"trace":      {zap.String(traceKey, "projects/p/traces/t"), ""},
+		"bad trace":  {zap.Int(traceKey, 1), "collides with a reserved key"},
+		"regular":    {zap.Int("one", 1), ""},
+	}
+
+	for name, tt := range tests {
+		err := strictFields([]zapcore.Field{tt.field}, &encoderConfig)
+		if tt.err == "" {
+			assert.NoError(t, err, name)
+			continue
+		}
+
+		require.Error(t, err, name)
+		assert.Contains(t, err.Error(), tt.err, name)
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	t.Setenv("ZAPDRIVER_MODE", "production")
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	output := &bytes.Buffer{}
+	c := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+	StrictMode()(c)
+	ErrorOutput(zapcore.AddSync(output))(c)
+
+	require.NoError(t, c.Write(zapcore.Entry{Message: "ok"}, []zapcore.Field{Label("one", "1")}))
+	assert.Empty(t, output.String())
+
+	require.NoError(t, c.Write(zapcore.Entry{Message: "bad"}, []zapcore.Field{zap.Int("labels.one", 1)}))
+	assert.Contains(t, output.String(), `misused fields in entry "bad": labels.one: label is not a string`)
+	assert.Equal(t, 2, logs.Len())
+
+	c.With([]zapcore.Field{zap.String("severity", "DEBUG")})
+	assert.Contains(t, output.String(), "misused fields in logger.With(): severity: collides with a reserved key")
+
+	t.Setenv("ZAPDRIVER_MODE", "development")
+	StrictMode()(c)
+	assert.Panics(t, func() {
+		_ = c.Write(zapcore.Entry{Message: "bad"}, []zapcore.Field{zap.Int("labels.one", 1)})
+	})
+	assert.NotPanics(t, func() {
+		_ = c.Write(zapcore.Entry{Message: "ok"}, []zapcore.Field{Label("one", "1")})
+	})
+}
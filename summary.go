@@ -0,0 +1,223 @@
+package zapdriver
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// errorSummaryMessage is the message of the entries summarizing the errors of
+// an interval.
+const errorSummaryMessage = "Error summary."
+
+// errorSummarizer holds the configuration of an ErrorSummarizer.
+type errorSummarizer struct {
+	examples int
+}
+
+// zapdriver error summarizer option to add at most `n` example trace IDs to
+// each summary entry
+func SummaryExamples(n int) func(*errorSummarizer) {
+	return func(s *errorSummarizer) {
+		s.examples = n
+	}
+}
+
+// zapdriver core option to count the entries with level error or above using
+// `summarizer`, which writes a summary of the errors of each interval to the
+// core. The errors are counted before sampling, rate limiting and repeat
+// suppression, so the summaries stay accurate when most occurrences are dropped
+func SummarizeErrors(summarizer *ErrorSummarizer) func(*core) {
+	return func(c *core) {
+		c.config.Summarizer = summarizer
+	}
+}
+
+// summaryKey groups the errors of a summary. The summaries of the loggers
+// sharing a summarizer are kept apart, and written by the core of the logger.
+type summaryKey struct {
+	core    *core
+	message string
+	kind    string
+}
+
+// errorSummary counts the errors with the same key during an interval.
+type errorSummary struct {
+	count  int
+	traces []string
+}
+
+// ErrorSummarizer counts the errors written by a core (see `SummarizeErrors()`)
+// per message and error kind (as classified by `ClassifyErrors()`), and writes
+// an Info entry for each of them at the end of every interval, with the number
+// of occurrences and example trace IDs. Log-based metrics and alerts on the
+// summaries are cheap, even when the errors themselves are sampled.
+//
+// A summarizer can be shared by several loggers, in which case the summaries of
+// each logger are written by the logger, like its other entries.
+type ErrorSummarizer struct {
+	interval time.Duration
+	config   errorSummarizer
+
+	mutex     *sync.Mutex
+	summaries map[summaryKey]*errorSummary
+	order     []summaryKey
+	closed    bool
+
+	start     sync.Once
+	closeOnce sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewErrorSummarizer returns an ErrorSummarizer writing summaries every
+// `interval` (or every minute, if `interval` is not positive), from the first
+// error it counts. Use `Close()` to stop it.
+func NewErrorSummarizer(interval time.Duration, options ...func(*errorSummarizer)) *ErrorSummarizer {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	s := &ErrorSummarizer{
+		interval:  interval,
+		config:    errorSummarizer{examples: 3},
+		mutex:     &sync.Mutex{},
+		summaries: map[summaryKey]*errorSummary{},
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, option := range options {
+		option(&s.config)
+	}
+
+	return s
+}
+
+// record counts an error of the core, along with its trace. Errors are not
+// counted once the summarizer is closed.
+func (s *ErrorSummarizer) record(c *core, message, kind, trace string) {
+	key := summaryKey{core: c, message: message, kind: kind}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.start.Do(func() { go s.run() })
+
+	summary, ok := s.summaries[key]
+	if !ok {
+		summary = &errorSummary{}
+		s.summaries[key] = summary
+		s.order = append(s.order, key)
+	}
+
+	summary.count++
+	if trace == "" || len(summary.traces) >= s.config.examples {
+		return
+	}
+	for _, t := range summary.traces {
+		if t == trace {
+			return
+		}
+	}
+	summary.traces = append(summary.traces, trace)
+}
+
+// Flush writes the summaries of the errors counted since the previous flush,
+// in the order the errors were first seen.
+func (s *ErrorSummarizer) Flush() {
+	s.mutex.Lock()
+	summaries, order := s.summaries, s.order
+	s.summaries, s.order = map[summaryKey]*errorSummary{}, nil
+	s.mutex.Unlock()
+
+	for _, key := range order {
+		summary := summaries[key]
+
+		fields := []zapcore.Field{
+			zap.String("errorMessage", key.message),
+			zap.Int("count", summary.count),
+			zap.String("period", FormatLatency(s.interval)),
+		}
+		if len(summary.traces) > 0 {
+			fields = append(fields, zap.Strings("exampleTraces", summary.traces))
+		}
+
+		lbls := NewLabelSet()
+		if key.kind != "" {
+			lbls.Add(errorKindLabel, key.kind)
+		}
+
+		ent := zapcore.Entry{
+			Level:   zapcore.InfoLevel,
+			Time:    key.core.now(),
+			Message: errorSummaryMessage,
+		}
+
+		// The summary goes through the whole pipeline of the core, so it holds
+		// the labels and service context of the logger, and honours its level.
+		if ce := key.core.Check(ent, nil); ce != nil {
+			ce.Write(append(fields, lbls.Field())...)
+		}
+	}
+}
+
+// Close stops the summarizer, after writing the pending summaries. Closing it
+// again does nothing.
+func (s *ErrorSummarizer) Close() {
+	s.closeOnce.Do(func() {
+		s.mutex.Lock()
+		s.closed = true
+		s.mutex.Unlock()
+
+		// Without any error counted, there is no goroutine to stop.
+		s.start.Do(func() { close(s.done) })
+
+		close(s.stop)
+		<-s.done
+	})
+}
+
+func (s *ErrorSummarizer) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Flush()
+		case <-s.stop:
+			s.Flush()
+			return
+		}
+	}
+}
+
+// summarizeError counts the error entry with the summarizer of the core.
+func (c *core) summarizeError(ent zapcore.Entry, fields []zapcore.Field) {
+	var kind string
+	for i := range fields {
+		if err, ok := fields[i].Interface.(error); ok && fields[i].Type == zapcore.ErrorType {
+			kind = c.errorKind(err)
+			break
+		}
+	}
+
+	trace, ok := traceOf(fields)
+	if !ok {
+		trace = c.trace
+	}
+
+	root := c.root
+	if root == nil {
+		root = c
+	}
+
+	c.config.Summarizer.record(root, ent.Message, kind, trace)
+}
@@ -0,0 +1,137 @@
+package zapdriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSummarizeErrors(t *testing.T) {
+	t.Parallel()
+
+	summarizer := NewErrorSummarizer(time.Hour, SummaryExamples(2))
+	defer summarizer.Close()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(SummarizeErrors(summarizer)))
+
+	for i := 0; i < 3; i++ {
+		logger.Error("query failed", zap.Error(fmt.Errorf("query: %w", context.DeadlineExceeded)),
+			zap.String(traceKey, fmt.Sprintf("projects/p/traces/%d", i)))
+	}
+	logger.With(zap.String(traceKey, "projects/p/traces/9")).Error("query failed", zap.Error(errors.New("boom")))
+	logger.Warn("slow query")
+
+	require.Equal(t, 5, logs.Len())
+	logs.TakeAll()
+
+	summarizer.Flush()
+	require.Equal(t, 2, logs.Len())
+
+	summary := logs.All()[0]
+	assert.Equal(t, zapcore.InfoLevel, summary.Level)
+	assert.Equal(t, errorSummaryMessage, summary.Message)
+	assert.Equal(t, "query failed", summary.ContextMap()["errorMessage"])
+	assert.Equal(t, int64(3), summary.ContextMap()["count"])
+	assert.Equal(t, []interface{}{"projects/p/traces/0", "projects/p/traces/1"}, summary.ContextMap()["exampleTraces"])
+	assert.Equal(t, map[string]interface{}{errorKindLabel: "context_deadline"}, summary.ContextMap()[labelsKey])
+
+	summary = logs.All()[1]
+	assert.Equal(t, int64(1), summary.ContextMap()["count"])
+	assert.Equal(t, []interface{}{"projects/p/traces/9"}, summary.ContextMap()["exampleTraces"])
+	assert.Empty(t, summary.ContextMap()[labelsKey])
+
+	summarizer.Flush()
+	assert.Equal(t, 2, logs.Len(), "summaries are reset after each flush")
+}
+
+func TestSummarizeErrors_Sampled(t *testing.T) {
+	t.Parallel()
+
+	summarizer := NewErrorSummarizer(time.Hour)
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(
+		SummarizeErrors(summarizer),
+		RateLimitByLabel("tenant", 1, time.Hour),
+	))
+
+	for i := 0; i < 5; i++ {
+		logger.Error("failed", Label("tenant", "acme"))
+	}
+	require.Equal(t, 1, logs.Len())
+
+	summarizer.Close()
+	require.Equal(t, 2, logs.Len())
+	assert.Equal(t, int64(5), logs.All()[1].ContextMap()["count"], "summaries count the suppressed errors")
+}
+
+func TestSummarizeErrors_Shared(t *testing.T) {
+	t.Parallel()
+
+	summarizer := NewErrorSummarizer(time.Hour)
+	defer summarizer.Close()
+
+	now := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	apicore, apiLogs := observer.New(zapcore.DebugLevel)
+	api := zap.New(apicore, WrapCore(SummarizeErrors(summarizer), ServiceName("api"), Clock(fixedClock(now))))
+	workercore, workerLogs := observer.New(zapcore.ErrorLevel)
+	worker := zap.New(workercore, WrapCore(SummarizeErrors(summarizer), ServiceName("worker")))
+
+	api.With(Label("tenant", "acme")).Error("failed")
+	api.Error("failed")
+	worker.Error("failed")
+	apiLogs.TakeAll()
+	workerLogs.TakeAll()
+
+	summarizer.Flush()
+
+	require.Equal(t, 1, apiLogs.Len())
+	summary := apiLogs.All()[0]
+	assert.Equal(t, int64(2), summary.ContextMap()["count"], "the errors of the derived loggers are summarized together")
+	assert.Equal(t, now, summary.Time)
+	assert.Equal(t, "api", summary.ContextMap()[serviceContextKey].(map[string]interface{})["service"])
+	assert.Empty(t, summary.ContextMap()[labelsKey], "the labels of the derived loggers are not added")
+
+	assert.Equal(t, 0, workerLogs.Len(), "summaries honour the level of the logger")
+}
+
+func TestErrorSummarizer_Close(t *testing.T) {
+	t.Parallel()
+
+	NewErrorSummarizer(time.Hour).Close()
+
+	summarizer := NewErrorSummarizer(time.Hour)
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(SummarizeErrors(summarizer)))
+
+	logger.Error("failed")
+	summarizer.Close()
+	assert.Equal(t, 2, logs.Len(), "the pending summary is written")
+
+	assert.NotPanics(t, summarizer.Close)
+
+	logger.Error("failed")
+	assert.Empty(t, summarizer.summaries, "errors are not counted once closed")
+}
+
+func TestNewErrorSummarizer_Interval(t *testing.T) {
+	t.Parallel()
+
+	summarizer := NewErrorSummarizer(0)
+	defer summarizer.Close()
+
+	assert.Equal(t, time.Minute, summarizer.interval)
+
+	debugcore, _ := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(SummarizeErrors(summarizer)))
+	assert.NotPanics(t, func() { logger.Error("failed") })
+}
@@ -0,0 +1,108 @@
+package zapdriver
+
+import (
+	"context"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// ContextSyncer is implemented by the zapdriver core and writers which can
+// bound the time spent flushing buffered entries.
+type ContextSyncer interface {
+	// SyncContext flushes buffered entries, like `Sync()`, but returns when
+	// the context is done. The returned error is a *SyncError when entries
+	// could not be written before the context was done.
+	SyncContext(ctx context.Context) error
+}
+
+// SyncError is returned by `SyncContext()` when the context is done (or the
+// writer fails) before all buffered entries are written.
+type SyncError struct {
+	// Dropped is the number of entries which were not written. Entries still
+	// queued may be written after the error is returned.
+	Dropped int
+
+	// Err is the cause of the error, such as `context.DeadlineExceeded`.
+	Err error
+}
+
+func (e *SyncError) Error() string {
+	return "zapdriver: sync failed, " + strconv.Itoa(e.Dropped) + " entries dropped: " + e.Err.Error()
+}
+
+func (e *SyncError) Unwrap() error {
+	return e.Err
+}
+
+// SyncContext flushes the buffered entries of the logger, returning when the
+// context is done, so that a graceful shutdown can bound the time spent
+// flushing logs to a network sink. Cores which do not implement ContextSyncer
+// are synced in the background, and abandoned when the context is done.
+func SyncContext(ctx context.Context, logger *zap.Logger) error {
+	return syncContext(ctx, logger.Core())
+}
+
+// syncContext syncs `s`, returning when the context is done.
+func syncContext(ctx context.Context, s interface{ Sync() error }) error {
+	if cs, ok := s.(ContextSyncer); ok {
+		return cs.SyncContext(ctx)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Sync()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return &SyncError{Err: ctx.Err()}
+	}
+}
+
+// SyncContext implements the ContextSyncer interface. Entries still queued by
+// the asynchronous queue when the context is done are reported as dropped.
+func (c *core) SyncContext(ctx context.Context) error {
+	if c.queue != nil {
+		if err := c.queue.flushContext(ctx); err != nil {
+			c.onError(err)
+			return err
+		}
+	}
+
+	err := syncContext(ctx, c.Core)
+	if err != nil {
+		c.onError(err)
+	}
+
+	return err
+}
+
+// contextMutex is a mutex which can also be acquired until a context is done,
+// so that `SyncContext()` is bounded even when a write holds the lock.
+type contextMutex chan struct{}
+
+func newContextMutex() contextMutex {
+	return make(contextMutex, 1)
+}
+
+func (m contextMutex) Lock() {
+	m <- struct{}{}
+}
+
+func (m contextMutex) Unlock() {
+	<-m
+}
+
+// LockContext acquires the mutex, or returns a *SyncError when the context is
+// done first.
+func (m contextMutex) LockContext(ctx context.Context) error {
+	select {
+	case m <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return &SyncError{Err: ctx.Err()}
+	}
+}
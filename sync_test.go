@@ -0,0 +1,86 @@
+package zapdriver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// slowSyncer is a WriteSyncer whose Sync blocks until it is released.
+type slowSyncer struct {
+	release chan struct{}
+}
+
+func (s *slowSyncer) Write(p []byte) (int, error) { return len(p), nil }
+func (s *slowSyncer) Sync() error                 { <-s.release; return nil }
+
+func TestSyncContext(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(Async(16, BlockOnOverflow)))
+
+	logger.Info("hello")
+
+	require.NoError(t, SyncContext(context.Background(), logger))
+	assert.Equal(t, 1, logs.Len())
+}
+
+func TestSyncContext_QueueDeadline(t *testing.T) {
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	blocking := &blockingCore{Core: debugcore, release: make(chan struct{})}
+	logger := zap.New(blocking, WrapCore(Async(16, BlockOnOverflow)))
+
+	for i := 0; i < 3; i++ {
+		logger.Info("hello")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := SyncContext(ctx, logger)
+
+	var syncErr *SyncError
+	require.True(t, errors.As(err, &syncErr))
+	assert.Equal(t, 3, syncErr.Dropped)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	close(blocking.release)
+	require.NoError(t, SyncContext(context.Background(), logger))
+	assert.Equal(t, 3, logs.Len())
+}
+
+func TestSyncContext_SlowWriter(t *testing.T) {
+	w := &slowSyncer{release: make(chan struct{})}
+	defer close(w.release)
+
+	zcore := zapcore.NewCore(zapcore.NewJSONEncoder(NewProductionEncoderConfig()), w, zapcore.DebugLevel)
+	logger := zap.New(zcore, WrapCore())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := SyncContext(ctx, logger)
+
+	var syncErr *SyncError
+	require.True(t, errors.As(err, &syncErr))
+	assert.Zero(t, syncErr.Dropped)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestResilientWriter_SyncContext(t *testing.T) {
+	slow := &slowSyncer{release: make(chan struct{})}
+	defer close(slow.release)
+
+	w := NewResilientWriter(zapcore.AddSync(&flakyWriter{}), WriteFallback(slow))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.True(t, errors.Is(w.SyncContext(ctx), context.DeadlineExceeded))
+}
@@ -0,0 +1,286 @@
+package zapdriver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+const defaultLoggingEndpoint = "https://logging.googleapis.com/v2/entries:list"
+
+// tailer holds the configuration of `Tail()`.
+type tailer struct {
+	client   *http.Client
+	project  string
+	interval time.Duration
+	overlap  time.Duration
+	endpoint string
+}
+
+// zapdriver tail option to use `client` to call the Cloud Logging API. The
+// client is responsible for authenticating the requests, for example using
+// `golang.org/x/oauth2/google.DefaultClient`
+func TailClient(client *http.Client) func(*tailer) {
+	return func(t *tailer) {
+		t.client = client
+	}
+}
+
+// zapdriver tail option to read the logs of project `id`, instead of the
+// project set in the `GOOGLE_CLOUD_PROJECT` environment variable
+func TailProject(id string) func(*tailer) {
+	return func(t *tailer) {
+		t.project = id
+	}
+}
+
+// zapdriver tail option to poll for new entries every `interval`
+func TailInterval(interval time.Duration) func(*tailer) {
+	return func(t *tailer) {
+		t.interval = interval
+	}
+}
+
+// zapdriver tail option to look back `overlap` before the last entry received
+// at every poll, instead of 30 seconds, so the entries ingested late by Cloud
+// Logging are not missed
+func TailOverlap(overlap time.Duration) func(*tailer) {
+	return func(t *tailer) {
+		t.overlap = overlap
+	}
+}
+
+// Tail follows the entries matching the Logs Explorer `filter` written to Cloud
+// Logging from now on, and calls `fn` with each entry, in order, decoded into
+// the same typed model as `ParseEntry()`. It blocks until the context is
+// canceled, or the API returns an error.
+//
+// Entries are retrieved by polling the `entries.list` API. As entries can be
+// ingested after entries with a later timestamp, every poll looks back a while
+// before the last entry received (see `TailOverlap()`), and the entries already
+// received are skipped using their insert ID. The entries ingested late are
+// passed to `fn` when they are received, so they can be out of order.
+//
+// see: https://cloud.google.com/logging/docs/reference/v2/rest/v2/entries/list
+func Tail(ctx context.Context, filter string, fn func(ParsedEntry), options ...func(*tailer)) error {
+	t := &tailer{
+		client:   http.DefaultClient,
+		project:  os.Getenv("GOOGLE_CLOUD_PROJECT"),
+		interval: time.Second,
+		overlap:  30 * time.Second,
+		endpoint: defaultLoggingEndpoint,
+	}
+	for _, option := range options {
+		option(t)
+	}
+
+	if t.project == "" {
+		return errors.New("zapdriver: no project configured to tail logs from")
+	}
+
+	start := time.Now().UTC()
+	since := start
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	// seen holds the timestamps of the entries received within the overlap,
+	// by insert ID.
+	seen := map[string]time.Time{}
+
+	for {
+		entries, err := t.list(ctx, filter, since.Add(-t.overlap))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		last := since
+		for i := range entries {
+			if entries[i].Timestamp.Before(start) {
+				continue
+			}
+			if id := entries[i].insertID; id != "" {
+				if _, ok := seen[id]; ok {
+					continue
+				}
+				seen[id] = entries[i].Timestamp
+			} else if !entries[i].Timestamp.After(since) {
+				continue
+			}
+
+			if entries[i].Timestamp.After(last) {
+				last = entries[i].Timestamp
+			}
+
+			fn(entries[i].ParsedEntry)
+		}
+		since = last
+
+		for id, timestamp := range seen {
+			if timestamp.Before(since.Add(-t.overlap)) {
+				delete(seen, id)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// tailRequest is the body of an `entries.list` request.
+type tailRequest struct {
+	ResourceNames []string `json:"resourceNames"`
+	Filter        string   `json:"filter"`
+	OrderBy       string   `json:"orderBy"`
+	PageSize      int      `json:"pageSize"`
+	PageToken     string   `json:"pageToken,omitempty"`
+}
+
+// tailResponse is the body of an `entries.list` response.
+type tailResponse struct {
+	Entries       []logEntry `json:"entries"`
+	NextPageToken string     `json:"nextPageToken"`
+}
+
+// logEntry is a LogEntry as returned by the Cloud Logging API.
+//
+// see: https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry
+type logEntry struct {
+	InsertID       string                 `json:"insertId"`
+	Timestamp      time.Time              `json:"timestamp"`
+	Severity       Severity               `json:"severity"`
+	TextPayload    string                 `json:"textPayload"`
+	JSONPayload    map[string]interface{} `json:"jsonPayload"`
+	Labels         map[string]string      `json:"labels"`
+	SourceLocation *ParsedSourceLocation  `json:"sourceLocation"`
+	Operation      *ParsedOperation       `json:"operation"`
+	HTTPRequest    *HTTPPayload           `json:"httpRequest"`
+	Trace          string                 `json:"trace"`
+	SpanID         string                 `json:"spanId"`
+	TraceSampled   bool                   `json:"traceSampled"`
+}
+
+// listedEntry is an entry returned by the API, with its insert ID.
+type listedEntry struct {
+	ParsedEntry
+
+	insertID string
+}
+
+// list returns all the entries matching the filter, logged from `since`.
+func (t *tailer) list(ctx context.Context, filter string, since time.Time) ([]listedEntry, error) {
+	req := tailRequest{
+		ResourceNames: []string{"projects/" + t.project},
+		Filter:        fmt.Sprintf("timestamp >= %q", since.Format(time.RFC3339Nano)),
+		OrderBy:       "timestamp asc",
+		PageSize:      1000,
+	}
+	if filter != "" {
+		req.Filter = "(" + filter + ") AND " + req.Filter
+	}
+
+	entries := []listedEntry{}
+	for {
+		res, err := t.call(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range res.Entries {
+			entry, err := res.Entries[i].parsed()
+			if err != nil {
+				return nil, err
+			}
+
+			entries = append(entries, listedEntry{ParsedEntry: entry, insertID: res.Entries[i].InsertID})
+		}
+
+		if res.NextPageToken == "" {
+			return entries, nil
+		}
+
+		req.PageToken = res.NextPageToken
+	}
+}
+
+func (t *tailer) call(ctx context.Context, req tailRequest) (*tailResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", "application/json")
+
+	res, err := t.client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close() // nolint: errcheck
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("zapdriver: listing log entries failed: %s", res.Status)
+	}
+
+	out := &tailResponse{}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// parsed converts the API LogEntry into the typed model of `ParseEntry()`. The
+// JSON payload is parsed like a log line, so the fields left in the payload by
+// Cloud Logging (such as the message, the service context or the error
+// context) are decoded as well.
+func (e logEntry) parsed() (ParsedEntry, error) {
+	entry := ParsedEntry{Payload: map[string]interface{}{}}
+	if len(e.JSONPayload) > 0 {
+		line, err := json.Marshal(e.JSONPayload)
+		if err != nil {
+			return ParsedEntry{}, err
+		}
+
+		if entry, err = ParseEntry(line); err != nil {
+			return ParsedEntry{}, err
+		}
+	}
+
+	entry.Timestamp = e.Timestamp
+	entry.Severity = e.Severity
+	if entry.Message == "" {
+		entry.Message = e.TextPayload
+	}
+	if e.Labels != nil {
+		entry.Labels = e.Labels
+	}
+	if e.SourceLocation != nil {
+		entry.SourceLocation = e.SourceLocation
+	}
+	if e.Operation != nil {
+		entry.Operation = e.Operation
+	}
+	if e.HTTPRequest != nil {
+		entry.HTTPRequest = e.HTTPRequest
+	}
+	if e.Trace != "" {
+		entry.Trace, entry.SpanID, entry.TraceSampled = e.Trace, e.SpanID, e.TraceSampled
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,144 @@
+package zapdriver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTail(t *testing.T) {
+	var requests []tailRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := tailRequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req)
+
+		if len(requests) > 1 {
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"entries": [{
+			"timestamp": "2030-01-02T03:04:05.123Z",
+			"severity": "ERROR",
+			"insertId": "a",
+			"jsonPayload": {
+				"message": "hello",
+				"error": "boom",
+				"logger": "api",
+				"caller": "main.go:12",
+				"stacktrace": "main.main",
+				"serviceContext": {"service": "api", "version": "v1"},
+				"context": {"reportLocation": {"filePath": "main.go", "lineNumber": 12, "functionName": "main.main"}}
+			},
+			"labels": {"one": "world"},
+			"sourceLocation": {"file": "main.go", "line": "12", "function": "main.main"},
+			"trace": "projects/my-project/traces/abc",
+			"spanId": "0"
+		}]}`))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var got []ParsedEntry
+
+	err := Tail(ctx, `severity >= ERROR`, func(entry ParsedEntry) {
+		got = append(got, entry)
+		cancel()
+	},
+		TailProject("my-project"),
+		TailClient(server.Client()),
+		TailInterval(time.Millisecond),
+		func(t *tailer) { t.endpoint = server.URL },
+	)
+	require.NoError(t, err)
+
+	require.Len(t, got, 1)
+	assert.Equal(t, SeverityError, got[0].Severity)
+	assert.Equal(t, "hello", got[0].Message)
+	assert.Equal(t, map[string]string{"one": "world"}, got[0].Labels)
+	assert.Equal(t, "12", got[0].SourceLocation.Line)
+	assert.Equal(t, "projects/my-project/traces/abc", got[0].Trace)
+	assert.Equal(t, map[string]interface{}{"error": "boom"}, got[0].Payload)
+	assert.Equal(t, "api", got[0].Logger)
+	assert.Equal(t, "main.go:12", got[0].Caller)
+	assert.Equal(t, "main.main", got[0].Stacktrace)
+	require.NotNil(t, got[0].ServiceContext)
+	assert.Equal(t, "v1", got[0].ServiceContext.Version)
+	require.NotNil(t, got[0].ErrorContext)
+	assert.Equal(t, 12, got[0].ErrorContext.ReportLocation.Line)
+
+	require.NotEmpty(t, requests)
+	assert.Equal(t, []string{"projects/my-project"}, requests[0].ResourceNames)
+	assert.Contains(t, requests[0].Filter, "(severity >= ERROR) AND timestamp >= ")
+}
+
+func TestTail_LateEntries(t *testing.T) {
+	base := time.Now().UTC().Add(time.Hour)
+	entry := func(id string, timestamp time.Time) string {
+		return `{"insertId": "` + id + `", "timestamp": "` + timestamp.Format(time.RFC3339Nano) + `", "textPayload": "` + id + `"}`
+	}
+
+	var requests []tailRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := tailRequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req)
+
+		switch len(requests) {
+		case 1:
+			_, _ = w.Write([]byte(`{"entries": [` + entry("a", base.Add(2*time.Second)) + `]}`))
+		case 2:
+			// "b" was ingested after "a", with an earlier timestamp.
+			_, _ = w.Write([]byte(`{"entries": [` + entry("b", base.Add(time.Second)) + `, ` + entry("a", base.Add(2*time.Second)) + `]}`))
+		default:
+			_, _ = w.Write([]byte(`{"entries": [` + entry("c", base.Add(3*time.Second)) + `]}`))
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var got []string
+
+	err := Tail(ctx, "", func(entry ParsedEntry) {
+		got = append(got, entry.Message)
+		if len(got) == 3 {
+			cancel()
+		}
+	},
+		TailProject("my-project"),
+		TailClient(server.Client()),
+		TailInterval(time.Millisecond),
+		TailOverlap(10*time.Second),
+		func(t *tailer) { t.endpoint = server.URL },
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"a", "b", "c"}, got)
+	require.Len(t, requests, 3)
+	assert.Equal(t, requests[2].Filter, requests[1].Filter, "the overlap starts before the last entry")
+	assert.Equal(t, `timestamp >= "`+base.Add(-8*time.Second).Format(time.RFC3339Nano)+`"`, requests[1].Filter)
+}
+
+func TestTail_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := Tail(context.Background(), "", func(ParsedEntry) {},
+		TailProject("my-project"),
+		func(t *tailer) { t.endpoint = server.URL },
+	)
+	assert.Error(t, err)
+
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+	err = Tail(context.Background(), "", func(ParsedEntry) {})
+	assert.Error(t, err)
+}
@@ -0,0 +1,252 @@
+package zapdriver
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// tailDroppedMessage is the message of the entries counting the entries
+// dropped from a full tail buffer.
+const tailDroppedMessage = "Tail buffer full, entries dropped."
+
+// tailBuffer holds the configuration of the tail buffer middleware.
+type tailBuffer struct {
+	latency time.Duration
+	level   zapcore.Level
+	size    int
+	now     func() time.Time
+}
+
+// zapdriver tail buffer option to also write the buffered entries of the
+// requests taking longer than `threshold`
+func TailBufferLatency(threshold time.Duration) func(*tailBuffer) {
+	return func(t *tailBuffer) {
+		t.latency = threshold
+	}
+}
+
+// zapdriver tail buffer option to buffer the entries up to `level`, instead of
+// the Debug and Info entries
+func TailBufferLevel(level zapcore.Level) func(*tailBuffer) {
+	return func(t *tailBuffer) {
+		t.level = level
+	}
+}
+
+// zapdriver tail buffer option to buffer at most `n` entries per request,
+// instead of 1000. The oldest entries are dropped from a full buffer
+func TailBufferSize(n int) func(*tailBuffer) {
+	return func(t *tailBuffer) {
+		t.size = n
+	}
+}
+
+// TailBuffer returns a HTTP middleware which buffers the Debug and Info entries
+// of every request, logged using the logger of the request context (see
+// `FromContext()`), instead of writing them. The buffered entries are written,
+// with their original timestamps, only if the request fails (an entry with the
+// Error level or above is logged, or the response status is 5xx), or takes
+// longer than the latency threshold (see `TailBufferLatency()`). They are
+// discarded otherwise.
+//
+// Debug entries are buffered even if the logger level is higher, so failed
+// requests come with detailed context, at near-zero steady-state volume. Once
+// the buffer is written, the following entries of the request are written
+// directly. The middleware should be installed after the `LoggerMiddleware`:
+//
+//	handler := zapdriver.LoggerMiddleware(logger)(zapdriver.TailBuffer()(mux))
+func TailBuffer(options ...func(*tailBuffer)) func(http.Handler) http.Handler {
+	t := &tailBuffer{
+		level: zapcore.InfoLevel,
+		size:  1000,
+		now:   time.Now,
+	}
+	for _, option := range options {
+		option(t)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := t.now()
+
+			tail := &tailEntries{config: t, mutex: &sync.Mutex{}}
+			logger := FromContext(r.Context()).WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+				return &tailCore{Core: c, tail: tail}
+			}))
+
+			sw := &statusWriter{ResponseWriter: w}
+
+			// The entries are also written when the handler panics, as the
+			// request failed.
+			completed := false
+			defer func() {
+				status := atomic.LoadInt32(&sw.status)
+				if !completed || status >= http.StatusInternalServerError || (t.latency > 0 && t.now().Sub(start) > t.latency) {
+					_ = tail.flush()
+					return
+				}
+
+				tail.discard()
+			}()
+
+			next.ServeHTTP(sw, r.WithContext(WithLogger(r.Context(), logger)))
+			completed = true
+		})
+	}
+}
+
+// tailEntry is a buffered entry, with the core to write it to.
+type tailEntry struct {
+	core   zapcore.Core
+	ent    zapcore.Entry
+	fields []zapcore.Field
+}
+
+// tailEntries holds the buffered entries of a request. It is shared between
+// the core and all the cores derived from it using `With()`.
+type tailEntries struct {
+	config *tailBuffer
+
+	mutex   *sync.Mutex
+	entries []tailEntry
+	dropped int
+
+	// flushed is true once the entries have been written or discarded, after
+	// which entries are written directly.
+	flushed bool
+}
+
+// add buffers the entry, and returns false if it should be written directly.
+func (t *tailEntries) add(e tailEntry) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.flushed {
+		return false
+	}
+
+	if t.config.size <= 0 {
+		t.dropped++
+		return true
+	}
+
+	if len(t.entries) >= t.config.size {
+		t.entries = t.entries[1:]
+		t.dropped++
+	}
+
+	t.entries = append(t.entries, e)
+
+	return true
+}
+
+// flush writes the buffered entries, and stops buffering.
+func (t *tailEntries) flush() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.flushed {
+		return nil
+	}
+
+	entries, dropped := t.entries, t.dropped
+	t.entries, t.flushed = nil, true
+
+	var err error
+	if dropped > 0 && len(entries) > 0 {
+		first := entries[0]
+		note := zapcore.Entry{
+			Level:      zapcore.WarnLevel,
+			Time:       first.ent.Time,
+			LoggerName: first.ent.LoggerName,
+			Message:    tailDroppedMessage,
+		}
+
+		err = first.core.Write(note, []zapcore.Field{zap.Int("droppedEntries", dropped)})
+	}
+
+	for i := range entries {
+		if werr := entries[i].core.Write(entries[i].ent, entries[i].fields); werr != nil && err == nil {
+			err = werr
+		}
+	}
+
+	return err
+}
+
+// discard drops the buffered entries, and stops buffering.
+func (t *tailEntries) discard() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.entries, t.flushed = nil, true
+}
+
+// tailCore buffers the entries of a request.
+type tailCore struct {
+	zapcore.Core
+
+	tail *tailEntries
+}
+
+// With implements zapcore.Core interface.
+func (c *tailCore) With(fields []zapcore.Field) zapcore.Core {
+	return &tailCore{Core: c.Core.With(fields), tail: c.tail}
+}
+
+// Enabled implements zapcore.LevelEnabler interface.
+func (c *tailCore) Enabled(level zapcore.Level) bool {
+	return level <= c.tail.config.level || c.Core.Enabled(level)
+}
+
+// Check implements zapcore.Core interface. Buffered entries are checked
+// regardless of the level of the wrapped core, and error entries flush the
+// buffer before they are written.
+func (c *tailCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level <= c.tail.config.level {
+		return ce.AddCore(ent, c)
+	}
+
+	if zapcore.ErrorLevel.Enabled(ent.Level) {
+		ce = ce.AddCore(ent, &tailFlusher{tail: c.tail})
+	}
+
+	return c.Core.Check(ent, ce)
+}
+
+// Write implements zapcore.Core interface. Once the buffer is written, the
+// entries below the level of the wrapped core are dropped, as they were only
+// checked to be buffered.
+func (c *tailCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if c.tail.add(tailEntry{core: c.Core, ent: ent, fields: append([]zapcore.Field{}, fields...)}) {
+		return nil
+	}
+
+	if !c.Core.Enabled(ent.Level) {
+		return nil
+	}
+
+	return c.Core.Write(ent, fields)
+}
+
+// tailFlusher flushes the buffered entries when an error entry is written.
+type tailFlusher struct {
+	tail *tailEntries
+}
+
+func (f *tailFlusher) Enabled(zapcore.Level) bool { return true }
+
+func (f *tailFlusher) With([]zapcore.Field) zapcore.Core { return f }
+
+func (f *tailFlusher) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, f)
+}
+
+func (f *tailFlusher) Write(zapcore.Entry, []zapcore.Field) error { return f.tail.flush() }
+
+func (f *tailFlusher) Sync() error { return nil }
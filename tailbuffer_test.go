@@ -0,0 +1,150 @@
+package zapdriver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// serveTail serves a request with the tail buffer middleware, using a logger
+// with the Info level.
+func serveTail(t *testing.T, handler http.HandlerFunc, options ...func(*tailBuffer)) *observer.ObservedLogs {
+	t.Helper()
+
+	infocore, logs := observer.New(zapcore.InfoLevel)
+
+	req := httptest.NewRequest("GET", "/path", nil)
+	req = req.WithContext(WithLogger(req.Context(), zap.New(infocore)))
+	TailBuffer(options...)(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+	return logs
+}
+
+func tailMessages(logs *observer.ObservedLogs) []string {
+	out := []string{}
+	for _, e := range logs.All() {
+		out = append(out, e.Message)
+	}
+
+	return out
+}
+
+func TestTailBuffer_Discard(t *testing.T) {
+	t.Parallel()
+
+	logs := serveTail(t, func(w http.ResponseWriter, r *http.Request) {
+		logger := FromContext(r.Context())
+		logger.Debug("debug")
+		logger.Info("info")
+		logger.Warn("warn")
+	})
+
+	assert.Equal(t, []string{"warn"}, tailMessages(logs))
+}
+
+func TestTailBuffer_Error(t *testing.T) {
+	t.Parallel()
+
+	var logged time.Time
+	logs := serveTail(t, func(w http.ResponseWriter, r *http.Request) {
+		logger := FromContext(r.Context()).With(zap.String("hello", "world"))
+		logger.Debug("debug")
+		logged = time.Now()
+		time.Sleep(time.Millisecond)
+
+		logger.Error("error")
+		logger.Debug("after")
+		logger.Info("info")
+	})
+
+	assert.Equal(t, []string{"debug", "error", "info"}, tailMessages(logs), "entries below the logger level are dropped once flushed")
+
+	entry := logs.All()[0]
+	assert.Equal(t, zapcore.DebugLevel, entry.Level)
+	assert.True(t, entry.Time.Before(logged))
+	assert.Equal(t, "world", entry.ContextMap()["hello"])
+}
+
+func TestTailBuffer_Status(t *testing.T) {
+	t.Parallel()
+
+	logs := serveTail(t, func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Info("info")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	assert.Equal(t, []string{"info"}, tailMessages(logs))
+
+	logs = serveTail(t, func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Info("info")
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	assert.Empty(t, tailMessages(logs))
+}
+
+func TestTailBuffer_Panic(t *testing.T) {
+	t.Parallel()
+
+	infocore, logs := observer.New(zapcore.InfoLevel)
+	handler := TailBuffer()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Info("info")
+		panic("boom")
+	}))
+
+	req := httptest.NewRequest("GET", "/path", nil)
+	req = req.WithContext(WithLogger(req.Context(), zap.New(infocore)))
+	assert.PanicsWithValue(t, "boom", func() { handler.ServeHTTP(httptest.NewRecorder(), req) })
+
+	assert.Equal(t, []string{"info"}, tailMessages(logs), "the entries of panicking requests are written")
+}
+
+func TestTailBuffer_Latency(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func(t *tailBuffer) {
+		t.now = func() time.Time { return now }
+	}
+
+	logs := serveTail(t, func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Info("info")
+		now = now.Add(2 * time.Second)
+	}, TailBufferLatency(time.Second), clock)
+
+	assert.Equal(t, []string{"info"}, tailMessages(logs))
+}
+
+func TestTailBuffer_Size(t *testing.T) {
+	t.Parallel()
+
+	logs := serveTail(t, func(w http.ResponseWriter, r *http.Request) {
+		logger := FromContext(r.Context())
+		logger.Info("one")
+		logger.Info("two")
+		logger.Info("three")
+		logger.Error("error")
+	}, TailBufferSize(2))
+
+	require.Equal(t, []string{tailDroppedMessage, "two", "three", "error"}, tailMessages(logs))
+	assert.Equal(t, int64(1), logs.All()[0].ContextMap()["droppedEntries"])
+}
+
+func TestTailBuffer_Level(t *testing.T) {
+	t.Parallel()
+
+	logs := serveTail(t, func(w http.ResponseWriter, r *http.Request) {
+		logger := FromContext(r.Context())
+		logger.Debug("debug")
+		logger.Info("info")
+	}, TailBufferLevel(zapcore.DebugLevel))
+
+	assert.Equal(t, []string{"info"}, tailMessages(logs))
+}
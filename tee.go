@@ -0,0 +1,162 @@
+package zapdriver
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Format is the representation of the entries written to the secondary core of
+// `Tee()`.
+type Format int
+
+const (
+	// FormatJSON writes the fields of the entries as-is, except for the special
+	// Stackdriver keys, which lose their `logging.googleapis.com/` prefix
+	// (e.g. `labels` and `trace`). This is the default.
+	FormatJSON Format = iota
+
+	// FormatLogfmt writes the same fields as FormatJSON, with the objects
+	// flattened into dot-separated keys holding plain values (e.g.
+	// `labels.app`), for encoders which don't nest objects, such as logfmt or
+	// console encoders.
+	FormatLogfmt
+)
+
+// Tee returns a core duplicating the entries to the `primary` core, writing
+// Stackdriver-formatted entries (e.g. to standard output, for Cloud Logging),
+// and to the `secondary` core, writing the entries in `secondaryFormat` (e.g.
+// to a file or socket, for on-host debugging). Wrapped with the zapdriver
+// core, the entries are enriched (labels, trace context, source location, etc.)
+// once for both cores:
+//
+//	logger := zap.New(zapdriver.Tee(stdout, file, zapdriver.FormatLogfmt), zapdriver.WrapCore())
+func Tee(primary, secondary zapcore.Core, secondaryFormat Format) zapcore.Core {
+	return &teeCore{primary: primary, secondary: &plainCore{Core: secondary, format: secondaryFormat}}
+}
+
+// teeCore duplicates the entries to two cores. Unlike `zapcore.NewTee()`, the
+// level of the entries is checked against each core in Write, as the zapdriver
+// core wrapping it only checks the entries against the combined level.
+type teeCore struct {
+	primary   zapcore.Core
+	secondary zapcore.Core
+}
+
+// Enabled returns true if any of the cores is enabled at the level.
+func (c *teeCore) Enabled(level zapcore.Level) bool {
+	return c.primary.Enabled(level) || c.secondary.Enabled(level)
+}
+
+// With adds structured context to both cores.
+func (c *teeCore) With(fields []zapcore.Field) zapcore.Core {
+	return &teeCore{primary: c.primary.With(fields), secondary: c.secondary.With(fields)}
+}
+
+// Check adds the cores enabled for the entry to the CheckedEntry.
+func (c *teeCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	ce = c.primary.Check(ent, ce)
+
+	return c.secondary.Check(ent, ce)
+}
+
+// Write writes the entry to the cores enabled at its level.
+func (c *teeCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	var err error
+	if c.primary.Enabled(ent.Level) {
+		err = multierr.Append(err, c.primary.Write(ent, fields))
+	}
+	if c.secondary.Enabled(ent.Level) {
+		err = multierr.Append(err, c.secondary.Write(ent, fields))
+	}
+
+	return err
+}
+
+// Sync flushes both cores.
+func (c *teeCore) Sync() error {
+	return multierr.Append(c.primary.Sync(), c.secondary.Sync())
+}
+
+// plainCore rewrites the special Stackdriver fields of the entries into plain
+// fields, before writing them to the wrapped core.
+type plainCore struct {
+	zapcore.Core
+
+	format Format
+}
+
+// With adds structured context to the Core.
+func (c *plainCore) With(fields []zapcore.Field) zapcore.Core {
+	return &plainCore{Core: c.Core.With(plainFields(fields, c.format)), format: c.format}
+}
+
+// Check determines whether the supplied Entry should be logged.
+func (c *plainCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+// Write writes the entry, with plain fields, to the wrapped core.
+func (c *plainCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, plainFields(fields, c.format))
+}
+
+// plainFields returns the fields with plain keys, flattened using the
+// FormatLogfmt format.
+func plainFields(fields []zapcore.Field, format Format) []zapcore.Field {
+	out := make([]zapcore.Field, 0, len(fields))
+	for _, field := range fields {
+		field.Key = strings.TrimPrefix(field.Key, googleKeyPrefix)
+
+		if format != FormatLogfmt {
+			out = append(out, field)
+			continue
+		}
+
+		switch field.Type {
+		case zapcore.ObjectMarshalerType, zapcore.InlineMarshalerType:
+			enc := zapcore.NewMapObjectEncoder()
+			field.AddTo(enc)
+
+			out = appendFlattened(out, "", enc.Fields)
+		default:
+			out = append(out, field)
+		}
+	}
+
+	return out
+}
+
+// appendFlattened appends a field for each plain value of the object, with the
+// dot-separated path of the value as key, in the order of the keys.
+func appendFlattened(fields []zapcore.Field, prefix string, object map[string]interface{}) []zapcore.Field {
+	keys := make([]string, 0, len(object))
+	for k := range object {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if nested, ok := object[k].(map[string]interface{}); ok {
+			fields = appendFlattened(fields, prefix+k+".", nested)
+			continue
+		}
+
+		fields = append(fields, zap.Any(prefix+k, object[k]))
+	}
+
+	return fields
+}
+
+// SyncContext implements the ContextSyncer interface.
+func (c *teeCore) SyncContext(ctx context.Context) error {
+	return multierr.Append(syncContext(ctx, c.primary), syncContext(ctx, c.secondary))
+}
@@ -0,0 +1,68 @@
+package zapdriver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTee(t *testing.T) {
+	t.Parallel()
+
+	primary, primaryLogs := observer.New(zapcore.DebugLevel)
+	secondary, secondaryLogs := observer.New(zapcore.DebugLevel)
+
+	logger := zap.New(Tee(primary, secondary, FormatJSON), WrapCore()).With(Label("app", "api"))
+	logger.Info("hello", TraceContext("105445aa7843bc8bf206b120001000", "0", true, "my-project")...)
+
+	require.Equal(t, 1, primaryLogs.Len())
+	require.Equal(t, 1, secondaryLogs.Len())
+
+	assert.Equal(t, "projects/my-project/traces/105445aa7843bc8bf206b120001000", primaryLogs.All()[0].ContextMap()[traceKey])
+	assert.Equal(t, map[string]interface{}{"app": "api"}, primaryLogs.All()[0].ContextMap()[labelsKey])
+
+	fields := secondaryLogs.All()[0].ContextMap()
+	assert.Equal(t, "projects/my-project/traces/105445aa7843bc8bf206b120001000", fields["trace"])
+	assert.Equal(t, "0", fields["spanId"])
+	assert.Equal(t, true, fields["trace_sampled"])
+	assert.Equal(t, map[string]interface{}{"app": "api"}, fields["labels"])
+	assert.NotContains(t, fields, labelsKey)
+}
+
+func TestTee_Logfmt(t *testing.T) {
+	t.Parallel()
+
+	primary, primaryLogs := observer.New(zapcore.DebugLevel)
+	secondary, secondaryLogs := observer.New(zapcore.InfoLevel)
+
+	logger := zap.New(Tee(primary, secondary, FormatLogfmt), WrapCore())
+	logger = logger.With(zap.Object("db", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		enc.AddString("name", "users")
+		return nil
+	})))
+
+	logger.Debug("debug only")
+	logger.Info("hello", Label("app", "api"), Label("env", "prod"), HTTP(&HTTPPayload{RequestMethod: "GET", Status: 200}))
+
+	require.Equal(t, 2, primaryLogs.Len())
+	require.Equal(t, 1, secondaryLogs.Len())
+
+	keys := []string{}
+	for _, field := range secondaryLogs.All()[0].Context {
+		keys = append(keys, field.Key)
+	}
+
+	assert.Contains(t, keys, "db.name")
+	assert.Contains(t, keys, "httpRequest.requestMethod")
+	assert.Contains(t, keys, "httpRequest.status")
+
+	fields := secondaryLogs.All()[0].ContextMap()
+	assert.Equal(t, "users", fields["db.name"])
+	assert.Equal(t, "api", fields["labels.app"])
+	assert.Equal(t, "prod", fields["labels.env"])
+	assert.Equal(t, "GET", fields["httpRequest.requestMethod"])
+}
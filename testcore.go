@@ -0,0 +1,92 @@
+package zapdriver
+
+import (
+	"bytes"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// NewTestCore returns a zapdriver core, configured with `options`, that
+// captures all log entries in memory instead of writing them out. The captured
+// entries are available through the returned TestEntries, after all zapdriver
+// transformations (label merging, service context, error report injection) and
+// JSON encoding have been applied, so tests can assert on the exact payload
+// Cloud Logging would receive. Labels are encoded in key order (see
+// `SortedLabels()`), so golden files of the lines are stable.
+func NewTestCore(options ...func(*core)) (zapcore.Core, *TestEntries) {
+	entries := &TestEntries{mutex: &sync.Mutex{}}
+
+	c := zapcore.NewCore(
+		zapcore.NewJSONEncoder(NewProductionEncoderConfig()),
+		entries,
+		zapcore.DebugLevel,
+	)
+
+	return newCore(c, append([]func(*core){SortedLabels(true)}, options...)), entries
+}
+
+// TestEntries holds the entries captured by a test core.
+type TestEntries struct {
+	mutex   *sync.Mutex
+	lines   [][]byte
+	entries []ParsedEntry
+}
+
+// Write implements zapcore.WriteSyncer interface. Each write contains a single
+// encoded entry.
+func (t *TestEntries) Write(p []byte) (int, error) {
+	line := bytes.TrimSpace(append([]byte{}, p...))
+
+	entry, err := ParseEntry(line)
+	if err != nil {
+		return 0, err
+	}
+
+	t.mutex.Lock()
+	t.lines = append(t.lines, line)
+	t.entries = append(t.entries, entry)
+	t.mutex.Unlock()
+
+	return len(p), nil
+}
+
+// Sync implements zapcore.WriteSyncer interface.
+func (t *TestEntries) Sync() error {
+	return nil
+}
+
+// Len returns the number of captured entries.
+func (t *TestEntries) Len() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return len(t.entries)
+}
+
+// All returns all the captured entries, in the order they were written.
+func (t *TestEntries) All() []ParsedEntry {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return append([]ParsedEntry{}, t.entries...)
+}
+
+// Lines returns the raw JSON lines of all the captured entries.
+func (t *TestEntries) Lines() [][]byte {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return append([][]byte{}, t.lines...)
+}
+
+// TakeAll returns all the captured entries, and clears them.
+func (t *TestEntries) TakeAll() []ParsedEntry {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	entries := t.entries
+	t.entries, t.lines = nil, nil
+
+	return entries
+}
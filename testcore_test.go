@@ -0,0 +1,72 @@
+package zapdriver_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/gridwise/zapdriver"
+)
+
+func TestNewTestCore(t *testing.T) {
+	t.Parallel()
+
+	core, entries := zapdriver.NewTestCore(
+		zapdriver.ReportAllErrors(true),
+		zapdriver.ServiceName("my service"),
+	)
+	logger := zap.New(core, zap.AddCaller()).With(zapdriver.Label("one", "world"))
+
+	logger.Info("hello", zapdriver.Label("two", "worlds"), zap.Int("count", 1))
+	logger.Error("failed")
+
+	require.Equal(t, 2, entries.Len())
+	all := entries.All()
+
+	assert.Equal(t, "hello", all[0].Message)
+	assert.Equal(t, zapdriver.SeverityInfo, all[0].Severity)
+	assert.Equal(t, map[string]string{"one": "world", "two": "worlds"}, all[0].Labels)
+	assert.Equal(t, map[string]interface{}{"count": float64(1)}, all[0].Payload)
+	assert.Equal(t, "my service", all[0].ServiceContext.Name)
+	assert.Nil(t, all[0].ErrorContext)
+	assert.NotNil(t, all[0].SourceLocation)
+
+	assert.Equal(t, zapdriver.SeverityError, all[1].Severity)
+	assert.Equal(t, map[string]string{"one": "world"}, all[1].Labels)
+	assert.NotNil(t, all[1].ErrorContext)
+
+	assert.Len(t, entries.Lines(), 2)
+	assert.Len(t, entries.TakeAll(), 2)
+	assert.Zero(t, entries.Len())
+}
+
+func TestNewTestCore_SortedLabels(t *testing.T) {
+	t.Parallel()
+
+	core, entries := zapdriver.NewTestCore()
+	logger := zap.New(core)
+
+	for i := 0; i < 10; i++ {
+		logger.Info("hello", zapdriver.Label("c", "3"), zapdriver.Label("a", "1"), zapdriver.Label("b", "2"))
+	}
+
+	for _, line := range entries.Lines() {
+		assert.Contains(t, string(line), `"logging.googleapis.com/labels":{"a":"1","b":"2","c":"3"}`)
+	}
+}
+
+func TestNewTestCore_ServiceLabels(t *testing.T) {
+	t.Parallel()
+
+	core, entries := zapdriver.NewTestCore(
+		zapdriver.ServiceName("my-service"),
+		zapdriver.ServiceContextLocation(zapdriver.ServiceInLabels),
+	)
+	zap.New(core).Info("hello")
+
+	require.Equal(t, 1, entries.Len())
+	assert.Equal(t, map[string]string{"service_name": "my-service"}, entries.All()[0].Labels)
+	assert.Nil(t, entries.All()[0].ServiceContext)
+}
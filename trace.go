@@ -2,8 +2,12 @@ package zapdriver
 
 import (
 	"fmt"
+	"os"
+	"strings"
+	"sync"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 const (
@@ -14,11 +18,121 @@ const (
 
 // TraceContext adds the correct Stackdriver "trace", "span", "trace_sampled fields
 //
+// The project name is optional. When omitted (or empty), the project ID is
+// detected using `ProjectID()`.
+//
 // see: https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry
-func TraceContext(trace string, spanId string, sampled bool, projectName string) []zap.Field {
+func TraceContext(trace string, spanId string, sampled bool, projectName ...string) []zap.Field {
+	var project string
+	if len(projectName) > 0 {
+		project = projectName[0]
+	}
+	if project == "" {
+		project = ProjectID()
+	}
+
 	return []zap.Field{
-		zap.String(traceKey, fmt.Sprintf("projects/%s/traces/%s", projectName, trace)),
+		zap.String(traceKey, traceName(project, trace)),
 		zap.String(spanKey, spanId),
 		zap.Bool(traceSampledKey, sampled),
 	}
 }
+
+// TraceContextWithProject adds the trace context fields, with the trace
+// resource name referencing the project `projectID`. Use it when the trace
+// originates from a different project than the one the process runs in, for
+// example when logs of several projects are aggregated into a single sink.
+func TraceContextWithProject(projectID, traceID, spanID string, sampled bool) []zap.Field {
+	return []zap.Field{
+		zap.String(traceKey, traceName(projectID, traceID)),
+		zap.String(spanKey, spanID),
+		zap.Bool(traceSampledKey, sampled),
+	}
+}
+
+// zapdriver core option to qualify trace IDs without a project (such as those
+// added by `TraceContext()` when the project cannot be detected) with the
+// project `projectID`
+func TraceProject(projectID string) func(*core) {
+	return func(c *core) {
+		c.config.TraceProject = projectID
+	}
+}
+
+// detectTraceProject is the core option of the presets, qualifying trace IDs
+// with the project detected using `ProjectID()`, which queries the metadata
+// server when the first trace is qualified rather than when building the core.
+func detectTraceProject() func(*core) {
+	return func(c *core) {
+		c.config.DetectTraceProject = true
+	}
+}
+
+// traceProject returns the project qualifying the trace IDs without a project,
+// or an empty string if they are not qualified.
+func (c *core) traceProject() string {
+	if c.config.TraceProject == "" && c.config.DetectTraceProject {
+		return ProjectID()
+	}
+
+	return c.config.TraceProject
+}
+
+// qualifyTraces rewrites the trace fields holding a bare trace ID to the trace
+// resource name in `project`. The fields are copied before being modified.
+func qualifyTraces(fields []zapcore.Field, project string) []zapcore.Field {
+	copied := false
+	for i := range fields {
+		if fields[i].Key != traceKey || fields[i].Type != zapcore.StringType ||
+			fields[i].String == "" || strings.HasPrefix(fields[i].String, "projects/") {
+			continue
+		}
+
+		if !copied {
+			fields = append([]zapcore.Field{}, fields...)
+			copied = true
+		}
+
+		fields[i].String = traceName(project, fields[i].String)
+	}
+
+	return fields
+}
+
+// traceName returns the resource name of the trace. The trace ID is returned
+// as-is when the project is unknown.
+func traceName(project, trace string) string {
+	if project == "" {
+		return trace
+	}
+
+	return fmt.Sprintf("projects/%s/traces/%s", project, trace)
+}
+
+// ProjectID returns the ID of the GCP project the process runs in. It is read
+// from the `GOOGLE_CLOUD_PROJECT` environment variable when set, or retrieved
+// from the metadata server otherwise. The result is cached after the first
+// call. An empty string is returned if the project cannot be detected.
+func ProjectID() string {
+	return detectedProject.get()
+}
+
+// projectDetector lazily detects and caches the project ID.
+type projectDetector struct {
+	once sync.Once
+	id   string
+}
+
+var detectedProject = &projectDetector{}
+
+func (d *projectDetector) get() string {
+	d.once.Do(func() {
+		if d.id = os.Getenv("GOOGLE_CLOUD_PROJECT"); d.id != "" {
+			return
+		}
+
+		d.id, _ = metadataValue("project/project-id")
+	})
+
+	return d.id
+}
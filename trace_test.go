@@ -1,10 +1,17 @@
 package zapdriver
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestTraceContext(t *testing.T) {
@@ -17,3 +24,94 @@ func TestTraceContext(t *testing.T) {
 		zap.Bool(traceSampledKey, true),
 	})
 }
+
+func TestTraceContext_DetectedProject(t *testing.T) {
+	defer func(d *projectDetector) { detectedProject = d }(detectedProject)
+	detectedProject = &projectDetector{}
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "detected-project")
+
+	fields := TraceContext("105445aa7843bc8bf206b120001000", "0", true)
+	assert.Equal(t, zap.String(traceKey, "projects/detected-project/traces/105445aa7843bc8bf206b120001000"), fields[0])
+
+	fields = TraceContext("105445aa7843bc8bf206b120001000", "0", true, "")
+	assert.Equal(t, zap.String(traceKey, "projects/detected-project/traces/105445aa7843bc8bf206b120001000"), fields[0])
+}
+
+func TestProjectID(t *testing.T) {
+	defer func(d *projectDetector) { detectedProject = d }(detectedProject)
+
+	detectedProject = &projectDetector{}
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+	newMetadataServer(t, map[string]string{"project/project-id": "metadata-project"})
+
+	assert.Equal(t, "metadata-project", ProjectID())
+
+	// The result is cached.
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "env-project")
+	assert.Equal(t, "metadata-project", ProjectID())
+
+	detectedProject = &projectDetector{}
+	assert.Equal(t, "env-project", ProjectID())
+}
+
+func TestTraceName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "abc", traceName("", "abc"))
+	assert.Equal(t, "projects/p/traces/abc", traceName("p", "abc"))
+}
+
+func TestTraceContextWithProject(t *testing.T) {
+	t.Parallel()
+
+	fields := TraceContextWithProject("other-project", "105445aa7843bc8bf206b120001000", "0", false)
+	assert.Equal(t, fields, []zap.Field{
+		zap.String(traceKey, "projects/other-project/traces/105445aa7843bc8bf206b120001000"),
+		zap.String(spanKey, "0"),
+		zap.Bool(traceSampledKey, false),
+	})
+}
+
+func TestTraceProject(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	c := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+	TraceProject("default-project")(c)
+
+	bare := []zapcore.Field{zap.String(traceKey, "abc")}
+	require.NoError(t, c.Write(zapcore.Entry{}, bare))
+	require.NoError(t, c.Write(zapcore.Entry{}, TraceContextWithProject("other-project", "def", "0", true)))
+	require.NoError(t, c.With([]zapcore.Field{zap.String(traceKey, "ghi")}).Write(zapcore.Entry{}, nil))
+
+	entries := logs.All()
+	assert.Equal(t, "projects/default-project/traces/abc", entries[0].ContextMap()[traceKey])
+	assert.Equal(t, "projects/other-project/traces/def", entries[1].ContextMap()[traceKey])
+	assert.Equal(t, "projects/default-project/traces/ghi", entries[2].ContextMap()[traceKey])
+	assert.Equal(t, "abc", bare[0].String)
+}
+
+func TestDetectTraceProject(t *testing.T) {
+	defer func(d *projectDetector) { detectedProject = d }(detectedProject)
+	detectedProject = &projectDetector{}
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte("my-project"))
+	}))
+	defer server.Close()
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(detectTraceProject()))
+	assert.Zero(t, atomic.LoadInt32(&requests), "the metadata server is queried on first use")
+
+	logger.Info("hello", zap.String(traceKey, "abc"))
+	assert.NotZero(t, atomic.LoadInt32(&requests))
+	assert.Equal(t, "projects/my-project/traces/abc", logs.All()[0].ContextMap()[traceKey])
+}
@@ -0,0 +1,80 @@
+package zapdriver
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// Transformer rewrites entries before they are written to the wrapped core,
+// for example to rename keys, enrich entries or scrub PII.
+//
+// Transformers receive the final fields of the entry, after the labels, source
+// location and error report fields have been added by the zapdriver core. The
+// fields added using `With()` are transformed once, when added, with an empty
+// entry. The fields must not be modified in place, as the slice may be shared
+// with the caller; return a new slice instead.
+type Transformer interface {
+	Apply(zapcore.Entry, []zapcore.Field) (zapcore.Entry, []zapcore.Field)
+}
+
+// TransformerFunc is an adapter to use a function as a Transformer.
+type TransformerFunc func(zapcore.Entry, []zapcore.Field) (zapcore.Entry, []zapcore.Field)
+
+// Apply calls f(ent, fields).
+func (f TransformerFunc) Apply(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	return f(ent, fields)
+}
+
+// zapdriver core option to apply `transformers` to every entry, in order,
+// before it is written to the wrapped core, and to the fields added using
+// `With()`
+func Transform(transformers ...Transformer) func(*core) {
+	return func(c *core) {
+		c.config.Transformers = append(c.config.Transformers, transformers...)
+	}
+}
+
+// transform applies all transformers to the entry.
+func (c *core) transform(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	for _, t := range c.config.Transformers {
+		ent, fields = t.Apply(ent, fields)
+	}
+
+	return ent, fields
+}
+
+// zapdriver core option to rename the keys of the fields of the entries (and of
+// the fields added using `With()`) found in `keys` (e.g. `err` to `error`, or
+// `latency_ms` to `latencyMs`), so field names can be standardized across
+// services without changing every call site. Fields are renamed before any
+// other processing; the special fields of Cloud Logging are never renamed
+func RenameFields(keys map[string]string) func(*core) {
+	return func(c *core) {
+		renames := make(map[string]string, len(keys))
+		for from, to := range keys {
+			renames[from] = to
+		}
+
+		c.config.Renames = renames
+	}
+}
+
+// renameFields renames the keys of the fields. The fields are copied before
+// being modified.
+func (c *core) renameFields(fields []zapcore.Field) []zapcore.Field {
+	copied := false
+	for i := range fields {
+		to, ok := c.config.Renames[fields[i].Key]
+		if !ok || isSpecialField(fields[i]) {
+			continue
+		}
+
+		if !copied {
+			fields = append([]zapcore.Field{}, fields...)
+			copied = true
+		}
+
+		fields[i].Key = to
+	}
+
+	return fields
+}
@@ -0,0 +1,103 @@
+package zapdriver
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTransform(t *testing.T) {
+	t.Parallel()
+
+	scrub := TransformerFunc(func(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+		out := make([]zapcore.Field, 0, len(fields))
+		for _, f := range fields {
+			if f.Key == "email" {
+				f = zap.String("email", "[redacted]")
+			}
+			out = append(out, f)
+		}
+
+		return ent, out
+	})
+
+	upper := TransformerFunc(func(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+		ent.Message = strings.ToUpper(ent.Message)
+		return ent, append(fields[:len(fields):len(fields)], zap.Int("count", len(fields)))
+	})
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	c := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+	Transform(scrub, upper)(c)
+
+	fields := []zapcore.Field{zap.String("email", "jane@example.com"), Label("one", "1")}
+	require.NoError(t, c.Write(zapcore.Entry{Message: "hello"}, fields))
+
+	entry := logs.All()[0]
+	assert.Equal(t, "HELLO", entry.Message)
+	assert.Equal(t, "[redacted]", entry.ContextMap()["email"])
+	assert.Contains(t, entry.ContextMap(), labelsKey)
+	// email and labels
+	assert.Equal(t, int64(2), entry.ContextMap()["count"])
+	assert.Equal(t, "jane@example.com", fields[0].String)
+}
+
+func TestTransform_With(t *testing.T) {
+	t.Parallel()
+
+	scrub := TransformerFunc(func(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+		out := make([]zapcore.Field, 0, len(fields))
+		for _, f := range fields {
+			if f.Key == "email" {
+				f = zap.String("email", "[redacted]")
+			}
+			out = append(out, f)
+		}
+
+		return ent, out
+	})
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(Transform(scrub)))
+
+	logger.With(zap.String("email", "jane@example.com")).Info("hello")
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "[redacted]", logs.All()[0].ContextMap()["email"])
+}
+
+func TestRenameFields(t *testing.T) {
+	t.Parallel()
+
+	keys := map[string]string{"err": "error", "latency_ms": "latencyMs", traceKey: "trace"}
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(debugcore, WrapCore(RenameFields(keys))).With(zap.Int("latency_ms", 12))
+	keys["count"] = "total"
+
+	fields := []zapcore.Field{
+		zap.NamedError("err", errors.New("boom")),
+		zap.Int("count", 1),
+		zap.String(traceKey, "projects/p/traces/1"),
+	}
+	logger.Info("hello", fields...)
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, map[string]interface{}{
+		"error":     "boom",
+		"count":     int64(1),
+		"latencyMs": int64(12),
+		traceKey:    "projects/p/traces/1",
+		labelsKey:   map[string]interface{}{},
+	}, logs.All()[0].ContextMap())
+	assert.Equal(t, "err", fields[0].Key, "fields are not modified")
+}
@@ -0,0 +1,157 @@
+package zapdriver
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// httpDirectionLabel is the label telling outbound requests apart from the
+	// requests handled by the service.
+	httpDirectionLabel = "http_direction"
+	httpOutbound       = "outbound"
+)
+
+// transport logs outbound requests, and propagates the trace context.
+type transport struct {
+	base   http.RoundTripper
+	logger *zap.Logger
+}
+
+// RoundTripper wraps `base` (or `http.DefaultTransport` when nil) to propagate
+// the trace context of the request context (as attached by `Middleware()`) to
+// outbound requests, using the `X-Cloud-Trace-Context` and `traceparent`
+// headers, with a new span ID for each request. Headers already set on the
+// request are left untouched.
+//
+// Each outbound request is logged with an `httpRequest` payload holding its
+// status and latency, and the `http_direction` label (see `NewOutgoingHTTP()`),
+// using `logger`, or the logger of the request context (see `FromContext()`)
+// when nil.
+func RoundTripper(base http.RoundTripper, logger *zap.Logger) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &transport{base: base, logger: logger}
+}
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if ids, ok := IDsFromContext(req.Context()); ok && ids.Trace != "" {
+		req = req.Clone(req.Context())
+		injectTraceHeaders(req.Header, ids)
+	}
+
+	start := time.Now()
+	res, err := t.base.RoundTrip(req)
+
+	logger := t.logger
+	if logger == nil {
+		logger = FromContext(req.Context())
+	}
+
+	fields := NewOutgoingHTTP(req, res, time.Since(start))
+	if err != nil {
+		logger.Error("Outbound request failed.", append(fields, zap.Error(err))...)
+		return res, err
+	}
+
+	logger.Info("Outbound request.", fields...)
+
+	return res, nil
+}
+
+// TraceHeaders returns the `X-Cloud-Trace-Context` and `traceparent` headers
+// propagating the trace of `ids` to an outbound call, with a new span ID. The
+// `traceparent` header is omitted when the trace ID is not a 32-character
+// hexadecimal string. No headers are returned when `ids` has no trace.
+func TraceHeaders(ids IDs) http.Header {
+	h := http.Header{}
+	if ids.Trace == "" {
+		return h
+	}
+
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	span := binary.BigEndian.Uint64(b[:])
+
+	options, flags := "0", "00"
+	if ids.Sampled {
+		options, flags = "1", "01"
+	}
+
+	// The span ID is a decimal number in this header.
+	h.Set(cloudTraceHeader, ids.Trace+"/"+strconv.FormatUint(span, 10)+";o="+options)
+
+	if len(ids.Trace) == 32 && isHex(ids.Trace) {
+		h.Set(traceparentHeader, "00-"+ids.Trace+"-"+SpanIDFromUint64(span)+"-"+flags)
+	}
+
+	return h
+}
+
+// injectTraceHeaders sets the trace context headers missing from `h`.
+func injectTraceHeaders(h http.Header, ids IDs) {
+	for k, v := range TraceHeaders(ids) {
+		if h.Get(k) == "" {
+			h[k] = v
+		}
+	}
+}
+
+// NewOutgoingHTTP returns the fields of an outbound request made by the
+// service: the `httpRequest` payload of the request and its response (nil when
+// the request failed), with its latency, and the `http_direction` label set to
+// `outbound`, so Logs Explorer queries can tell calls to dependencies apart
+// from the requests handled by the service, e.g.
+// `labels.http_direction="outbound"`. The bodies are not read, and the request
+// URL is written without its query string, and with its password redacted.
+func NewOutgoingHTTP(req *http.Request, res *http.Response, latency time.Duration) []zap.Field {
+	return []zap.Field{
+		HTTP(outboundPayload(req, res, latency)),
+		LabelsMap(map[string]string{httpDirectionLabel: httpOutbound}),
+	}
+}
+
+// outboundURL returns the URL of an outbound request, without the query string
+// and fragment, which often hold credentials or tokens, and with the password
+// redacted.
+func outboundURL(u *url.URL) string {
+	redacted := *u
+	redacted.RawQuery, redacted.ForceQuery = "", false
+	redacted.Fragment, redacted.RawFragment = "", ""
+
+	return redacted.Redacted()
+}
+
+// outboundPayload returns the HTTP payload of an outbound request. The bodies
+// are not read, so the sizes are only known when the content length is set.
+func outboundPayload(req *http.Request, res *http.Response, latency time.Duration) *HTTPPayload {
+	payload := &HTTPPayload{
+		RequestMethod: req.Method,
+		RequestURL:    outboundURL(req.URL),
+		UserAgent:     req.UserAgent(),
+		Latency:       FormatLatency(latency),
+	}
+
+	if req.ContentLength > 0 {
+		payload.RequestSize = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	if res != nil {
+		payload.Status = res.StatusCode
+		payload.Protocol = res.Proto
+		if res.ContentLength >= 0 {
+			payload.ResponseSize = strconv.FormatInt(res.ContentLength, 10)
+		}
+	}
+
+	return payload
+}
@@ -0,0 +1,137 @@
+package zapdriver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// maxLabelKeySize and maxLabelValueSize are the maximum sizes (in bytes) of
+	// label keys and values accepted by Cloud Logging.
+	maxLabelKeySize   = 512
+	maxLabelValueSize = 64 * 1024
+)
+
+var (
+	durationPattern = regexp.MustCompile(`^-?\d+(\.\d{1,9})?s$`)
+	methodPattern   = regexp.MustCompile(`^[A-Z]+$`)
+)
+
+// zapdriver core option to validate the special fields (`httpRequest`,
+// `sourceLocation`, `operation`, labels and trace context) of every entry
+// against the types and value constraints of Cloud Logging, to catch malformed
+// payloads during development. Invalid entries are still written, after a
+// warning is written to the error output of the core, or panic when `strict`
+// is set to true
+func ValidateEntries(strict bool) func(*core) {
+	return func(c *core) {
+		c.config.Validate = true
+		c.config.ValidateStrict = strict
+	}
+}
+
+// validate reports the invalid special fields of the entry.
+func (c *core) validate(ent zapcore.Entry, fields []zapcore.Field) {
+	err := validateFields(fields, c.encoderKeys())
+	if err == nil {
+		return
+	}
+
+	if c.config.ValidateStrict {
+		panic(fmt.Sprintf("zapdriver: invalid entry %q: %v", ent.Message, err))
+	}
+
+	c.warn("invalid entry %q: %v", ent.Message, err)
+}
+
+// validateFields returns the errors of all invalid special fields.
+func validateFields(fields []zapcore.Field, keys *zapcore.EncoderConfig) error {
+	var err error
+	for i := range fields {
+		err = multierr.Append(err, validateField(fields[i], keys))
+	}
+
+	return err
+}
+
+func validateField(field zapcore.Field, keys *zapcore.EncoderConfig) error {
+	if isReservedKeyCollision(field, keys) {
+		return fmt.Errorf("%s: collides with a reserved key", field.Key)
+	}
+
+	switch v := field.Interface.(type) {
+	case *HTTPPayload:
+		return validateHTTPPayload(v)
+	case *source:
+		if v.File == "" {
+			return fmt.Errorf("%s: missing file", sourceKey)
+		}
+		if line, err := strconv.ParseInt(v.Line, 10, 64); err != nil || line < 0 {
+			return fmt.Errorf("%s: invalid line %q", sourceKey, v.Line)
+		}
+	case *operation:
+		if v.ID == "" && v.Producer == "" {
+			return fmt.Errorf("%s: missing id and producer", operationKey)
+		}
+	case *LabelSet:
+		return validateLabels(v)
+	}
+
+	if field.Key == spanKey && field.String != "" && (len(field.String) != 16 || !isHex(field.String)) {
+		return fmt.Errorf("%s: invalid span ID %q, expected 16 hex characters", spanKey, field.String)
+	}
+
+	return nil
+}
+
+func validateHTTPPayload(req *HTTPPayload) error {
+	var err error
+	if req.RequestMethod != "" && !methodPattern.MatchString(req.RequestMethod) {
+		err = multierr.Append(err, fmt.Errorf("httpRequest: invalid requestMethod %q", req.RequestMethod))
+	}
+
+	if req.Status != 0 && (req.Status < 100 || req.Status > 599) {
+		err = multierr.Append(err, fmt.Errorf("httpRequest: invalid status %d", req.Status))
+	}
+
+	if req.Latency != "" && !durationPattern.MatchString(req.Latency) {
+		err = multierr.Append(err, fmt.Errorf("httpRequest: invalid latency %q, expected a duration such as \"3.5s\"", req.Latency))
+	}
+
+	sizes := map[string]string{
+		"requestSize":    req.RequestSize,
+		"responseSize":   req.ResponseSize,
+		"cacheFillBytes": req.CacheFillBytes,
+	}
+	for _, name := range []string{"requestSize", "responseSize", "cacheFillBytes"} {
+		if v := sizes[name]; v != "" {
+			if n, perr := strconv.ParseInt(v, 10, 64); perr != nil || n < 0 {
+				err = multierr.Append(err, fmt.Errorf("httpRequest: invalid %s %q", name, v))
+			}
+		}
+	}
+
+	return err
+}
+
+func validateLabels(lbls *LabelSet) error {
+	lbls.mutex.RLock()
+	defer lbls.mutex.RUnlock()
+
+	var err error
+	for k, v := range lbls.store {
+		if k == "" || len(k) > maxLabelKeySize {
+			err = multierr.Append(err, fmt.Errorf("%s: invalid key %q", labelsKey, k))
+		}
+
+		if len(v) > maxLabelValueSize {
+			err = multierr.Append(err, fmt.Errorf("%s: value of %q exceeds %d bytes", labelsKey, k, maxLabelValueSize))
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,75 @@
+package zapdriver
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestValidateFields(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		field zapcore.Field
+		err   string
+	}{
+		"valid http":      {HTTP(&HTTPPayload{RequestMethod: "GET", Status: 200, Latency: "0.5s", RequestSize: "12"}), ""},
+		"http method":     {HTTP(&HTTPPayload{RequestMethod: "get"}), `invalid requestMethod "get"`},
+		"http status":     {HTTP(&HTTPPayload{Status: 1000}), "invalid status 1000"},
+		"http latency":    {HTTP(&HTTPPayload{Latency: "500ms"}), `invalid latency "500ms"`},
+		"http size":       {HTTP(&HTTPPayload{ResponseSize: "big"}), `invalid responseSize "big"`},
+		"valid source":    {SourceLocation(0, "main.go", 12, true), ""},
+		"source file":     {SourceLocation(0, "", 12, true), "missing file"},
+		"valid operation": {OperationStart("id", "producer"), ""},
+		"operation":       {OperationStart("", ""), "missing id and producer"},
+		"valid labels":    {Labels(Label("one", "1")), ""},
+		"labels key":      {LabelsMap(map[string]string{"": "1"}), `invalid key ""`},
+		"labels value":    {LabelsMap(map[string]string{"one": strings.Repeat("x", maxLabelValueSize+1)}), "exceeds"},
+		"valid span":      {zap.String(spanKey, "000000000000007b"), ""},
+		"span":            {zap.String(spanKey, "123"), `invalid span ID "123"`},
+		"reserved":        {zap.String(sourceKey, "main.go"), "collides with a reserved key"},
+		"regular":         {zap.String("hello", "world"), ""},
+	}
+
+	for name, tt := range tests {
+		err := validateFields([]zapcore.Field{tt.field}, &encoderConfig)
+		if tt.err == "" {
+			assert.NoError(t, err, name)
+			continue
+		}
+
+		require.Error(t, err, name)
+		assert.Contains(t, err.Error(), tt.err, name)
+	}
+}
+
+func TestValidateEntries(t *testing.T) {
+	t.Parallel()
+
+	debugcore, logs := observer.New(zapcore.DebugLevel)
+	output := &bytes.Buffer{}
+	c := &core{
+		Core:       debugcore,
+		permLabels: NewLabelSet(),
+	}
+	ValidateEntries(false)(c)
+	ErrorOutput(zapcore.AddSync(output))(c)
+
+	require.NoError(t, c.Write(zapcore.Entry{Message: "ok"}, []zapcore.Field{HTTP(&HTTPPayload{Status: 200})}))
+	assert.Empty(t, output.String())
+
+	require.NoError(t, c.Write(zapcore.Entry{Message: "bad"}, []zapcore.Field{HTTP(&HTTPPayload{Status: 42})}))
+	assert.Contains(t, output.String(), `invalid entry "bad": httpRequest: invalid status 42`)
+	assert.Equal(t, 2, logs.Len())
+
+	ValidateEntries(true)(c)
+	assert.Panics(t, func() {
+		_ = c.Write(zapcore.Entry{Message: "bad"}, []zapcore.Field{HTTP(&HTTPPayload{Status: 42})})
+	})
+}